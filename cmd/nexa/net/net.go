@@ -9,6 +9,7 @@ func Cmd(ctx *ctx.Ctx) []*cobra.Command {
 	var cmds []*cobra.Command
 	cmds = append(cmds, newCmdHttpStat(ctx))
 	cmds = append(cmds, newCmdHttpBinStat(ctx))
+	cmds = append(cmds, newSynScan(ctx))
 
 	return cmds
 }
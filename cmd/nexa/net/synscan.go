@@ -2,30 +2,32 @@ package net
 
 import (
 	"github.com/nexa/pkg/ctx"
-	"github.com/nexa/pkg/net/httpstat"
+	"github.com/nexa/pkg/net/synscan"
 	"github.com/spf13/cobra"
 )
 
 // newSynScan 使用syn扫描哪些端口可用
 func newSynScan(ctx *ctx.Ctx) *cobra.Command {
-	httpStat := httpstat.NewHttpStat(ctx)
+	scanner := synscan.New(ctx, ctx.Logger())
 
 	cmd := &cobra.Command{
-		Use:     "httpstat",
-		Short:   "nexa httpstat url",
-		Long:    `nexa httpstat url -X GET.`,
-		Example: `nexa httpstat www.google.com -X GET -H "Accept: application/json, text/plain, */*"`,
+		Use:   "synscan",
+		Short: "nexa synscan <host|cidr>",
+		Long: `nexa synscan <host|cidr> -p 1-1024,8080.
+Sends raw TCP SYN probes (requires root or CAP_NET_RAW) and falls back to connect() scanning otherwise.`,
+		Example: `nexa synscan 10.0.0.1 -p 1-1024,8080
+  nexa synscan 192.168.1.0/24 -p 22,443 --rate 500 --retries 2`,
+		Args: cobra.MaximumNArgs(1),
 		// stop printing usage when the command errors
 		SilenceUsage: true,
 	}
-	cmd.Run = func(cmd *cobra.Command, args []string) {
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			cmd.Help()
-			return
+			return cmd.Help()
 		}
-		httpStat.RunHttpStat(args[0])
+		return scanner.Run(args[0])
 	}
 
-	httpStat.ParseFlags(cmd)
+	scanner.ParseFlags(cmd)
 	return cmd
 }
@@ -0,0 +1,111 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/nexa/pkg/ctx"
+	"github.com/nexa/pkg/node/check"
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"github.com/spf13/cobra"
+)
+
+func checkCmd(cctx *ctx.Ctx, reg *nodecollector.Registry, rf *nodeRenderFlags, cf *nodeCollectorFlags, collectOnly *[]string, pf *nodePostFilterFlags) *cobra.Command {
+	var (
+		rulesFile    string
+		printDefault bool
+		rateInterval time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "evaluate threshold rules against fresh metrics and exit 0/1/2/3 (OK/WARNING/CRITICAL/UNKNOWN)",
+		Long: "Collect node metrics and evaluate threshold rules written in a PromQL subset, e.g.\n" +
+			"  node_filesystem_avail_bytes / node_filesystem_size_bytes < 0.1\n" +
+			"Every series a rule returns is a finding. The exit code follows the Nagios plugin convention.\n" +
+			"Without --rules the built-in pack is used (see --print-default-rules). Rules using rate()\n" +
+			"collect twice, --rate-interval apart.",
+		Example:      "nexa node check\n  nexa node check --rules rules.yaml -o json\n  nexa node check --print-default-rules > rules.yaml",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Monitoring plugins read the exit code: every failure must be UNKNOWN, never 0.
+			unknown := func(err error) {
+				fmt.Printf("NODE %s - %v\n", check.StatusUnknown, err)
+				os.Exit(int(check.StatusUnknown))
+			}
+			if printDefault {
+				if _, err := os.Stdout.Write(check.DefaultRulesYAML()); err != nil {
+					unknown(err)
+				}
+				return nil
+			}
+			rep, err := runCheck(cctx, reg, rf, cf, *collectOnly, *pf, rulesFile, rateInterval)
+			if err != nil {
+				unknown(err)
+			}
+			if rf.output == string(render.FormatJSON) {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(rep); err != nil {
+					unknown(err)
+				}
+			} else if err := render.PrintCheck(os.Stdout, rep); err != nil {
+				unknown(err)
+			}
+			os.Exit(int(rep.Status))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&rulesFile, "rules", "", "YAML rule file replacing the built-in pack")
+	cmd.Flags().BoolVar(&printDefault, "print-default-rules", false, "print the built-in rule pack and exit")
+	cmd.Flags().DurationVar(&rateInterval, "rate-interval", time.Second, "time between the two collections needed by rate(), irate() and increase()")
+	return cmd
+}
+
+func runCheck(cctx *ctx.Ctx, reg *nodecollector.Registry, rf *nodeRenderFlags, cf *nodeCollectorFlags, collectOnly []string, pf nodePostFilterFlags, rulesFile string, rateInterval time.Duration) (*check.Report, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("nexa node collectors are currently implemented for linux; current GOOS=%s", runtime.GOOS)
+	}
+	if rf.output != string(render.FormatTable) && rf.output != string(render.FormatJSON) {
+		return nil, fmt.Errorf("check supports -o table or -o json")
+	}
+	rules := check.DefaultRules()
+	if rulesFile != "" {
+		var err error
+		if rules, err = check.LoadRules(rulesFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only run what the rules read: default collectors that are unavailable here would otherwise
+	// turn every run UNKNOWN.
+	names := collectOnly
+	if len(names) == 0 {
+		for _, name := range check.Collectors(rules) {
+			if reg.Has(name) && reg.Status(name).Implemented {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var prev *nodecollector.Snapshot
+	if check.NeedsRate(rules) {
+		at := time.Now()
+		families, _, _ := collectNamed(cctx.Context(), reg, names, pf, cf.collect)
+		prev = nodecollector.NewSnapshot("", at, names, families)
+		select {
+		case <-time.After(rateInterval):
+		case <-cctx.Context().Done():
+			return nil, cctx.Context().Err()
+		}
+	}
+	at := time.Now()
+	families, errs, _ := collectNamed(cctx.Context(), reg, names, pf, cf.collect)
+
+	rep := check.Evaluate(rules, check.NewDataset(at, families, prev))
+	rep.SetCollectErrors(errs)
+	return rep, nil
+}
@@ -1,29 +1,72 @@
 package node
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/nexa/pkg/ctx"
 	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/exporter"
 	"github.com/nexa/pkg/node/render"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 type nodeRenderFlags struct {
 	samples bool
 	limit   int
 	human   bool
+	watch   time.Duration
+	metric  string
+	labels  []string
+	output  string
+}
+
+// format validates -o; watch frames are tables only.
+func (rf *nodeRenderFlags) format() (render.Format, error) {
+	f, err := render.ParseFormat(rf.output)
+	if err != nil {
+		return "", err
+	}
+	if rf.watch > 0 && f != render.FormatTable {
+		return "", fmt.Errorf("--watch only supports -o table")
+	}
+	return f, nil
+}
+
+func (rf *nodeRenderFlags) options() render.Options {
+	return render.Options{
+		ShowSamples: rf.samples,
+		Limit:       rf.limit,
+		Humanize:    rf.human,
+		MetricRegex: rf.metric,
+		LabelEquals: rf.labels,
+	}
 }
 
 type nodeCollectorFlags struct {
+	backend         string
+	timeout         string
+	parallelism     int
+	collect         nodecollector.CollectOptions
+	textfileDirs    []string
+	processGroups   string
+	cgroupMaxDepth  int
+	cgroupInclude   string
+	cgroupExclude   string
+	paths           nodecollector.Paths
 	disableDefaults bool
 	forceEnable     map[string]*bool
 	forceDisable    map[string]*bool
+	pluginDir       string
 }
 
 type nodePostFilterFlags struct {
@@ -41,6 +84,16 @@ type nodePostFilterFlags struct {
 
 func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 	reg := nodecollector.NewDefaultRegistry(cctx)
+	// Plugins need their --collector.<name> flags before cobra parses os.Args, so the directory is
+	// taken from the raw arguments, and only scanned when `nexa node` is what runs.
+	pluginDir, pluginDirSet := pluginDirFromArgs(os.Args[1:])
+	if !pluginDirSet {
+		pluginDir = nodecollector.DefaultPluginDir
+	}
+	var pluginErr error
+	if len(os.Args) > 1 && os.Args[1] == "node" {
+		pluginErr = registerPlugins(reg, pluginDir, pluginDirSet)
+	}
 
 	var rf nodeRenderFlags
 	var collectOnly []string
@@ -56,6 +109,40 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 		Short:        "node metrics collectors (node_exporter-like)",
 		Long:         "Collect node (machine) metrics with pluggable collectors and render as tables.",
 		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if pluginErr != nil {
+				return pluginErr
+			}
+			if cf.pluginDir != pluginDir {
+				return fmt.Errorf("%s: %q was not found when scanning the arguments for plugins", pluginDirFlag, cf.pluginDir)
+			}
+			if err := applyPaths(cf.paths); err != nil {
+				return err
+			}
+			def, overrides, err := nodecollector.ParseBackendSpec(cf.backend)
+			if err != nil {
+				return err
+			}
+			if err := reg.SetBackend(def, overrides); err != nil {
+				return err
+			}
+			timeout, timeouts, err := nodecollector.ParseTimeoutSpec(cf.timeout)
+			if err != nil {
+				return err
+			}
+			cf.collect = nodecollector.CollectOptions{Parallelism: cf.parallelism, Timeout: timeout, Timeouts: timeouts}
+			if cf.processGroups != "" {
+				groups, err := nodecollector.LoadProcessGroupsConfig(cf.processGroups)
+				if err != nil {
+					return err
+				}
+				nodecollector.SetProcessGroupsConfig(groups)
+			}
+			if err := applyCgroupStatsOptions(cf); err != nil {
+				return err
+			}
+			return nodecollector.ConfigureUpstream(upstreamCollectors(reg, computeEnabledSet(reg, cf), collectOnly), cf.textfileDirs)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if runtime.GOOS != "linux" {
 				return fmt.Errorf("nexa node collectors are currently implemented for linux; current GOOS=%s", runtime.GOOS)
@@ -72,21 +159,31 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 				if _, ok := enabledSet[name]; !ok {
 					return fmt.Errorf("collector %s is disabled by flags (try enabling with --collector.%s)", name, name)
 				}
-				families, err := reg.Collect(name)
+				format, err := rf.format()
 				if err != nil {
 					return err
 				}
-				families, err = applyPostFilters(families, pf)
+				collect := func() ([]nodecollector.MetricFamily, error) {
+					res := reg.CollectMany(cctx.Context(), []string{name}, cf.collect)[0]
+					if res.Err != nil {
+						return nil, res.Err
+					}
+					return applyPostFilters(res.Families, pf)
+				}
+				if rf.watch > 0 {
+					return runWatch(cctx.Context(), os.Stdout, "nexa node "+name, rf.watch, collect, rf.options())
+				}
+				families, err := collect()
 				if err != nil {
 					return err
 				}
 				sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
-				return render.PrintMetricFamilies(os.Stdout, families, render.Options{ShowSamples: rf.samples, Limit: rf.limit, Humanize: rf.human})
+				return render.Write(os.Stdout, families, format, rf.options())
 			}
 
 			// `nexa node --collect ...` or `nexa node --exclude ...`
 			if len(collectOnly) > 0 || len(exclude) > 0 {
-				return allCmd(reg, &rf, &collectOnly, &exclude, enabledSet, pf).RunE(cmd, args)
+				return allCmd(cctx, reg, &rf, &cf, &collectOnly, &exclude, &pf).RunE(cmd, args)
 			}
 
 			return cmd.Help()
@@ -96,8 +193,26 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 	cmd.PersistentFlags().BoolVar(&rf.samples, "samples", false, "print per-sample time series rows")
 	cmd.PersistentFlags().IntVar(&rf.limit, "limit", 2000, "max output rows in --samples mode (protects console)")
 	cmd.PersistentFlags().BoolVar(&rf.human, "human-readable", true, "human readable output (bytes, seconds, big integers)")
+	cmd.PersistentFlags().StringVarP(&rf.output, "output", "o", string(render.FormatTable), "output format: "+strings.Join(render.FormatNames(), "|"))
+	cmd.PersistentFlags().StringVar(&rf.metric, "metric", "", "only show metric families whose name matches this regexp (anchored), e.g. 'node_filesystem_.*'")
+	cmd.PersistentFlags().StringArrayVar(&rf.labels, "label", nil, "only show series matching this label selector: k=v, k!=v, k=~re or k!~re (repeatable)")
+	cmd.PersistentFlags().DurationVar(&rf.watch, "watch", 0, "re-collect at this interval and show per-second rates for counters (e.g. --watch 2s)")
 	cmd.PersistentFlags().StringArrayVar(&collectOnly, "collect", nil, "collect only these collectors (repeatable; mutual exclusive with --exclude)")
 	cmd.PersistentFlags().StringArrayVar(&exclude, "exclude", nil, "exclude these collectors (repeatable; mutual exclusive with --collect)")
+	cmd.PersistentFlags().StringVar(&cf.backend, "collector.backend", string(nodecollector.BackendAuto), "collector implementation: native|upstream|auto, optionally with per-collector overrides, e.g. auto,diskstats=upstream")
+	cmd.PersistentFlags().StringVar(&cf.timeout, "collector.timeout", nodecollector.DefaultCollectTimeout.String(), "deadline of each collector run (0 disables), optionally with per-collector overrides, e.g. 10s,filesystem=30s")
+	cmd.PersistentFlags().IntVar(&cf.parallelism, "collector.parallelism", 8, "maximum number of collectors running at once (0 runs all at once)")
+	defaults := nodecollector.DefaultPaths()
+	cmd.PersistentFlags().StringVar(&cf.paths.Procfs, "path.procfs", defaults.Procfs, "procfs mountpoint (e.g. /host/proc when the host is mounted into a container)")
+	cmd.PersistentFlags().StringVar(&cf.paths.Sysfs, "path.sysfs", defaults.Sysfs, "sysfs mountpoint")
+	cmd.PersistentFlags().StringVar(&cf.paths.Rootfs, "path.rootfs", defaults.Rootfs, "rootfs mountpoint; filesystem mount points and os-release are resolved below it")
+	cmd.PersistentFlags().StringVar(&cf.paths.UdevData, "path.udev.data", defaults.UdevData, "udev data path")
+	cmd.PersistentFlags().StringArrayVar(&cf.textfileDirs, "collector.textfile.directory", nil, "directory to read *.prom text files from, supports glob matching (repeatable)")
+	cmd.PersistentFlags().StringVar(&cf.processGroups, "collector.processes_grouped.config", "", "YAML file with the process groups of the processes_grouped collector (default: one group per process name)")
+	cmd.PersistentFlags().IntVar(&cf.cgroupMaxDepth, "collector.cgroup_stats.max-depth", nodecollector.DefaultCgroupStatsOptions().MaxDepth, "deepest cgroup level reported below the root (0 for no limit)")
+	cmd.PersistentFlags().StringVar(&cf.cgroupInclude, "collector.cgroup_stats.paths-include", "", "regexp of cgroup paths to report, e.g. '^/kubepods' (mutually exclusive with exclude)")
+	cmd.PersistentFlags().StringVar(&cf.cgroupExclude, "collector.cgroup_stats.paths-exclude", "", "regexp of cgroup paths not to report (mutually exclusive with include)")
+	cmd.PersistentFlags().StringVar(&cf.pluginDir, pluginDirFlag, nodecollector.DefaultPluginDir, "directory of external collector executables that print Prometheus text or JSON metric families, each with an optional <name>.yaml config (empty disables plugins)")
 	cmd.PersistentFlags().BoolVar(&cf.disableDefaults, "collector.disable-defaults", false, "disable all collectors by default (enable explicitly with --collector.<name>)")
 
 	// Subset of upstream include/exclude flags applied as post-filters on gathered metrics.
@@ -121,7 +236,17 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 	}
 
 	cmd.AddCommand(listCmd(reg))
-	cmd.AddCommand(allCmd(reg, &rf, &collectOnly, &exclude, nil, pf))
+	cmd.AddCommand(allCmd(cctx, reg, &rf, &cf, &collectOnly, &exclude, &pf))
+	cmd.AddCommand(serveCmd(cctx, reg, &rf, &cf, &pf))
+	cmd.AddCommand(snapshotCmd(cctx, reg, &rf, &cf, &collectOnly, &exclude, &pf))
+	cmd.AddCommand(diffCmd(&rf))
+	cmd.AddCommand(checkCmd(cctx, reg, &rf, &cf, &collectOnly, &pf))
+	cmd.AddCommand(pushCmd(cctx, reg, &rf, &cf, &collectOnly, &exclude, &pf))
+	cmd.AddCommand(textfileCmd(&rf, &cf))
+	cmd.AddCommand(topCmd(cctx, reg, &cf, &collectOnly, &pf))
+	cmd.AddCommand(recordCmd(cctx, reg, &rf, &cf, &collectOnly, &exclude, &pf))
+	cmd.AddCommand(replayCmd(&rf))
+	cmd.AddCommand(socketsCmd(cctx, &rf))
 	// NOTE: Cobra subcommand names must be literal; we keep the collector runner on root args.
 
 	return []*cobra.Command{cmd}
@@ -144,7 +269,63 @@ func listCmd(reg *nodecollector.Registry) *cobra.Command {
 	}
 }
 
-func allCmd(reg *nodecollector.Registry, rf *nodeRenderFlags, collectOnly *[]string, exclude *[]string, enabledSet map[string]struct{}, pf nodePostFilterFlags) *cobra.Command {
+func serveCmd(cctx *ctx.Ctx, reg *nodecollector.Registry, rf *nodeRenderFlags, cf *nodeCollectorFlags, pf *nodePostFilterFlags) *cobra.Command {
+	var (
+		listen      string
+		metricsPath string
+		maxRequests int
+	)
+	cmd := &cobra.Command{
+		Use:          "serve",
+		Short:        "expose enabled collectors as a Prometheus /metrics endpoint",
+		Example:      "nexa node serve --listen :9100\n  nexa node serve --listen 127.0.0.1:9100 --collector.disable-defaults --collector.cpu --collector.meminfo",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runtime.GOOS != "linux" {
+				return fmt.Errorf("nexa node collectors are currently implemented for linux; current GOOS=%s", runtime.GOOS)
+			}
+
+			enabledSet := computeEnabledSet(reg, *cf)
+			names := make([]string, 0, len(enabledSet))
+			for name := range enabledSet {
+				if reg.Status(name).Implemented {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			if len(names) == 0 {
+				return fmt.Errorf("no collectors enabled")
+			}
+
+			filter, err := nodecollector.ParseFilter(rf.metric, rf.labels)
+			if err != nil {
+				return err
+			}
+			h := exporter.Handler(reg, exporter.Options{
+				Names:               names,
+				MaxRequestsInFlight: maxRequests,
+				Collect:             cf.collect,
+				Transform: func(families []nodecollector.MetricFamily) ([]nodecollector.MetricFamily, error) {
+					families, err := applyPostFilters(families, *pf)
+					if err != nil {
+						return nil, err
+					}
+					return filter.Apply(families), nil
+				},
+			})
+
+			cctx.Logger().Info("serving node metrics", zap.String("listen", listen), zap.String("path", metricsPath), zap.Strings("collectors", names))
+			fmt.Fprintf(os.Stdout, "Serving %d collectors on http://%s%s\n", len(names), listen, metricsPath)
+			return exporter.Serve(cctx.Context(), listen, metricsPath, h)
+		},
+	}
+	cmd.Flags().StringVar(&listen, "listen", ":9100", "address to listen on for HTTP requests")
+	cmd.Flags().StringVar(&metricsPath, "telemetry-path", "/metrics", "path under which to expose metrics")
+	cmd.Flags().IntVar(&maxRequests, "max-requests", 40, "maximum number of parallel scrape requests (0 disables the limit)")
+	return cmd
+}
+
+func allCmd(cctx *ctx.Ctx, reg *nodecollector.Registry, rf *nodeRenderFlags, cf *nodeCollectorFlags, collectOnly *[]string, exclude *[]string, pf *nodePostFilterFlags) *cobra.Command {
 	return &cobra.Command{
 		Use:          "all",
 		Short:        "run default collectors (implemented only)",
@@ -158,73 +339,54 @@ func allCmd(reg *nodecollector.Registry, rf *nodeRenderFlags, collectOnly *[]str
 				return fmt.Errorf("combined --collect and --exclude are not allowed")
 			}
 
-			var families []nodecollector.MetricFamily
-			var notEnabled []string
-			var errs []string
+			format, err := rf.format()
+			if err != nil {
+				return err
+			}
+			// Keep stdout parseable for machine-readable formats.
+			notes := io.Writer(os.Stdout)
+			if format != render.FormatTable {
+				notes = os.Stderr
+			}
 
-			selected := reg.DefaultCollectorsLinuxEnabledByDefault()
-			if enabledSet != nil {
-				tmp := make([]string, 0, len(selected))
-				for _, n := range selected {
-					if _, ok := enabledSet[n]; ok {
-						tmp = append(tmp, n)
-					}
-				}
-				selected = tmp
-			}
-			if len(*collectOnly) > 0 {
-				selected = append([]string(nil), (*collectOnly)...)
-			} else if len(*exclude) > 0 {
-				exset := map[string]struct{}{}
-				for _, n := range *exclude {
-					exset[n] = struct{}{}
-				}
-				tmp := make([]string, 0, len(selected))
-				for _, n := range selected {
-					if _, ok := exset[n]; ok {
-						continue
-					}
-					tmp = append(tmp, n)
-				}
-				selected = tmp
+			selected, notEnabled := selectCollectors(reg, *collectOnly, *exclude, computeEnabledSet(reg, *cf))
+			collect := func() ([]nodecollector.MetricFamily, []string, []nodecollector.CollectResult) {
+				return collectNamed(cctx.Context(), reg, selected, *pf, cf.collect)
 			}
 
-			for _, name := range selected {
-				if enabledSet != nil {
-					if _, ok := enabledSet[name]; !ok {
-						notEnabled = append(notEnabled, name)
-						continue
+			if rf.watch > 0 {
+				return runWatch(cctx.Context(), os.Stdout, "nexa node all", rf.watch, func() ([]nodecollector.MetricFamily, error) {
+					families, errs, _ := collect()
+					if len(errs) > 0 {
+						return families, errors.New(strings.Join(errs, "; "))
 					}
-				}
-				f, err := reg.Collect(name)
-				if err != nil {
-					errs = append(errs, fmt.Sprintf("%s: %v", name, err))
-					continue
-				}
-				ff, ferr := applyPostFilters(f, pf)
-				if ferr != nil {
-					errs = append(errs, fmt.Sprintf("%s(filter): %v", name, ferr))
-					continue
-				}
-				families = append(families, ff...)
+					return families, nil
+				}, rf.options())
 			}
 
+			families, errs, results := collect()
 			sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
 
-			if err := render.PrintMetricFamilies(os.Stdout, families, render.Options{ShowSamples: rf.samples, Limit: rf.limit, Humanize: rf.human}); err != nil {
+			if err := render.Write(os.Stdout, families, format, rf.options()); err != nil {
 				return err
 			}
 
+			if len(results) > 0 {
+				fmt.Fprintln(notes)
+				if err := render.PrintCollectTimings(notes, results); err != nil {
+					return err
+				}
+			}
 			if len(notEnabled) > 0 {
 				sort.Strings(notEnabled)
-				fmt.Fprintln(os.Stdout)
-				fmt.Fprintf(os.Stdout, "Not enabled collectors: %s\n", strings.Join(notEnabled, ", "))
+				fmt.Fprintln(notes)
+				fmt.Fprintf(notes, "Not enabled collectors: %s\n", strings.Join(notEnabled, ", "))
 			}
 			if len(errs) > 0 {
-				fmt.Fprintln(os.Stdout)
-				fmt.Fprintln(os.Stdout, "Errors:")
+				fmt.Fprintln(notes)
+				fmt.Fprintln(notes, "Errors:")
 				for _, e := range errs {
-					fmt.Fprintf(os.Stdout, "- %s\n", e)
+					fmt.Fprintf(notes, "- %s\n", e)
 				}
 			}
 
@@ -233,6 +395,140 @@ func allCmd(reg *nodecollector.Registry, rf *nodeRenderFlags, collectOnly *[]str
 	}
 }
 
+// selectCollectors resolves which collectors `all` (and snapshot) run: the enabled and implemented
+// defaults, narrowed by --collect/--exclude. Explicitly requested collectors that are disabled by flags are
+// returned as notEnabled.
+func selectCollectors(reg *nodecollector.Registry, collectOnly, exclude []string, enabledSet map[string]struct{}) (selected, notEnabled []string) {
+	selected = reg.DefaultCollectorsLinuxEnabledByDefault()
+	if enabledSet != nil {
+		tmp := make([]string, 0, len(selected))
+		for _, n := range selected {
+			if _, ok := enabledSet[n]; ok {
+				tmp = append(tmp, n)
+			}
+		}
+		selected = tmp
+	}
+	if len(collectOnly) > 0 {
+		selected = append([]string(nil), collectOnly...)
+	} else if len(exclude) > 0 {
+		exset := map[string]struct{}{}
+		for _, n := range exclude {
+			exset[n] = struct{}{}
+		}
+		tmp := make([]string, 0, len(selected))
+		for _, n := range selected {
+			if _, ok := exset[n]; ok {
+				continue
+			}
+			tmp = append(tmp, n)
+		}
+		selected = tmp
+	}
+	if enabledSet != nil {
+		tmp := make([]string, 0, len(selected))
+		for _, name := range selected {
+			if _, ok := enabledSet[name]; !ok {
+				notEnabled = append(notEnabled, name)
+				continue
+			}
+			tmp = append(tmp, name)
+		}
+		selected = tmp
+	}
+	if len(collectOnly) == 0 {
+		// e.g. --collector.backend=native leaves collectors without a native implementation unavailable.
+		tmp := make([]string, 0, len(selected))
+		for _, name := range selected {
+			if reg.Status(name).Implemented {
+				tmp = append(tmp, name)
+			}
+		}
+		selected = tmp
+	}
+	return selected, notEnabled
+}
+
+// collectNamed runs the named collectors concurrently and applies the post-filters.
+// Failing or timed-out collectors are reported in errs and do not abort the rest.
+func collectNamed(ctx context.Context, reg *nodecollector.Registry, names []string, pf nodePostFilterFlags, opt nodecollector.CollectOptions) (families []nodecollector.MetricFamily, errs []string, results []nodecollector.CollectResult) {
+	results = reg.CollectMany(ctx, names, opt)
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", r.Name, r.Err))
+			continue
+		}
+		ff, ferr := applyPostFilters(r.Families, pf)
+		if ferr != nil {
+			errs = append(errs, fmt.Sprintf("%s(filter): %v", r.Name, ferr))
+			continue
+		}
+		families = append(families, ff...)
+	}
+	return families, errs, results
+}
+
+// applyPaths checks the --path.* roots and hands them to native and upstream collectors. The udev
+// data path is optional: containers often lack it and diskstats then omits the udev labels.
+func applyPaths(p nodecollector.Paths) error {
+	for _, f := range []struct{ flag, dir string }{
+		{"path.procfs", p.Procfs},
+		{"path.sysfs", p.Sysfs},
+		{"path.rootfs", p.Rootfs},
+	} {
+		st, err := os.Stat(f.dir)
+		if err != nil {
+			return fmt.Errorf("--%s: %w", f.flag, err)
+		}
+		if !st.IsDir() {
+			return fmt.Errorf("--%s: %s is not a directory", f.flag, f.dir)
+		}
+	}
+	nodecollector.SetPaths(p)
+	return nil
+}
+
+// applyCgroupStatsOptions hands the --collector.cgroup_stats.* flags to the collector.
+func applyCgroupStatsOptions(cf nodeCollectorFlags) error {
+	if cf.cgroupInclude != "" && cf.cgroupExclude != "" {
+		return fmt.Errorf("collector.cgroup_stats.paths-include and paths-exclude are mutually exclusive")
+	}
+	opt := nodecollector.CgroupStatsOptions{MaxDepth: cf.cgroupMaxDepth}
+	var err error
+	if cf.cgroupInclude != "" {
+		if opt.Include, err = regexp.Compile(cf.cgroupInclude); err != nil {
+			return fmt.Errorf("collector.cgroup_stats.paths-include: %w", err)
+		}
+	}
+	if cf.cgroupExclude != "" {
+		if opt.Exclude, err = regexp.Compile(cf.cgroupExclude); err != nil {
+			return fmt.Errorf("collector.cgroup_stats.paths-exclude: %w", err)
+		}
+	}
+	nodecollector.SetCgroupStatsOptions(opt)
+	return nil
+}
+
+// upstreamCollectors lists the enabled (or explicitly requested) collectors that run on the upstream
+// backend; only those are instantiated in node_exporter.
+func upstreamCollectors(reg *nodecollector.Registry, enabledSet map[string]struct{}, collectOnly []string) []string {
+	want := map[string]struct{}{}
+	for name := range enabledSet {
+		want[name] = struct{}{}
+	}
+	for _, name := range collectOnly {
+		want[name] = struct{}{}
+	}
+	out := make([]string, 0, len(want))
+	for name := range want {
+		if reg.Status(name).Backend == nodecollector.BackendUpstream {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
 func computeEnabledSet(reg *nodecollector.Registry, cf nodeCollectorFlags) map[string]struct{} {
 	enabled := map[string]struct{}{}
 	if !cf.disableDefaults {
@@ -327,11 +623,11 @@ func applyPostFilters(families []nodecollector.MetricFamily, pf nodePostFilterFl
 		}
 	}
 
-	filterSample := func(f nodecollector.MetricFamily, s nodecollector.Sample) bool {
+	keep := func(f nodecollector.MetricFamily, labels []nodecollector.Label) bool {
 		switch {
 		case strings.HasPrefix(f.Name, "node_filesystem_"):
-			mp := labelValue(s.Labels, "mountpoint")
-			fs := labelValue(s.Labels, "fstype")
+			mp := labelValue(labels, "mountpoint")
+			fs := labelValue(labels, "fstype")
 			if fsMountInc != nil && !fsMountInc.MatchString(mp) {
 				return false
 			}
@@ -345,7 +641,7 @@ func applyPostFilters(families []nodecollector.MetricFamily, pf nodePostFilterFl
 				return false
 			}
 		case strings.HasPrefix(f.Name, "node_network_"):
-			dev := labelValue(s.Labels, "device")
+			dev := labelValue(labels, "device")
 			if netInc != nil && !netInc.MatchString(dev) {
 				return false
 			}
@@ -353,7 +649,7 @@ func applyPostFilters(families []nodecollector.MetricFamily, pf nodePostFilterFl
 				return false
 			}
 		case strings.HasPrefix(f.Name, "node_disk_"):
-			dev := labelValue(s.Labels, "device")
+			dev := labelValue(labels, "device")
 			if diskInc != nil && !diskInc.MatchString(dev) {
 				return false
 			}
@@ -367,15 +663,22 @@ func applyPostFilters(families []nodecollector.MetricFamily, pf nodePostFilterFl
 	out := make([]nodecollector.MetricFamily, 0, len(families))
 	for _, f := range families {
 		nf := f
-		if len(nf.Samples) > 0 {
-			nf.Samples = nf.Samples[:0]
-			for _, s := range f.Samples {
-				if filterSample(f, s) {
-					nf.Samples = append(nf.Samples, s)
-				}
+		nf.Samples, nf.Histograms, nf.Summaries = nil, nil, nil
+		for _, s := range f.Samples {
+			if keep(f, s.Labels) {
+				nf.Samples = append(nf.Samples, s)
+			}
+		}
+		for _, h := range f.Histograms {
+			if keep(f, h.Labels) {
+				nf.Histograms = append(nf.Histograms, h)
+			}
+		}
+		for _, s := range f.Summaries {
+			if keep(f, s.Labels) {
+				nf.Summaries = append(nf.Summaries, s)
 			}
 		}
-		// TODO: extend to Histograms/Summaries for relevant collectors.
 		out = append(out, nf)
 	}
 	return out, nil
@@ -389,4 +692,3 @@ func labelValue(labels []nodecollector.Label, key string) string {
 	}
 	return ""
 }
-
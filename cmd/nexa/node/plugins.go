@@ -0,0 +1,49 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	nodecollector "github.com/nexa/pkg/node/collector"
+)
+
+const pluginDirFlag = "collector.plugin.directory"
+
+// pluginDirFromArgs returns the value of --collector.plugin.directory in args. Plugins have to be
+// registered before the --collector.<name> flags are generated, i.e. before cobra parses args.
+func pluginDirFromArgs(args []string) (dir string, set bool) {
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		if v, ok := strings.CutPrefix(a, "--"+pluginDirFlag+"="); ok {
+			dir, set = v, true
+		} else if a == "--"+pluginDirFlag && i+1 < len(args) {
+			dir, set = args[i+1], true
+		}
+	}
+	return dir, set
+}
+
+// registerPlugins loads the external collectors of dir into reg. A missing default directory is
+// not an error; an explicitly given one is.
+func registerPlugins(reg *nodecollector.Registry, dir string, explicit bool) error {
+	if dir == "" {
+		return nil
+	}
+	plugins, err := nodecollector.LoadPlugins(dir)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", pluginDirFlag, err)
+	}
+	for _, p := range plugins {
+		if err := reg.RegisterPlugin(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/ctx"
+	"github.com/nexa/pkg/net/httpclient"
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/push"
+	"github.com/nexa/pkg/node/render"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func pushCmd(cctx *ctx.Ctx, reg *nodecollector.Registry, rf *nodeRenderFlags, cf *nodeCollectorFlags, collectOnly *[]string, exclude *[]string, pf *nodePostFilterFlags) *cobra.Command {
+	var (
+		pushgateway string
+		job         string
+		remoteWrite string
+		interval    time.Duration
+		instance    string
+		labels      []string
+		httpCfg     httpclient.Config
+		retry       = push.DefaultRetry
+	)
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "push collected metrics to a Pushgateway or a Prometheus remote_write endpoint",
+		Long: "Collect like `nexa node all` and push the result, once or every --interval.\n" +
+			"To a Pushgateway the metrics replace the group job/<job>/instance/<instance>[/<label>/<value>...];\n" +
+			"to remote_write every series gets the instance and --external-label labels unless it already has them.\n" +
+			"Failed pushes are retried with exponential backoff on network errors, 429 and 5xx.",
+		Example: "nexa node push --pushgateway http://pushgateway:9091 --job nexa --interval 30s\n" +
+			"  nexa node push --remote-write https://prometheus/api/v1/write --bearer-token-file /run/secrets/token --external-label dc=eu-1",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runtime.GOOS != "linux" {
+				return fmt.Errorf("nexa node collectors are currently implemented for linux; current GOOS=%s", runtime.GOOS)
+			}
+			if len(*collectOnly) > 0 && len(*exclude) > 0 {
+				return fmt.Errorf("combined --collect and --exclude are not allowed")
+			}
+			if pushgateway == "" && remoteWrite == "" {
+				return fmt.Errorf("one of --pushgateway or --remote-write is required")
+			}
+			client, err := httpCfg.NewClient()
+			if err != nil {
+				return err
+			}
+			extra, err := parseExternalLabels(labels)
+			if err != nil {
+				return err
+			}
+			if instance == "" {
+				instance, _ = os.Hostname()
+			}
+			if instance != "" && !hasLabel(extra, "instance") {
+				extra = append(extra, nodecollector.Label{Name: "instance", Value: instance})
+			}
+
+			var pg *push.Pushgateway
+			if pushgateway != "" {
+				pg = &push.Pushgateway{URL: pushgateway, Job: job, Grouping: extra, Client: client, Retry: retry}
+				if _, err := pg.GroupURL(); err != nil {
+					return err
+				}
+			}
+			var rw *push.RemoteWrite
+			if remoteWrite != "" {
+				rw = &push.RemoteWrite{URL: remoteWrite, ExternalLabels: extra, Client: client, Retry: retry}
+			}
+
+			selected, notEnabled := selectCollectors(reg, *collectOnly, *exclude, computeEnabledSet(reg, *cf))
+			if len(selected) == 0 {
+				return fmt.Errorf("no collectors enabled")
+			}
+			if len(notEnabled) > 0 {
+				sort.Strings(notEnabled)
+				fmt.Fprintf(os.Stderr, "Not enabled collectors: %s\n", strings.Join(notEnabled, ", "))
+			}
+
+			once := func(ctx context.Context) error {
+				at := time.Now()
+				families, errs, _ := collectNamed(ctx, reg, selected, *pf, cf.collect)
+				for _, e := range errs {
+					cctx.Logger().Warn("collector failed", zap.String("error", e))
+				}
+				families, err := render.FilterFamilies(families, rf.options())
+				if err != nil {
+					return err
+				}
+				var pushErrs []string
+				if pg != nil {
+					if err := pg.Push(ctx, families); err != nil {
+						pushErrs = append(pushErrs, err.Error())
+					}
+				}
+				if rw != nil {
+					if err := rw.Write(ctx, families, at); err != nil {
+						pushErrs = append(pushErrs, err.Error())
+					}
+				}
+				if len(pushErrs) > 0 {
+					return fmt.Errorf("%s", strings.Join(pushErrs, "; "))
+				}
+				cctx.Logger().Info("pushed node metrics", zap.Int("families", len(families)), zap.Duration("took", time.Since(at)))
+				return nil
+			}
+
+			ctx := cctx.Context()
+			if interval <= 0 {
+				return once(ctx)
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if err := once(ctx); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					cctx.Logger().Error("push failed", zap.Error(err))
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVar(&pushgateway, "pushgateway", "", "Pushgateway base URL, e.g. http://pushgateway:9091")
+	cmd.Flags().StringVar(&job, "job", "nexa", "Pushgateway job name")
+	cmd.Flags().StringVar(&remoteWrite, "remote-write", "", "Prometheus remote_write URL, e.g. http://prometheus:9090/api/v1/write")
+	cmd.Flags().DurationVar(&interval, "interval", 0, "push repeatedly at this interval (0 pushes once and exits)")
+	cmd.Flags().StringVar(&instance, "instance", "", "instance label of the pushed metrics (default: os hostname)")
+	cmd.Flags().StringArrayVar(&labels, "external-label", nil, "extra label k=v added to the pushed series (repeatable); part of the grouping key for --pushgateway")
+	httpCfg.RegisterFlags(cmd.Flags())
+	cmd.Flags().DurationVar(&httpCfg.Timeout, "push.timeout", 30*time.Second, "timeout of each push attempt")
+	cmd.Flags().IntVar(&retry.Attempts, "push.attempts", retry.Attempts, "attempts per push, including the first")
+	cmd.Flags().DurationVar(&retry.MinBackoff, "push.min-backoff", retry.MinBackoff, "initial delay between attempts, doubled after each failure")
+	cmd.Flags().DurationVar(&retry.MaxBackoff, "push.max-backoff", retry.MaxBackoff, "maximum delay between attempts")
+	return cmd
+}
+
+// parseExternalLabels parses repeated --external-label k=v flags.
+func parseExternalLabels(specs []string) ([]nodecollector.Label, error) {
+	out := make([]nodecollector.Label, 0, len(specs))
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --external-label %q (want k=v)", spec)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate --external-label %s", name)
+		}
+		seen[name] = true
+		out = append(out, nodecollector.Label{Name: name, Value: value})
+	}
+	return out, nil
+}
+
+func hasLabel(labels []nodecollector.Label, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
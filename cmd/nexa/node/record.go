@@ -0,0 +1,199 @@
+package node
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/ctx"
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"github.com/nexa/pkg/node/tsdb"
+	"github.com/nexa/pkg/timeutil"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const defaultRecordDir = "/var/lib/nexa"
+
+func recordCmd(cctx *ctx.Ctx, reg *nodecollector.Registry, rf *nodeRenderFlags, cf *nodeCollectorFlags, collectOnly *[]string, exclude *[]string, pf *nodePostFilterFlags) *cobra.Command {
+	var (
+		dir           string
+		interval      time.Duration
+		retention     time.Duration
+		blockDuration time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "record collections to a local time series store for later replay",
+		Long: "Collect every --interval and append the samples to compressed block files under --dir.\n" +
+			"Blocks older than --retention are deleted, so the directory stays bounded. Use\n" +
+			"`nexa node replay` to look at what the node was doing at a given time.",
+		Example:      "nexa node record --interval 10s --retention 24h --dir /var/lib/nexa\n  nexa node record --collect cpu --collect diskstats --dir /tmp/nexa --retention 2h",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runtime.GOOS != "linux" {
+				return fmt.Errorf("nexa node collectors are currently implemented for linux; current GOOS=%s", runtime.GOOS)
+			}
+			if len(*collectOnly) > 0 && len(*exclude) > 0 {
+				return fmt.Errorf("combined --collect and --exclude are not allowed")
+			}
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			selected, _ := selectCollectors(reg, *collectOnly, *exclude, computeEnabledSet(reg, *cf))
+			if len(selected) == 0 {
+				return fmt.Errorf("no collectors enabled")
+			}
+			host, _ := os.Hostname()
+			w, err := tsdb.Create(tsdb.Options{Dir: dir, Retention: retention, BlockDuration: blockDuration, Host: host})
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := w.Close(); err != nil {
+					cctx.Logger().Error("closing recording", zap.Error(err))
+				}
+			}()
+
+			log := cctx.Logger()
+			log.Info("recording node metrics", zap.String("dir", dir), zap.Duration("interval", interval), zap.Duration("retention", retention), zap.Strings("collectors", selected))
+			fmt.Fprintf(os.Stdout, "Recording %d collectors every %s to %s\n", len(selected), interval, dir)
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				at := time.Now()
+				families, errs, _ := collectNamed(cctx.Context(), reg, selected, *pf, cf.collect)
+				for _, e := range errs {
+					log.Warn("collection error", zap.String("error", e))
+				}
+				families, err := render.FilterFamilies(families, rf.options())
+				if err != nil {
+					return err
+				}
+				if err := w.Append(at, families); err != nil {
+					return err
+				}
+				select {
+				case <-cctx.Context().Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", defaultRecordDir, "directory for the recorded blocks")
+	cmd.Flags().DurationVar(&interval, "interval", 10*time.Second, "collection interval")
+	cmd.Flags().DurationVar(&retention, "retention", 24*time.Hour, "delete blocks older than this (0 keeps everything)")
+	cmd.Flags().DurationVar(&blockDuration, "block-duration", tsdb.DefaultBlockDuration, "time window covered by each block file")
+	return cmd
+}
+
+func replayCmd(rf *nodeRenderFlags) *cobra.Command {
+	var (
+		dir      string
+		at       string
+		span     string
+		step     time.Duration
+		lookback time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "show recorded metrics at a point in time (--at) or rates over a range (--range)",
+		Long: "Read the blocks written by `nexa node record`.\n\n" +
+			"--at renders the last collection at or before the given time, like `nexa node all`.\n" +
+			"--range renders per-second rates between the first and last collection in the range, like\n" +
+			"`nexa node --watch`; with --step one frame is printed per step.\n\n" +
+			"Times are HH:MM[:SS] (the most recent such time), YYYY-MM-DD HH:MM[:SS], RFC 3339, -DURATION or now.",
+		Example:      "nexa node replay --at 03:00\n  nexa node replay --range 02:50..03:10 --metric 'node_disk_.*'\n  nexa node replay --range -1h..now --step 10m --dir /tmp/nexa",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (at == "") == (span == "") {
+				return fmt.Errorf("exactly one of --at and --range is required")
+			}
+			format, err := rf.format()
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+
+			if at != "" {
+				t, err := timeutil.ParseTime(at, now)
+				if err != nil {
+					return err
+				}
+				rec, err := tsdb.Read(dir, t.Add(-lookback), t)
+				if err != nil {
+					return err
+				}
+				families, got, ok := rec.At(t)
+				if !ok {
+					return fmt.Errorf("nothing recorded in %s between %s and %s", dir, t.Add(-lookback).Format(time.RFC3339), t.Format(time.RFC3339))
+				}
+				notes := io.Writer(os.Stdout)
+				if format != render.FormatTable {
+					notes = os.Stderr
+				}
+				fmt.Fprintf(notes, "Recorded%s at %s\n\n", onHosts(rec.Hosts), got.Format(time.RFC3339))
+				return render.Write(os.Stdout, families, format, rf.options())
+			}
+
+			if format != render.FormatTable {
+				return fmt.Errorf("--range only supports -o table")
+			}
+			from, to, err := timeutil.ParseRange(span, now)
+			if err != nil {
+				return err
+			}
+			rec, err := tsdb.Read(dir, from, to)
+			if err != nil {
+				return err
+			}
+			times := rec.Times()
+			if len(times) < 2 {
+				return fmt.Errorf("need at least two collections in %s between %s and %s, found %d", dir, from.Format(time.RFC3339), to.Format(time.RFC3339), len(times))
+			}
+			bounds := []time.Time{times[0], times[len(times)-1]}
+			if step > 0 {
+				bounds = bounds[:1]
+				for t := times[0].Add(step); !t.After(times[len(times)-1]); t = t.Add(step) {
+					bounds = append(bounds, t)
+				}
+			}
+			for i := 1; i < len(bounds); i++ {
+				prev, prevAt, _ := rec.At(bounds[i-1])
+				cur, curAt, _ := rec.At(bounds[i])
+				if !curAt.After(prevAt) {
+					continue // no collection in this step
+				}
+				if i > 1 {
+					fmt.Fprintln(os.Stdout)
+				}
+				fmt.Fprintf(os.Stdout, "Rates%s from %s to %s (%s)\n", onHosts(rec.Hosts), prevAt.Format(time.RFC3339), curAt.Format(time.RFC3339), curAt.Sub(prevAt))
+				if err := render.PrintRateFrame(os.Stdout, prev, cur, curAt.Sub(prevAt), rf.options()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", defaultRecordDir, "directory written by `nexa node record`")
+	cmd.Flags().StringVar(&at, "at", "", "show the last collection at or before this time")
+	cmd.Flags().StringVar(&span, "range", "", "show rates over FROM..TO")
+	cmd.Flags().DurationVar(&step, "step", 0, "with --range, print one frame per step instead of one for the whole range")
+	cmd.Flags().DurationVar(&lookback, "lookback", 5*time.Minute, "with --at, how far back to look for a collection")
+	return cmd
+}
+
+func onHosts(hosts []string) string {
+	if len(hosts) == 0 {
+		return ""
+	}
+	return " on " + strings.Join(hosts, ", ")
+}
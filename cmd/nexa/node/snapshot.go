@@ -0,0 +1,149 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/ctx"
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"github.com/spf13/cobra"
+)
+
+func snapshotCmd(cctx *ctx.Ctx, reg *nodecollector.Registry, rf *nodeRenderFlags, cf *nodeCollectorFlags, collectOnly *[]string, exclude *[]string, pf *nodePostFilterFlags) *cobra.Command {
+	var (
+		file string
+		host string
+	)
+	cmd := &cobra.Command{
+		Use:          "snapshot",
+		Short:        "capture the output of `nexa node all` to a versioned JSON file",
+		Example:      "nexa node snapshot -f before.json\n  nexa node snapshot --collect cpu --collect meminfo -f - | ssh other 'cat > remote.json'",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runtime.GOOS != "linux" {
+				return fmt.Errorf("nexa node collectors are currently implemented for linux; current GOOS=%s", runtime.GOOS)
+			}
+			if len(*collectOnly) > 0 && len(*exclude) > 0 {
+				return fmt.Errorf("combined --collect and --exclude are not allowed")
+			}
+			if host == "" {
+				host, _ = os.Hostname()
+			}
+
+			selected, notEnabled := selectCollectors(reg, *collectOnly, *exclude, computeEnabledSet(reg, *cf))
+			if len(selected) == 0 {
+				return fmt.Errorf("no collectors enabled")
+			}
+			at := time.Now()
+			families, errs, _ := collectNamed(cctx.Context(), reg, selected, *pf, cf.collect)
+			families, err := render.FilterFamilies(families, rf.options())
+			if err != nil {
+				return err
+			}
+			sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+
+			snap := nodecollector.NewSnapshot(host, at, selected, families)
+			snap.Errors = errs
+
+			var w io.Writer = os.Stdout
+			if file != "-" {
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			if err := snap.Write(w); err != nil {
+				return err
+			}
+
+			if len(notEnabled) > 0 {
+				sort.Strings(notEnabled)
+				fmt.Fprintf(os.Stderr, "Not enabled collectors: %s\n", strings.Join(notEnabled, ", "))
+			}
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "error: %s\n", e)
+			}
+			if file != "-" {
+				fmt.Fprintf(os.Stderr, "Wrote %d metric families from %d collectors to %s\n", len(families), len(selected), file)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "output file (- for stdout)")
+	cmd.Flags().StringVar(&host, "host", "", "host name recorded in the snapshot (default: os hostname)")
+	return cmd
+}
+
+func diffCmd(rf *nodeRenderFlags) *cobra.Command {
+	var threshold string
+	cmd := &cobra.Command{
+		Use:   "diff BEFORE.json AFTER.json",
+		Short: "compare two snapshots: added/removed series, changed gauges and counter rates",
+		Long: "Compare two snapshots taken with `nexa node snapshot`.\n" +
+			"Gauges are reported when they change by more than --threshold; counters are reported as\n" +
+			"per-second rates computed from the two snapshot timestamps.",
+		Example:      "nexa node diff before.json after.json\n  nexa node diff before.json after.json --threshold 5% --metric 'node_memory_.*'",
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := rf.format()
+			if err != nil {
+				return err
+			}
+			if format != render.FormatTable && format != render.FormatJSON {
+				return fmt.Errorf("diff supports -o table or -o json")
+			}
+			opt, err := parseThreshold(threshold)
+			if err != nil {
+				return err
+			}
+
+			before, err := nodecollector.LoadSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			after, err := nodecollector.LoadSnapshot(args[1])
+			if err != nil {
+				return err
+			}
+			for _, s := range []*nodecollector.Snapshot{before, after} {
+				if s.Families, err = render.FilterFamilies(s.Families, rf.options()); err != nil {
+					return err
+				}
+			}
+
+			d := nodecollector.DiffSnapshots(before, after, opt)
+			if format == render.FormatJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(d)
+			}
+			return render.PrintDiff(os.Stdout, d, rf.options())
+		},
+	}
+	cmd.Flags().StringVar(&threshold, "threshold", "0", "minimum gauge change to report: absolute (e.g. 1048576) or relative to the old value (e.g. 5%)")
+	return cmd
+}
+
+func parseThreshold(s string) (nodecollector.DiffOptions, error) {
+	s = strings.TrimSpace(s)
+	rel := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || v < 0 {
+		return nodecollector.DiffOptions{}, fmt.Errorf("invalid --threshold %q (want e.g. 0.5 or 5%%)", s)
+	}
+	if rel {
+		v /= 100
+	}
+	return nodecollector.DiffOptions{Threshold: v, Relative: rel}, nil
+}
@@ -0,0 +1,155 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/nexa/pkg/ctx"
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"github.com/nexa/pkg/node/sockets"
+	"github.com/spf13/cobra"
+)
+
+type socketStatsJSON struct {
+	Port          uint16         `json:"port"`
+	Direction     string         `json:"direction"`
+	Remote        string         `json:"remote,omitempty"`
+	Total         int            `json:"total"`
+	States        map[string]int `json:"states"`
+	RecvQueue     uint64         `json:"recv_queue_bytes"`
+	SendQueue     uint64         `json:"send_queue_bytes"`
+	AcceptQueue   *uint64        `json:"accept_queue,omitempty"`
+	AcceptBacklog *uint64        `json:"accept_queue_max,omitempty"`
+	Retrans       uint64         `json:"retransmitted_segments"`
+	LowCwnd       int            `json:"low_cwnd"`
+	AvgRTT        float64        `json:"avg_rtt_seconds"`
+	MaxRTT        float64        `json:"max_rtt_seconds"`
+}
+
+func socketsCmd(cctx *ctx.Ctx, rf *nodeRenderFlags) *cobra.Command {
+	var (
+		top      int
+		sortBy   string
+		byRemote bool
+		pid      int
+		ports    []int
+		ipv4     bool
+		ipv6     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "sockets",
+		Short: "TCP sockets per port and state with queues, retransmits and congestion (ss-style)",
+		Long: "Dump every TCP socket with its tcp_info over sock_diag and aggregate them per port.\n" +
+			"Connections to a local port with a listener count towards that port (direction in); all\n" +
+			"others count towards their remote port (direction out), so ephemeral ports do not show up.\n" +
+			"Recv Q and Send Q sum the queued bytes of the connections, Accept Q shows the listeners'\n" +
+			"accept queue over their backlog, Retrans sums the retransmitted segments of the current\n" +
+			fmt.Sprintf("connections and Low Cwnd counts established ones with a congestion window below %d.\n", sockets.LowCwnd) +
+			"Use --pid to look into the network namespace of a process, e.g. a container.",
+		Example: "nexa node sockets\n  nexa node sockets --sort time_wait --top 5\n  nexa node sockets --port 443 --by-remote --sort retrans\n" +
+			"  nexa node sockets --pid $(pidof -s nginx) -o json",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runtime.GOOS != "linux" {
+				return fmt.Errorf("nexa node sockets is only implemented for linux; current GOOS=%s", runtime.GOOS)
+			}
+			format, err := rf.format()
+			if err != nil {
+				return err
+			}
+			if format != render.FormatTable && format != render.FormatJSON {
+				return fmt.Errorf("sockets supports -o table or -o json")
+			}
+			opt := sockets.Options{IPv4: ipv4, IPv6: ipv6}
+			if pid > 0 {
+				opt.NetNS = filepath.Join(nodecollector.CurrentPaths().Procfs, strconv.Itoa(pid), "ns", "net")
+			}
+			socks, err := sockets.Dump(cctx.Context(), opt)
+			if err != nil {
+				return err
+			}
+			stats := sockets.Aggregate(socks, byRemote)
+			if len(ports) > 0 {
+				keep := map[uint16]bool{}
+				for _, p := range ports {
+					keep[uint16(p)] = true
+				}
+				filtered := stats[:0]
+				for _, st := range stats {
+					if keep[st.Port] {
+						filtered = append(filtered, st)
+					}
+				}
+				stats = filtered
+			}
+			if !sockets.Sort(stats, sortBy) {
+				return fmt.Errorf("unknown --sort %q (want %s)", sortBy, strings.Join(sockets.SortKeys, ", "))
+			}
+			groups := len(stats)
+			if top > 0 && len(stats) > top {
+				stats = stats[:top]
+			}
+
+			if format == render.FormatJSON {
+				rows := make([]socketStatsJSON, 0, len(stats))
+				for i := range stats {
+					rows = append(rows, socketStatsToJSON(&stats[i]))
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(rows)
+			}
+			if err := render.PrintSocketStats(os.Stdout, stats, byRemote, rf.human); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "\n%d sockets in %d groups", len(socks), groups)
+			if len(stats) < groups {
+				fmt.Fprintf(os.Stdout, "; top %d by %s", len(stats), sortBy)
+			}
+			fmt.Fprintln(os.Stdout)
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&top, "top", 20, "show only the first N groups (0 shows all)")
+	cmd.Flags().StringVar(&sortBy, "sort", "total", "order of the groups: "+strings.Join(sockets.SortKeys, "|"))
+	cmd.Flags().BoolVar(&byRemote, "by-remote", false, "break each port down per remote address")
+	cmd.Flags().IntVar(&pid, "pid", 0, "inspect the network namespace of this process")
+	cmd.Flags().IntSliceVar(&ports, "port", nil, "only show these ports (repeatable)")
+	cmd.Flags().BoolVarP(&ipv4, "ipv4", "4", false, "only IPv4 sockets")
+	cmd.Flags().BoolVarP(&ipv6, "ipv6", "6", false, "only IPv6 sockets")
+	return cmd
+}
+
+func socketStatsToJSON(st *sockets.Stats) socketStatsJSON {
+	row := socketStatsJSON{
+		Port:      st.Port,
+		Direction: string(st.Direction),
+		Total:     st.Total,
+		States:    map[string]int{},
+		RecvQueue: st.RecvQ,
+		SendQueue: st.SendQ,
+		Retrans:   st.Retrans,
+		LowCwnd:   st.LowCwnd,
+		AvgRTT:    st.AvgRTT().Seconds(),
+		MaxRTT:    st.MaxRTT.Seconds(),
+	}
+	if st.Remote.IsValid() {
+		row.Remote = st.Remote.String()
+	}
+	for _, s := range sockets.States() {
+		if n := st.Count(s); n > 0 {
+			row.States[s.String()] = n
+		}
+	}
+	if st.Count(sockets.StateListen) > 0 {
+		row.AcceptQueue, row.AcceptBacklog = &st.AcceptQ, &st.AcceptQMax
+	}
+	return row
+}
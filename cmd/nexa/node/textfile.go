@@ -0,0 +1,171 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"github.com/nexa/pkg/node/textfile"
+	"github.com/spf13/cobra"
+)
+
+func textfileCmd(rf *nodeRenderFlags, cf *nodeCollectorFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "textfile",
+		Short: "check and write *.prom files for the textfile collector",
+	}
+	cmd.AddCommand(textfileLintCmd(rf, cf))
+	cmd.AddCommand(textfileWriteCmd())
+	return cmd
+}
+
+func textfileLintCmd(rf *nodeRenderFlags, cf *nodeCollectorFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint [DIR|FILE...]",
+		Short: "report problems that make the textfile collector drop or misread *.prom files",
+		Long: "Check *.prom files the way the textfile collector reads them: invalid metric and label\n" +
+			"names, duplicate series (also across files), mixed types, families split across the file,\n" +
+			"client-side timestamps and missing HELP/TYPE lines. Directories are checked for *.prom\n" +
+			"files; without arguments the --collector.textfile.directory paths are checked.\n" +
+			"Exits with status 1 when any error is found.",
+		Example:      "nexa node textfile lint /var/lib/node_exporter/textfile_collector\n  nexa node textfile lint backup.prom -o json",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := rf.format()
+			if err != nil {
+				return err
+			}
+			if format != render.FormatTable && format != render.FormatJSON {
+				return fmt.Errorf("textfile lint supports -o table or -o json")
+			}
+			paths := args
+			if len(paths) == 0 {
+				paths = cf.textfileDirs
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("no files to check (pass a directory or set --collector.textfile.directory)")
+			}
+			files, err := textfile.Files(paths)
+			if err != nil {
+				return err
+			}
+			problems, err := textfile.Lint(files)
+			if err != nil {
+				return err
+			}
+
+			if format == render.FormatJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(struct {
+					Files    []string           `json:"files"`
+					Problems []textfile.Problem `json:"problems"`
+				}{files, append([]textfile.Problem{}, problems...)}); err != nil {
+					return err
+				}
+			} else if err := render.PrintTextfileLint(os.Stdout, len(files), problems); err != nil {
+				return err
+			}
+			if textfile.HasErrors(problems) {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+}
+
+func textfileWriteCmd() *cobra.Command {
+	var (
+		dir    string
+		file   string
+		name   string
+		value  string
+		typ    string
+		help   string
+		labels []string
+		merge  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "write",
+		Short: "atomically write a metric to a *.prom file",
+		Long: "Write one sample to <dir>/<file> via a temporary file and a rename, so the textfile\n" +
+			"collector never sees a partial file. With --merge the other series of an existing file\n" +
+			"are kept and a series with the same labels is replaced.",
+		Example: "nexa node textfile write --dir /var/lib/node_exporter/textfile_collector --name backup_last_success_seconds --value $(date +%s) --label job=db\n" +
+			"  nexa node textfile write --dir . --file backup.prom --merge --name backup_ok --value 1 --label job=home",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dir == "" || name == "" || value == "" {
+				return fmt.Errorf("--dir, --name and --value are required")
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid --value %q", value)
+			}
+			lbls, err := parseMetricLabels(labels)
+			if err != nil {
+				return err
+			}
+			if file == "" {
+				file = name + ".prom"
+			}
+			if !strings.HasSuffix(file, ".prom") {
+				return fmt.Errorf("--file %q must end in .prom or the textfile collector ignores it", file)
+			}
+			path := filepath.Join(dir, file)
+
+			var families []nodecollector.MetricFamily
+			if merge {
+				existing, problems, err := textfile.ParseFile(path)
+				switch {
+				case errors.Is(err, fs.ErrNotExist):
+				case err != nil:
+					return err
+				case textfile.HasErrors(problems):
+					for _, p := range problems {
+						fmt.Fprintln(os.Stderr, p)
+					}
+					return fmt.Errorf("%s has errors; fix it or write without --merge", path)
+				default:
+					families = existing
+				}
+			}
+			families, err = textfile.SetSample(families, name, help, nodecollector.MetricType(typ), lbls, v)
+			if err != nil {
+				return err
+			}
+			return textfile.WriteFile(path, families)
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", "", "textfile collector directory")
+	cmd.Flags().StringVar(&file, "file", "", "file name below --dir (default: <name>.prom)")
+	cmd.Flags().StringVar(&name, "name", "", "metric name")
+	cmd.Flags().StringVar(&value, "value", "", "sample value (NaN, +Inf and -Inf are accepted)")
+	cmd.Flags().StringVar(&typ, "type", string(nodecollector.MetricTypeGauge), "metric type: gauge|counter|untyped")
+	cmd.Flags().StringVar(&help, "help-text", "", "HELP text of the metric")
+	// Shadows the persistent --label selector, which has no meaning when writing.
+	cmd.Flags().StringArrayVar(&labels, "label", nil, "label of the sample as k=v (repeatable)")
+	cmd.Flags().BoolVar(&merge, "merge", false, "keep the other series of an existing file")
+	return cmd
+}
+
+func parseMetricLabels(specs []string) ([]nodecollector.Label, error) {
+	out := make([]nodecollector.Label, 0, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --label %q (want k=v)", spec)
+		}
+		out = append(out, nodecollector.Label{Name: name, Value: value})
+	}
+	return out, nil
+}
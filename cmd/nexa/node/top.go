@@ -0,0 +1,221 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/ctx"
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"github.com/nexa/pkg/node/top"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func topCmd(cctx *ctx.Ctx, reg *nodecollector.Registry, cf *nodeCollectorFlags, collectOnly *[]string, pf *nodePostFilterFlags) *cobra.Command {
+	var (
+		interval   time.Duration
+		history    int
+		iterations int
+		plain      bool
+	)
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "full-screen dashboard of CPU, memory, PSI, disk, network and filesystem activity",
+		Long: "Refresh node metrics every --interval and show them in panes with history sparklines:\n" +
+			"CPU modes per core, memory and swap, pressure stall information, disk IOPS, throughput\n" +
+			"and latency per device, network traffic and drops per interface and filesystem usage.\n" +
+			"Switch panes with tab or 1-6, sort with s/S and r, quit with q (press ? for all keys).\n" +
+			"When stdout is not a terminal (or with --plain) every pane is printed as a table on each\n" +
+			"refresh instead.",
+		Example:      "nexa node top\n  nexa node top --interval 1s --collector.filesystem.fs-types-exclude '^(tmpfs|overlay)$'\n  nexa node top --plain --iterations 3 > incident.txt",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runtime.GOOS != "linux" {
+				return fmt.Errorf("nexa node collectors are currently implemented for linux; current GOOS=%s", runtime.GOOS)
+			}
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			names := *collectOnly
+			if len(names) == 0 {
+				for _, name := range top.Collectors {
+					if reg.Has(name) && reg.Status(name).Implemented {
+						names = append(names, name)
+					}
+				}
+			}
+			collect := func() ([]nodecollector.MetricFamily, []string) {
+				families, errs, _ := collectNamed(cctx.Context(), reg, names, *pf, cf.collect)
+				return families, errs
+			}
+			host, _ := os.Hostname()
+			d := top.New(history)
+
+			if plain || !isTerminal(os.Stdout) || !term.IsTerminal(int(os.Stdin.Fd())) {
+				return runTopPlain(cctx.Context(), os.Stdout, d, collect, interval, iterations)
+			}
+			return runTopTUI(cctx.Context(), d, collect, interval, host)
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "refresh interval")
+	cmd.Flags().IntVar(&history, "history", top.DefaultHistory, "refreshes kept per row for the sparklines")
+	cmd.Flags().IntVar(&iterations, "iterations", 0, "stop after this many refreshes in plain mode (0 runs until interrupted)")
+	cmd.Flags().BoolVar(&plain, "plain", false, "print repeated tables even when stdout is a terminal")
+	return cmd
+}
+
+// runTopPlain prints all panes as tables on every refresh, like `nexa node --watch`.
+func runTopPlain(c context.Context, w io.Writer, d *top.Dashboard, collect func() ([]nodecollector.MetricFamily, []string), interval time.Duration, iterations int) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 1; ; i++ {
+		families, errs := collect()
+		at := time.Now()
+		d.Update(families, at)
+
+		if i > 1 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Every %s: nexa node top    %s\n", interval, at.Format(time.RFC3339))
+		for _, e := range errs {
+			fmt.Fprintf(w, "error: %s\n", e)
+		}
+		if i == 1 {
+			fmt.Fprintln(w, "(rates available from the next sample)")
+		}
+		fmt.Fprintln(w)
+		if err := render.PrintTopPlain(w, d.Panes()); err != nil {
+			return err
+		}
+		if iterations > 0 && i >= iterations {
+			return nil
+		}
+		select {
+		case <-c.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type topResult struct {
+	families []nodecollector.MetricFamily
+	errs     []string
+	at       time.Time
+}
+
+// runTopTUI draws the interactive dashboard on the alternate screen with the terminal in raw
+// mode. Collections run in the background so keys stay responsive while a slow collector runs.
+func runTopTUI(c context.Context, d *top.Dashboard, collect func() ([]nodecollector.MetricFamily, []string), interval time.Duration, host string) error {
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(stdin, state)
+	fmt.Fprint(os.Stdout, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(os.Stdout, "\033[?25h\033[?1049l")
+
+	keys := make(chan []top.Key)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			if ks := top.ParseKeys(buf[:n]); len(ks) > 0 {
+				keys <- ks
+			}
+		}
+	}()
+
+	results := make(chan topResult, 1)
+	busy := false
+	start := func() {
+		if busy {
+			return
+		}
+		busy = true
+		go func() {
+			families, errs := collect()
+			results <- topResult{families, errs, time.Now()}
+		}()
+	}
+
+	view := top.NewView()
+	width, height, _ := term.GetSize(stdout)
+	var (
+		lastAt  time.Time
+		status  = "collecting..."
+		updates int
+	)
+	draw := func() error {
+		title := fmt.Sprintf("nexa node top - %s - every %s", host, interval)
+		if !lastAt.IsZero() {
+			title += " - " + lastAt.Format("15:04:05")
+		}
+		return render.DrawTop(os.Stdout, d.Panes(), view, render.TopFrame{Title: title, Status: status, Width: width, Height: height})
+	}
+
+	start()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// Terminal resizes are picked up by polling, which works without platform signals.
+	resize := time.NewTicker(250 * time.Millisecond)
+	defer resize.Stop()
+	if err := draw(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-c.Done():
+			return nil
+		case <-ticker.C:
+			start()
+		case r := <-results:
+			busy = false
+			d.Update(r.families, r.at)
+			lastAt = r.at
+			updates++
+			switch {
+			case len(r.errs) > 0:
+				status = "error: " + strings.Join(r.errs, "; ")
+			case updates == 1:
+				status = "rates available from the next refresh"
+			default:
+				status = ""
+			}
+		case <-resize.C:
+			w, h, err := term.GetSize(stdout)
+			if err != nil || (w == width && h == height) {
+				continue
+			}
+			width, height = w, h
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range ks {
+				if k == top.KeyRefresh {
+					start()
+					continue
+				}
+				if quit, _ := view.Handle(k, d.Panes(), render.TopPageSize(height)); quit {
+					return nil
+				}
+			}
+		}
+		if err := draw(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	nodecollector "github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"golang.org/x/term"
+)
+
+// runWatch samples collect every interval and redraws a rate frame (like iostat/sar) until c is cancelled.
+// Collection errors are shown in the frame header instead of aborting the loop; partial results still count.
+func runWatch(c context.Context, w io.Writer, title string, interval time.Duration, collect func() ([]nodecollector.MetricFamily, error), opt render.Options) error {
+	if interval <= 0 {
+		return fmt.Errorf("--watch interval must be positive")
+	}
+	tty := isTerminal(w)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		prev   []nodecollector.MetricFamily
+		prevAt time.Time
+	)
+	for {
+		cur, err := collect()
+		at := time.Now()
+		sort.Slice(cur, func(i, j int) bool { return cur[i].Name < cur[j].Name })
+
+		if tty {
+			fmt.Fprint(w, render.ClearScreen)
+		} else if prev != nil {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Every %s: %s    %s\n", interval, title, at.Format(time.RFC3339))
+		if err != nil {
+			fmt.Fprintf(w, "error: %v\n", err)
+		}
+		if prev == nil {
+			fmt.Fprintln(w, "(rates available from the next sample)")
+		}
+		if rerr := render.PrintRateFrame(w, prev, cur, at.Sub(prevAt), opt); rerr != nil {
+			return rerr
+		}
+		if cur != nil {
+			prev, prevAt = cur, at
+		}
+
+		select {
+		case <-c.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,256 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type chartOptions struct {
+	Width  int // total width including the y axis; defaults to 100
+	Height int // plot rows; defaults to 15
+	ASCII  bool
+	Color  bool
+}
+
+type chartGlyphs struct {
+	markers                      []string
+	vertical, tick, corner, axis string
+}
+
+var (
+	unicodeGlyphs = chartGlyphs{
+		markers:  []string{"●", "■", "▲", "◆", "★", "✚", "○", "□", "△", "◇"},
+		vertical: "│", tick: "┤", corner: "└", axis: "─",
+	}
+	asciiGlyphs = chartGlyphs{
+		markers:  []string{"*", "+", "x", "o", "#", "@", "%", "&", "=", "$"},
+		vertical: "|", tick: "+", corner: "+", axis: "-",
+	}
+	// ANSI colours of the series, in legend order.
+	chartColors = []int{32, 33, 34, 35, 36, 31, 92, 93, 94, 95, 96, 91}
+)
+
+// renderRangeChart draws series as a line chart on the start + k*step grid followed by a
+// legend. Each column shows the peak of the steps it covers; steps without a finite value are
+// gaps, and consecutive points are joined with vertical strokes in the series' colour.
+func renderRangeChart(w io.Writer, series []rangeSeries, start, end time.Time, step time.Duration, opt chartOptions) error {
+	if opt.Width <= 0 {
+		opt.Width = 100
+	}
+	if opt.Height <= 0 {
+		opt.Height = 15
+	}
+	opt.Height = max(opt.Height, 2)
+	g := unicodeGlyphs
+	if opt.ASCII {
+		g = asciiGlyphs
+	}
+
+	steps := int(end.Sub(start)/step) + 1
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, p := range s.Points {
+			if !math.IsNaN(p.V) && !math.IsInf(p.V, 0) {
+				lo, hi = min(lo, p.V), max(hi, p.V)
+			}
+		}
+	}
+	if math.IsInf(lo, 1) {
+		_, err := fmt.Fprintln(w, "(no finite values to draw)")
+		return err
+	}
+	if lo == hi {
+		pad := math.Max(math.Abs(lo)*0.1, 1)
+		lo, hi = lo-pad, hi+pad
+	}
+
+	rowValue := func(r int) float64 { return hi - (hi-lo)*float64(r)/float64(opt.Height-1) }
+	labels := make([]string, opt.Height)
+	labelW := 0
+	for r := range labels {
+		if r == 0 || r == opt.Height-1 || (r%4 == 0 && opt.Height-1-r >= 2) {
+			labels[r] = formatChartValue(rowValue(r))
+			labelW = max(labelW, len(labels[r]))
+		}
+	}
+	cols := min(max(opt.Width-labelW-2, 10), steps)
+
+	// grid[row][col] is the series index drawn there, -1 for none; stroke marks joins.
+	grid := make([][]int, opt.Height)
+	stroke := make([][]bool, opt.Height)
+	for r := range grid {
+		grid[r] = make([]int, cols)
+		stroke[r] = make([]bool, cols)
+		for c := range grid[r] {
+			grid[r][c] = -1
+		}
+	}
+	for i, s := range series {
+		ys := columnRows(s, start, step, steps, cols, lo, hi, opt.Height)
+		prev := -1
+		for c, y := range ys {
+			if y < 0 {
+				prev = -1
+				continue
+			}
+			grid[y][c], stroke[y][c] = i, false
+			if prev >= 0 {
+				for r := min(prev, y) + 1; r < max(prev, y); r++ {
+					if grid[r][c] < 0 {
+						grid[r][c], stroke[r][c] = i, true
+					}
+				}
+			}
+			prev = y
+		}
+	}
+
+	paint := func(i int, glyph string) string {
+		if !opt.Color {
+			return glyph
+		}
+		return fmt.Sprintf("\x1b[%dm%s\x1b[0m", chartColors[i%len(chartColors)], glyph)
+	}
+	var b strings.Builder
+	for r := range grid {
+		axis := g.vertical
+		if labels[r] != "" {
+			axis = g.tick
+		}
+		var row strings.Builder
+		fmt.Fprintf(&row, "%*s %s", labelW, labels[r], axis)
+		for c, i := range grid[r] {
+			switch {
+			case i < 0:
+				row.WriteByte(' ')
+			case stroke[r][c]:
+				row.WriteString(paint(i, g.vertical))
+			default:
+				row.WriteString(paint(i, g.markers[i%len(g.markers)]))
+			}
+		}
+		b.WriteString(strings.TrimRight(row.String(), " "))
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "%*s %s%s\n", labelW, "", g.corner, strings.Repeat(g.axis, cols))
+	if ts := timeAxis(start, end, cols); ts != "" {
+		b.WriteString(strings.Repeat(" ", labelW+2))
+		b.WriteString(ts)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	// Legend: marker, last and peak value, series name.
+	lastW, peakW := 0, 0
+	stats := make([][2]string, len(series))
+	for i, s := range series {
+		last, peak := seriesStats(s)
+		stats[i] = [2]string{formatChartValue(last), formatChartValue(peak)}
+		lastW, peakW = max(lastW, len(stats[i][0])), max(peakW, len(stats[i][1]))
+	}
+	for i, s := range series {
+		fmt.Fprintf(&b, "%s last %-*s  max %-*s  %s\n", paint(i, g.markers[i%len(g.markers)]),
+			lastW, stats[i][0], peakW, stats[i][1], formatSeriesName(s.Metric))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// columnRows maps s onto cols columns, each covering an equal share of the steps, and returns
+// the row (0 at the top) of the column's peak, or -1 where the column has no finite value.
+func columnRows(s rangeSeries, start time.Time, step time.Duration, steps, cols int, lo, hi float64, height int) []int {
+	peaks := make([]float64, cols)
+	for c := range peaks {
+		peaks[c] = math.NaN()
+	}
+	for _, p := range s.Points {
+		if math.IsNaN(p.V) || math.IsInf(p.V, 0) {
+			continue
+		}
+		k := int(math.Round(float64(p.T.Sub(start)) / float64(step)))
+		if k < 0 || k >= steps {
+			continue
+		}
+		c := k * cols / steps
+		if math.IsNaN(peaks[c]) || p.V > peaks[c] {
+			peaks[c] = p.V
+		}
+	}
+	rows := make([]int, cols)
+	for c, v := range peaks {
+		if math.IsNaN(v) {
+			rows[c] = -1
+			continue
+		}
+		y := int(math.Round((v - lo) / (hi - lo) * float64(height-1)))
+		rows[c] = height - 1 - min(max(y, 0), height-1)
+	}
+	return rows
+}
+
+// timeAxis labels the start, middle and end of a cols wide x axis where they fit.
+func timeAxis(start, end time.Time, cols int) string {
+	layout := "15:04"
+	switch d := end.Sub(start); {
+	case d > 24*time.Hour:
+		layout = "01-02 15:04"
+	case d <= 10*time.Minute:
+		layout = "15:04:05"
+	}
+	line := []rune(strings.Repeat(" ", cols))
+	put := func(at int, s string) bool {
+		n := utf8.RuneCountInString(s)
+		if at < 0 || at+n > cols {
+			return false
+		}
+		// Keep a blank column between labels.
+		for i := max(at-1, 0); i < min(at+n+1, cols); i++ {
+			if line[i] != ' ' {
+				return false
+			}
+		}
+		copy(line[at:], []rune(s))
+		return true
+	}
+	first, last := start.Format(layout), end.Format(layout)
+	put(0, first)
+	if put(cols-len(last), last) {
+		mid := start.Add(end.Sub(start) / 2).Format(layout)
+		if cols >= len(first)+len(mid)+len(last)+4 {
+			put((cols-len(mid))/2, mid)
+		}
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+// formatChartValue formats v compactly with an SI suffix for axis labels and the legend.
+func formatChartValue(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	a := math.Abs(v)
+	for _, u := range []struct {
+		div    float64
+		suffix string
+	}{{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+		if a >= u.div {
+			return trimFloat(strconv.FormatFloat(v/u.div, 'f', 2, 64)) + u.suffix
+		}
+	}
+	if a >= 1 || a == 0 {
+		return trimFloat(strconv.FormatFloat(v, 'f', 2, 64))
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+func trimFloat(s string) string {
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const matrixBody = `{"status":"success","data":{"resultType":"matrix","result":[
+ {"metric":{"job":"api"},"values":[[1700000000,"1"],[1700000030,"4"],[1700000060,"NaN"],[1700000090,"2"]]},
+ {"metric":{"__name__":"up","job":"db"},"values":[[1700000000,"0"],[1700000090,"0"]]},
+ {"metric":{"job":"idle"},"values":[[1700000000,"NaN"]]}
+]}}`
+
+func TestQueryRange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" || r.FormValue("step") != "30" || r.FormValue("start") != "1700000000" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(matrixBody))
+	}))
+	defer srv.Close()
+
+	start := time.Unix(1700000000, 0)
+	end := start.Add(90 * time.Second)
+	pc, err := newPromClient(srv.URL, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := promQueryRange(context.Background(), pc, "x", start, end, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := matrixSeries(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series = capSeries(series, 2)
+	if len(series) != 2 || series[0].Metric["job"] != "api" || series[1].Metric["job"] != "db" {
+		t.Fatalf("capped series = %+v", series)
+	}
+
+	var buf bytes.Buffer
+	if err := renderRangeChart(&buf, series, start, end, 30*time.Second, chartOptions{Width: 40, Height: 5, ASCII: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"4 + *\n" +
+		"  | |\n" +
+		"  | | *\n" +
+		"  |*\n" +
+		"0 ++  +\n" +
+		"  +----\n"
+	if got := buf.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("chart:\n%s\nwant prefix:\n%s", got, want)
+	}
+	if got := buf.String(); !strings.Contains(got, `* last 2  max 4  {job="api"}`) || !strings.Contains(got, `+ last 0  max 0  up{job="db"}`) {
+		t.Errorf("legend:\n%s", got)
+	}
+
+	buf.Reset()
+	if err := writeRangeCSV(&buf, series); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "time,\"{job=\"\"api\"\"}\",\"up{job=\"\"db\"\"}\"\n" +
+		"2023-11-14T22:13:20Z,1,0\n" +
+		"2023-11-14T22:13:50Z,4,\n" +
+		"2023-11-14T22:14:20Z,,\n" +
+		"2023-11-14T22:14:50Z,2,0\n"
+	if buf.String() != wantCSV {
+		t.Errorf("csv:\n%s\nwant:\n%s", buf.String(), wantCSV)
+	}
+
+	buf.Reset()
+	if err := writeRangeJSON(&buf, series[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"value": null,`+"\n"+`        "raw": "NaN"`) {
+		t.Errorf("json NaN point:\n%s", buf.String())
+	}
+}
+
+func TestFormatChartValue(t *testing.T) {
+	for v, want := range map[float64]string{0: "0", 1.5: "1.5", 0.00123: "0.00123", 1234: "1.23k", 2.5e9: "2.5G", -3e6: "-3M"} {
+		if got := formatChartValue(v); got != want {
+			t.Errorf("formatChartValue(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// serviceAccountTokenFile is sent as bearer token to a discovered https Prometheus when nexa runs
+// in a pod and no other credentials are given, which is what kube-rbac-proxy expects.
+var serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// promStatus is the envelope of every Prometheus API response.
+type promStatus struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (s *promStatus) envelope() *promStatus { return s }
+
+// promClient sends requests to one Prometheus. baseURL may carry a path prefix (the service
+// proxy's), API paths are appended to it. Close releases a port-forward.
+type promClient struct {
+	baseURL *url.URL
+	http    *http.Client
+	close   func()
+}
+
+func (c *promClient) apiURL(apiPath string) *url.URL {
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + apiPath
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+	return &u
+}
+
+func (c *promClient) Close() {
+	if c != nil && c.close != nil {
+		c.close()
+	}
+}
+
+// get calls apiPath and decodes the response into out. what names the call in errors, e.g.
+// "query" gives "prometheus query failed: ...".
+func (c *promClient) get(ctx context.Context, apiPath string, params url.Values, what string, out interface{ envelope() *promStatus }) error {
+	u := c.apiURL(apiPath)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Bad queries come back as 400/422 with the usual error envelope.
+		var st promStatus
+		if json.Unmarshal(body, &st) == nil && st.Error != "" {
+			return fmt.Errorf("prometheus %s failed: %s (%s)", what, st.Error, st.ErrorType)
+		}
+		return fmt.Errorf("prometheus http %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return err
+	}
+	if st := out.envelope(); st.Status != "success" {
+		if st.Error != "" {
+			return fmt.Errorf("prometheus %s failed: %s (%s)", what, st.Error, st.ErrorType)
+		}
+		return fmt.Errorf("prometheus %s failed: status=%s", what, st.Status)
+	}
+	return nil
+}
+
+func newPromClient(address string, hc *http.Client) (*promClient, error) {
+	u, err := url.Parse(strings.TrimSpace(address))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Prometheus address %q (want e.g. http://10.247.96.18:9090)", address)
+	}
+	// If caller passes something like http://host:port/targets (proxy), drop path and point to API.
+	u.Path = ""
+	u.RawPath = ""
+	return &promClient{baseURL: u, http: hc}, nil
+}
@@ -0,0 +1,107 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nexa/pkg/net/httpclient"
+)
+
+func TestPromClientAuthTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Scope-OrgID") != "tenant-1" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.FormValue("query") == "bad(" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := promConnFlags{address: srv.URL, transport: transportDirect, scheme: "http"}
+	f.auth = httpclient.Config{BearerTokenFile: tokenFile, CAFile: caFile, Headers: []string{"X-Scope-OrgID: tenant-1"}}
+	pc, err := f.connect(context.Background(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := promQuery(context.Background(), pc, "up"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := promQuery(context.Background(), pc, "bad("); err == nil || err.Error() != "prometheus query failed: parse error (bad_data)" {
+		t.Errorf("bad query error = %v", err)
+	}
+
+	// Without the CA the self-signed certificate is rejected.
+	f.auth.CAFile = ""
+	if pc, err = f.connect(context.Background(), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := promQuery(context.Background(), pc, "up"); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("untrusted certificate accepted: %v", err)
+	}
+}
+
+func TestServiceAccountTokenFallback(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("sa-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := serviceAccountTokenFile
+	serviceAccountTokenFile = tokenFile
+	defer func() { serviceAccountTokenFile = old }()
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+
+	var got string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Header.Get("Authorization") })
+	httpSrv, httpsSrv := httptest.NewServer(handler), httptest.NewTLSServer(handler)
+	defer httpSrv.Close()
+	defer httpsSrv.Close()
+
+	authorization := func(f promConnFlags) string {
+		srv := httpSrv
+		if f.scheme == "https" {
+			srv = httpsSrv
+			f.auth.InsecureSkipVerify = true
+		}
+		pc, err := f.discoveredClient(srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = ""
+		resp, err := pc.http.Get(pc.apiURL("/api/v1/status/buildinfo").String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return got
+	}
+	if got := authorization(promConnFlags{scheme: "https"}); got != "Bearer sa-token" {
+		t.Errorf("https Authorization = %q", got)
+	}
+	if got := authorization(promConnFlags{scheme: "http"}); got != "" {
+		t.Errorf("ServiceAccount token sent over http: %q", got)
+	}
+	if got := authorization(promConnFlags{scheme: "https", auth: httpclient.Config{BasicAuth: "admin:pw"}}); got != "Basic YWRtaW46cHc=" {
+		t.Errorf("explicit basic auth = %q", got)
+	}
+}
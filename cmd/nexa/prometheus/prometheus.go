@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net"
-	"net/http"
 	"net/url"
 	"os"
 	"sort"
@@ -34,10 +32,7 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 	var (
 		namespace  string
 		query      string
-		address    string
-		service    string
-		selector   string
-		portName   string
+		conn       promConnFlags
 		kubeconfig string
 		timeout    time.Duration
 		limit      int
@@ -55,7 +50,7 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 	queryCmd := &cobra.Command{
 		Use:          "query [promql]",
 		Short:        "query Prometheus instant query API",
-		Example:      "nexa prometheus query -n monitoring 'up==1'\n  nexa prometheus query -n monitoring --query 'up==1'",
+		Example:      "nexa prometheus query -n monitoring 'up==1'\n  nexa prometheus query -n monitoring --query 'up==1'\n  nexa prometheus query -n monitoring --transport proxy 'up==0'",
 		SilenceUsage: true,
 		Args:         cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -75,24 +70,17 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 				return fmt.Errorf("empty query")
 			}
 
-			baseURL := address
-			if baseURL == "" {
-				cli, err := newKubeClient(kubeconfig)
-				if err != nil {
-					return err
-				}
-				host, port, err := discoverPrometheus(ctx, cli, namespace, service, selector, portName)
-				if err != nil {
-					return err
-				}
-				baseURL = fmt.Sprintf("http://%s", net.JoinHostPort(host, port))
+			pc, err := conn.connect(ctx, kubeconfig, namespace)
+			if err != nil {
+				return err
 			}
+			defer pc.Close()
 
-			res, err := promQuery(ctx, baseURL, q)
+			res, err := promQuery(ctx, pc, q)
 			if err != nil {
 				return err
 			}
-			return renderPromResult(ctx, os.Stdout, baseURL, q, res, limit)
+			return renderPromResult(ctx, os.Stdout, pc, q, res, limit)
 		},
 	}
 
@@ -100,14 +88,15 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 	cmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "path to kubeconfig (optional; defaults to in-cluster or ~/.kube/config)")
 
 	queryCmd.Flags().StringVar(&query, "query", "up==1", "PromQL query to execute (instant query)")
-	queryCmd.Flags().StringVar(&address, "address", "", "Prometheus base URL, e.g. http://10.247.96.18:9090 (skip discovery)")
-	queryCmd.Flags().StringVar(&service, "service", "", "Prometheus Service name (optional, prefer if known)")
-	queryCmd.Flags().StringVar(&selector, "selector", "", "Service label selector to find Prometheus, e.g. app=prometheus")
-	queryCmd.Flags().StringVar(&portName, "port-name", "", "Service port name to use (optional)")
+	conn.register(queryCmd.Flags(), "Prometheus base URL, e.g. http://10.247.96.18:9090 (skip discovery)")
 	queryCmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "overall timeout for discovery and query")
 	queryCmd.Flags().IntVar(&limit, "limit", 2000, "max output rows (protects console)")
 
 	cmd.AddCommand(queryCmd)
+	cmd.AddCommand(queryRangeCmd(&namespace, &kubeconfig, &conn))
+	cmd.AddCommand(alertsCmd(&namespace, &kubeconfig, &conn))
+	cmd.AddCommand(rulesCmd(&namespace, &kubeconfig, &conn))
+	cmd.AddCommand(whyCmd(&namespace, &kubeconfig, &conn))
 
 	var allNamespaces bool
 	monitorCmd := &cobra.Command{
@@ -174,23 +163,20 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
 			defer cancel()
 
-			cli, err := newKubeClient(kubeconfig)
+			pc, err := conn.connect(ctx, kubeconfig, namespace)
 			if err != nil {
 				return err
 			}
-			host, port, err := discoverPrometheus(ctx, cli, namespace, service, selector, portName)
-			if err != nil {
-				return err
-			}
-			baseURL := fmt.Sprintf("http://%s", net.JoinHostPort(host, port))
+			defer pc.Close()
 
-			names, err := promMetricNames(ctx, baseURL)
+			names, err := promMetricNames(ctx, pc)
 			if err != nil {
 				return err
 			}
 			return renderMetricNames(os.Stdout, names, limit)
 		},
 	}
+	conn.register(listCmd.Flags(), "Prometheus base URL, e.g. http://10.247.96.18:9090 (skip discovery)")
 	cmd.AddCommand(listCmd)
 
 	targetsCmd := &cobra.Command{
@@ -202,20 +188,13 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
 			defer cancel()
 
-			baseURL := address
-			if baseURL == "" {
-				cli, err := newKubeClient(kubeconfig)
-				if err != nil {
-					return err
-				}
-				host, port, err := discoverPrometheus(ctx, cli, namespace, service, selector, portName)
-				if err != nil {
-					return err
-				}
-				baseURL = fmt.Sprintf("http://%s", net.JoinHostPort(host, port))
+			pc, err := conn.connect(ctx, kubeconfig, namespace)
+			if err != nil {
+				return err
 			}
+			defer pc.Close()
 
-			active, dropped, err := promTargetsRows(ctx, baseURL)
+			active, dropped, err := promTargetsRows(ctx, pc)
 			if err != nil {
 				return err
 			}
@@ -231,7 +210,7 @@ func Cmd(cctx *ctx.Ctx) []*cobra.Command {
 		},
 	}
 	// Allow direct proxy testing (e.g. http://.../targets) without discovery.
-	targetsCmd.Flags().StringVar(&address, "address", "", "Prometheus base URL or /targets page URL (skip discovery)")
+	conn.register(targetsCmd.Flags(), "Prometheus base URL or /targets page URL (skip discovery)")
 	cmd.AddCommand(targetsCmd)
 
 	return []*cobra.Command{cmd}
@@ -392,53 +371,14 @@ func renderMonitorTable(w io.Writer, title string, rows []monitorRow, limit int)
 	return nil
 }
 
-func discoverPrometheus(ctx context.Context, cli *kubernetes.Clientset, namespace, serviceName, selector, portName string) (host string, port string, err error) {
+func discoverPrometheus(ctx context.Context, cli kubernetes.Interface, namespace, serviceName, selector, portName string) (host string, port string, err error) {
 	if namespace == "" {
 		namespace = "default"
 	}
 
-	var svc *corev1.Service
-	if serviceName != "" {
-		s, gerr := cli.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
-		if gerr != nil {
-			return "", "", gerr
-		}
-		svc = s
-	} else {
-		sel := strings.TrimSpace(selector)
-		if sel == "" {
-			// Common labels used by kube-prometheus-stack / prometheus-operator / standalone deployments.
-			// We'll try a few selectors in order, stopping at the first match.
-			candidates := []string{
-				"app.kubernetes.io/name=prometheus",
-				"app=prometheus",
-				"app.kubernetes.io/component=prometheus",
-				"operated-prometheus=true",
-			}
-			for _, c := range candidates {
-				list, lerr := cli.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: c})
-				if lerr != nil {
-					continue
-				}
-				if len(list.Items) > 0 {
-					svc = &list.Items[0]
-					break
-				}
-			}
-		} else {
-			list, lerr := cli.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: sel})
-			if lerr != nil {
-				return "", "", lerr
-			}
-			if len(list.Items) == 0 {
-				return "", "", fmt.Errorf("no Service matched selector %q in namespace %q", sel, namespace)
-			}
-			svc = &list.Items[0]
-		}
-	}
-
-	if svc == nil {
-		return "", "", fmt.Errorf("unable to discover Prometheus Service in namespace %q (try --service or --selector or --address)", namespace)
+	svc, err := findPrometheusService(ctx, cli, namespace, serviceName, selector)
+	if err != nil {
+		return "", "", err
 	}
 
 	p, perr := pickServicePort(svc, portName)
@@ -472,6 +412,51 @@ func discoverPrometheus(ctx context.Context, cli *kubernetes.Clientset, namespac
 	return "", "", fmt.Errorf("found Service %q but could not resolve an address/port (no ClusterIP; endpoints empty)", svc.Name)
 }
 
+// findPrometheusService returns the Service named serviceName, else the first one matching selector,
+// else the first one matching the labels common Prometheus installations put on their Service.
+func findPrometheusService(ctx context.Context, cli kubernetes.Interface, namespace, serviceName, selector string) (*corev1.Service, error) {
+	if serviceName != "" {
+		return cli.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	}
+
+	sel := strings.TrimSpace(selector)
+	if sel != "" {
+		list, err := cli.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: sel})
+		if err != nil {
+			return nil, err
+		}
+		if len(list.Items) == 0 {
+			return nil, fmt.Errorf("no Service matched selector %q in namespace %q", sel, namespace)
+		}
+		return &list.Items[0], nil
+	}
+
+	// Common labels used by kube-prometheus-stack / prometheus-operator / standalone deployments.
+	// We'll try a few selectors in order, stopping at the first match.
+	candidates := []string{
+		"app.kubernetes.io/name=prometheus",
+		"app=prometheus",
+		"app.kubernetes.io/component=prometheus",
+		"operated-prometheus=true",
+	}
+	var svc *corev1.Service
+	for _, c := range candidates {
+		list, lerr := cli.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: c})
+		if lerr != nil {
+			continue
+		}
+		if len(list.Items) > 0 {
+			svc = &list.Items[0]
+			break
+		}
+	}
+
+	if svc == nil {
+		return nil, fmt.Errorf("unable to discover Prometheus Service in namespace %q (try --service or --selector or --address)", namespace)
+	}
+	return svc, nil
+}
+
 func pickServicePort(svc *corev1.Service, portName string) (corev1.ServicePort, error) {
 	if svc == nil {
 		return corev1.ServicePort{}, errors.New("nil service")
@@ -526,10 +511,8 @@ func pickEndpointPort(ports []corev1.EndpointPort, portName string, servicePort
 }
 
 type promAPIResponse struct {
-	Status    string `json:"status"`
-	ErrorType string `json:"errorType,omitempty"`
-	Error     string `json:"error,omitempty"`
-	Data      struct {
+	promStatus
+	Data struct {
 		ResultType string           `json:"resultType"`
 		Result     []promVectorItem `json:"result"`
 	} `json:"data"`
@@ -539,32 +522,18 @@ type promVectorItem struct {
 	Metric map[string]string `json:"metric"`
 	// value is [ <unix_time>, "<sample_value>" ]
 	Value []any `json:"value"`
+	// values is set instead for matrix results: [[ <unix_time>, "<sample_value>" ], ...]
+	Values [][]any `json:"values,omitempty"`
 }
 
 type promLabelValuesResponse struct {
-	Status    string   `json:"status"`
-	ErrorType string   `json:"errorType,omitempty"`
-	Error     string   `json:"error,omitempty"`
-	Data      []string `json:"data"`
-}
-
-func promAPIURL(baseURL string, apiPath string) (*url.URL, error) {
-	u, err := url.Parse(strings.TrimSpace(baseURL))
-	if err != nil {
-		return nil, err
-	}
-	// If caller passes something like http://host:port/targets (proxy), drop path and point to API.
-	u.Path = apiPath
-	u.RawQuery = ""
-	u.Fragment = ""
-	return u, nil
+	promStatus
+	Data []string `json:"data"`
 }
 
 type promTargetsResponse struct {
-	Status    string `json:"status"`
-	ErrorType string `json:"errorType,omitempty"`
-	Error     string `json:"error,omitempty"`
-	Data      struct {
+	promStatus
+	Data struct {
 		ActiveTargets []struct {
 			ScrapePool         string            `json:"scrapePool"`
 			ScrapeURL          string            `json:"scrapeUrl"`
@@ -583,81 +552,45 @@ type promTargetsResponse struct {
 	} `json:"data"`
 }
 
-func promQuery(ctx context.Context, baseURL string, q string) (*promAPIResponse, error) {
-	u, err := promAPIURL(baseURL, "/api/v1/query")
-	if err != nil {
-		return nil, err
-	}
-	qq := u.Query()
-	qq.Set("query", q)
-	u.RawQuery = qq.Encode()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
+func promQuery(ctx context.Context, pc *promClient, q string) (*promAPIResponse, error) {
+	return promQueryAPI(ctx, pc, "/api/v1/query", url.Values{"query": {q}})
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// promQueryRange evaluates q at every step between start and end; the result is a matrix.
+func promQueryRange(ctx context.Context, pc *promClient, q string, start, end time.Time, step time.Duration) (*promAPIResponse, error) {
+	return promQueryAPI(ctx, pc, "/api/v1/query_range", url.Values{
+		"query": {q},
+		"start": {formatPromTime(start)},
+		"end":   {formatPromTime(end)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	})
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("prometheus http %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
+func formatPromTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
 
+func promQueryAPI(ctx context.Context, pc *promClient, apiPath string, params url.Values) (*promAPIResponse, error) {
 	var out promAPIResponse
-	if err := json.Unmarshal(body, &out); err != nil {
+	if err := pc.get(ctx, apiPath, params, "query", &out); err != nil {
 		return nil, err
 	}
-	if out.Status != "success" {
-		if out.Error != "" {
-			return nil, fmt.Errorf("prometheus query failed: %s (%s)", out.Error, out.ErrorType)
-		}
-		return nil, fmt.Errorf("prometheus query failed: status=%s", out.Status)
-	}
 	return &out, nil
 }
 
-func promTargetsLastErrorByKey(ctx context.Context, baseURL string) (map[string]string, error) {
-	u, err := promAPIURL(baseURL, "/api/v1/targets")
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+func promTargets(ctx context.Context, pc *promClient) (*promTargetsResponse, error) {
+	var out promTargetsResponse
+	if err := pc.get(ctx, "/api/v1/targets", nil, "targets", &out); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return &out, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+func promTargetsLastErrorByKey(ctx context.Context, pc *promClient) (map[string]string, error) {
+	out, err := promTargets(ctx, pc)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("prometheus http %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
-
-	var out promTargetsResponse
-	if err := json.Unmarshal(body, &out); err != nil {
-		return nil, err
-	}
-	if out.Status != "success" {
-		if out.Error != "" {
-			return nil, fmt.Errorf("prometheus targets failed: %s (%s)", out.Error, out.ErrorType)
-		}
-		return nil, fmt.Errorf("prometheus targets failed: status=%s", out.Status)
-	}
 
 	m := make(map[string]string, len(out.Data.ActiveTargets))
 	for _, t := range out.Data.ActiveTargets {
@@ -691,40 +624,11 @@ type promTargetRow struct {
 	Discovered string
 }
 
-func promTargetsRows(ctx context.Context, baseURL string) (active []promTargetRow, dropped []string, err error) {
-	u, err := promAPIURL(baseURL, "/api/v1/targets")
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+func promTargetsRows(ctx context.Context, pc *promClient) (active []promTargetRow, dropped []string, err error) {
+	out, err := promTargets(ctx, pc)
 	if err != nil {
 		return nil, nil, err
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, nil, err
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, nil, fmt.Errorf("prometheus http %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
-
-	var out promTargetsResponse
-	if err := json.Unmarshal(body, &out); err != nil {
-		return nil, nil, err
-	}
-	if out.Status != "success" {
-		if out.Error != "" {
-			return nil, nil, fmt.Errorf("prometheus targets failed: %s (%s)", out.Error, out.ErrorType)
-		}
-		return nil, nil, fmt.Errorf("prometheus targets failed: status=%s", out.Status)
-	}
 
 	active = make([]promTargetRow, 0, len(out.Data.ActiveTargets))
 	for _, t := range out.Data.ActiveTargets {
@@ -804,45 +708,16 @@ func upTargetKey(labels map[string]string) string {
 	return strings.Join(parts, "|")
 }
 
-func promMetricNames(ctx context.Context, baseURL string) ([]string, error) {
-	u, err := promAPIURL(baseURL, "/api/v1/label/__name__/values")
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("prometheus http %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
-
+func promMetricNames(ctx context.Context, pc *promClient) ([]string, error) {
 	var out promLabelValuesResponse
-	if err := json.Unmarshal(body, &out); err != nil {
+	if err := pc.get(ctx, "/api/v1/label/__name__/values", nil, "label values", &out); err != nil {
 		return nil, err
 	}
-	if out.Status != "success" {
-		if out.Error != "" {
-			return nil, fmt.Errorf("prometheus label values failed: %s (%s)", out.Error, out.ErrorType)
-		}
-		return nil, fmt.Errorf("prometheus label values failed: status=%s", out.Status)
-	}
 	sort.Strings(out.Data)
 	return out.Data, nil
 }
 
-func renderPromResult(ctx context.Context, w io.Writer, baseURL string, query string, res *promAPIResponse, limit int) error {
+func renderPromResult(ctx context.Context, w io.Writer, pc *promClient, query string, res *promAPIResponse, limit int) error {
 	if res == nil {
 		return errors.New("nil response")
 	}
@@ -859,8 +734,8 @@ func renderPromResult(ctx context.Context, w io.Writer, baseURL string, query st
 
 	// When target disappears, `up` series can be absent (not 0). In that case, `up==0` returns empty,
 	// but Prometheus UI still shows scrape pools with 0 active targets. Provide a helpful fallback.
-	if len(res.Data.Result) == 0 && isUpEqualsZero(query) && ctx != nil && baseURL != "" {
-		if err := renderDownTargetsFallback(ctx, w, baseURL, limit); err == nil {
+	if len(res.Data.Result) == 0 && isUpEqualsZero(query) && ctx != nil && pc != nil {
+		if err := renderDownTargetsFallback(ctx, w, pc, limit); err == nil {
 			return nil
 		}
 		// if fallback fails, continue to render empty table like before
@@ -888,8 +763,8 @@ func renderPromResult(ctx context.Context, w io.Writer, baseURL string, query st
 				if fv >= 1 {
 					desc = "UP"
 				} else {
-					if lastErr == nil && ctx != nil && baseURL != "" {
-						m, _ := promTargetsLastErrorByKey(ctx, baseURL)
+					if lastErr == nil && ctx != nil && pc != nil {
+						m, _ := promTargetsLastErrorByKey(ctx, pc)
 						lastErr = m
 					}
 					if lastErr != nil {
@@ -922,8 +797,8 @@ func isUpEqualsZero(q string) bool {
 	return s == "up==0" || s == "up==0.0" || s == "up==false"
 }
 
-func renderDownTargetsFallback(ctx context.Context, w io.Writer, baseURL string, limit int) error {
-	active, dropped, err := promTargetsRows(ctx, baseURL)
+func renderDownTargetsFallback(ctx context.Context, w io.Writer, pc *promClient, limit int) error {
+	active, dropped, err := promTargetsRows(ctx, pc)
 	if err != nil {
 		return err
 	}
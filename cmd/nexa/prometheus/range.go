@@ -0,0 +1,332 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/timeutil"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Prometheus rejects range queries with more points per series than this.
+const maxRangePoints = 11000
+
+// rangeSeries is one series of a matrix result. Timestamps are on the start + k*step grid;
+// steps where the series had no sample are simply absent.
+type rangeSeries struct {
+	Metric map[string]string
+	Points []rangePoint
+}
+
+type rangePoint struct {
+	T time.Time
+	V float64
+}
+
+func queryRangeCmd(namespace, kubeconfig *string, conn *promConnFlags) *cobra.Command {
+	var (
+		timeout time.Duration
+		limit   int
+		start   string
+		end     string
+		step    time.Duration
+		output  string
+		width   int
+		height  int
+		ascii   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "query-range <promql>",
+		Short: "run a Prometheus range query and draw it as a terminal line chart",
+		Long: "Evaluate the query over a time range via /api/v1/query_range and draw one line per series,\n" +
+			"with a legend of their last and peak values. Steps without a sample (or with NaN) are left\n" +
+			"as gaps. When a column covers several steps it shows their peak, so short spikes stay visible.\n" +
+			"--limit caps the number of series, keeping those with the highest peaks.\n" +
+			"--start and --end take -DURATION, now, HH:MM[:SS], YYYY-MM-DD HH:MM[:SS], RFC 3339 or unix seconds.",
+		Example: "nexa prometheus query-range -n monitoring 'rate(node_network_receive_bytes_total[5m])' --start -1h --step 30s\n" +
+			"  nexa prometheus query-range --address http://10.247.96.18:9090 'up' --start '2024-05-01 09:00' --end '2024-05-01 11:00'\n" +
+			"  nexa prometheus query-range -n monitoring 'sum by (pod) (rate(container_cpu_usage_seconds_total[5m]))' -o csv > cpu.csv",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q := args[0]
+			if strings.TrimSpace(q) == "" {
+				return fmt.Errorf("empty query")
+			}
+			switch output {
+			case "chart", "csv", "json":
+			default:
+				return fmt.Errorf("unknown --output %q (want chart, csv or json)", output)
+			}
+			now := time.Now()
+			from, err := timeutil.ParseTime(start, now)
+			if err != nil {
+				return fmt.Errorf("--start: %w", err)
+			}
+			to, err := timeutil.ParseTime(end, now)
+			if err != nil {
+				return fmt.Errorf("--end: %w", err)
+			}
+			if !to.After(from) {
+				return fmt.Errorf("--end (%s) must be after --start (%s)", to.Format(time.RFC3339), from.Format(time.RFC3339))
+			}
+			if step <= 0 {
+				step = autoStep(to.Sub(from))
+			}
+			if n := to.Sub(from)/step + 1; n > maxRangePoints {
+				return fmt.Errorf("%s at --step %s is %d points per series; Prometheus allows at most %d, use a larger --step", to.Sub(from), step, n, maxRangePoints)
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			pc, err := conn.connect(ctx, *kubeconfig, *namespace)
+			if err != nil {
+				return err
+			}
+			defer pc.Close()
+
+			res, err := promQueryRange(ctx, pc, q, from, to, step)
+			if err != nil {
+				return err
+			}
+			series, err := matrixSeries(res)
+			if err != nil {
+				return err
+			}
+			total := len(series)
+			series = capSeries(series, limit)
+
+			switch output {
+			case "csv":
+				return writeRangeCSV(os.Stdout, series)
+			case "json":
+				return writeRangeJSON(os.Stdout, series)
+			}
+			if total == 0 {
+				fmt.Fprintf(os.Stdout, "no series returned for %s between %s and %s\n", q, from.Format(time.RFC3339), to.Format(time.RFC3339))
+				return nil
+			}
+			opt := chartOptions{Width: width, Height: height, ASCII: ascii}
+			if fd := int(os.Stdout.Fd()); term.IsTerminal(fd) {
+				opt.Color = true
+				if opt.Width <= 0 {
+					opt.Width, _, _ = term.GetSize(fd)
+				}
+			}
+			fmt.Fprintf(os.Stdout, "%s  (%s .. %s, step %s)\n\n", q, from.Format(time.RFC3339), to.Format(time.RFC3339), step)
+			if err := renderRangeChart(os.Stdout, series, from, to, step, opt); err != nil {
+				return err
+			}
+			if len(series) < total {
+				fmt.Fprintf(os.Stdout, "\n(showing %d of %d series with the highest peaks; use --limit)\n", len(series), total)
+			}
+			return nil
+		},
+	}
+	conn.register(cmd.Flags(), "Prometheus base URL, e.g. http://10.247.96.18:9090 (skip discovery)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "overall timeout for discovery and query")
+	cmd.Flags().IntVar(&limit, "limit", 10, "max series to draw or export (0 for all)")
+	cmd.Flags().StringVar(&start, "start", "-1h", "start of the range")
+	cmd.Flags().StringVar(&end, "end", "now", "end of the range")
+	cmd.Flags().DurationVar(&step, "step", 0, "query resolution (default: the range split into 240 steps)")
+	cmd.Flags().StringVarP(&output, "output", "o", "chart", "output format: chart|csv|json")
+	cmd.Flags().IntVar(&width, "width", 0, "chart width in columns (default: terminal width or 100)")
+	cmd.Flags().IntVar(&height, "height", 15, "chart height in rows")
+	cmd.Flags().BoolVar(&ascii, "ascii", false, "draw the chart with ASCII characters only")
+	return cmd
+}
+
+// autoStep splits d into about 240 steps, rounded up to whole seconds.
+func autoStep(d time.Duration) time.Duration {
+	step := (d / 240).Round(time.Second)
+	if step < d/240 {
+		step += time.Second
+	}
+	return max(step, time.Second)
+}
+
+// matrixSeries decodes the [ <unix_time>, "<sample_value>" ] pairs of a matrix result.
+// "NaN" and "+Inf" parse like any other value.
+func matrixSeries(res *promAPIResponse) ([]rangeSeries, error) {
+	if res == nil {
+		return nil, fmt.Errorf("nil response")
+	}
+	if res.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected resultType=%q for a range query (want matrix)", res.Data.ResultType)
+	}
+	out := make([]rangeSeries, 0, len(res.Data.Result))
+	for _, it := range res.Data.Result {
+		s := rangeSeries{Metric: it.Metric, Points: make([]rangePoint, 0, len(it.Values))}
+		for _, v := range it.Values {
+			if len(v) != 2 {
+				return nil, fmt.Errorf("series %s: malformed sample %v", formatSeriesName(it.Metric), v)
+			}
+			ts, ok := v[0].(float64)
+			str, ok2 := v[1].(string)
+			if !ok || !ok2 {
+				return nil, fmt.Errorf("series %s: malformed sample %v", formatSeriesName(it.Metric), v)
+			}
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				return nil, fmt.Errorf("series %s: %w", formatSeriesName(it.Metric), err)
+			}
+			s.Points = append(s.Points, rangePoint{T: time.UnixMilli(int64(math.Round(ts * 1000))), V: f})
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// capSeries orders series by their peak (highest first) and keeps the first limit of them.
+func capSeries(series []rangeSeries, limit int) []rangeSeries {
+	type ranked struct {
+		s    rangeSeries
+		name string
+		peak float64
+	}
+	rs := make([]ranked, len(series))
+	for i, s := range series {
+		_, peak := seriesStats(s)
+		rs[i] = ranked{s: s, name: formatSeriesName(s.Metric), peak: peak}
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		pi, pj := rs[i].peak, rs[j].peak
+		switch {
+		case math.IsNaN(pi) != math.IsNaN(pj):
+			return math.IsNaN(pj)
+		case pi != pj && !math.IsNaN(pi):
+			return pi > pj
+		}
+		return rs[i].name < rs[j].name
+	})
+	if limit > 0 && len(rs) > limit {
+		rs = rs[:limit]
+	}
+	out := make([]rangeSeries, len(rs))
+	for i := range rs {
+		out[i] = rs[i].s
+	}
+	return out
+}
+
+// seriesStats returns the last and the largest finite value of s, NaN if there is none.
+func seriesStats(s rangeSeries) (last, peak float64) {
+	last, peak = math.NaN(), math.NaN()
+	for _, p := range s.Points {
+		if math.IsNaN(p.V) || math.IsInf(p.V, 0) {
+			continue
+		}
+		last = p.V
+		if math.IsNaN(peak) || p.V > peak {
+			peak = p.V
+		}
+	}
+	return last, peak
+}
+
+// formatSeriesName renders a series like the Prometheus UI does: name{label="value",...}.
+func formatSeriesName(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(m["__name__"])
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(strconv.Quote(m[k]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// writeRangeCSV writes one row per timestamp and one column per series; steps where a series
+// has no sample (or NaN) are empty cells.
+func writeRangeCSV(w io.Writer, series []rangeSeries) error {
+	cw := csv.NewWriter(w)
+	header := []string{"time"}
+	rows := map[int64][]string{}
+	var times []int64
+	for i, s := range series {
+		header = append(header, formatSeriesName(s.Metric))
+		for _, p := range s.Points {
+			ms := p.T.UnixMilli()
+			row, ok := rows[ms]
+			if !ok {
+				row = make([]string, len(series))
+				rows[ms] = row
+				times = append(times, ms)
+			}
+			if !math.IsNaN(p.V) {
+				row[i] = strconv.FormatFloat(p.V, 'g', -1, 64)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, ms := range times {
+		rec := append([]string{time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)}, rows[ms]...)
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type rangeSeriesJSON struct {
+	Metric map[string]string `json:"metric"`
+	Points []rangePointJSON  `json:"points"`
+}
+
+type rangePointJSON struct {
+	Time  time.Time `json:"time"`
+	Value *float64  `json:"value"`
+	// Raw keeps values JSON numbers cannot express: "NaN", "+Inf" and "-Inf".
+	Raw string `json:"raw,omitempty"`
+}
+
+func writeRangeJSON(w io.Writer, series []rangeSeries) error {
+	out := make([]rangeSeriesJSON, 0, len(series))
+	for _, s := range series {
+		js := rangeSeriesJSON{Metric: s.Metric, Points: make([]rangePointJSON, 0, len(s.Points))}
+		if js.Metric == nil {
+			js.Metric = map[string]string{}
+		}
+		for _, p := range s.Points {
+			pj := rangePointJSON{Time: p.T.UTC()}
+			if math.IsNaN(p.V) || math.IsInf(p.V, 0) {
+				pj.Raw = strconv.FormatFloat(p.V, 'g', -1, 64)
+			} else {
+				v := p.V
+				pj.Value = &v
+			}
+			js.Points = append(js.Points, pj)
+		}
+		out = append(out, js)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,486 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var prometheusRuleGVR = schema.GroupVersionResource{
+	Group:    "monitoring.coreos.com",
+	Version:  "v1",
+	Resource: "prometheusrules",
+}
+
+type promAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    time.Time         `json:"activeAt"`
+	Value       string            `json:"value"`
+}
+
+type promAlertsResponse struct {
+	promStatus
+	Data struct {
+		Alerts []promAlert `json:"alerts"`
+	} `json:"data"`
+}
+
+type promRule struct {
+	Name           string    `json:"name"`
+	Query          string    `json:"query"`
+	Type           string    `json:"type"`
+	Health         string    `json:"health"`
+	LastError      string    `json:"lastError"`
+	State          string    `json:"state"`
+	EvaluationTime float64   `json:"evaluationTime"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+}
+
+type promRuleGroup struct {
+	Name           string     `json:"name"`
+	File           string     `json:"file"`
+	Rules          []promRule `json:"rules"`
+	Interval       float64    `json:"interval"`
+	EvaluationTime float64    `json:"evaluationTime"`
+	LastEvaluation time.Time  `json:"lastEvaluation"`
+}
+
+type promRulesResponse struct {
+	promStatus
+	Data struct {
+		Groups []promRuleGroup `json:"groups"`
+	} `json:"data"`
+}
+
+func promAlerts(ctx context.Context, pc *promClient) ([]promAlert, error) {
+	var out promAlertsResponse
+	if err := pc.get(ctx, "/api/v1/alerts", nil, "alerts", &out); err != nil {
+		return nil, err
+	}
+	return out.Data.Alerts, nil
+}
+
+func promRuleGroups(ctx context.Context, pc *promClient) ([]promRuleGroup, error) {
+	var out promRulesResponse
+	if err := pc.get(ctx, "/api/v1/rules", nil, "rules", &out); err != nil {
+		return nil, err
+	}
+	return out.Data.Groups, nil
+}
+
+func alertsCmd(namespace, kubeconfig *string, conn *promConnFlags) *cobra.Command {
+	var (
+		timeout time.Duration
+		limit   int
+		state   string
+	)
+	cmd := &cobra.Command{
+		Use:          "alerts",
+		Short:        "list firing and pending alerts (like /alerts page)",
+		Example:      "nexa prometheus alerts -n monitoring\n  nexa prometheus alerts -n monitoring --state firing --transport proxy",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch state {
+			case "", "firing", "pending":
+			default:
+				return fmt.Errorf("unknown --state %q (want firing or pending)", state)
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			pc, err := conn.connect(ctx, *kubeconfig, *namespace)
+			if err != nil {
+				return err
+			}
+			defer pc.Close()
+
+			alerts, err := promAlerts(ctx, pc)
+			if err != nil {
+				return err
+			}
+			if state != "" {
+				filtered := alerts[:0]
+				for _, a := range alerts {
+					if a.State == state {
+						filtered = append(filtered, a)
+					}
+				}
+				alerts = filtered
+			}
+			return renderAlerts(os.Stdout, alerts, time.Now(), limit)
+		},
+	}
+	conn.register(cmd.Flags(), "Prometheus base URL, e.g. http://10.247.96.18:9090 (skip discovery)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "overall timeout for discovery and query")
+	cmd.Flags().IntVar(&limit, "limit", 2000, "max output rows (protects console)")
+	cmd.Flags().StringVar(&state, "state", "", "only show alerts in this state: firing or pending")
+	return cmd
+}
+
+func rulesCmd(namespace, kubeconfig *string, conn *promConnFlags) *cobra.Command {
+	var (
+		timeout       time.Duration
+		limit         int
+		allNamespaces bool
+	)
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "show rule groups with health and evaluation time, and PrometheusRules that were never loaded",
+		Long: "List the rule groups Prometheus evaluates (like /rules page) with their health, last error and\n" +
+			"evaluation time, followed by the rules that failed their last evaluation.\n" +
+			"PrometheusRule resources (prometheus-operator) in the namespace, or all namespaces with -A, are\n" +
+			"matched against the loaded rule files. A PrometheusRule that is not loaded usually means the\n" +
+			"Prometheus ruleSelector or ruleNamespaceSelector does not select it.",
+		Example:      "nexa prometheus rules -n monitoring\n  nexa prometheus rules -n monitoring -A --transport proxy",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			pc, err := conn.connect(ctx, *kubeconfig, *namespace)
+			if err != nil {
+				return err
+			}
+			defer pc.Close()
+
+			groups, err := promRuleGroups(ctx, pc)
+			if err != nil {
+				return err
+			}
+			if err := renderRuleGroups(os.Stdout, groups, limit); err != nil {
+				return err
+			}
+
+			ns := *namespace
+			if allNamespaces {
+				ns = ""
+			}
+			crs, err := listPrometheusRules(ctx, *kubeconfig, ns)
+			if err != nil {
+				fmt.Fprintf(os.Stdout, "\n(PrometheusRule check skipped: %v)\n", err)
+				return nil
+			}
+			return renderRuleCRs(os.Stdout, matchPrometheusRules(crs, groups), limit)
+		},
+	}
+	conn.register(cmd.Flags(), "Prometheus base URL, e.g. http://10.247.96.18:9090 (skip discovery)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "overall timeout for discovery and query")
+	cmd.Flags().IntVar(&limit, "limit", 2000, "max output rows (protects console)")
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "check PrometheusRules across all namespaces")
+	return cmd
+}
+
+func listPrometheusRules(ctx context.Context, kubeconfig, namespace string) ([]unstructured.Unstructured, error) {
+	cfg, err := newKubeRESTConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return listUnstructured(ctx, dyn, namespace, prometheusRuleGVR)
+}
+
+func renderAlerts(w io.Writer, alerts []promAlert, now time.Time, limit int) error {
+	if limit <= 0 {
+		limit = 2000
+	}
+	stateRank := map[string]int{"firing": 0, "pending": 1}
+	severityRank := map[string]int{"critical": 0, "error": 1, "warning": 2, "info": 3}
+	rank := func(m map[string]int, k string) int {
+		if r, ok := m[strings.ToLower(k)]; ok {
+			return r
+		}
+		return len(m)
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if ra, rb := rank(stateRank, a.State), rank(stateRank, b.State); ra != rb {
+			return ra < rb
+		}
+		if ra, rb := rank(severityRank, a.Labels["severity"]), rank(severityRank, b.Labels["severity"]); ra != rb {
+			return ra < rb
+		}
+		if !a.ActiveAt.Equal(b.ActiveAt) {
+			return a.ActiveAt.Before(b.ActiveAt)
+		}
+		return a.Labels["alertname"] < b.Labels["alertname"]
+	})
+
+	counts := map[string]int{}
+	for _, a := range alerts {
+		counts[a.State]++
+	}
+	fmt.Fprintf(w, "Alerts: firing=%d, pending=%d\n", counts["firing"], counts["pending"])
+	t := tablewriter.NewWriter(w)
+	t.Header([]string{"Alert", "State", "Severity", "ActiveFor", "Value", "Labels", "Annotations"})
+	printed := 0
+	for _, a := range alerts {
+		if printed >= limit {
+			break
+		}
+		activeFor := ""
+		if !a.ActiveAt.IsZero() {
+			activeFor = now.Sub(a.ActiveAt).Round(time.Second).String()
+		}
+		_ = t.Append([]string{
+			a.Labels["alertname"],
+			a.State,
+			a.Labels["severity"],
+			activeFor,
+			a.Value,
+			formatMap(a.Labels, []string{"alertname", "severity"}),
+			formatAnnotations(a.Annotations),
+		})
+		printed++
+	}
+	if err := t.Render(); err != nil {
+		return err
+	}
+	if len(alerts) > printed {
+		fmt.Fprintf(w, "(truncated to %d rows; use --limit)\n", printed)
+	}
+	return nil
+}
+
+// formatAnnotations puts summary and description first, one annotation per line.
+func formatAnnotations(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	first := map[string]int{"summary": 0, "description": 1, "message": 2}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, oki := first[keys[i]]
+		rj, okj := first[keys[j]]
+		switch {
+		case oki && okj:
+			return ri < rj
+		case oki != okj:
+			return oki
+		}
+		return keys[i] < keys[j]
+	})
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+": "+strings.TrimSpace(m[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderRuleGroups(w io.Writer, groups []promRuleGroup, limit int) error {
+	if limit <= 0 {
+		limit = 2000
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].File == groups[j].File {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].File < groups[j].File
+	})
+
+	type unhealthy struct {
+		group string
+		rule  promRule
+	}
+	var bad []unhealthy
+	total := 0
+	fmt.Fprintf(w, "Rule groups: %d\n", len(groups))
+	t := tablewriter.NewWriter(w)
+	t.Header([]string{"Group", "File", "Alerting", "Recording", "Health", "EvalTime", "Interval", "LastEval"})
+	printed := 0
+	for _, g := range groups {
+		alerting, recording, errs := 0, 0, 0
+		for _, r := range g.Rules {
+			if r.Type == "alerting" {
+				alerting++
+			} else {
+				recording++
+			}
+			if r.Health != "" && r.Health != "ok" {
+				errs++
+				bad = append(bad, unhealthy{group: g.Name, rule: r})
+			}
+		}
+		total += len(g.Rules)
+		health := "ok"
+		if errs > 0 {
+			health = fmt.Sprintf("%d unhealthy", errs)
+		}
+		// A group that takes longer than its interval to evaluate skips evaluations.
+		if g.Interval > 0 && g.EvaluationTime > g.Interval {
+			health += ", SLOW"
+		}
+		if printed >= limit {
+			continue
+		}
+		_ = t.Append([]string{
+			g.Name,
+			path.Base(g.File),
+			strconv.Itoa(alerting),
+			strconv.Itoa(recording),
+			health,
+			formatSeconds(g.EvaluationTime),
+			formatSeconds(g.Interval),
+			formatEvalTime(g.LastEvaluation),
+		})
+		printed++
+	}
+	if err := t.Render(); err != nil {
+		return err
+	}
+	if len(groups) > printed {
+		fmt.Fprintf(w, "(truncated to %d rows; use --limit)\n", printed)
+	}
+	fmt.Fprintf(w, "Total rules: %d, unhealthy: %d\n", total, len(bad))
+
+	if len(bad) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Unhealthy rules (%d)\n", len(bad))
+	tb := tablewriter.NewWriter(w)
+	tb.Header([]string{"Group", "Rule", "Type", "Health", "LastError"})
+	printed = 0
+	for _, b := range bad {
+		if printed >= limit {
+			break
+		}
+		_ = tb.Append([]string{b.group, b.rule.Name, b.rule.Type, b.rule.Health, b.rule.LastError})
+		printed++
+	}
+	if err := tb.Render(); err != nil {
+		return err
+	}
+	if len(bad) > printed {
+		fmt.Fprintf(w, "(truncated to %d rows; use --limit)\n", printed)
+	}
+	return nil
+}
+
+func formatSeconds(s float64) string {
+	if s <= 0 {
+		return ""
+	}
+	d := time.Duration(s * float64(time.Second))
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.String()
+}
+
+func formatEvalTime(t time.Time) string {
+	if t.IsZero() || t.Year() <= 1 {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
+
+type ruleCRRow struct {
+	Namespace string
+	Name      string
+	Groups    int
+	Loaded    bool
+}
+
+// matchPrometheusRules reports for each PrometheusRule whether Prometheus loaded it. The operator
+// writes each one to a rule file named <namespace>-<name>-<uid>.yaml (<namespace>-<name>.yaml
+// before v0.50), so the CR is loaded when a rule group comes from that file.
+func matchPrometheusRules(crs []unstructured.Unstructured, groups []promRuleGroup) []ruleCRRow {
+	files := map[string]struct{}{}
+	for _, g := range groups {
+		files[path.Base(g.File)] = struct{}{}
+	}
+	rows := make([]ruleCRRow, 0, len(crs))
+	for _, cr := range crs {
+		specGroups, _, _ := unstructured.NestedSlice(cr.Object, "spec", "groups")
+		prefix := cr.GetNamespace() + "-" + cr.GetName()
+		_, loaded := files[prefix+"-"+string(cr.GetUID())+".yaml"]
+		if _, ok := files[prefix+".yaml"]; ok {
+			loaded = true
+		}
+		rows = append(rows, ruleCRRow{
+			Namespace: cr.GetNamespace(),
+			Name:      cr.GetName(),
+			Groups:    len(specGroups),
+			Loaded:    loaded,
+		})
+	}
+	missing := func(r ruleCRRow) bool { return !r.Loaded && r.Groups > 0 }
+	sort.Slice(rows, func(i, j int) bool {
+		if missing(rows[i]) != missing(rows[j]) {
+			return missing(rows[i])
+		}
+		if rows[i].Namespace == rows[j].Namespace {
+			return rows[i].Name < rows[j].Name
+		}
+		return rows[i].Namespace < rows[j].Namespace
+	})
+	return rows
+}
+
+func renderRuleCRs(w io.Writer, rows []ruleCRRow, limit int) error {
+	if limit <= 0 {
+		limit = 2000
+	}
+	notLoaded := 0
+	for _, r := range rows {
+		// A PrometheusRule without groups produces no rule file.
+		if !r.Loaded && r.Groups > 0 {
+			notLoaded++
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "PrometheusRules: %d, not loaded: %d\n", len(rows), notLoaded)
+	if len(rows) == 0 {
+		return nil
+	}
+	t := tablewriter.NewWriter(w)
+	t.Header([]string{"Namespace", "Name", "Groups", "State"})
+	printed := 0
+	for _, r := range rows {
+		if printed >= limit {
+			break
+		}
+		state := "loaded"
+		switch {
+		case r.Groups == 0:
+			state = "no groups"
+		case !r.Loaded:
+			state = "NOT LOADED"
+		}
+		_ = t.Append([]string{r.Namespace, r.Name, strconv.Itoa(r.Groups), state})
+		printed++
+	}
+	if err := t.Render(); err != nil {
+		return err
+	}
+	if len(rows) > printed {
+		fmt.Fprintf(w, "(truncated to %d rows; use --limit)\n", printed)
+	}
+	if notLoaded > 0 {
+		fmt.Fprintln(w, "PrometheusRules that are NOT LOADED are usually not selected by the Prometheus ruleSelector or")
+		fmt.Fprintln(w, "ruleNamespaceSelector (compare their labels and namespace), or were rejected by the operator as invalid.")
+	}
+	return nil
+}
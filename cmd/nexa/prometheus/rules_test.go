@@ -0,0 +1,116 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const rulesBody = `{"status":"success","data":{"groups":[
+ {"name":"node.rules","file":"/etc/prometheus/rules/prometheus-k8s-rulefiles-0/monitoring-node-rules-5f1c.yaml","interval":30,"evaluationTime":0.0021,"lastEvaluation":"2024-05-01T10:00:00Z","rules":[
+  {"name":"instance:node_cpu:rate5m","type":"recording","health":"ok"},
+  {"name":"NodeDown","type":"alerting","health":"err","lastError":"many-to-many matching not allowed","state":"inactive"}]},
+ {"name":"slow","file":"/etc/prometheus/rules/prometheus-k8s-rulefiles-0/shop-legacy.yaml","interval":15,"evaluationTime":20,"lastEvaluation":"0001-01-01T00:00:00Z","rules":[
+  {"name":"job:errors:rate1h","type":"recording","health":"ok"}]}]}}`
+
+const alertsBody = `{"status":"success","data":{"alerts":[
+ {"labels":{"alertname":"DiskFilling","severity":"warning","instance":"n1"},"annotations":{"runbook_url":"http://rb","summary":"disk filling"},"state":"firing","activeAt":"2024-05-01T09:00:00Z","value":"0.93"},
+ {"labels":{"alertname":"NodeDown","severity":"critical","instance":"n2"},"annotations":{},"state":"pending","activeAt":"2024-05-01T09:58:00Z","value":"0"},
+ {"labels":{"alertname":"APIDown","severity":"critical"},"annotations":{},"state":"firing","activeAt":"2024-05-01T09:50:00Z","value":"1"}]}}`
+
+func TestRulesAndAlerts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/rules":
+			_, _ = w.Write([]byte(rulesBody))
+		case "/api/v1/alerts":
+			_, _ = w.Write([]byte(alertsBody))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	pc, err := newPromClient(srv.URL, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	groups, err := promRuleGroups(ctx, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderRuleGroups(&buf, groups, 0); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"1 unhealthy", "OK, SLOW", "never", "Total rules: 3, unhealthy: 1", "many-to-many matching not allowed"} {
+		if !strings.Contains(strings.ToUpper(out), strings.ToUpper(want)) {
+			t.Errorf("rules output lacks %q:\n%s", want, out)
+		}
+	}
+
+	cr := func(ns, name, uid string, groups int) unstructured.Unstructured {
+		u := unstructured.Unstructured{Object: map[string]any{}}
+		u.SetNamespace(ns)
+		u.SetName(name)
+		u.SetUID(types.UID(uid))
+		gs := make([]any, groups)
+		for i := range gs {
+			gs[i] = map[string]any{"name": "g"}
+		}
+		_ = unstructured.SetNestedSlice(u.Object, gs, "spec", "groups")
+		return u
+	}
+	rows := matchPrometheusRules([]unstructured.Unstructured{
+		cr("monitoring", "node-rules", "5f1c", 1),
+		cr("shop", "legacy", "aaaa", 1),
+		cr("shop", "orders", "bbbb", 2),
+		cr("shop", "empty", "cccc", 0),
+	}, groups)
+	var got []string
+	for _, r := range rows {
+		got = append(got, r.Namespace+"/"+r.Name+"="+map[bool]string{true: "loaded", false: "missing"}[r.Loaded])
+	}
+	want := "shop/orders=missing monitoring/node-rules=loaded shop/empty=missing shop/legacy=loaded"
+	if strings.Join(got, " ") != want {
+		t.Errorf("matchPrometheusRules = %v, want %s", got, want)
+	}
+	buf.Reset()
+	if err := renderRuleCRs(&buf, rows, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "PrometheusRules: 4, not loaded: 1") || !strings.Contains(buf.String(), "ruleSelector") {
+		t.Errorf("PrometheusRule output:\n%s", buf.String())
+	}
+
+	alerts, err := promAlerts(ctx, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	if err := renderAlerts(&buf, alerts, now, 0); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	if !strings.HasPrefix(out, "Alerts: firing=2, pending=1\n") {
+		t.Errorf("alerts summary:\n%s", out)
+	}
+	// Firing before pending, critical before warning.
+	api, disk, node := strings.Index(out, "APIDown"), strings.Index(out, "DiskFilling"), strings.Index(out, "NodeDown")
+	if api < 0 || !(api < disk && disk < node) {
+		t.Errorf("alert order:\n%s", out)
+	}
+	if !strings.Contains(out, "1h0m0s") || !strings.Contains(out, "summary: disk filling") {
+		t.Errorf("alerts output lacks duration or annotations:\n%s", out)
+	}
+}
@@ -0,0 +1,222 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/nexa/pkg/net/httpclient"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// How a discovered Prometheus is reached.
+const (
+	// transportDirect dials the Service ClusterIP (or a pod IP), which only works inside the cluster.
+	transportDirect = "direct"
+	// transportProxy goes through the apiserver's services/proxy subresource.
+	transportProxy = "proxy"
+	// transportPortForward opens an SPDY port-forward to a pod backing the Service.
+	transportPortForward = "port-forward"
+)
+
+// promConnFlags are the flags of the subcommands that talk to the Prometheus HTTP API.
+type promConnFlags struct {
+	address   string
+	service   string
+	selector  string
+	portName  string
+	transport string
+	scheme    string
+	auth      httpclient.Config
+}
+
+func (f *promConnFlags) register(fs *pflag.FlagSet, addressUsage string) {
+	fs.StringVar(&f.address, "address", "", addressUsage)
+	fs.StringVar(&f.service, "service", "", "Prometheus Service name (optional, prefer if known)")
+	fs.StringVar(&f.selector, "selector", "", "Service label selector to find Prometheus, e.g. app=prometheus")
+	fs.StringVar(&f.portName, "port-name", "", "Service port name to use (optional)")
+	fs.StringVar(&f.transport, "transport", transportDirect, "how to reach the discovered Prometheus: direct (in-cluster), proxy (apiserver service proxy) or port-forward")
+	fs.StringVar(&f.scheme, "scheme", "http", "scheme of the discovered Prometheus: http or https")
+	f.auth.RegisterFlags(fs)
+}
+
+// connect returns a client for --address, or for the Prometheus discovered in namespace and
+// reached over --transport. The caller must Close it.
+func (f *promConnFlags) connect(ctx context.Context, kubeconfig, namespace string) (*promClient, error) {
+	switch f.transport {
+	case transportDirect, transportProxy, transportPortForward:
+	default:
+		return nil, fmt.Errorf("unknown --transport %q (want %s, %s or %s)", f.transport, transportDirect, transportProxy, transportPortForward)
+	}
+	if f.scheme != "http" && f.scheme != "https" {
+		return nil, fmt.Errorf("unknown --scheme %q (want http or https)", f.scheme)
+	}
+	if f.address != "" {
+		if f.transport != transportDirect {
+			return nil, fmt.Errorf("--transport=%s discovers Prometheus through the apiserver; drop --address", f.transport)
+		}
+		hc, err := f.auth.NewClient()
+		if err != nil {
+			return nil, err
+		}
+		return newPromClient(f.address, hc)
+	}
+	cfg, err := newKubeRESTConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return f.connectWithConfig(ctx, cfg, namespace)
+}
+
+func (f *promConnFlags) connectWithConfig(ctx context.Context, cfg *rest.Config, namespace string) (*promClient, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+	cli, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if f.transport == transportProxy {
+		// The apiserver authenticates us with the kubeconfig; it does not pass credentials on.
+		if f.auth.Credentials() || f.auth.TLS() {
+			return nil, fmt.Errorf("--transport=proxy authenticates with the kubeconfig; drop the Prometheus auth and TLS flags")
+		}
+	}
+	if f.transport == transportDirect {
+		host, port, err := discoverPrometheus(ctx, cli, namespace, f.service, f.selector, f.portName)
+		if err != nil {
+			return nil, err
+		}
+		return f.discoveredClient(net.JoinHostPort(host, port))
+	}
+
+	svc, err := findPrometheusService(ctx, cli, namespace, f.service, f.selector)
+	if err != nil {
+		return nil, err
+	}
+	p, err := pickServicePort(svc, f.portName)
+	if err != nil {
+		return nil, err
+	}
+	if f.transport == transportProxy {
+		return proxyPromClient(cfg, cli, svc, p, f.scheme, f.auth.Headers)
+	}
+	pod, port, err := backingPod(ctx, cli, svc, p, f.portName)
+	if err != nil {
+		return nil, err
+	}
+	local, stop, err := portForward(ctx, cfg, cli, namespace, pod, port)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := f.discoveredClient(local)
+	if err != nil {
+		close(stop)
+		return nil, err
+	}
+	pc.close = func() { close(stop) }
+	return pc, nil
+}
+
+// discoveredClient returns a client for the discovered Prometheus at hostPort. Running in a pod
+// without other credentials, the ServiceAccount token is sent, but only over https.
+func (f *promConnFlags) discoveredClient(hostPort string) (*promClient, error) {
+	auth := f.auth
+	if f.scheme == "https" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" && !auth.Credentials() {
+		if _, err := os.Stat(serviceAccountTokenFile); err == nil {
+			auth.BearerTokenFile = serviceAccountTokenFile
+		}
+	}
+	hc, err := auth.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return newPromClient(f.scheme+"://"+hostPort, hc)
+}
+
+// proxyPromClient sends requests to /api/v1/namespaces/<ns>/services/[https:]<name>:<port>/proxy
+// on the apiserver, authenticated like any other API call. Custom headers are passed along.
+func proxyPromClient(cfg *rest.Config, cli kubernetes.Interface, svc *corev1.Service, p corev1.ServicePort, scheme string, headers []string) (*promClient, error) {
+	hc, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if hc.Transport, err = (httpclient.Config{Headers: headers}).RoundTripper(hc.Transport); err != nil {
+		return nil, err
+	}
+	port := p.Name
+	if port == "" {
+		port = strconv.Itoa(int(p.Port))
+	}
+	name := svc.Name + ":" + port
+	if scheme == "https" {
+		name = "https:" + name
+	}
+	u := cli.CoreV1().RESTClient().Get().
+		Namespace(svc.Namespace).Resource("services").Name(name).SubResource("proxy").URL()
+	return &promClient{baseURL: u, http: hc}, nil
+}
+
+// backingPod picks a ready pod behind svc and the container port p is forwarded to.
+func backingPod(ctx context.Context, cli kubernetes.Interface, svc *corev1.Service, p corev1.ServicePort, portName string) (string, int, error) {
+	ep, err := cli.CoreV1().Endpoints(svc.Namespace).Get(ctx, svc.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+	for _, ss := range ep.Subsets {
+		pp := pickEndpointPort(ss.Ports, portName, int(p.Port))
+		if pp == 0 {
+			continue
+		}
+		for _, addr := range ss.Addresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" && addr.TargetRef.Name != "" {
+				return addr.TargetRef.Name, int(pp), nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("service %q has no ready pod to port-forward to", svc.Name)
+}
+
+// portForward forwards a random local port to port of pod until stop is closed and returns the
+// local host:port.
+func portForward(ctx context.Context, cfg *rest.Config, cli kubernetes.Interface, namespace, pod string, port int) (local string, stop chan struct{}, err error) {
+	rt, upgrader, err := spdy.RoundTripperFor(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	u := cli.CoreV1().RESTClient().Post().
+		Namespace(namespace).Resource("pods").Name(pod).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: rt}, http.MethodPost, u)
+
+	stop, ready := make(chan struct{}), make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stop, ready, io.Discard, os.Stderr)
+	if err != nil {
+		return "", nil, err
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- fw.ForwardPorts() }()
+	select {
+	case <-ready:
+	case err := <-errc:
+		return "", nil, fmt.Errorf("port-forward to pod %s/%s: %w", namespace, pod, err)
+	case <-ctx.Done():
+		close(stop)
+		return "", nil, ctx.Err()
+	}
+	ports, err := fw.GetPorts()
+	if err != nil || len(ports) == 0 {
+		close(stop)
+		return "", nil, fmt.Errorf("port-forward to pod %s/%s: no local port: %v", namespace, pod, err)
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(int(ports[0].Local))), stop, nil
+}
@@ -0,0 +1,121 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// fakeAPIServer serves a prometheus-k8s Service in monitoring, its Endpoints and the service proxy.
+func fakeAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	svc := corev1.Service{
+		TypeMeta:   metav1.TypeMeta{Kind: "Service", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus-k8s", Namespace: "monitoring", Labels: map[string]string{"app.kubernetes.io/name": "prometheus"}},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "10.96.0.20",
+			Ports:     []corev1.ServicePort{{Name: "web", Port: 9090, TargetPort: intstr.FromString("web")}},
+		},
+	}
+	ep := corev1.Endpoints{
+		TypeMeta:   metav1.TypeMeta{Kind: "Endpoints", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus-k8s", Namespace: "monitoring"},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: "10.244.1.7", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "prometheus-k8s-0"}}},
+			Ports:     []corev1.EndpointPort{{Name: "web", Port: 9090}},
+		}},
+	}
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/monitoring/services":
+			list := corev1.ServiceList{TypeMeta: metav1.TypeMeta{Kind: "ServiceList", APIVersion: "v1"}}
+			if r.URL.Query().Get("labelSelector") == "app.kubernetes.io/name=prometheus" {
+				list.Items = append(list.Items, svc)
+			}
+			writeJSON(w, list)
+		case "/api/v1/namespaces/monitoring/endpoints/prometheus-k8s":
+			writeJSON(w, ep)
+		case "/api/v1/namespaces/monitoring/services/prometheus-k8s:web/proxy/api/v1/query":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up","job":"api"},"value":[1700000000,"1"]}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestConnectTransports(t *testing.T) {
+	srv := fakeAPIServer(t)
+	defer srv.Close()
+	cfg := &rest.Config{Host: srv.URL, BearerToken: "sa-token"}
+	ctx := context.Background()
+
+	direct := promConnFlags{transport: transportDirect, scheme: "http"}
+	pc, err := direct.connectWithConfig(ctx, cfg, "monitoring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pc.apiURL("/api/v1/query").String(); got != "http://10.96.0.20:9090/api/v1/query" {
+		t.Errorf("direct URL = %s", got)
+	}
+
+	proxy := promConnFlags{transport: transportProxy, scheme: "http"}
+	pc, err = proxy.connectWithConfig(ctx, cfg, "monitoring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := promQuery(ctx, pc, "up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Data.Result) != 1 || res.Data.Result[0].Metric["job"] != "api" {
+		t.Errorf("proxied query result = %+v", res.Data)
+	}
+
+	cli, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := findPrometheusService(ctx, cli, "monitoring", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod, port, err := backingPod(ctx, cli, svc, svc.Spec.Ports[0], "")
+	if err != nil || pod != "prometheus-k8s-0" || port != 9090 {
+		t.Errorf("backingPod = %s, %d, %v", pod, port, err)
+	}
+}
+
+func TestConnectFlags(t *testing.T) {
+	f := promConnFlags{address: "http://10.161.42.222:30090/targets", transport: transportDirect, scheme: "http"}
+	pc, err := f.connect(context.Background(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pc.apiURL("/api/v1/targets").String(); got != "http://10.161.42.222:30090/api/v1/targets" {
+		t.Errorf("address URL = %s", got)
+	}
+	f.transport = transportProxy
+	if _, err := f.connect(context.Background(), "", ""); err == nil {
+		t.Error("--address with --transport=proxy accepted")
+	}
+	f.transport = "ssh"
+	if _, err := f.connect(context.Background(), "", ""); err == nil {
+		t.Error("unknown transport accepted")
+	}
+}
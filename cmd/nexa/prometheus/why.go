@@ -0,0 +1,570 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	serviceMonitorGVR = schema.GroupVersionResource{
+		Group:    "monitoring.coreos.com",
+		Version:  "v1",
+		Resource: "servicemonitors",
+	}
+	prometheusGVR = schema.GroupVersionResource{
+		Group:    "monitoring.coreos.com",
+		Version:  "v1",
+		Resource: "prometheuses",
+	}
+)
+
+// Outcome of a whyStep.
+const (
+	whyOK   = "OK"
+	whyWarn = "WARN"
+	whyFail = "FAIL"
+	whySkip = "SKIP"
+)
+
+type whyStep struct {
+	Result  string
+	Summary string
+	Details []string
+}
+
+// whyInputs is everything explainScrape looks at. Missing pieces carry the error that made them
+// unavailable so the matching step can be skipped instead of failing the whole run.
+type whyInputs struct {
+	Service      *corev1.Service
+	Endpoints    *corev1.Endpoints
+	EndpointsErr error
+
+	ServiceMonitors []unstructured.Unstructured
+	MonitorsErr     error
+	Prometheuses    []unstructured.Unstructured
+	PrometheusesErr error
+	// NamespaceLabels is nil when namespaces could not be listed.
+	NamespaceLabels map[string]map[string]string
+
+	Targets    *promTargetsResponse
+	TargetsErr error
+}
+
+func whyCmd(namespace, kubeconfig *string, conn *promConnFlags) *cobra.Command {
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "why <namespace>/<service>",
+		Short: "explain step by step why a Service is (not) scraped through a ServiceMonitor",
+		Long: "Walk the prometheus-operator chain for a Service and report where it breaks:\n" +
+			"  1. the Service exists\n" +
+			"  2. a ServiceMonitor selects it (spec.selector and spec.namespaceSelector)\n" +
+			"  3. the ServiceMonitor endpoint ports exist on the Service\n" +
+			"  4. a Prometheus resource selects that ServiceMonitor (serviceMonitorSelector and\n" +
+			"     serviceMonitorNamespaceSelector)\n" +
+			"  5. the Endpoints have ready addresses\n" +
+			"  6. Prometheus lists the Service as an active target, or dropped it during relabeling\n" +
+			"-n and the connection flags locate the Prometheus to ask in step 6.",
+		Example:      "nexa prometheus why shop/orders -n monitoring\n  nexa prometheus why shop/orders -n monitoring --transport proxy",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ns, name, ok := strings.Cut(args[0], "/")
+			if !ok || ns == "" || name == "" {
+				return fmt.Errorf("want <namespace>/<service>, got %q", args[0])
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			cfg, err := newKubeRESTConfig(*kubeconfig)
+			if err != nil {
+				return err
+			}
+			cli, err := kubernetes.NewForConfig(cfg)
+			if err != nil {
+				return err
+			}
+			dyn, err := dynamic.NewForConfig(cfg)
+			if err != nil {
+				return err
+			}
+			in, err := collectWhyInputs(ctx, cli, dyn, ns, name)
+			if err != nil {
+				return err
+			}
+
+			pc, err := conn.connect(ctx, *kubeconfig, *namespace)
+			if err == nil {
+				defer pc.Close()
+				in.Targets, err = promTargets(ctx, pc)
+			}
+			in.TargetsErr = err
+
+			renderWhy(os.Stdout, in.Service, explainScrape(in))
+			return nil
+		},
+	}
+	conn.register(cmd.Flags(), "Prometheus base URL, e.g. http://10.247.96.18:9090 (skip discovery)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "overall timeout for discovery and queries")
+	return cmd
+}
+
+// collectWhyInputs reads the Service and the cluster state around it. Only a missing Service is an
+// error; anything else that cannot be read is recorded in the returned inputs.
+func collectWhyInputs(ctx context.Context, cli kubernetes.Interface, dyn dynamic.Interface, ns, name string) (*whyInputs, error) {
+	svc, err := cli.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("service %s/%s not found", ns, name)
+		}
+		return nil, err
+	}
+	in := &whyInputs{Service: svc}
+
+	in.Endpoints, in.EndpointsErr = cli.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+	if in.EndpointsErr != nil {
+		in.Endpoints = nil
+	}
+
+	// ServiceMonitors elsewhere may reach into ns with a namespaceSelector; fall back to ns alone
+	// when listing cluster-wide is not allowed.
+	in.ServiceMonitors, in.MonitorsErr = listUnstructured(ctx, dyn, "", serviceMonitorGVR)
+	if in.MonitorsErr != nil {
+		in.ServiceMonitors, in.MonitorsErr = listUnstructured(ctx, dyn, ns, serviceMonitorGVR)
+	}
+	in.Prometheuses, in.PrometheusesErr = listUnstructured(ctx, dyn, "", prometheusGVR)
+
+	if list, err := cli.CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err == nil {
+		in.NamespaceLabels = make(map[string]map[string]string, len(list.Items))
+		for _, n := range list.Items {
+			in.NamespaceLabels[n.Name] = n.Labels
+		}
+	}
+	return in, nil
+}
+
+// explainScrape checks each link between the Service and a Prometheus scraping it.
+func explainScrape(in *whyInputs) []whyStep {
+	svc := in.Service
+	steps := []whyStep{{
+		Result:  whyOK,
+		Summary: fmt.Sprintf("Service %s/%s exists", svc.Namespace, svc.Name),
+		Details: []string{
+			"labels: " + orNone(formatMap(svc.Labels, nil)),
+			"ports: " + formatServicePorts(svc.Spec.Ports),
+		},
+	}}
+
+	matched, step := selectingMonitors(in)
+	steps = append(steps, step)
+
+	usable, portNames, step := monitorPorts(svc, matched)
+	steps = append(steps, step)
+
+	steps = append(steps, selectingPrometheuses(in, usable))
+	steps = append(steps, endpointsReady(in, portNames))
+	steps = append(steps, scrapeTargets(in))
+	return steps
+}
+
+// selectingMonitors returns the ServiceMonitors whose selector and namespaceSelector cover the
+// Service. Near misses, where only one of the two matches, are listed when none does.
+func selectingMonitors(in *whyInputs) ([]*unstructured.Unstructured, whyStep) {
+	if in.MonitorsErr != nil {
+		return nil, whyStep{Result: whySkip, Summary: "ServiceMonitors could not be listed", Details: []string{in.MonitorsErr.Error()}}
+	}
+	svc := in.Service
+	var (
+		matched []*unstructured.Unstructured
+		details []string
+		misses  []string
+	)
+	for i := range in.ServiceMonitors {
+		sm := &in.ServiceMonitors[i]
+		id := sm.GetNamespace() + "/" + sm.GetName()
+		nsOK, nsDesc := monitorSelectsNamespace(sm, svc.Namespace)
+		sel, found, err := labelSelectorAt(sm.Object, "spec", "selector")
+		if err != nil {
+			misses = append(misses, fmt.Sprintf("ServiceMonitor %s: invalid selector: %v", id, err))
+			continue
+		}
+		if !found {
+			sel = labels.Everything()
+		}
+		labelsOK := sel.Matches(labels.Set(svc.Labels))
+		switch {
+		case nsOK && labelsOK:
+			matched = append(matched, sm)
+			details = append(details, fmt.Sprintf("ServiceMonitor %s (selector %s, watches %s)", id, describeSelector(sel), nsDesc))
+		case nsOK:
+			misses = append(misses, fmt.Sprintf("ServiceMonitor %s watches %s but its selector %s does not match the Service labels", id, nsDesc, describeSelector(sel)))
+		case labelsOK && !sel.Empty():
+			misses = append(misses, fmt.Sprintf("ServiceMonitor %s selector %s matches, but it watches %s, not %s", id, describeSelector(sel), nsDesc, svc.Namespace))
+		}
+	}
+	if len(matched) == 0 {
+		details = append(misses, "a ServiceMonitor needs spec.selector matching the Service labels and spec.namespaceSelector covering "+svc.Namespace)
+		return nil, whyStep{Result: whyFail, Summary: "no ServiceMonitor selects the Service", Details: details}
+	}
+	return matched, whyStep{Result: whyOK, Summary: fmt.Sprintf("%d ServiceMonitor(s) select the Service", len(matched)), Details: details}
+}
+
+// monitorPorts checks that the endpoints of each ServiceMonitor name a port of the Service. It
+// returns the ServiceMonitors with at least one usable endpoint and the Service ports they scrape.
+func monitorPorts(svc *corev1.Service, matched []*unstructured.Unstructured) ([]*unstructured.Unstructured, map[string]bool, whyStep) {
+	if len(matched) == 0 {
+		return nil, nil, whyStep{Result: whySkip, Summary: "ServiceMonitor ports not checked: no ServiceMonitor selects the Service"}
+	}
+	var (
+		usable  []*unstructured.Unstructured
+		details []string
+		broken  int
+	)
+	ports := map[string]bool{}
+	for _, sm := range matched {
+		id := sm.GetNamespace() + "/" + sm.GetName()
+		ok := false
+		eps, _, _ := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+		if len(eps) == 0 {
+			details = append(details, fmt.Sprintf("ServiceMonitor %s has no endpoints", id))
+		}
+		for i, e := range eps {
+			ep, _ := e.(map[string]any)
+			port, _ := ep["port"].(string)
+			targetPort := ""
+			if tp, found := ep["targetPort"]; found && tp != nil {
+				targetPort = fmt.Sprint(tp)
+			}
+			name, desc, found := resolveMonitorPort(svc, port, targetPort)
+			if !found {
+				broken++
+				details = append(details, fmt.Sprintf("ServiceMonitor %s endpoint %d: %s is not a port of the Service (ports: %s)", id, i, desc, formatServicePorts(svc.Spec.Ports)))
+				continue
+			}
+			ok = true
+			ports[name] = true
+			details = append(details, fmt.Sprintf("ServiceMonitor %s endpoint %d: %s", id, i, desc))
+		}
+		if ok {
+			usable = append(usable, sm)
+		}
+	}
+	switch {
+	case len(usable) == 0:
+		return nil, nil, whyStep{Result: whyFail, Summary: "no ServiceMonitor endpoint names a port of the Service", Details: details}
+	case broken > 0:
+		return usable, ports, whyStep{Result: whyWarn, Summary: fmt.Sprintf("%d ServiceMonitor endpoint(s) name no port of the Service", broken), Details: details}
+	}
+	return usable, ports, whyStep{Result: whyOK, Summary: "ServiceMonitor endpoint ports exist on the Service", Details: details}
+}
+
+// resolveMonitorPort finds the Service port a ServiceMonitor endpoint refers to, by port name or,
+// failing that, by targetPort.
+func resolveMonitorPort(svc *corev1.Service, port, targetPort string) (name, desc string, found bool) {
+	switch {
+	case port != "":
+		for _, p := range svc.Spec.Ports {
+			if p.Name == port {
+				return p.Name, fmt.Sprintf("port %q -> %d", port, p.Port), true
+			}
+		}
+		return "", fmt.Sprintf("port %q", port), false
+	case targetPort != "":
+		for _, p := range svc.Spec.Ports {
+			if p.TargetPort.String() == targetPort || (p.TargetPort.IntValue() == 0 && strconv.Itoa(int(p.Port)) == targetPort) {
+				return p.Name, fmt.Sprintf("targetPort %s (Service port %s)", targetPort, orNone(p.Name)), true
+			}
+		}
+		return "", "targetPort " + targetPort, false
+	}
+	return "", "an endpoint without port or targetPort", false
+}
+
+// selectingPrometheuses checks that some Prometheus resource picks up one of the ServiceMonitors.
+func selectingPrometheuses(in *whyInputs, monitors []*unstructured.Unstructured) whyStep {
+	switch {
+	case len(monitors) == 0:
+		return whyStep{Result: whySkip, Summary: "Prometheus selection not checked: no usable ServiceMonitor"}
+	case in.PrometheusesErr != nil:
+		return whyStep{Result: whySkip, Summary: "Prometheus resources could not be listed", Details: []string{in.PrometheusesErr.Error()}}
+	case len(in.Prometheuses) == 0:
+		return whyStep{Result: whyFail, Summary: "no Prometheus resources found", Details: []string{"nothing turns ServiceMonitors into scrape configs"}}
+	}
+	var (
+		details  []string
+		selected int
+	)
+	for _, sm := range monitors {
+		for i := range in.Prometheuses {
+			p := &in.Prometheuses[i]
+			ok, reason := prometheusSelects(p, sm, in.NamespaceLabels)
+			line := fmt.Sprintf("Prometheus %s/%s -> ServiceMonitor %s/%s: %s", p.GetNamespace(), p.GetName(), sm.GetNamespace(), sm.GetName(), reason)
+			details = append(details, line)
+			if ok {
+				selected++
+			}
+		}
+	}
+	if selected == 0 {
+		return whyStep{Result: whyFail, Summary: "no Prometheus selects the ServiceMonitor(s)", Details: details}
+	}
+	return whyStep{Result: whyOK, Summary: "a Prometheus selects the ServiceMonitor", Details: details}
+}
+
+// prometheusSelects applies serviceMonitorSelector and serviceMonitorNamespaceSelector of p to sm.
+// An unset serviceMonitorSelector selects nothing; an unset namespace selector means p's own
+// namespace only.
+func prometheusSelects(p, sm *unstructured.Unstructured, nsLabels map[string]map[string]string) (bool, string) {
+	sel, found, err := labelSelectorAt(p.Object, "spec", "serviceMonitorSelector")
+	switch {
+	case err != nil:
+		return false, "invalid serviceMonitorSelector: " + err.Error()
+	case !found:
+		return false, "serviceMonitorSelector is not set, so no ServiceMonitor is selected"
+	case !sel.Matches(labels.Set(sm.GetLabels())):
+		return false, fmt.Sprintf("serviceMonitorSelector %s does not match the ServiceMonitor labels {%s}", describeSelector(sel), formatMap(sm.GetLabels(), nil))
+	}
+
+	nsSel, found, err := labelSelectorAt(p.Object, "spec", "serviceMonitorNamespaceSelector")
+	switch {
+	case err != nil:
+		return false, "invalid serviceMonitorNamespaceSelector: " + err.Error()
+	case !found:
+		if p.GetNamespace() != sm.GetNamespace() {
+			return false, fmt.Sprintf("serviceMonitorNamespaceSelector is not set, so only ServiceMonitors in %s are selected", p.GetNamespace())
+		}
+	case !nsSel.Empty():
+		l, ok := nsLabels[sm.GetNamespace()]
+		if !ok {
+			return false, fmt.Sprintf("cannot read the labels of namespace %s to apply serviceMonitorNamespaceSelector %s", sm.GetNamespace(), nsSel)
+		}
+		if !nsSel.Matches(labels.Set(l)) {
+			return false, fmt.Sprintf("serviceMonitorNamespaceSelector %s does not match namespace %s labels {%s}", nsSel, sm.GetNamespace(), formatMap(l, nil))
+		}
+	}
+	return true, "selected"
+}
+
+// endpointsReady counts the ready and not-ready addresses behind the scraped Service ports, or all
+// ports when none are known.
+func endpointsReady(in *whyInputs, portNames map[string]bool) whyStep {
+	if in.Endpoints == nil {
+		if in.EndpointsErr != nil && !apierrors.IsNotFound(in.EndpointsErr) {
+			return whyStep{Result: whySkip, Summary: "Endpoints could not be read", Details: []string{in.EndpointsErr.Error()}}
+		}
+		return whyStep{Result: whyFail, Summary: "the Service has no Endpoints", Details: []string{"is spec.selector of the Service matching any pod?"}}
+	}
+	var (
+		ready    int
+		notReady []string
+	)
+	for _, ss := range in.Endpoints.Subsets {
+		if len(portNames) > 0 && !slices.ContainsFunc(ss.Ports, func(p corev1.EndpointPort) bool { return portNames[p.Name] }) {
+			continue
+		}
+		ready += len(ss.Addresses)
+		for _, a := range ss.NotReadyAddresses {
+			notReady = append(notReady, endpointAddressName(a))
+		}
+	}
+	summary := fmt.Sprintf("Endpoints: %d ready, %d not ready", ready, len(notReady))
+	var details []string
+	if len(notReady) > 0 {
+		sort.Strings(notReady)
+		details = append(details, "not ready: "+strings.Join(notReady, ", "))
+	}
+	switch {
+	case ready == 0:
+		return whyStep{Result: whyFail, Summary: summary, Details: append(details, "Prometheus discovers the addresses but the pods are not serving")}
+	case len(notReady) > 0:
+		return whyStep{Result: whyWarn, Summary: summary, Details: details}
+	}
+	return whyStep{Result: whyOK, Summary: summary}
+}
+
+func endpointAddressName(a corev1.EndpointAddress) string {
+	if a.TargetRef != nil && a.TargetRef.Name != "" {
+		return a.TargetRef.Name
+	}
+	return a.IP
+}
+
+// scrapeTargets looks the Service up among the active and dropped targets of Prometheus.
+func scrapeTargets(in *whyInputs) whyStep {
+	if in.TargetsErr != nil {
+		return whyStep{Result: whySkip, Summary: "Prometheus targets not checked", Details: []string{in.TargetsErr.Error()}}
+	}
+	svc := in.Service
+	var (
+		up, down  int
+		pools     []string
+		lastError []string
+	)
+	for _, t := range in.Targets.Data.ActiveTargets {
+		if !targetOfService(t.DiscoveredLabels, t.Labels, svc.Namespace, svc.Name) {
+			continue
+		}
+		if !slices.Contains(pools, t.ScrapePool) {
+			pools = append(pools, t.ScrapePool)
+		}
+		if t.Health == "up" {
+			up++
+			continue
+		}
+		down++
+		if t.LastError != "" && !slices.Contains(lastError, t.LastError) && len(lastError) < 3 {
+			lastError = append(lastError, t.LastError)
+		}
+	}
+	if up+down > 0 {
+		sort.Strings(pools)
+		details := []string{"scrape pools: " + strings.Join(pools, ", ")}
+		summary := fmt.Sprintf("Prometheus scrapes %d target(s): %d up, %d down", up+down, up, down)
+		if down > 0 {
+			for _, e := range lastError {
+				details = append(details, "last error: "+e)
+			}
+			return whyStep{Result: whyWarn, Summary: summary, Details: details}
+		}
+		return whyStep{Result: whyOK, Summary: summary, Details: details}
+	}
+
+	var dropped []string
+	for _, t := range in.Targets.Data.DroppedTargets {
+		d := t.DiscoveredLabels
+		if !targetOfService(d, nil, svc.Namespace, svc.Name) {
+			continue
+		}
+		dropped = append(dropped, fmt.Sprintf("%s port=%s ready=%s pool=%s",
+			d["__address__"], orNone(d["__meta_kubernetes_endpoint_port_name"]),
+			orNone(d["__meta_kubernetes_endpoint_ready"]), orNone(d["__scrape_pool__"])))
+	}
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		details := dropped
+		if len(details) > 5 {
+			details = append(details[:5:5], fmt.Sprintf("... and %d more", len(dropped)-5))
+		}
+		details = append(details, "the generated config keeps only the ServiceMonitor endpoint port; check the port name and spec.endpoints[].relabelings")
+		return whyStep{Result: whyFail, Summary: fmt.Sprintf("Prometheus discovered %d endpoint(s) of the Service but relabeling dropped them all", len(dropped)), Details: details}
+	}
+	return whyStep{Result: whyFail, Summary: "Prometheus has not discovered the Service", Details: []string{
+		"the config may not be reloaded yet (check the config-reloader and operator logs),",
+		"or this is not the Prometheus selecting the ServiceMonitor (see -n, --service, --selector);",
+		"dropped targets are only listed up to keep_dropped_targets",
+	}}
+}
+
+// targetOfService matches a target by its Kubernetes SD labels, or by the namespace and service
+// target labels prometheus-operator adds.
+func targetOfService(discovered, lbls map[string]string, ns, name string) bool {
+	if discovered["__meta_kubernetes_namespace"] == ns && discovered["__meta_kubernetes_service_name"] == name {
+		return true
+	}
+	return lbls != nil && lbls["namespace"] == ns && lbls["service"] == name
+}
+
+func renderWhy(w io.Writer, svc *corev1.Service, steps []whyStep) {
+	fmt.Fprintf(w, "Why is %s/%s (not) scraped?\n\n", svc.Namespace, svc.Name)
+	for i, s := range steps {
+		fmt.Fprintf(w, "%d. [%-4s] %s\n", i+1, s.Result, s.Summary)
+		for _, d := range s.Details {
+			fmt.Fprintf(w, "           %s\n", d)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Verdict: "+whyVerdict(steps))
+}
+
+// whyVerdict names the first broken step, or how sure we are that the Service is scraped.
+func whyVerdict(steps []whyStep) string {
+	warn, skip := false, false
+	for i, s := range steps {
+		switch s.Result {
+		case whyFail:
+			return fmt.Sprintf("not scraped; step %d: %s", i+1, s.Summary)
+		case whyWarn:
+			warn = true
+		case whySkip:
+			skip = true
+		}
+	}
+	switch {
+	case skip:
+		return "no broken step found, but some steps could not be checked"
+	case warn:
+		return "scraped, with warnings"
+	}
+	return "scraped"
+}
+
+// monitorSelectsNamespace reports whether the namespaceSelector of a ServiceMonitor covers ns.
+// Without one, a ServiceMonitor only watches its own namespace.
+func monitorSelectsNamespace(sm *unstructured.Unstructured, ns string) (bool, string) {
+	if anyNS, _, _ := unstructured.NestedBool(sm.Object, "spec", "namespaceSelector", "any"); anyNS {
+		return true, "any namespace"
+	}
+	names, _, _ := unstructured.NestedStringSlice(sm.Object, "spec", "namespaceSelector", "matchNames")
+	if len(names) > 0 {
+		return slices.Contains(names, ns), "namespaces " + strings.Join(names, ",")
+	}
+	return sm.GetNamespace() == ns, "its own namespace " + sm.GetNamespace()
+}
+
+// labelSelectorAt reads the metav1.LabelSelector at fields of obj. found is false when the field is
+// absent or null.
+func labelSelectorAt(obj map[string]any, fields ...string) (sel labels.Selector, found bool, err error) {
+	raw, found, err := unstructured.NestedFieldNoCopy(obj, fields...)
+	if err != nil || !found || raw == nil {
+		return nil, false, err
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, true, fmt.Errorf("%s is not a label selector", strings.Join(fields, "."))
+	}
+	var ls metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &ls); err != nil {
+		return nil, true, err
+	}
+	sel, err = metav1.LabelSelectorAsSelector(&ls)
+	return sel, true, err
+}
+
+func describeSelector(sel labels.Selector) string {
+	if sel.Empty() {
+		return "{} (everything)"
+	}
+	return sel.String()
+}
+
+func formatServicePorts(ports []corev1.ServicePort) string {
+	parts := make([]string, 0, len(ports))
+	for _, p := range ports {
+		parts = append(parts, fmt.Sprintf("%s:%d", orNone(p.Name), p.Port))
+	}
+	return orNone(strings.Join(parts, ", "))
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
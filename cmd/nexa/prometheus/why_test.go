@@ -0,0 +1,111 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const whyTargetsBody = `{"status":"success","data":{
+ "activeTargets":[
+  {"scrapePool":"serviceMonitor/monitoring/orders/0","health":"up","labels":{"namespace":"shop","service":"orders"},
+   "discoveredLabels":{"__meta_kubernetes_namespace":"shop","__meta_kubernetes_service_name":"orders"}},
+  {"scrapePool":"serviceMonitor/monitoring/api/0","health":"down","labels":{"namespace":"shop","service":"api"},
+   "discoveredLabels":{"__meta_kubernetes_namespace":"shop","__meta_kubernetes_service_name":"api"}}],
+ "droppedTargets":[
+  {"discoveredLabels":{"__address__":"10.244.1.9:8080","__meta_kubernetes_namespace":"shop","__meta_kubernetes_service_name":"orders","__meta_kubernetes_endpoint_port_name":"http"}}]}}`
+
+func TestExplainScrape(t *testing.T) {
+	obj := func(ns, name string, lbls map[string]string, spec map[string]any) unstructured.Unstructured {
+		u := unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+		u.SetNamespace(ns)
+		u.SetName(name)
+		u.SetLabels(lbls)
+		return u
+	}
+	whyInputsFor := func() *whyInputs {
+		in := &whyInputs{
+			Service: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "orders", Labels: map[string]string{"app": "orders"}},
+				Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9102}}},
+			},
+			Endpoints: &corev1.Endpoints{Subsets: []corev1.EndpointSubset{{
+				Addresses:         []corev1.EndpointAddress{{IP: "10.244.1.9"}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.244.2.3", TargetRef: &corev1.ObjectReference{Name: "orders-1"}}},
+				Ports:             []corev1.EndpointPort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9102}},
+			}}},
+			ServiceMonitors: []unstructured.Unstructured{
+				obj("monitoring", "orders", map[string]string{"release": "kps"}, map[string]any{
+					"selector":          map[string]any{"matchLabels": map[string]any{"app": "orders"}},
+					"namespaceSelector": map[string]any{"matchNames": []any{"shop"}},
+					"endpoints":         []any{map[string]any{"port": "metrics"}},
+				}),
+				obj("shop", "other", nil, map[string]any{
+					"selector": map[string]any{"matchLabels": map[string]any{"app": "other"}},
+				}),
+			},
+			Prometheuses: []unstructured.Unstructured{
+				obj("monitoring", "k8s", nil, map[string]any{
+					"serviceMonitorSelector":          map[string]any{"matchLabels": map[string]any{"release": "kps"}},
+					"serviceMonitorNamespaceSelector": map[string]any{"matchLabels": map[string]any{"team": "platform"}},
+				}),
+			},
+			NamespaceLabels: map[string]map[string]string{"monitoring": {"team": "platform"}, "shop": {}},
+			Targets:         &promTargetsResponse{},
+		}
+		if err := json.Unmarshal([]byte(whyTargetsBody), in.Targets); err != nil {
+			t.Fatal(err)
+		}
+		return in
+	}
+
+	in := whyInputsFor()
+	var buf bytes.Buffer
+	steps := explainScrape(in)
+	renderWhy(&buf, in.Service, steps)
+	out := buf.String()
+	for _, want := range []string{
+		"2. [OK  ] 1 ServiceMonitor(s) select the Service",
+		`endpoint 0: port "metrics" -> 9102`,
+		"Prometheus monitoring/k8s -> ServiceMonitor monitoring/orders: selected",
+		"5. [WARN] Endpoints: 1 ready, 1 not ready",
+		"not ready: orders-1",
+		"6. [OK  ] Prometheus scrapes 1 target(s): 1 up, 0 down",
+		"Verdict: scraped, with warnings",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		mutate func(in *whyInputs)
+		want   string
+	}{
+		{"no ServiceMonitor", func(in *whyInputs) { in.Service.Labels = map[string]string{"app": "legacy"} },
+			"not scraped; step 2: no ServiceMonitor selects the Service"},
+		{"wrong port", func(in *whyInputs) {
+			_ = unstructured.SetNestedSlice(in.ServiceMonitors[0].Object, []any{map[string]any{"port": "web"}}, "spec", "endpoints")
+		}, "not scraped; step 3: no ServiceMonitor endpoint names a port of the Service"},
+		{"Prometheus selector", func(in *whyInputs) { in.ServiceMonitors[0].SetLabels(nil) },
+			"not scraped; step 4: no Prometheus selects the ServiceMonitor(s)"},
+		{"namespace selector", func(in *whyInputs) { in.NamespaceLabels["monitoring"] = nil },
+			"not scraped; step 4: no Prometheus selects the ServiceMonitor(s)"},
+		{"no ready pods", func(in *whyInputs) { in.Endpoints.Subsets[0].Addresses = nil },
+			"not scraped; step 5: Endpoints: 0 ready, 1 not ready"},
+		{"relabel dropped", func(in *whyInputs) { in.Targets.Data.ActiveTargets = in.Targets.Data.ActiveTargets[1:] },
+			"not scraped; step 6: Prometheus discovered 1 endpoint(s) of the Service but relabeling dropped them all"},
+	} {
+		in := whyInputsFor()
+		tc.mutate(in)
+		if got := whyVerdict(explainScrape(in)); got != tc.want {
+			t.Errorf("%s: verdict = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package httpclient builds HTTP clients with authentication, TLS and custom headers from a common
+// set of command-line flags, so every nexa command that talks HTTP is configured the same way.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Config holds the authentication, TLS and header settings of a client. Secrets may be given
+// inline or as files; files are re-read on every request so rotated tokens are picked up.
+type Config struct {
+	// BasicAuth is USER:PASSWORD, or USER: together with PasswordFile.
+	BasicAuth    string
+	PasswordFile string
+
+	BearerToken     string
+	BearerTokenFile string
+
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+
+	// Headers are extra 'Name: value' headers sent with every request.
+	Headers []string
+
+	// Timeout bounds each request; 0 means no timeout.
+	Timeout time.Duration
+
+	// flags are the flag sets of RegisterFlags (one per subcommand sharing c), used to name the
+	// flag the user typed in errors.
+	flags []*pflag.FlagSet
+}
+
+// flagAliases are the names nexa prometheus gave the TLS flags before the flag set was shared
+// with node push; both spellings are accepted.
+var flagAliases = map[string]string{
+	"tls.ca-file":              "ca-file",
+	"tls.cert-file":            "cert",
+	"tls.key-file":             "key",
+	"tls.server-name":          "tls-server-name",
+	"tls.insecure-skip-verify": "insecure-skip-verify",
+}
+
+// RegisterFlags binds c to the authentication, TLS and header flags of fs. Each --tls.* flag is
+// also registered under its alias in flagAliases.
+func (c *Config) RegisterFlags(fs *pflag.FlagSet) {
+	c.flags = append(c.flags, fs)
+	fs.StringVar(&c.BasicAuth, "basic-auth", "", "HTTP basic auth as USER:PASSWORD")
+	fs.StringVar(&c.PasswordFile, "basic-auth.password-file", "", "read the basic auth password from this file (use with --basic-auth USER:)")
+	fs.StringVar(&c.BearerToken, "bearer-token", "", "HTTP bearer token")
+	fs.StringVar(&c.BearerTokenFile, "bearer-token-file", "", "read the HTTP bearer token from this file")
+	fs.StringVar(&c.CAFile, "tls.ca-file", "", "CA certificate to verify the server with")
+	fs.StringVar(&c.CertFile, "tls.cert-file", "", "client certificate for mutual TLS (with --tls.key-file)")
+	fs.StringVar(&c.KeyFile, "tls.key-file", "", "client key for mutual TLS (with --tls.cert-file)")
+	fs.StringVar(&c.ServerName, "tls.server-name", "", "server name to verify the certificate against")
+	fs.BoolVar(&c.InsecureSkipVerify, "tls.insecure-skip-verify", false, "do not verify the server certificate")
+	fs.StringArrayVarP(&c.Headers, "header", "H", nil, "extra HTTP header 'Name: value' (repeatable), e.g. X-Scope-OrgID: tenant-1")
+	fs.StringVar(&c.CAFile, flagAliases["tls.ca-file"], "", "alias of --tls.ca-file")
+	fs.StringVar(&c.CertFile, flagAliases["tls.cert-file"], "", "alias of --tls.cert-file")
+	fs.StringVar(&c.KeyFile, flagAliases["tls.key-file"], "", "alias of --tls.key-file")
+	fs.StringVar(&c.ServerName, flagAliases["tls.server-name"], "", "alias of --tls.server-name")
+	fs.BoolVar(&c.InsecureSkipVerify, flagAliases["tls.insecure-skip-verify"], false, "alias of --tls.insecure-skip-verify")
+}
+
+// flag returns --name, or its alias when the user spelled the TLS flags the nexa prometheus way.
+func (c Config) flag(name string) string {
+	for _, fs := range c.flags {
+		for _, alias := range flagAliases {
+			if fs.Changed(alias) {
+				return "--" + flagAliases[name]
+			}
+		}
+	}
+	return "--" + name
+}
+
+// Credentials reports whether basic auth or a bearer token was given.
+func (c Config) Credentials() bool {
+	return c.BasicAuth != "" || c.PasswordFile != "" || c.BearerToken != "" || c.BearerTokenFile != ""
+}
+
+// TLS reports whether any TLS setting was given.
+func (c Config) TLS() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.ServerName != "" || c.InsecureSkipVerify
+}
+
+// Validate rejects conflicting or malformed settings.
+func (c Config) Validate() error {
+	basic := c.BasicAuth != "" || c.PasswordFile != ""
+	bearer := c.BearerToken != "" || c.BearerTokenFile != ""
+	switch {
+	case basic && bearer:
+		return fmt.Errorf("--basic-auth and --bearer-token[-file] are mutually exclusive")
+	case c.BearerToken != "" && c.BearerTokenFile != "":
+		return fmt.Errorf("--bearer-token and --bearer-token-file are mutually exclusive")
+	case (c.CertFile == "") != (c.KeyFile == ""):
+		return fmt.Errorf("%s and %s must be given together", c.flag("tls.cert-file"), c.flag("tls.key-file"))
+	}
+	if basic {
+		user, password, ok := strings.Cut(c.BasicAuth, ":")
+		if !ok || user == "" {
+			return fmt.Errorf("invalid --basic-auth (want USER:PASSWORD)")
+		}
+		if password != "" && c.PasswordFile != "" {
+			return fmt.Errorf("--basic-auth with a password and --basic-auth.password-file are mutually exclusive")
+		}
+	}
+	_, err := c.Header()
+	return err
+}
+
+// Header returns the parsed Headers.
+func (c Config) Header() (http.Header, error) {
+	h := http.Header{}
+	for _, kv := range c.Headers {
+		name, value, ok := strings.Cut(kv, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --header %q (want 'Name: value')", kv)
+		}
+		h.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+// TLSConfig loads the CA and client certificate files.
+func (c Config) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicit opt-in
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s %s: no PEM certificates found", c.flag("tls.ca-file"), c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// NewClient builds an http.Client from c.
+func (c Config) NewClient() (*http.Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper = http.DefaultTransport
+	if c.TLS() {
+		tlsConfig, err := c.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = tlsConfig
+		rt = tr
+	}
+	rt, err := c.RoundTripper(rt)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: rt, Timeout: c.Timeout}, nil
+}
+
+// RoundTripper wraps next so that every request carries the Headers and the Authorization of c.
+// The TLS settings are not applied; next is used as is.
+func (c Config) RoundTripper(next http.RoundTripper) (http.RoundTripper, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	header, err := c.Header()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) == 0 && !c.Credentials() {
+		return next, nil
+	}
+	return roundTripper{cfg: c, header: header, next: next}, nil
+}
+
+type roundTripper struct {
+	cfg    Config
+	header http.Header
+	next   http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range rt.header {
+		req.Header[k] = v
+	}
+	if err := rt.cfg.authorize(req); err != nil {
+		return nil, err
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// authorize sets the Authorization header of req.
+func (c Config) authorize(req *http.Request) error {
+	switch {
+	case c.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	case c.BearerTokenFile != "":
+		token, err := readSecret(c.BearerTokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.BasicAuth != "":
+		user, password, _ := strings.Cut(c.BasicAuth, ":")
+		if c.PasswordFile != "" {
+			var err error
+			if password, err = readSecret(c.PasswordFile); err != nil {
+				return err
+			}
+		}
+		req.SetBasicAuth(user, password)
+	}
+	return nil
+}
+
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
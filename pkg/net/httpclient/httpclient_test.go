@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestConfigValidate(t *testing.T) {
+	for _, c := range []Config{
+		{BasicAuth: "a:b", BearerToken: "b"},
+		{BasicAuth: "nopassword"},
+		{BasicAuth: ":pw"},
+		{BasicAuth: "a:b", PasswordFile: "b"},
+		{PasswordFile: "b"},
+		{BearerToken: "a", BearerTokenFile: "b"},
+		{CertFile: "a"},
+		{Headers: []string{"NoColon"}},
+	} {
+		if c.Validate() == nil {
+			t.Errorf("%+v: expected error", c)
+		}
+	}
+	for _, c := range []Config{
+		{BasicAuth: "a:b"},
+		{BasicAuth: "a:", PasswordFile: "b"},
+		{CertFile: "a", KeyFile: "b"},
+		{Headers: []string{"X-Scope-OrgID: tenant-1"}},
+	} {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%+v: %v", c, err)
+		}
+	}
+}
+
+func TestFlagAliases(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--cert", "a.pem"}, "--cert and --key must be given together"},
+		{[]string{"--tls.cert-file", "a.pem"}, "--tls.cert-file and --tls.key-file must be given together"},
+	} {
+		var c Config
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		c.RegisterFlags(fs)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Validate(); err == nil || err.Error() != tc.want {
+			t.Errorf("%v: err = %v, want %s", tc.args, err, tc.want)
+		}
+	}
+
+	var c Config
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	c.RegisterFlags(fs)
+	if err := fs.Parse([]string{"--ca-file", "ca.pem", "--key", "k.pem", "--cert", "c.pem", "--insecure-skip-verify", "--tls-server-name", "prom"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.CAFile != "ca.pem" || c.KeyFile != "k.pem" || c.CertFile != "c.pem" || !c.InsecureSkipVerify || c.ServerName != "prom" {
+		t.Errorf("aliases not bound: %+v", c)
+	}
+}
+
+func TestRoundTripper(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	tokenFile := filepath.Join(dir, "token")
+	get := func(c Config) http.Header {
+		t.Helper()
+		client, err := c.NewClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = nil
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return got
+	}
+
+	if err := os.WriteFile(passwordFile, []byte("pw\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := get(Config{BasicAuth: "push:", PasswordFile: passwordFile, Headers: []string{"X-Scope-OrgID: tenant-1"}})
+	if h.Get("Authorization") != "Basic cHVzaDpwdw==" || h.Get("X-Scope-OrgID") != "tenant-1" {
+		t.Errorf("headers = %v", h)
+	}
+
+	// Token files are re-read on every request.
+	c := Config{BearerTokenFile: tokenFile}
+	for _, token := range []string{"one", "two"} {
+		if err := os.WriteFile(tokenFile, []byte(token), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if h := get(c); h.Get("Authorization") != "Bearer "+token {
+			t.Errorf("Authorization = %q, want Bearer %s", h.Get("Authorization"), token)
+		}
+	}
+
+	if h := get(Config{}); h.Get("Authorization") != "" {
+		t.Errorf("Authorization without credentials = %q", h.Get("Authorization"))
+	}
+}
@@ -0,0 +1,91 @@
+package synscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// connectScan performs a full TCP handshake per port. It needs no privileges and works for IPv6,
+// at the cost of being slower and visible to the target application.
+func (s *Scanner) connectScan(c context.Context, hosts []net.IP, ports []int) ([]Result, error) {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	lim := newLimiter(s.Rate)
+	defer lim.Stop()
+
+	type job struct {
+		host net.IP
+		port int
+	}
+	jobs := make(chan job)
+	var (
+		mu      sync.Mutex
+		results []Result
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				r := s.probeConnect(c, lim, j.host, j.port)
+				if c.Err() != nil {
+					// don't report ports we never got a real answer for
+					continue
+				}
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, h := range hosts {
+		for _, p := range ports {
+			select {
+			case <-c.Done():
+				break feed
+			case jobs <- job{host: h, port: p}:
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, c.Err()
+}
+
+func (s *Scanner) probeConnect(c context.Context, lim *limiter, host net.IP, port int) Result {
+	r := Result{Host: host, Port: port, State: StateFiltered, Method: MethodConnect}
+	addr := net.JoinHostPort(host.String(), strconv.Itoa(port))
+	d := net.Dialer{Timeout: s.Timeout}
+
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if err := lim.Wait(c); err != nil {
+			return r
+		}
+		start := time.Now()
+		conn, err := d.DialContext(c, "tcp", addr)
+		if err == nil {
+			r.RTT = time.Since(start)
+			r.State = StateOpen
+			_ = conn.Close()
+			return r
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			r.RTT = time.Since(start)
+			r.State = StateClosed
+			return r
+		}
+		// timeouts, unreachable hosts and the like are retried and eventually reported as filtered
+	}
+	return r
+}
@@ -0,0 +1,81 @@
+package synscan
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+type probeKey struct {
+	host [4]byte
+	port uint16
+}
+
+func ipKey(ip net.IP) [4]byte {
+	var k [4]byte
+	copy(k[:], ip.To4())
+	return k
+}
+
+// parseReply extracts the responder and TCP flags from an IPv4 packet addressed to our source port.
+func parseReply(pkt []byte, sport uint16) (probeKey, byte, bool) {
+	if len(pkt) < 20 || pkt[0]>>4 != 4 || pkt[9] != syscall.IPPROTO_TCP {
+		return probeKey{}, 0, false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if len(pkt) < ihl+20 {
+		return probeKey{}, 0, false
+	}
+	tcp := pkt[ihl:]
+	if binary.BigEndian.Uint16(tcp[2:4]) != sport {
+		return probeKey{}, 0, false
+	}
+	var k probeKey
+	copy(k.host[:], pkt[12:16])
+	k.port = binary.BigEndian.Uint16(tcp[0:2])
+	return k, tcp[13], true
+}
+
+// buildSYN builds a TCP SYN segment (with an MSS option) for a SOCK_RAW/IPPROTO_TCP socket;
+// the kernel prepends the IP header.
+func buildSYN(src, dst net.IP, sport, dport uint16, seq uint32) []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint16(b[0:2], sport)
+	binary.BigEndian.PutUint16(b[2:4], dport)
+	binary.BigEndian.PutUint32(b[4:8], seq)
+	b[12] = 6 << 4 // data offset: 6 words
+	b[13] = tcpFlagSYN
+	binary.BigEndian.PutUint16(b[14:16], 1024) // window
+	// MSS option (kind 2, len 4, 1460)
+	b[20], b[21] = 2, 4
+	binary.BigEndian.PutUint16(b[22:24], 1460)
+	binary.BigEndian.PutUint16(b[16:18], tcpChecksum(src.To4(), dst.To4(), b))
+	return b
+}
+
+func tcpChecksum(src, dst net.IP, segment []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+	add(src)
+	add(dst)
+	sum += uint32(syscall.IPPROTO_TCP)
+	sum += uint32(len(segment))
+	add(segment)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,133 @@
+package synscan
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxTargets protects against accidentally scanning huge networks (e.g. a /8).
+const maxTargets = 65536
+
+// ParsePorts parses a port specification such as "22,80,1000-1024" into a sorted, de-duplicated list.
+func ParsePorts(spec string) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty port specification")
+	}
+	seen := map[int]struct{}{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		start, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parsePort(hi)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		for p := start; p <= end; p++ {
+			seen[p] = struct{}{}
+		}
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("empty port specification")
+	}
+	out := make([]int, 0, len(seen))
+	for p := range seen {
+		out = append(out, p)
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
+
+// ParseTargets expands a host name, IP address or CIDR into the list of addresses to scan.
+// For IPv4 networks larger than /31 the network and broadcast addresses are skipped.
+func ParseTargets(ctx context.Context, target string) ([]net.IP, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("empty target")
+	}
+
+	if strings.Contains(target, "/") {
+		ip, ipnet, err := net.ParseCIDR(target)
+		if err != nil {
+			return nil, err
+		}
+		ones, bits := ipnet.Mask.Size()
+		if bits-ones > 16 {
+			return nil, fmt.Errorf("network %s is too large (max %d addresses)", target, maxTargets)
+		}
+		var out []net.IP
+		for cur := ip.Mask(ipnet.Mask); ipnet.Contains(cur); cur = nextIP(cur) {
+			out = append(out, cur)
+		}
+		if ip.To4() != nil && bits-ones >= 2 && len(out) > 2 {
+			out = out[1 : len(out)-1]
+		}
+		return out, nil
+	}
+
+	if ip := net.ParseIP(target); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	// Prefer IPv4, as the raw SYN engine only speaks IPv4.
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			return []net.IP{a.IP}, nil
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", target)
+	}
+	return []net.IP{addrs[0].IP}, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		n := binary.BigEndian.Uint32(v4) + 1
+		if n == 0 {
+			// wrapped around; nil is not contained in any network
+			return nil
+		}
+		out := make(net.IP, 4)
+		binary.BigEndian.PutUint32(out, n)
+		return out
+	}
+	out := make(net.IP, len(ip))
+	copy(out, ip)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i]++
+		if out[i] != 0 {
+			break
+		}
+	}
+	return out
+}
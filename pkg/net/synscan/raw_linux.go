@@ -0,0 +1,164 @@
+//go:build linux
+
+package synscan
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type probe struct {
+	result Result
+	sentAt time.Time
+	done   bool
+}
+
+// synScan sends hand-crafted SYN segments over a raw IPv4 socket and classifies replies:
+// SYN/ACK means open, RST means closed and silence (after all retries) means filtered.
+// The kernel answers the SYN/ACK with a RST on our behalf since no socket owns the source port.
+func (s *Scanner) synScan(c context.Context, hosts []net.IP, ports []int) ([]Result, error) {
+	for _, h := range hosts {
+		if h.To4() == nil {
+			return nil, fmt.Errorf("%w: %s is not an IPv4 address", errRawUnavailable, h)
+		}
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRawUnavailable, err)
+	}
+	defer syscall.Close(fd)
+
+	// Wake up the receive loop periodically so it can observe cancellation.
+	tv := syscall.NsecToTimeval((100 * time.Millisecond).Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return nil, fmt.Errorf("%w: %v", errRawUnavailable, err)
+	}
+
+	srcByHost := make(map[[4]byte]net.IP, len(hosts))
+	for _, h := range hosts {
+		src, err := sourceIPFor(h)
+		if err != nil {
+			return nil, err
+		}
+		srcByHost[ipKey(h)] = src
+	}
+
+	sport := uint16(40000 + rand.Intn(20000))
+	seq := rand.Uint32()
+
+	var mu sync.Mutex
+	probes := make(map[probeKey]*probe, len(hosts)*len(ports))
+	order := make([]probeKey, 0, len(hosts)*len(ports))
+	for _, h := range hosts {
+		for _, p := range ports {
+			k := probeKey{host: ipKey(h), port: uint16(p)}
+			probes[k] = &probe{result: Result{Host: h.To4(), Port: p, State: StateFiltered, Method: MethodSyn}}
+			order = append(order, k)
+		}
+	}
+
+	recvCtx, stopRecv := context.WithCancel(c)
+	recvDone := make(chan struct{})
+	go func() {
+		defer close(recvDone)
+		buf := make([]byte, 65535)
+		for recvCtx.Err() == nil {
+			n, _, rerr := syscall.Recvfrom(fd, buf, 0)
+			if rerr != nil {
+				continue
+			}
+			k, flags, ok := parseReply(buf[:n], sport)
+			if !ok {
+				continue
+			}
+			mu.Lock()
+			if pr, found := probes[k]; found && !pr.done {
+				switch {
+				case flags&(tcpFlagSYN|tcpFlagACK) == tcpFlagSYN|tcpFlagACK:
+					pr.result.State = StateOpen
+				case flags&tcpFlagRST != 0:
+					pr.result.State = StateClosed
+				default:
+					mu.Unlock()
+					continue
+				}
+				pr.result.RTT = time.Since(pr.sentAt)
+				pr.done = true
+			}
+			mu.Unlock()
+		}
+	}()
+
+	lim := newLimiter(s.Rate)
+	defer lim.Stop()
+
+	var scanErr error
+rounds:
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		sent := 0
+		for _, k := range order {
+			mu.Lock()
+			pr := probes[k]
+			skip := pr.done
+			mu.Unlock()
+			if skip {
+				continue
+			}
+			if err := lim.Wait(c); err != nil {
+				scanErr = err
+				break rounds
+			}
+			pkt := buildSYN(srcByHost[k.host], pr.result.Host, sport, k.port, seq)
+			mu.Lock()
+			pr.sentAt = time.Now()
+			mu.Unlock()
+			if err := syscall.Sendto(fd, pkt, 0, &syscall.SockaddrInet4{Addr: k.host}); err != nil {
+				s.logger.Debug("send SYN failed", zap.String("host", pr.result.Host.String()), zap.Int("port", int(k.port)), zap.Error(err))
+			}
+			sent++
+		}
+		if sent == 0 {
+			break
+		}
+		select {
+		case <-c.Done():
+			scanErr = c.Err()
+			break rounds
+		case <-time.After(s.Timeout):
+		}
+	}
+
+	stopRecv()
+	<-recvDone
+
+	mu.Lock()
+	defer mu.Unlock()
+	results := make([]Result, 0, len(order))
+	for _, k := range order {
+		pr := probes[k]
+		if scanErr != nil && !pr.done {
+			// interrupted before we could conclude anything about this port
+			continue
+		}
+		results = append(results, pr.result)
+	}
+	return results, scanErr
+}
+
+// sourceIPFor asks the routing table which local address would be used to reach dst.
+func sourceIPFor(dst net.IP) (net.IP, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.To4(), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package synscan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+)
+
+func (s *Scanner) synScan(c context.Context, hosts []net.IP, ports []int) ([]Result, error) {
+	return nil, fmt.Errorf("%w: not supported on %s", errRawUnavailable, runtime.GOOS)
+}
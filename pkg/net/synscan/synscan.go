@@ -1,15 +1,212 @@
 package synscan
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
 	"github.com/nexa/pkg/ctx"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+type State string
+
+const (
+	StateOpen     State = "open"
+	StateClosed   State = "closed"
+	StateFiltered State = "filtered"
+)
+
+const (
+	MethodSyn     = "syn"
+	MethodConnect = "connect"
+)
+
+// errRawUnavailable is returned when raw sockets cannot be used (non-linux, missing CAP_NET_RAW, IPv6 target).
+var errRawUnavailable = errors.New("raw socket scanning unavailable")
+
+// Result is the outcome of probing a single host:port.
+type Result struct {
+	Host   net.IP
+	Port   int
+	State  State
+	Method string
+	RTT    time.Duration
+}
+
 type Scanner struct {
 	ctx    *ctx.Ctx
 	logger *zap.Logger
+
+	// Command line flags.
+	Ports       string
+	Rate        int
+	Retries     int
+	Timeout     time.Duration
+	Workers     int
+	ConnectOnly bool
+	ShowAll     bool
 }
 
 func New(ctx *ctx.Ctx, logger *zap.Logger) *Scanner {
-	return &Scanner{ctx: ctx, logger: logger}
+	return &Scanner{
+		ctx:     ctx,
+		logger:  logger,
+		Ports:   "1-1024",
+		Rate:    1000,
+		Retries: 1,
+		Timeout: time.Second,
+		Workers: 256,
+	}
+}
+
+func (s *Scanner) ParseFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&s.Ports, "ports", "p", s.Ports, "ports to scan, e.g. 1-1024,8080")
+	cmd.Flags().IntVar(&s.Rate, "rate", s.Rate, "max probes per second (0 means unlimited)")
+	cmd.Flags().IntVar(&s.Retries, "retries", s.Retries, "number of retransmissions for unanswered probes")
+	cmd.Flags().DurationVar(&s.Timeout, "timeout", s.Timeout, "time to wait for a reply to each round of probes")
+	cmd.Flags().IntVar(&s.Workers, "workers", s.Workers, "concurrent connections in connect() mode")
+	cmd.Flags().BoolVar(&s.ConnectOnly, "connect", false, "use connect() scanning instead of raw SYN packets")
+	cmd.Flags().BoolVarP(&s.ShowAll, "all", "a", false, "show closed and filtered ports as well")
+}
+
+// Run scans target (host, IP or CIDR) and prints the results as a table.
+func (s *Scanner) Run(target string) error {
+	c := s.ctx.Context()
+
+	ports, err := ParsePorts(s.Ports)
+	if err != nil {
+		return err
+	}
+	hosts, err := ParseTargets(c, target)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	results, err := s.Scan(c, hosts, ports)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stdout, "scan interrupted; showing partial results")
+	}
+	return s.render(os.Stdout, results, time.Since(start))
+}
+
+// Scan probes every host:port pair. It uses raw SYN packets when possible and falls back
+// to connect() scanning when raw sockets are unavailable. Results are sorted by host and port.
+// On cancellation the results gathered so far are returned together with the context error.
+func (s *Scanner) Scan(c context.Context, hosts []net.IP, ports []int) ([]Result, error) {
+	var (
+		results []Result
+		err     error
+	)
+	if !s.ConnectOnly {
+		results, err = s.synScan(c, hosts, ports)
+		if errors.Is(err, errRawUnavailable) {
+			s.logger.Info("falling back to connect() scan", zap.Error(err))
+			results, err = s.connectScan(c, hosts, ports)
+		}
+	} else {
+		results, err = s.connectScan(c, hosts, ports)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if cmp := compareIP(results[i].Host, results[j].Host); cmp != 0 {
+			return cmp < 0
+		}
+		return results[i].Port < results[j].Port
+	})
+	return results, err
+}
+
+func (s *Scanner) render(w io.Writer, results []Result, elapsed time.Duration) error {
+	t := tablewriter.NewWriter(w)
+	t.Header([]string{"Host", "Port", "State", "Service", "RTT", "Method"})
+
+	counts := map[State]int{}
+	for _, r := range results {
+		counts[r.State]++
+		if r.State != StateOpen && !s.ShowAll {
+			continue
+		}
+		rtt := ""
+		if r.RTT > 0 {
+			rtt = r.RTT.Round(time.Microsecond).String()
+		}
+		_ = t.Append([]string{r.Host.String(), strconv.Itoa(r.Port) + "/tcp", string(r.State), serviceName(r.Port), rtt, r.Method})
+	}
+	if err := t.Render(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d probed: open=%d closed=%d filtered=%d in %s\n",
+		len(results), counts[StateOpen], counts[StateClosed], counts[StateFiltered], elapsed.Round(time.Millisecond))
+	return nil
+}
+
+func serviceName(port int) string {
+	return wellKnownServices[port]
+}
+
+var wellKnownServices = map[int]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "domain", 80: "http", 110: "pop3", 111: "rpcbind",
+	143: "imap", 443: "https", 445: "microsoft-ds", 993: "imaps", 995: "pop3s", 2379: "etcd-client",
+	2380: "etcd-server", 3306: "mysql", 5432: "postgresql", 6379: "redis", 6443: "kube-apiserver",
+	8080: "http-alt", 9090: "prometheus", 9100: "node-exporter", 10250: "kubelet", 27017: "mongodb",
+}
+
+func compareIP(a, b net.IP) int {
+	a16, b16 := a.To16(), b.To16()
+	for i := range a16 {
+		if a16[i] != b16[i] {
+			if a16[i] < b16[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// limiter paces probes to at most rate per second; a non-positive rate disables pacing.
+type limiter struct {
+	ticker *time.Ticker
+}
+
+func newLimiter(rate int) *limiter {
+	if rate <= 0 {
+		return &limiter{}
+	}
+	interval := time.Second / time.Duration(rate)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+	return &limiter{ticker: time.NewTicker(interval)}
+}
+
+func (l *limiter) Wait(c context.Context) error {
+	if l.ticker == nil {
+		return c.Err()
+	}
+	select {
+	case <-c.Done():
+		return c.Err()
+	case <-l.ticker.C:
+		return nil
+	}
+}
+
+func (l *limiter) Stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
 }
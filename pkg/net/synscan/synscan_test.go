@@ -0,0 +1,114 @@
+package synscan
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestParsePorts(t *testing.T) {
+	got, err := ParsePorts("80, 22,20-23,8080")
+	if err != nil {
+		t.Fatalf("ParsePorts error: %v", err)
+	}
+	want := []int{20, 21, 22, 23, 80, 8080}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParsePorts = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"", "0", "65536", "10-1", "a-b"} {
+		if _, err := ParsePorts(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseTargets_CIDR(t *testing.T) {
+	hosts, err := ParseTargets(context.Background(), "10.0.0.0/30")
+	if err != nil {
+		t.Fatalf("ParseTargets error: %v", err)
+	}
+	if len(hosts) != 2 || !hosts[0].Equal(net.ParseIP("10.0.0.1")) || !hosts[1].Equal(net.ParseIP("10.0.0.2")) {
+		t.Fatalf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestScan_Loopback(t *testing.T) {
+	openLn, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer openLn.Close()
+	go func() {
+		for {
+			c, err := openLn.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}
+	}()
+
+	closedLn, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedPort := closedLn.Addr().(*net.TCPAddr).Port
+	_ = closedLn.Close()
+
+	openPort := openLn.Addr().(*net.TCPAddr).Port
+	hosts := []net.IP{net.ParseIP("127.0.0.1")}
+	ports := []int{openPort, closedPort}
+
+	for _, connectOnly := range []bool{true, false} {
+		s := &Scanner{logger: zap.NewNop(), Rate: 0, Retries: 1, Timeout: 500 * time.Millisecond, Workers: 4, ConnectOnly: connectOnly}
+		results, err := s.Scan(context.Background(), hosts, ports)
+		if err != nil {
+			t.Fatalf("Scan(connectOnly=%v) error: %v", connectOnly, err)
+		}
+		states := map[int]State{}
+		for _, r := range results {
+			states[r.Port] = r.State
+		}
+		if states[openPort] != StateOpen {
+			t.Fatalf("connectOnly=%v: port %d state=%q, want open", connectOnly, openPort, states[openPort])
+		}
+		if states[closedPort] != StateClosed {
+			t.Fatalf("connectOnly=%v: port %d state=%q, want closed", connectOnly, closedPort, states[closedPort])
+		}
+	}
+}
+
+func TestScan_Cancelled(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := &Scanner{logger: zap.NewNop(), Rate: 10, Timeout: time.Second, Workers: 1, ConnectOnly: true}
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Scan(c, []net.IP{net.ParseIP("127.0.0.1")}, []int{1, 2, 3, 4, 5})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected context error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not stop after cancellation")
+	}
+}
+
+func TestTCPChecksum(t *testing.T) {
+	src := net.ParseIP("10.0.0.1").To4()
+	dst := net.ParseIP("10.0.0.2").To4()
+	seg := buildSYN(src, dst, 40000, 80, 1)
+	// Re-computing the checksum over a segment that already carries it must yield zero.
+	if got := tcpChecksum(src, dst, seg); got != 0 {
+		t.Fatalf("checksum verification = %#x, want 0", got)
+	}
+}
@@ -0,0 +1,436 @@
+package check
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+// Sample is one element of an instant vector.
+type Sample struct {
+	// Metric is the metric name; it is dropped by arithmetic and aggregation, like in PromQL.
+	Metric string
+	Labels []collector.Label
+	Value  float64
+}
+
+// value is the result of evaluating an expression: a scalar or an instant vector.
+type value struct {
+	scalar bool
+	s      float64
+	v      []Sample
+}
+
+func scalarValue(f float64) value  { return value{scalar: true, s: f} }
+func vectorValue(v []Sample) value { return value{v: v} }
+
+// Dataset is the input of an evaluation: the current collection and, for rate(), an earlier one.
+type Dataset struct {
+	now      time.Time
+	cur      map[string][]Sample
+	prev     map[string][]Sample
+	elapsed  time.Duration
+	hasPrior bool
+}
+
+// NewDataset indexes families collected at now. prev may be nil when no rule uses rate().
+func NewDataset(now time.Time, families []collector.MetricFamily, prev *collector.Snapshot) *Dataset {
+	d := &Dataset{now: now, cur: flatten(families)}
+	if prev != nil {
+		d.prev = flatten(prev.Families)
+		d.elapsed = now.Sub(prev.Time)
+		d.hasPrior = true
+	}
+	return d
+}
+
+// flatten expands families into scalar series; histograms and summaries contribute their
+// _bucket/_count/_sum and quantile series as in the exposition format.
+func flatten(families []collector.MetricFamily) map[string][]Sample {
+	out := map[string][]Sample{}
+	add := func(name string, labels []collector.Label, v float64) {
+		out[name] = append(out[name], Sample{Metric: name, Labels: labels, Value: v})
+	}
+	for _, f := range families {
+		for _, s := range f.Samples {
+			add(f.Name, s.Labels, s.Value)
+		}
+		for _, h := range f.Histograms {
+			for _, b := range h.Buckets {
+				add(f.Name+"_bucket", withLabel(h.Labels, "le", formatBound(b.UpperBound)), float64(b.Count))
+			}
+			add(f.Name+"_count", h.Labels, float64(h.Count))
+			add(f.Name+"_sum", h.Labels, h.Sum)
+		}
+		for _, s := range f.Summaries {
+			for _, q := range s.Quantiles {
+				add(f.Name, withLabel(s.Labels, "quantile", formatBound(q.Quantile)), q.Value)
+			}
+			add(f.Name+"_count", s.Labels, float64(s.Count))
+			add(f.Name+"_sum", s.Labels, s.Sum)
+		}
+	}
+	return out
+}
+
+func formatBound(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+func withLabel(labels []collector.Label, name, val string) []collector.Label {
+	out := make([]collector.Label, 0, len(labels)+1)
+	out = append(out, labels...)
+	out = append(out, collector.Label{Name: name, Value: val})
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Eval evaluates e. A scalar result is returned as a single sample without labels.
+func Eval(e Expr, d *Dataset) ([]Sample, error) {
+	v, err := d.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if v.scalar {
+		return []Sample{{Value: v.s}}, nil
+	}
+	return v.v, nil
+}
+
+func (d *Dataset) eval(e Expr) (value, error) {
+	switch e := e.(type) {
+	case numberLit:
+		return scalarValue(e.v), nil
+	case *parenExpr:
+		return d.eval(e.e)
+	case *vectorSelector:
+		if e.rng > 0 {
+			return value{}, fmt.Errorf("range selector %s must be used inside rate(), irate() or increase()", e)
+		}
+		return vectorValue(d.selectSeries(d.cur, e)), nil
+	case *unaryExpr:
+		v, err := d.eval(e.e)
+		if err != nil {
+			return value{}, err
+		}
+		if v.scalar {
+			return scalarValue(-v.s), nil
+		}
+		out := make([]Sample, len(v.v))
+		for i, s := range v.v {
+			out[i] = Sample{Labels: s.Labels, Value: -s.Value}
+		}
+		return vectorValue(out), nil
+	case *callExpr:
+		return d.evalCall(e)
+	case *aggregateExpr:
+		return d.evalAggregate(e)
+	case *binaryExpr:
+		return d.evalBinary(e)
+	}
+	return value{}, fmt.Errorf("unsupported expression %T", e)
+}
+
+func (d *Dataset) selectSeries(src map[string][]Sample, sel *vectorSelector) []Sample {
+	var out []Sample
+next:
+	for _, s := range src[sel.name] {
+		for _, m := range sel.matchers {
+			if !m.MatchesValue(labelValue(s.Labels, m.Name)) {
+				continue next
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func labelValue(labels []collector.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func (d *Dataset) evalCall(e *callExpr) (value, error) {
+	switch e.fn {
+	case "time":
+		return scalarValue(float64(d.now.UnixNano()) / 1e9), nil
+	case "abs":
+		v, err := d.eval(e.args[0])
+		if err != nil {
+			return value{}, err
+		}
+		if v.scalar {
+			return scalarValue(math.Abs(v.s)), nil
+		}
+		out := make([]Sample, len(v.v))
+		for i, s := range v.v {
+			out[i] = Sample{Labels: s.Labels, Value: math.Abs(s.Value)}
+		}
+		return vectorValue(out), nil
+	case "rate", "irate", "increase":
+		sel := e.args[0].(*vectorSelector)
+		if !d.hasPrior {
+			return value{}, fmt.Errorf("%s() needs two collections", e.fn)
+		}
+		prev := map[string]float64{}
+		for _, s := range d.selectSeries(d.prev, sel) {
+			prev[collector.SeriesKey(s.Labels)] = s.Value
+		}
+		var out []Sample
+		for _, s := range d.selectSeries(d.cur, sel) {
+			p, ok := prev[collector.SeriesKey(s.Labels)]
+			if !ok {
+				continue
+			}
+			r := collector.CounterRate(p, s.Value, d.elapsed)
+			if e.fn == "increase" {
+				r *= sel.rng.Seconds()
+			}
+			out = append(out, Sample{Labels: s.Labels, Value: r})
+		}
+		return vectorValue(out), nil
+	}
+	return value{}, fmt.Errorf("unknown function %s", e.fn)
+}
+
+func (d *Dataset) evalAggregate(e *aggregateExpr) (value, error) {
+	v, err := d.eval(e.e)
+	if err != nil {
+		return value{}, err
+	}
+	if v.scalar {
+		return value{}, fmt.Errorf("%s() expects a vector", e.op)
+	}
+	type group struct {
+		labels []collector.Label
+		vals   []float64
+	}
+	groups := map[string]*group{}
+	var order []string
+	for _, s := range v.v {
+		labels := groupLabels(s.Labels, e.grouping, !e.without)
+		k := collector.SeriesKey(labels)
+		g, ok := groups[k]
+		if !ok {
+			g = &group{labels: labels}
+			groups[k] = g
+			order = append(order, k)
+		}
+		g.vals = append(g.vals, s.Value)
+	}
+	out := make([]Sample, 0, len(groups))
+	for _, k := range order {
+		g := groups[k]
+		var r float64
+		switch e.op {
+		case "sum", "avg":
+			for _, x := range g.vals {
+				r += x
+			}
+			if e.op == "avg" {
+				r /= float64(len(g.vals))
+			}
+		case "count":
+			r = float64(len(g.vals))
+		case "min", "max":
+			r = g.vals[0]
+			for _, x := range g.vals[1:] {
+				if e.op == "min" && x < r || e.op == "max" && x > r || math.IsNaN(r) {
+					r = x
+				}
+			}
+		}
+		out = append(out, Sample{Labels: g.labels, Value: r})
+	}
+	return vectorValue(out), nil
+}
+
+// groupLabels keeps (include) or drops (!include) the named labels.
+func groupLabels(labels []collector.Label, names []string, include bool) []collector.Label {
+	set := map[string]bool{}
+	for _, n := range names {
+		set[n] = true
+	}
+	var out []collector.Label
+	for _, l := range labels {
+		if set[l.Name] == include {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func (d *Dataset) evalBinary(e *binaryExpr) (value, error) {
+	lhs, err := d.eval(e.lhs)
+	if err != nil {
+		return value{}, err
+	}
+	rhs, err := d.eval(e.rhs)
+	if err != nil {
+		return value{}, err
+	}
+
+	if isSetOp(e.op) {
+		if lhs.scalar || rhs.scalar {
+			return value{}, fmt.Errorf("%s is only defined between vectors", e.op)
+		}
+		return vectorValue(d.setOp(e, lhs.v, rhs.v)), nil
+	}
+
+	switch {
+	case lhs.scalar && rhs.scalar:
+		r, keep := apply(e.op, lhs.s, rhs.s)
+		if isComparison(e.op) && !e.returnBool {
+			return value{}, fmt.Errorf("comparisons between scalars must use bool")
+		}
+		if e.returnBool {
+			r = boolValue(keep)
+		}
+		return scalarValue(r), nil
+	case lhs.scalar || rhs.scalar:
+		vec, sc, vecLeft := lhs.v, rhs.s, true
+		if lhs.scalar {
+			vec, sc, vecLeft = rhs.v, lhs.s, false
+		}
+		var out []Sample
+		for _, s := range vec {
+			a, b := s.Value, sc
+			if !vecLeft {
+				a, b = sc, s.Value
+			}
+			r, keep := apply(e.op, a, b)
+			out = appendResult(out, e, s, r, keep, s.Value)
+		}
+		return vectorValue(out), nil
+	}
+
+	index := map[string][]Sample{}
+	for _, s := range rhs.v {
+		k := d.signature(e, s.Labels)
+		index[k] = append(index[k], s)
+	}
+	var out []Sample
+	for _, l := range lhs.v {
+		matches := index[d.signature(e, l.Labels)]
+		if len(matches) == 0 {
+			continue
+		}
+		if len(matches) > 1 {
+			return value{}, fmt.Errorf("%s: multiple matches on the right-hand side for %s; use on() or ignoring() or aggregate", e.op, collector.FormatLabels(l.Labels))
+		}
+		r, keep := apply(e.op, l.Value, matches[0].Value)
+		out = appendResult(out, e, l, r, keep, l.Value)
+	}
+	return vectorValue(out), nil
+}
+
+// appendResult adds a binary operation result: comparisons filter (keeping the left value and
+// metric name) unless bool is set; arithmetic drops the metric name.
+func appendResult(out []Sample, e *binaryExpr, s Sample, r float64, keep bool, orig float64) []Sample {
+	switch {
+	case e.returnBool:
+		return append(out, Sample{Labels: s.Labels, Value: boolValue(keep)})
+	case isComparison(e.op):
+		if keep {
+			return append(out, Sample{Metric: s.Metric, Labels: s.Labels, Value: orig})
+		}
+		return out
+	}
+	return append(out, Sample{Labels: s.Labels, Value: r})
+}
+
+func (d *Dataset) setOp(e *binaryExpr, lhs, rhs []Sample) []Sample {
+	right := map[string]bool{}
+	for _, s := range rhs {
+		right[d.signature(e, s.Labels)] = true
+	}
+	var out []Sample
+	switch e.op {
+	case "and", "unless":
+		for _, s := range lhs {
+			if right[d.signature(e, s.Labels)] == (e.op == "and") {
+				out = append(out, s)
+			}
+		}
+	case "or":
+		left := map[string]bool{}
+		out = append(out, lhs...)
+		for _, s := range lhs {
+			left[d.signature(e, s.Labels)] = true
+		}
+		for _, s := range rhs {
+			if !left[d.signature(e, s.Labels)] {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// signature is the match key of a vector element for binary operations.
+func (d *Dataset) signature(e *binaryExpr, labels []collector.Label) string {
+	switch {
+	case e.matchOn:
+		return collector.SeriesKey(groupLabels(labels, e.matching, true))
+	case e.matching != nil:
+		return collector.SeriesKey(groupLabels(labels, e.matching, false))
+	}
+	return collector.SeriesKey(labels)
+}
+
+// apply computes an arithmetic result or a comparison outcome.
+func apply(op string, a, b float64) (float64, bool) {
+	switch op {
+	case "+":
+		return a + b, true
+	case "-":
+		return a - b, true
+	case "*":
+		return a * b, true
+	case "/":
+		return a / b, true
+	case "%":
+		return math.Mod(a, b), true
+	case "==":
+		return a, a == b
+	case "!=":
+		return a, a != b
+	case ">":
+		return a, a > b
+	case "<":
+		return a, a < b
+	case ">=":
+		return a, a >= b
+	case "<=":
+		return a, a <= b
+	}
+	return math.NaN(), false
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// describe renders a sample as metric{labels} for findings.
+func describe(s Sample) string {
+	var b strings.Builder
+	b.WriteString(s.Metric)
+	if len(s.Labels) > 0 {
+		b.WriteString("{" + collector.FormatLabels(s.Labels) + "}")
+	}
+	return b.String()
+}
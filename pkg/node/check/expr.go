@@ -0,0 +1,553 @@
+package check
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+// The rule language is a small PromQL subset evaluated against one collection (two when a rule
+// uses rate): vector selectors, number literals, arithmetic, comparisons (filtering, or 0/1 with
+// bool), and/or/unless, on()/ignoring() matching, sum/avg/min/max/count by()/without(), and the
+// functions abs, rate, irate, increase and time. Many-to-one matches do not need group_left, and
+// vector operations keep the left-hand labels so findings still name the device or mountpoint.
+
+// Expr is a parsed rule expression.
+type Expr interface {
+	String() string
+}
+
+type numberLit struct{ v float64 }
+
+type vectorSelector struct {
+	name     string
+	matchers []*collector.LabelMatcher
+	// rng is set for matrix selectors such as x[5m], which are only valid inside rate functions.
+	rng time.Duration
+}
+
+type binaryExpr struct {
+	op       string
+	lhs, rhs Expr
+	// returnBool turns comparisons into 0/1 values instead of filters.
+	returnBool bool
+	// on/ignoring restrict the labels used to match vector elements.
+	matchOn  bool
+	matching []string
+}
+
+type unaryExpr struct {
+	op string
+	e  Expr
+}
+
+type aggregateExpr struct {
+	op      string
+	e       Expr
+	without bool
+	// grouping holds the by()/without() labels.
+	grouping []string
+}
+
+type callExpr struct {
+	fn   string
+	args []Expr
+}
+
+type parenExpr struct{ e Expr }
+
+func (n numberLit) String() string { return strconv.FormatFloat(n.v, 'g', -1, 64) }
+
+func (s *vectorSelector) String() string {
+	var b strings.Builder
+	b.WriteString(s.name)
+	if len(s.matchers) > 0 {
+		parts := make([]string, len(s.matchers))
+		for i, m := range s.matchers {
+			parts[i] = m.String()
+		}
+		b.WriteString("{" + strings.Join(parts, ",") + "}")
+	}
+	if s.rng > 0 {
+		b.WriteString("[" + s.rng.String() + "]")
+	}
+	return b.String()
+}
+
+func (e *binaryExpr) String() string {
+	op := e.op
+	if e.returnBool {
+		op += " bool"
+	}
+	if e.matching != nil || e.matchOn {
+		kw := "ignoring"
+		if e.matchOn {
+			kw = "on"
+		}
+		op += " " + kw + "(" + strings.Join(e.matching, ", ") + ")"
+	}
+	return e.lhs.String() + " " + op + " " + e.rhs.String()
+}
+
+func (e *unaryExpr) String() string { return e.op + e.e.String() }
+
+func (e *aggregateExpr) String() string {
+	s := e.op
+	if e.grouping != nil || e.without {
+		kw := "by"
+		if e.without {
+			kw = "without"
+		}
+		s += " " + kw + " (" + strings.Join(e.grouping, ", ") + ")"
+	}
+	return s + " (" + e.e.String() + ")"
+}
+
+func (e *callExpr) String() string {
+	args := make([]string, len(e.args))
+	for i, a := range e.args {
+		args[i] = a.String()
+	}
+	return e.fn + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (e *parenExpr) String() string { return "(" + e.e.String() + ")" }
+
+var aggregations = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+// functions maps supported function names to their argument count.
+var functions = map[string]int{"abs": 1, "rate": 1, "irate": 1, "increase": 1, "time": 0}
+
+// usesRate reports whether e needs a second collection.
+func usesRate(e Expr) bool {
+	switch e := e.(type) {
+	case *binaryExpr:
+		return usesRate(e.lhs) || usesRate(e.rhs)
+	case *unaryExpr:
+		return usesRate(e.e)
+	case *aggregateExpr:
+		return usesRate(e.e)
+	case *parenExpr:
+		return usesRate(e.e)
+	case *callExpr:
+		if e.fn == "rate" || e.fn == "irate" || e.fn == "increase" {
+			return true
+		}
+		for _, a := range e.args {
+			if usesRate(a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ---- lexer ----
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokDuration
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+func lex(input string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '[':
+			end := strings.IndexByte(input[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ at position %d", i)
+			}
+			toks = append(toks, token{tokDuration, strings.TrimSpace(input[i+1 : i+end]), i})
+			i += end + 1
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(input) && input[j] != c {
+				if input[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(input) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			raw := input[i : j+1]
+			if c == '\'' {
+				raw = `"` + strings.ReplaceAll(raw[1:len(raw)-1], `"`, `\"`) + `"`
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", i, err)
+			}
+			toks = append(toks, token{tokString, s, i})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(input) && input[i+1] >= '0' && input[i+1] <= '9':
+			j := i
+			for j < len(input) && (isIdentChar(input[j]) || input[j] == '.' ||
+				(input[j] == '+' || input[j] == '-') && (input[j-1] == 'e' || input[j-1] == 'E')) {
+				j++
+			}
+			toks = append(toks, token{tokNumber, input[i:j], i})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(input) && (isIdentChar(input[j]) || input[j] == ':') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, input[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, cand := range []string{"==", "!=", ">=", "<=", "=~", "!~", "+", "-", "*", "/", "%", ">", "<", "=", "(", ")", "{", "}", ","} {
+				if strings.HasPrefix(input[i:], cand) {
+					op = cand
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(input)}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
+
+// ---- parser ----
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// ParseExpr parses a rule expression.
+func ParseExpr(input string) (Expr, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.pos)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expectOp(op string) error {
+	if t := p.next(); t.kind != tokOp || t.val != op {
+		return fmt.Errorf("expected %q at position %d, got %q", op, t.pos, t.val)
+	}
+	return nil
+}
+
+// precedence of binary operators, lowest first.
+var precedence = map[string]int{
+	"or":  1,
+	"and": 2, "unless": 2,
+	"==": 3, "!=": 3, ">": 3, "<": 3, ">=": 3, "<=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+func isComparison(op string) bool { return precedence[op] == 3 }
+
+func isSetOp(op string) bool { return op == "and" || op == "or" || op == "unless" }
+
+func (p *parser) binaryOp() (string, bool) {
+	t := p.peek()
+	if t.kind == tokOp || t.kind == tokIdent {
+		if _, ok := precedence[t.val]; ok {
+			return t.val, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseBinary(minPrec int) (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.binaryOp()
+		if !ok || precedence[op] <= minPrec {
+			return lhs, nil
+		}
+		p.next()
+		be := &binaryExpr{op: op, lhs: lhs}
+		if t := p.peek(); t.kind == tokIdent && t.val == "bool" {
+			if !isComparison(op) {
+				return nil, fmt.Errorf("bool modifier used with non-comparison operator %s", op)
+			}
+			p.next()
+			be.returnBool = true
+		}
+		if t := p.peek(); t.kind == tokIdent && (t.val == "on" || t.val == "ignoring") {
+			p.next()
+			be.matchOn = t.val == "on"
+			if be.matching, err = p.parseLabelList(); err != nil {
+				return nil, err
+			}
+		}
+		if be.rhs, err = p.parseBinary(precedence[op]); err != nil {
+			return nil, err
+		}
+		lhs = be
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if t := p.peek(); t.kind == tokOp && (t.val == "-" || t.val == "+") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if t.val == "+" {
+			return e, nil
+		}
+		if n, ok := e.(numberLit); ok {
+			return numberLit{-n.v}, nil
+		}
+		return &unaryExpr{op: "-", e: e}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.val, t.pos)
+		}
+		return numberLit{v}, nil
+	case tokOp:
+		if t.val == "(" {
+			e, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return &parenExpr{e}, nil
+		}
+		if t.val == "{" {
+			p.pos--
+			return p.parseSelector("")
+		}
+	case tokIdent:
+		if strings.EqualFold(t.val, "inf") || strings.EqualFold(t.val, "nan") {
+			v, _ := strconv.ParseFloat(t.val, 64)
+			return numberLit{v}, nil
+		}
+		if aggregations[t.val] {
+			return p.parseAggregate(t.val)
+		}
+		if n, ok := functions[t.val]; ok && p.peek().val == "(" {
+			return p.parseCall(t.val, n)
+		}
+		return p.parseSelector(t.val)
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.pos)
+}
+
+func (p *parser) parseSelector(name string) (Expr, error) {
+	sel := &vectorSelector{name: name}
+	if t := p.peek(); t.kind == tokOp && t.val == "{" {
+		p.next()
+		for {
+			t := p.next()
+			if t.kind == tokOp && t.val == "}" {
+				break
+			}
+			if t.kind != tokIdent {
+				return nil, fmt.Errorf("expected label name at position %d, got %q", t.pos, t.val)
+			}
+			op := p.next()
+			if op.kind != tokOp {
+				return nil, fmt.Errorf("expected label matcher at position %d", op.pos)
+			}
+			val := p.next()
+			if val.kind != tokString {
+				return nil, fmt.Errorf("expected quoted label value at position %d", val.pos)
+			}
+			m, err := collector.NewLabelMatcher(collector.MatchType(op.val), t.val, val.val)
+			if err != nil {
+				return nil, err
+			}
+			sel.matchers = append(sel.matchers, m)
+			if n := p.peek(); n.kind == tokOp && n.val == "," {
+				p.next()
+			}
+		}
+	}
+	if sel.name == "" {
+		return nil, fmt.Errorf("selector without metric name is not supported")
+	}
+	if t := p.peek(); t.kind == tokDuration {
+		p.next()
+		d, err := parseRange(t.val)
+		if err != nil {
+			return nil, err
+		}
+		sel.rng = d
+	}
+	return sel, nil
+}
+
+// parseRange accepts Go durations plus the PromQL d/w units.
+func parseRange(s string) (time.Duration, error) {
+	mult := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		mult = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		mult = 7 * 24 * time.Hour
+	}
+	if mult > 0 {
+		n, err := strconv.Atoi(strings.TrimSpace(s[:len(s)-1]))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid range [%s]", s)
+		}
+		return time.Duration(n) * mult, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid range [%s]", s)
+	}
+	return d, nil
+}
+
+func (p *parser) parseLabelList() ([]string, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for {
+		t := p.next()
+		if t.kind == tokOp && t.val == ")" {
+			return labels, nil
+		}
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("expected label name at position %d, got %q", t.pos, t.val)
+		}
+		labels = append(labels, t.val)
+		if n := p.peek(); n.kind == tokOp && n.val == "," {
+			p.next()
+		}
+	}
+}
+
+func (p *parser) parseGrouping(agg *aggregateExpr) error {
+	t := p.peek()
+	if t.kind != tokIdent || t.val != "by" && t.val != "without" {
+		return nil
+	}
+	p.next()
+	agg.without = t.val == "without"
+	var err error
+	agg.grouping, err = p.parseLabelList()
+	return err
+}
+
+func (p *parser) parseAggregate(op string) (Expr, error) {
+	agg := &aggregateExpr{op: op}
+	if err := p.parseGrouping(agg); err != nil {
+		return nil, err
+	}
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	agg.e = e
+	if agg.grouping == nil {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+func (p *parser) parseCall(fn string, nargs int) (Expr, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	call := &callExpr{fn: fn}
+	for {
+		if t := p.peek(); t.kind == tokOp && t.val == ")" {
+			p.next()
+			break
+		}
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if t := p.peek(); t.kind == tokOp && t.val == "," {
+			p.next()
+		}
+	}
+	if len(call.args) != nargs {
+		return nil, fmt.Errorf("%s() takes %d argument(s), got %d", fn, nargs, len(call.args))
+	}
+	switch fn {
+	case "rate", "irate", "increase":
+		sel, ok := call.args[0].(*vectorSelector)
+		if !ok || sel.rng == 0 {
+			return nil, fmt.Errorf("%s() expects a range selector such as x[1m]", fn)
+		}
+	}
+	return call, nil
+}
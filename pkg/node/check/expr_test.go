@@ -0,0 +1,124 @@
+package check
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+func fs(mount string, v float64) collector.Sample {
+	return collector.Sample{Labels: []collector.Label{{Name: "fstype", Value: "ext4"}, {Name: "mountpoint", Value: mount}}, Value: v}
+}
+
+func testFamilies() []collector.MetricFamily {
+	return []collector.MetricFamily{
+		{Name: "node_filesystem_avail_bytes", Type: collector.MetricTypeGauge, Samples: []collector.Sample{fs("/", 5), fs("/data", 50)}},
+		{Name: "node_filesystem_size_bytes", Type: collector.MetricTypeGauge, Samples: []collector.Sample{fs("/", 100), fs("/data", 100)}},
+		{Name: "node_filesystem_readonly", Type: collector.MetricTypeGauge, Samples: []collector.Sample{fs("/", 0), fs("/data", 1)}},
+		{Name: "node_load1", Type: collector.MetricTypeGauge, Samples: []collector.Sample{{Value: 5}}},
+		{Name: "node_cpu_seconds_total", Type: collector.MetricTypeCounter, Samples: []collector.Sample{
+			{Labels: []collector.Label{{Name: "cpu", Value: "0"}, {Name: "mode", Value: "idle"}}, Value: 100},
+			{Labels: []collector.Label{{Name: "cpu", Value: "0"}, {Name: "mode", Value: "user"}}, Value: 10},
+			{Labels: []collector.Label{{Name: "cpu", Value: "1"}, {Name: "mode", Value: "idle"}}, Value: 200},
+		}},
+		{Name: "rpc_seconds", Type: collector.MetricTypeHistogram, Histograms: []collector.Histogram{{
+			Buckets: []collector.Bucket{{UpperBound: 0.1, Count: 3}, {UpperBound: math.Inf(1), Count: 4}}, Count: 4, Sum: 1,
+		}}},
+	}
+}
+
+func TestEval(t *testing.T) {
+	now := time.Unix(1000, 0)
+	prev := collector.NewSnapshot("", now.Add(-10*time.Second), nil, []collector.MetricFamily{
+		{Name: "node_cpu_seconds_total", Type: collector.MetricTypeCounter, Samples: []collector.Sample{
+			{Labels: []collector.Label{{Name: "cpu", Value: "0"}, {Name: "mode", Value: "idle"}}, Value: 90},
+		}},
+	})
+	d := NewDataset(now, testFamilies(), prev)
+
+	tests := []struct {
+		expr string
+		want map[string]float64 // series -> value
+	}{
+		{`node_load1`, map[string]float64{"node_load1": 5}},
+		{`node_filesystem_avail_bytes / node_filesystem_size_bytes < 0.1`, map[string]float64{`{fstype="ext4",mountpoint="/"}`: 0.05}},
+		{`node_filesystem_avail_bytes / node_filesystem_size_bytes < 0.9 and node_filesystem_readonly == 0`, map[string]float64{`{fstype="ext4",mountpoint="/"}`: 0.05}},
+		{`node_filesystem_avail_bytes unless on(mountpoint) node_filesystem_readonly == 0`, map[string]float64{`node_filesystem_avail_bytes{fstype="ext4",mountpoint="/data"}`: 50}},
+		{`node_load1 > 2 * count(node_cpu_seconds_total{mode="idle"})`, map[string]float64{"node_load1": 5}},
+		{`node_load1 > 3 * count(node_cpu_seconds_total{mode="idle"})`, map[string]float64{}},
+		{`sum by (mode) (node_cpu_seconds_total)`, map[string]float64{`{mode="idle"}`: 300, `{mode="user"}`: 10}},
+		{`max without (cpu, mode) (node_cpu_seconds_total)`, map[string]float64{"": 200}},
+		{`rate(node_cpu_seconds_total{mode="idle"}[1m])`, map[string]float64{`{cpu="0",mode="idle"}`: 1}},
+		{`node_load1 >= bool 5`, map[string]float64{"": 1}},
+		{`-node_load1 + 1`, map[string]float64{"": -4}},
+		{`abs(-3) * 2 - 1e1`, map[string]float64{"": -4}},
+		{`rpc_seconds_bucket{le="+Inf"} - ignoring(le) rpc_seconds_count`, map[string]float64{`{le="+Inf"}`: 0}},
+		{`(node_load1 > 1) or node_filesystem_readonly`, map[string]float64{"node_load1": 5, `node_filesystem_readonly{fstype="ext4",mountpoint="/"}`: 0, `node_filesystem_readonly{fstype="ext4",mountpoint="/data"}`: 1}},
+		{`time()`, map[string]float64{"": 1000}},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		got, err := Eval(e, d)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.expr, err)
+			continue
+		}
+		gotMap := map[string]float64{}
+		for _, s := range got {
+			gotMap[describe(s)] = s.Value
+		}
+		if len(gotMap) != len(tt.want) {
+			t.Errorf("Eval(%q) = %v, want %v", tt.expr, gotMap, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if g, ok := gotMap[k]; !ok || math.Abs(g-v) > 1e-9 {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, gotMap, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseExpr_Errors(t *testing.T) {
+	for _, bad := range []string{
+		``,
+		`node_load1 >`,
+		`sum(node_load1`,
+		`node_load1{mode=idle}`,
+		`rate(node_load1)`,
+		`abs(1, 2)`,
+		`node_load1 + bool 1`,
+		`{mode="idle"}`,
+		`node_load1[1x]`,
+		`node_load1 $ 2`,
+	} {
+		if _, err := ParseExpr(bad); err == nil {
+			t.Errorf("ParseExpr(%q): expected error", bad)
+		}
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	d := NewDataset(time.Now(), testFamilies(), nil)
+	for _, expr := range []string{
+		`rate(node_cpu_seconds_total[1m])`, // no previous collection
+		`node_cpu_seconds_total[1m]`,
+		`node_load1 / on() node_cpu_seconds_total`, // many matches on the right
+		`1 > 2`,
+	} {
+		e, err := ParseExpr(expr)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", expr, err)
+		}
+		if _, err := Eval(e, d); err == nil {
+			t.Errorf("Eval(%q): expected error", expr)
+		}
+	}
+}
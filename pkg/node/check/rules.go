@@ -0,0 +1,312 @@
+// Package check evaluates threshold rules against collected node metrics for `nexa node check`.
+package check
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed default_rules.yaml
+var defaultRulesYAML []byte
+
+// DefaultRulesYAML returns the built-in rule pack, e.g. as a starting point for a custom file.
+func DefaultRulesYAML() []byte { return append([]byte(nil), defaultRulesYAML...) }
+
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Rule fires for every series its expression returns.
+type Rule struct {
+	Name     string   `json:"name"`
+	Expr     string   `json:"expr"`
+	Severity Severity `json:"severity"`
+	// Summary is a text/template rendered per finding with $labels and $value (as in Prometheus
+	// alerting rules) and the percent and humanize functions.
+	Summary string `json:"summary,omitempty"`
+	// Collectors are the collectors the rule reads from. `nexa node check` runs the union of
+	// them unless --collect is given.
+	Collectors []string `json:"collectors,omitempty"`
+
+	expr    Expr
+	summary *template.Template
+}
+
+type ruleFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParseRules parses and validates a YAML rule file.
+func ParseRules(data []byte) ([]Rule, error) {
+	var f ruleFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: missing name", i+1)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+		switch r.Severity {
+		case SeverityWarning, SeverityCritical:
+		case "":
+			r.Severity = SeverityWarning
+		default:
+			return nil, fmt.Errorf("rule %s: unknown severity %q (want warning or critical)", r.Name, r.Severity)
+		}
+		e, err := ParseExpr(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		r.expr = e
+		if r.Summary != "" {
+			t, err := template.New(r.Name).Funcs(templateFuncs).Parse("{{$labels := .Labels}}{{$value := .Value}}" + r.Summary)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: summary: %w", r.Name, err)
+			}
+			r.summary = t
+		}
+	}
+	return f.Rules, nil
+}
+
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// DefaultRules returns the built-in pack: disk space and inodes, memory availability and PSI,
+// load, file descriptors, clock sync and skew, and conntrack table usage.
+func DefaultRules() []Rule {
+	rules, err := ParseRules(defaultRulesYAML)
+	if err != nil {
+		panic(fmt.Sprintf("default rules: %v", err))
+	}
+	return rules
+}
+
+// Collectors lists the collectors the rules ask for.
+func Collectors(rules []Rule) []string {
+	set := map[string]bool{}
+	for _, r := range rules {
+		for _, c := range r.Collectors {
+			set[c] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for c := range set {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NeedsRate reports whether any rule uses rate(), irate() or increase() and therefore needs two
+// collections.
+func NeedsRate(rules []Rule) bool {
+	for _, r := range rules {
+		if usesRate(r.expr) {
+			return true
+		}
+	}
+	return false
+}
+
+var templateFuncs = template.FuncMap{
+	"percent": func(v float64) string { return strconv.FormatFloat(v*100, 'f', 1, 64) + "%" },
+	"humanize": func(v float64) string {
+		if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) < 1000 {
+			return strconv.FormatFloat(v, 'g', 4, 64)
+		}
+		units := []string{"k", "M", "G", "T", "P"}
+		i := -1
+		for math.Abs(v) >= 1000 && i < len(units)-1 {
+			v /= 1000
+			i++
+		}
+		return strconv.FormatFloat(v, 'f', 2, 64) + units[i]
+	},
+}
+
+type summaryData struct {
+	Labels map[string]string
+	Value  float64
+}
+
+func (r *Rule) renderSummary(s Sample) string {
+	if r.summary == nil {
+		return ""
+	}
+	labels := map[string]string{}
+	for _, l := range s.Labels {
+		labels[l.Name] = l.Value
+	}
+	var b strings.Builder
+	if err := r.summary.Execute(&b, summaryData{Labels: labels, Value: s.Value}); err != nil {
+		return fmt.Sprintf("(summary: %v)", err)
+	}
+	return b.String()
+}
+
+// Status is the overall outcome, ordered by severity; the values are the Nagios exit codes.
+type Status int
+
+const (
+	StatusOK       Status = 0
+	StatusWarning  Status = 1
+	StatusCritical Status = 2
+	StatusUnknown  Status = 3
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "OK"
+	case StatusWarning:
+		return "WARNING"
+	case StatusCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+func (s Status) MarshalJSON() ([]byte, error) { return []byte(strconv.Quote(s.String())), nil }
+
+// Finding is one series returned by a rule.
+type Finding struct {
+	Rule     string            `json:"rule"`
+	Severity Severity          `json:"severity"`
+	Series   string            `json:"series"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Value    float64           `json:"value"`
+	Summary  string            `json:"summary,omitempty"`
+}
+
+// MarshalJSON writes a non-finite value, e.g. +Inf from a division by zero, as a null value with
+// its text in raw, since JSON numbers cannot express it.
+func (f Finding) MarshalJSON() ([]byte, error) {
+	type finding Finding
+	out := struct {
+		finding
+		Value *float64 `json:"value"`
+		Raw   string   `json:"raw,omitempty"`
+	}{finding: finding(f)}
+	if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) {
+		out.Raw = strconv.FormatFloat(f.Value, 'g', -1, 64)
+	} else {
+		out.Value = &f.Value
+	}
+	return json.Marshal(out)
+}
+
+// RuleError is a rule that could not be evaluated.
+type RuleError struct {
+	Rule  string `json:"rule"`
+	Error string `json:"error"`
+}
+
+// Report is the result of a check run.
+type Report struct {
+	Status   Status      `json:"status"`
+	Rules    int         `json:"rules"`
+	Findings []Finding   `json:"findings"`
+	Errors   []RuleError `json:"errors,omitempty"`
+	// CollectErrors are collectors that failed; they make the result UNKNOWN only when
+	// no rule produced a finding.
+	CollectErrors []string `json:"collect_errors,omitempty"`
+}
+
+// Evaluate runs every rule against d. Critical findings win over warnings, which win over rule
+// errors (UNKNOWN); a clean run is OK.
+func Evaluate(rules []Rule, d *Dataset) *Report {
+	rep := &Report{Rules: len(rules), Findings: []Finding{}}
+	for i := range rules {
+		r := &rules[i]
+		samples, err := Eval(r.expr, d)
+		if err != nil {
+			rep.Errors = append(rep.Errors, RuleError{Rule: r.Name, Error: err.Error()})
+			continue
+		}
+		for _, s := range samples {
+			f := Finding{Rule: r.Name, Severity: r.Severity, Series: describe(s), Value: s.Value, Summary: r.renderSummary(s)}
+			if len(s.Labels) > 0 {
+				f.Labels = map[string]string{}
+				for _, l := range s.Labels {
+					f.Labels[l.Name] = l.Value
+				}
+			}
+			rep.Findings = append(rep.Findings, f)
+		}
+	}
+	sort.SliceStable(rep.Findings, func(i, j int) bool {
+		return rep.Findings[i].Severity == SeverityCritical && rep.Findings[j].Severity != SeverityCritical
+	})
+	rep.Status = rep.status()
+	return rep
+}
+
+func (rep *Report) status() Status {
+	st := StatusOK
+	for _, f := range rep.Findings {
+		switch f.Severity {
+		case SeverityCritical:
+			return StatusCritical
+		case SeverityWarning:
+			st = StatusWarning
+		}
+	}
+	if st == StatusOK && (len(rep.Errors) > 0 || len(rep.CollectErrors) > 0) {
+		return StatusUnknown
+	}
+	return st
+}
+
+// SetCollectErrors records failed collectors and updates the status.
+func (rep *Report) SetCollectErrors(errs []string) {
+	rep.CollectErrors = errs
+	rep.Status = rep.status()
+}
+
+// Headline is the one-line Nagios plugin output, e.g. "NODE CRITICAL - 1 critical, 2 warning".
+func (rep *Report) Headline() string {
+	var crit, warn int
+	for _, f := range rep.Findings {
+		if f.Severity == SeverityCritical {
+			crit++
+		} else {
+			warn++
+		}
+	}
+	parts := []string{fmt.Sprintf("%d critical", crit), fmt.Sprintf("%d warning", warn)}
+	if n := len(rep.Errors); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d rule errors", n))
+	}
+	if n := len(rep.CollectErrors); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d collector errors", n))
+	}
+	return fmt.Sprintf("NODE %s - %s (%d rules)", rep.Status, strings.Join(parts, ", "), rep.Rules)
+}
@@ -0,0 +1,99 @@
+package check
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultRules(t *testing.T) {
+	rules := DefaultRules()
+	if len(rules) == 0 {
+		t.Fatal("empty default pack")
+	}
+	if !NeedsRate(rules) {
+		t.Fatal("the PSI rules use rate()")
+	}
+	want := []string{"conntrack", "cpu", "filefd", "filesystem", "loadavg", "meminfo", "pressure", "timex"}
+	if got := Collectors(rules); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Collectors() = %v, want %v", got, want)
+	}
+}
+
+func TestParseRules_Errors(t *testing.T) {
+	for name, doc := range map[string]string{
+		"missing name":   "rules:\n- expr: node_load1 > 1\n",
+		"bad severity":   "rules:\n- name: a\n  expr: node_load1 > 1\n  severity: page\n",
+		"bad expr":       "rules:\n- name: a\n  expr: node_load1 >\n",
+		"duplicate name": "rules:\n- name: a\n  expr: node_load1\n- name: a\n  expr: node_load5\n",
+		"unknown field":  "rules:\n- name: a\n  expr: node_load1\n  for: 5m\n",
+		"bad summary":    "rules:\n- name: a\n  expr: node_load1\n  summary: '{{ $value'\n",
+	} {
+		if _, err := ParseRules([]byte(doc)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+rules:
+- name: DiskFull
+  severity: critical
+  expr: node_filesystem_avail_bytes / node_filesystem_size_bytes < 0.1
+  summary: "{{ $labels.mountpoint }} has {{ $value | percent }} left"
+- name: Load
+  expr: node_load1 > 1
+- name: Broken
+  expr: rate(node_load1[1m])
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := Evaluate(rules, NewDataset(time.Now(), testFamilies(), nil))
+	if rep.Status != StatusCritical {
+		t.Fatalf("status = %s", rep.Status)
+	}
+	if len(rep.Findings) != 2 || rep.Findings[0].Rule != "DiskFull" || rep.Findings[0].Summary != "/ has 5.0% left" {
+		t.Fatalf("findings = %+v", rep.Findings)
+	}
+	if len(rep.Errors) != 1 || rep.Errors[0].Rule != "Broken" {
+		t.Fatalf("errors = %+v", rep.Errors)
+	}
+	if h := rep.Headline(); h != "NODE CRITICAL - 1 critical, 1 warning, 1 rule errors (3 rules)" {
+		t.Fatalf("headline = %q", h)
+	}
+
+	// Rule errors without findings are UNKNOWN.
+	rep = Evaluate(rules[2:], NewDataset(time.Now(), testFamilies(), nil))
+	if rep.Status != StatusUnknown {
+		t.Fatalf("status = %s, want UNKNOWN", rep.Status)
+	}
+	rep = Evaluate(nil, NewDataset(time.Now(), nil, nil))
+	if rep.Status != StatusOK {
+		t.Fatalf("status = %s, want OK", rep.Status)
+	}
+	rep.SetCollectErrors([]string{"timex: boom"})
+	if rep.Status != StatusUnknown {
+		t.Fatalf("status = %s, want UNKNOWN after collector errors", rep.Status)
+	}
+}
+
+func TestFindingJSON(t *testing.T) {
+	rep := &Report{Findings: []Finding{
+		{Rule: "Ratio", Value: math.Inf(1)},
+		{Rule: "Ratio", Value: math.NaN()},
+		{Rule: "Load", Value: 1.5},
+	}}
+	b, err := json.Marshal(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"value":null,"raw":"+Inf"`, `"value":null,"raw":"NaN"`, `"value":1.5}`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("JSON lacks %s:\n%s", want, b)
+		}
+	}
+}
@@ -0,0 +1,554 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CgroupStatsOptions limit which cgroups the cgroup_stats collector reports.
+type CgroupStatsOptions struct {
+	// MaxDepth is the deepest level reported below the root (the root is depth 0); 0 means
+	// no limit. Depth 4 reaches the containers of kubepods.slice/<qos>/<pod>.
+	MaxDepth int
+	// Include and Exclude match the cgroup path, e.g. /system.slice/nginx.service. Children of an
+	// excluded cgroup are still visited.
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+// DefaultCgroupStatsOptions matches the defaults of the nexa node flags.
+func DefaultCgroupStatsOptions() CgroupStatsOptions { return CgroupStatsOptions{MaxDepth: 4} }
+
+var (
+	cgroupStatsMu   sync.RWMutex
+	cgroupStatsOpts = DefaultCgroupStatsOptions()
+)
+
+// SetCgroupStatsOptions configures the cgroup_stats collector.
+func SetCgroupStatsOptions(opt CgroupStatsOptions) {
+	cgroupStatsMu.Lock()
+	cgroupStatsOpts = opt
+	cgroupStatsMu.Unlock()
+}
+
+func currentCgroupStatsOptions() CgroupStatsOptions {
+	cgroupStatsMu.RLock()
+	defer cgroupStatsMu.RUnlock()
+	return cgroupStatsOpts
+}
+
+type CgroupStatsCollector struct{}
+
+func NewCgroupStatsCollector() *CgroupStatsCollector { return &CgroupStatsCollector{} }
+
+func (c *CgroupStatsCollector) Name() string { return "cgroup_stats" }
+func (c *CgroupStatsCollector) Describe() string {
+	return "Exposes per-cgroup cpu, memory, io, pids and pressure statistics from /sys/fs/cgroup"
+}
+
+// cgroupStats is what one cgroup reports; nil pointers are files that do not exist for it.
+type cgroupStats struct {
+	path string
+
+	cpuUsage, cpuUser, cpuSystem          *float64
+	cpuPeriods, cpuThrottled, cpuThrottle *float64
+	memCurrent, memMax                    *float64
+	memEvents                             map[string]float64
+	io                                    map[string]*cgroupIO
+	pidsCurrent, pidsMax                  *float64
+	// pressure maps "cpu some" etc. to the total stall time in seconds.
+	pressure map[string]float64
+}
+
+type cgroupIO struct {
+	rbytes, wbytes, rios, wios float64
+}
+
+func (c *CgroupStatsCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
+	opt := currentCgroupStatsOptions()
+	root := sysFilePath("fs/cgroup")
+	var (
+		stats []*cgroupStats
+		err   error
+	)
+	if _, statErr := os.Stat(filepath.Join(root, "cgroup.controllers")); statErr == nil {
+		stats, err = walkCgroupV2(ctx, root, opt)
+	} else {
+		stats, err = walkCgroupV1(ctx, root, opt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cgroupFamilies(stats, blockDeviceNames()), nil
+}
+
+// cgroupDirs lists the cgroup directories below root in walk order, as paths relative to root
+// ("/" for root itself), honouring the depth limit and path filters.
+func cgroupDirs(ctx context.Context, root string, opt CgroupStatsOptions) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // cgroups come and go while walking
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = "/" + filepath.ToSlash(rel)
+		if rel == "/." {
+			rel = "/"
+		}
+		depth := 0
+		if rel != "/" {
+			depth = strings.Count(rel, "/")
+		}
+		if opt.MaxDepth > 0 && depth > opt.MaxDepth {
+			return filepath.SkipDir
+		}
+		if opt.Include != nil && !opt.Include.MatchString(rel) {
+			return nil
+		}
+		if opt.Exclude != nil && opt.Exclude.MatchString(rel) {
+			return nil
+		}
+		out = append(out, rel)
+		return nil
+	})
+	return out, err
+}
+
+func walkCgroupV2(ctx context.Context, root string, opt CgroupStatsOptions) ([]*cgroupStats, error) {
+	dirs, err := cgroupDirs(ctx, root, opt)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*cgroupStats, 0, len(dirs))
+	for _, rel := range dirs {
+		dir := filepath.Join(root, rel)
+		s := &cgroupStats{path: rel}
+		if kv := readKeyValues(filepath.Join(dir, "cpu.stat")); kv != nil {
+			usec := func(key string) *float64 {
+				if v, ok := kv[key]; ok {
+					v /= 1e6
+					return &v
+				}
+				return nil
+			}
+			s.cpuUsage, s.cpuUser, s.cpuSystem = usec("usage_usec"), usec("user_usec"), usec("system_usec")
+			s.cpuThrottle = usec("throttled_usec")
+			s.cpuPeriods, s.cpuThrottled = lookup(kv, "nr_periods"), lookup(kv, "nr_throttled")
+		}
+		s.memCurrent = readCgroupValue(filepath.Join(dir, "memory.current"))
+		s.memMax = readCgroupValue(filepath.Join(dir, "memory.max"))
+		s.memEvents = readKeyValues(filepath.Join(dir, "memory.events"))
+		s.io = readIOStatV2(filepath.Join(dir, "io.stat"))
+		s.pidsCurrent = readCgroupValue(filepath.Join(dir, "pids.current"))
+		s.pidsMax = readCgroupValue(filepath.Join(dir, "pids.max"))
+		for _, res := range []string{"cpu", "memory", "io"} {
+			for kind, v := range readPressure(filepath.Join(dir, res+".pressure")) {
+				if s.pressure == nil {
+					s.pressure = map[string]float64{}
+				}
+				s.pressure[res+" "+kind] = v
+			}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// walkCgroupV1 merges the cpu, cpuacct, memory, blkio and pids hierarchies by cgroup path.
+// cgroup v1 has no per-cgroup PSI files.
+func walkCgroupV1(ctx context.Context, root string, opt CgroupStatsOptions) ([]*cgroupStats, error) {
+	byPath := map[string]*cgroupStats{}
+	var order []string
+	get := func(rel string) *cgroupStats {
+		s, ok := byPath[rel]
+		if !ok {
+			s = &cgroupStats{path: rel}
+			byPath[rel] = s
+			order = append(order, rel)
+		}
+		return s
+	}
+	walk := func(controller string, fn func(dir string, s *cgroupStats)) error {
+		// cpu and cpuacct are usually symlinks to a shared cpu,cpuacct hierarchy.
+		hier, err := filepath.EvalSymlinks(filepath.Join(root, controller))
+		if err != nil {
+			return nil
+		}
+		dirs, err := cgroupDirs(ctx, hier, opt)
+		if err != nil {
+			return err
+		}
+		for _, rel := range dirs {
+			fn(filepath.Join(hier, rel), get(rel))
+		}
+		return nil
+	}
+
+	steps := []struct {
+		controller string
+		fn         func(dir string, s *cgroupStats)
+	}{
+		{"cpuacct", func(dir string, s *cgroupStats) {
+			if v := readCgroupValue(filepath.Join(dir, "cpuacct.usage")); v != nil {
+				*v /= 1e9
+				s.cpuUsage = v
+			}
+			if kv := readKeyValues(filepath.Join(dir, "cpuacct.stat")); kv != nil {
+				// user and system are in USER_HZ, which is 100 on every Linux architecture.
+				if v, ok := kv["user"]; ok {
+					v /= 100
+					s.cpuUser = &v
+				}
+				if v, ok := kv["system"]; ok {
+					v /= 100
+					s.cpuSystem = &v
+				}
+			}
+		}},
+		{"cpu", func(dir string, s *cgroupStats) {
+			if kv := readKeyValues(filepath.Join(dir, "cpu.stat")); kv != nil {
+				s.cpuPeriods, s.cpuThrottled = lookup(kv, "nr_periods"), lookup(kv, "nr_throttled")
+				if v, ok := kv["throttled_time"]; ok {
+					v /= 1e9
+					s.cpuThrottle = &v
+				}
+			}
+		}},
+		{"memory", func(dir string, s *cgroupStats) {
+			s.memCurrent = readCgroupValue(filepath.Join(dir, "memory.usage_in_bytes"))
+			// An unlimited v1 cgroup reports a page-rounded LONG_MAX.
+			if v := readCgroupValue(filepath.Join(dir, "memory.limit_in_bytes")); v != nil && *v < math.MaxInt64/2 {
+				s.memMax = v
+			}
+			if kv := readKeyValues(filepath.Join(dir, "memory.oom_control")); kv != nil {
+				if v, ok := kv["oom_kill"]; ok {
+					s.memEvents = map[string]float64{"oom_kill": v}
+				}
+			}
+		}},
+		{"blkio", func(dir string, s *cgroupStats) {
+			s.io = readBlkioV1(filepath.Join(dir, "blkio.throttle.io_service_bytes"), filepath.Join(dir, "blkio.throttle.io_serviced"))
+		}},
+		{"pids", func(dir string, s *cgroupStats) {
+			s.pidsCurrent = readCgroupValue(filepath.Join(dir, "pids.current"))
+			s.pidsMax = readCgroupValue(filepath.Join(dir, "pids.max"))
+		}},
+	}
+	for _, step := range steps {
+		if err := walk(step.controller, step.fn); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]*cgroupStats, 0, len(order))
+	for _, rel := range order {
+		out = append(out, byPath[rel])
+	}
+	return out, nil
+}
+
+func lookup(kv map[string]float64, key string) *float64 {
+	if v, ok := kv[key]; ok {
+		return &v
+	}
+	return nil
+}
+
+// readCgroupValue reads a single-number file; "max" (no limit) and missing files yield nil.
+func readCgroupValue(path string) *float64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// readKeyValues reads "key value" lines (cpu.stat, memory.events, ...).
+func readKeyValues(path string) map[string]float64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	out := map[string]float64{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			out[fields[0]] = v
+		}
+	}
+	return out
+}
+
+// readIOStatV2 reads io.stat lines such as "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0".
+func readIOStatV2(path string) map[string]*cgroupIO {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	out := map[string]*cgroupIO{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		st := &cgroupIO{}
+		for _, kv := range fields[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "rbytes":
+				st.rbytes = n
+			case "wbytes":
+				st.wbytes = n
+			case "rios":
+				st.rios = n
+			case "wios":
+				st.wios = n
+			}
+		}
+		out[fields[0]] = st
+	}
+	return out
+}
+
+// readBlkioV1 reads the "8:0 Read 123" lines of the v1 blkio throttle files.
+func readBlkioV1(bytesPath, iosPath string) map[string]*cgroupIO {
+	out := map[string]*cgroupIO{}
+	read := func(path string, set func(st *cgroupIO, op string, v float64)) {
+		f, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			fields := strings.Fields(sc.Text())
+			if len(fields) != 3 {
+				continue // the "Total" line
+			}
+			v, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				continue
+			}
+			st := out[fields[0]]
+			if st == nil {
+				st = &cgroupIO{}
+				out[fields[0]] = st
+			}
+			set(st, fields[1], v)
+		}
+	}
+	read(bytesPath, func(st *cgroupIO, op string, v float64) {
+		switch op {
+		case "Read":
+			st.rbytes = v
+		case "Write":
+			st.wbytes = v
+		}
+	})
+	read(iosPath, func(st *cgroupIO, op string, v float64) {
+		switch op {
+		case "Read":
+			st.rios = v
+		case "Write":
+			st.wios = v
+		}
+	})
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// readPressure reads a PSI file and returns the total stall seconds of its "some" and "full" lines.
+func readPressure(path string) map[string]float64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	out := map[string]float64{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		for _, kv := range fields[1:] {
+			if v, ok := strings.CutPrefix(kv, "total="); ok {
+				if n, err := strconv.ParseFloat(v, 64); err == nil {
+					out[fields[0]] = n / 1e6
+				}
+			}
+		}
+	}
+	return out
+}
+
+// blockDeviceNames maps "major:minor" to the device name from /proc/diskstats.
+func blockDeviceNames() map[string]string {
+	f, err := os.Open(procFilePath("diskstats"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	out := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 3 {
+			out[fields[0]+":"+fields[1]] = fields[2]
+		}
+	}
+	return out
+}
+
+var (
+	// kubelet pod cgroups: pod<uid> with the cgroupfs driver, kubepods-<qos>-pod<uid_with_underscores>.slice with systemd.
+	cgroupPodRe = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+	// Container scopes of containerd, CRI-O, docker and podman, or a bare 64 hex digit directory.
+	cgroupContainerRe = regexp.MustCompile(`^(?:(?:cri-containerd|crio|docker|containerd|libpod)-)?([0-9a-f]{64})(?:\.scope)?$`)
+)
+
+// cgroupLabels returns the cgroup label plus pod_uid and container_id when the path carries
+// container runtime metadata.
+func cgroupLabels(path string) []string {
+	kv := []string{"cgroup", path}
+	if m := cgroupPodRe.FindStringSubmatch(path); m != nil {
+		kv = append(kv, "pod_uid", strings.ReplaceAll(m[1], "_", "-"))
+	}
+	if m := cgroupContainerRe.FindStringSubmatch(filepath.Base(path)); m != nil {
+		kv = append(kv, "container_id", m[1])
+	}
+	return kv
+}
+
+func cgroupFamilies(stats []*cgroupStats, devices map[string]string) []MetricFamily {
+	type familyDef struct {
+		name, help string
+		typ        MetricType
+	}
+	defs := []familyDef{
+		{"node_cgroup_cpu_usage_seconds_total", "CPU time consumed by the cgroup.", MetricTypeCounter},
+		{"node_cgroup_cpu_user_seconds_total", "User CPU time consumed by the cgroup.", MetricTypeCounter},
+		{"node_cgroup_cpu_system_seconds_total", "System CPU time consumed by the cgroup.", MetricTypeCounter},
+		{"node_cgroup_cpu_periods_total", "Enforcement periods of the cgroup CPU quota.", MetricTypeCounter},
+		{"node_cgroup_cpu_throttled_periods_total", "Periods in which the cgroup was throttled.", MetricTypeCounter},
+		{"node_cgroup_cpu_throttled_seconds_total", "Time the cgroup was throttled.", MetricTypeCounter},
+		{"node_cgroup_memory_current_bytes", "Memory currently used by the cgroup.", MetricTypeGauge},
+		{"node_cgroup_memory_max_bytes", "Memory limit of the cgroup (absent when unlimited).", MetricTypeGauge},
+		{"node_cgroup_memory_events_total", "Memory events of the cgroup (memory.events), e.g. oom_kill.", MetricTypeCounter},
+		{"node_cgroup_io_read_bytes_total", "Bytes read by the cgroup, per device.", MetricTypeCounter},
+		{"node_cgroup_io_written_bytes_total", "Bytes written by the cgroup, per device.", MetricTypeCounter},
+		{"node_cgroup_io_reads_total", "Read operations of the cgroup, per device.", MetricTypeCounter},
+		{"node_cgroup_io_writes_total", "Write operations of the cgroup, per device.", MetricTypeCounter},
+		{"node_cgroup_pids_current", "Number of tasks in the cgroup.", MetricTypeGauge},
+		{"node_cgroup_pids_max", "Task limit of the cgroup (absent when unlimited).", MetricTypeGauge},
+		{"node_cgroup_pressure_waiting_seconds_total", "Time some tasks of the cgroup were stalled on the resource (PSI some).", MetricTypeCounter},
+		{"node_cgroup_pressure_stalled_seconds_total", "Time all tasks of the cgroup were stalled on the resource (PSI full).", MetricTypeCounter},
+	}
+	families := make(map[string]*MetricFamily, len(defs))
+	for _, d := range defs {
+		families[d.name] = &MetricFamily{Name: d.name, Help: d.help, Type: d.typ}
+	}
+	add := func(name string, v *float64, kv ...string) {
+		if v != nil {
+			f := families[name]
+			f.Samples = append(f.Samples, Sample{Labels: sortedLabels(kv...), Value: *v})
+		}
+	}
+	for _, s := range stats {
+		lbl := cgroupLabels(s.path)
+		with := func(kv ...string) []string { return append(append([]string(nil), lbl...), kv...) }
+		add("node_cgroup_cpu_usage_seconds_total", s.cpuUsage, lbl...)
+		add("node_cgroup_cpu_user_seconds_total", s.cpuUser, lbl...)
+		add("node_cgroup_cpu_system_seconds_total", s.cpuSystem, lbl...)
+		add("node_cgroup_cpu_periods_total", s.cpuPeriods, lbl...)
+		add("node_cgroup_cpu_throttled_periods_total", s.cpuThrottled, lbl...)
+		add("node_cgroup_cpu_throttled_seconds_total", s.cpuThrottle, lbl...)
+		add("node_cgroup_memory_current_bytes", s.memCurrent, lbl...)
+		add("node_cgroup_memory_max_bytes", s.memMax, lbl...)
+		for _, ev := range sortedKeys(s.memEvents) {
+			v := s.memEvents[ev]
+			add("node_cgroup_memory_events_total", &v, with("event", ev)...)
+		}
+		devs := make([]string, 0, len(s.io))
+		for dev := range s.io {
+			devs = append(devs, dev)
+		}
+		sort.Strings(devs)
+		for _, dev := range devs {
+			st := s.io[dev]
+			name := dev
+			if n, ok := devices[dev]; ok {
+				name = n
+			}
+			add("node_cgroup_io_read_bytes_total", &st.rbytes, with("device", name)...)
+			add("node_cgroup_io_written_bytes_total", &st.wbytes, with("device", name)...)
+			add("node_cgroup_io_reads_total", &st.rios, with("device", name)...)
+			add("node_cgroup_io_writes_total", &st.wios, with("device", name)...)
+		}
+		add("node_cgroup_pids_current", s.pidsCurrent, lbl...)
+		add("node_cgroup_pids_max", s.pidsMax, lbl...)
+		for _, key := range sortedKeys(s.pressure) {
+			res, kind, _ := strings.Cut(key, " ")
+			v := s.pressure[key]
+			switch kind {
+			case "some":
+				add("node_cgroup_pressure_waiting_seconds_total", &v, with("resource", res)...)
+			case "full":
+				add("node_cgroup_pressure_stalled_seconds_total", &v, with("resource", res)...)
+			}
+		}
+	}
+
+	out := make([]MetricFamily, 0, len(defs))
+	for _, d := range defs {
+		if f := families[d.name]; len(f.Samples) > 0 {
+			out = append(out, *f)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]float64) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCgroupLabels(t *testing.T) {
+	id := strings.Repeat("0123456789abcdef", 4)
+	tests := map[string]string{
+		"/system.slice/nginx.service": `cgroup="/system.slice/nginx.service"`,
+		"/kubepods/burstable/pod0d1b2c3d-4e5f-6789-abcd-ef0123456789/" + id: `cgroup="/kubepods/burstable/pod0d1b2c3d-4e5f-6789-abcd-ef0123456789/` + id +
+			`",container_id="` + id + `",pod_uid="0d1b2c3d-4e5f-6789-abcd-ef0123456789"`,
+		"/system.slice/docker-" + id + ".scope": `cgroup="/system.slice/docker-` + id + `.scope",container_id="` + id + `"`,
+		"/kubepods.slice/kubepods-pod0d1b2c3d_4e5f_6789_abcd_ef0123456789.slice/crio-" + id + ".scope": `cgroup="/kubepods.slice/kubepods-pod0d1b2c3d_4e5f_6789_abcd_ef0123456789.slice/crio-` + id +
+			`.scope",container_id="` + id + `",pod_uid="0d1b2c3d-4e5f-6789-abcd-ef0123456789"`,
+	}
+	for path, want := range tests {
+		if got := FormatLabels(sortedLabels(cgroupLabels(path)...)); got != want {
+			t.Errorf("cgroupLabels(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestCgroupDirsFilters(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"a/b/c/d", "system.slice/x.service", "user.slice"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		opt  CgroupStatsOptions
+		want string
+	}{
+		{CgroupStatsOptions{}, "/ /a /a/b /a/b/c /a/b/c/d /system.slice /system.slice/x.service /user.slice"},
+		{CgroupStatsOptions{MaxDepth: 1}, "/ /a /system.slice /user.slice"},
+		{CgroupStatsOptions{Include: regexp.MustCompile(`\.service$`)}, "/system.slice/x.service"},
+		{CgroupStatsOptions{MaxDepth: 2, Exclude: regexp.MustCompile(`^/a`)}, "/ /system.slice /system.slice/x.service /user.slice"},
+	}
+	for _, tt := range tests {
+		got, err := cgroupDirs(context.Background(), root, tt.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%+v: got %v, want %s", tt.opt, got, tt.want)
+		}
+	}
+}
+
+func TestCgroupStatsV1(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, "fs", "cgroup", path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("cpu,cpuacct/docker/cpuacct.usage", "2000000000\n")
+	write("cpu,cpuacct/docker/cpuacct.stat", "user 150\nsystem 50\n")
+	write("cpu,cpuacct/docker/cpu.stat", "nr_periods 10\nnr_throttled 2\nthrottled_time 500000000\n")
+	write("memory/docker/memory.usage_in_bytes", "4096\n")
+	write("memory/docker/memory.limit_in_bytes", "9223372036854771712\n")
+	write("memory/docker/memory.oom_control", "oom_kill_disable 0\nunder_oom 0\noom_kill 2\n")
+	write("blkio/docker/blkio.throttle.io_service_bytes", "8:0 Read 10\n8:0 Write 20\nTotal 30\n")
+	write("blkio/docker/blkio.throttle.io_serviced", "8:0 Read 1\n8:0 Write 2\nTotal 3\n")
+	write("pids/docker/pids.current", "3\n")
+	write("pids/docker/pids.max", "max\n")
+	for _, c := range []string{"cpu", "cpuacct"} {
+		if err := os.Symlink("cpu,cpuacct", filepath.Join(root, "fs", "cgroup", c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := CurrentPaths()
+	// No diskstats below root: devices keep their major:minor.
+	SetPaths(Paths{Sysfs: root, Procfs: root})
+	t.Cleanup(func() { SetPaths(old) })
+
+	families, err := NewCgroupStatsCollector().Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, f := range families {
+		for _, s := range f.Samples {
+			got[f.Name+"{"+FormatLabels(s.Labels)+"}"] = s.Value
+		}
+	}
+	want := map[string]float64{
+		`node_cgroup_cpu_usage_seconds_total{cgroup="/docker"}`:              2,
+		`node_cgroup_cpu_user_seconds_total{cgroup="/docker"}`:               1.5,
+		`node_cgroup_cpu_throttled_periods_total{cgroup="/docker"}`:          2,
+		`node_cgroup_cpu_throttled_seconds_total{cgroup="/docker"}`:          0.5,
+		`node_cgroup_memory_current_bytes{cgroup="/docker"}`:                 4096,
+		`node_cgroup_memory_events_total{cgroup="/docker",event="oom_kill"}`: 2,
+		`node_cgroup_io_written_bytes_total{cgroup="/docker",device="8:0"}`:  20,
+		`node_cgroup_io_reads_total{cgroup="/docker",device="8:0"}`:          1,
+		`node_cgroup_pids_current{cgroup="/docker"}`:                         3,
+	}
+	for k, v := range want {
+		if g, ok := got[k]; !ok || g != v {
+			t.Errorf("%s = %v (present %v), want %v", k, g, ok, v)
+		}
+	}
+	for k := range got {
+		if strings.HasPrefix(k, "node_cgroup_memory_max_bytes") || strings.HasPrefix(k, "node_cgroup_pids_max") {
+			t.Errorf("unlimited cgroup reported a limit: %s", k)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCollectTimeout bounds a single collector run when no timeout is configured.
+const DefaultCollectTimeout = 10 * time.Second
+
+// CollectOptions controls CollectMany.
+type CollectOptions struct {
+	// Parallelism caps the number of collectors running at once; <= 0 runs them all concurrently.
+	Parallelism int
+	// Timeout is the deadline of each collector; 0 disables it.
+	Timeout time.Duration
+	// Timeouts overrides Timeout for individual collectors.
+	Timeouts map[string]time.Duration
+}
+
+func (o CollectOptions) timeout(name string) time.Duration {
+	if d, ok := o.Timeouts[name]; ok {
+		return d
+	}
+	return o.Timeout
+}
+
+// CollectResult is the outcome of one collector run.
+type CollectResult struct {
+	Name     string
+	Families []MetricFamily
+	Duration time.Duration
+	Err      error
+}
+
+// TimedOut reports whether the collector was abandoned because its deadline passed.
+func (r CollectResult) TimedOut() bool { return errors.Is(r.Err, context.DeadlineExceeded) }
+
+// CollectMany runs the named collectors on a bounded worker pool and returns one result per name,
+// in the order of names. Each collector gets its own deadline derived from ctx; cancelling ctx
+// stops waiting for the remaining collectors.
+//
+// Collectors that ignore their context (most read procfs synchronously) cannot be interrupted: when
+// the deadline passes their result is discarded and the worker moves on, while the call itself
+// finishes in the background.
+//
+// Collectors on the upstream backend are not run one by one: they share a single scrape of the
+// node_exporter bridge, split back per collector, next to the worker pool.
+func (r *Registry) CollectMany(ctx context.Context, names []string, opt CollectOptions) []CollectResult {
+	results := make([]CollectResult, len(names))
+	var own, upstream []int
+	for i, name := range names {
+		if _, ok := r.collectors[name].(*UpstreamCollector); ok {
+			upstream = append(upstream, i)
+		} else {
+			own = append(own, i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	if len(upstream) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collectUpstream(ctx, names, upstream, opt, results)
+		}()
+	}
+
+	workers := opt.Parallelism
+	if workers <= 0 || workers > len(own) {
+		workers = len(own)
+	}
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = r.collectOne(ctx, names[i], opt.timeout(names[i]))
+			}
+		}()
+	}
+	for _, i := range own {
+		name := names[i]
+		if ctx.Err() != nil {
+			results[i] = CollectResult{Name: name, Err: ctx.Err()}
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (r *Registry) collectOne(ctx context.Context, name string, timeout time.Duration) CollectResult {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		families []MetricFamily
+		err      error
+	}
+	done := make(chan outcome, 1)
+	begin := time.Now()
+	go func() {
+		families, err := r.Collect(ctx, name)
+		done <- outcome{families, err}
+	}()
+
+	select {
+	case o := <-done:
+		return CollectResult{Name: name, Families: o.families, Duration: time.Since(begin), Err: o.err}
+	case <-ctx.Done():
+		return CollectResult{Name: name, Duration: time.Since(begin), Err: timeoutError(ctx.Err(), timeout)}
+	}
+}
+
+// timeoutError names the deadline of a collector that ran out of time.
+func timeoutError(err error, timeout time.Duration) error {
+	if timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return err
+}
+
+// ParseTimeoutSpec parses a --collector.timeout value: a default timeout optionally followed by
+// per-collector overrides, e.g. "10s" or "10s,filesystem=30s,nfs=2s". "0" disables the deadline.
+func ParseTimeoutSpec(spec string) (time.Duration, map[string]time.Duration, error) {
+	def := DefaultCollectTimeout
+	overrides := map[string]time.Duration{}
+	for i, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, isOverride := strings.Cut(part, "=")
+		if !isOverride {
+			if i != 0 {
+				return 0, nil, fmt.Errorf("invalid collector timeout %q: the default timeout must come first", spec)
+			}
+			value = name
+		}
+		d, err := parseTimeout(value)
+		if err != nil {
+			return 0, nil, err
+		}
+		if isOverride {
+			overrides[strings.TrimSpace(name)] = d
+		} else {
+			def = d
+		}
+	}
+	return def, overrides, nil
+}
+
+func parseTimeout(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid collector timeout %q (want e.g. 10s)", s)
+	}
+	return d, nil
+}
+
+// SortResultsByDuration orders results slowest first, e.g. for timing summaries.
+func SortResultsByDuration(results []CollectResult) {
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Duration > results[j].Duration })
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sleepCollector blocks for d, ignoring its context like the procfs collectors do.
+type sleepCollector struct {
+	name    string
+	d       time.Duration
+	running *int32
+	peak    *int32
+}
+
+func (c sleepCollector) Name() string     { return c.name }
+func (c sleepCollector) Describe() string { return "sleeps" }
+func (c sleepCollector) Collect(context.Context) ([]MetricFamily, error) {
+	if c.running != nil {
+		n := atomic.AddInt32(c.running, 1)
+		for {
+			p := atomic.LoadInt32(c.peak)
+			if n <= p || atomic.CompareAndSwapInt32(c.peak, p, n) {
+				break
+			}
+		}
+		defer atomic.AddInt32(c.running, -1)
+	}
+	time.Sleep(c.d)
+	return []MetricFamily{{Name: c.name, Type: MetricTypeGauge, Samples: []Sample{{Value: 1}}}}, nil
+}
+
+func TestCollectMany_Timeout(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterImplemented(sleepCollector{name: "fast"})
+	r.RegisterImplemented(sleepCollector{name: "hung", d: time.Hour})
+	r.RegisterImplemented(sleepCollector{name: "slowish", d: 50 * time.Millisecond})
+
+	begin := time.Now()
+	res := r.CollectMany(context.Background(), []string{"hung", "fast", "slowish", "missing"}, CollectOptions{
+		Timeout:  20 * time.Millisecond,
+		Timeouts: map[string]time.Duration{"slowish": time.Second},
+	})
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Fatalf("CollectMany waited %s for a hung collector", elapsed)
+	}
+	if len(res) != 4 || res[0].Name != "hung" || res[3].Name != "missing" {
+		t.Fatalf("results out of order: %+v", res)
+	}
+	if !res[0].TimedOut() {
+		t.Fatalf("hung: err = %v, want timeout", res[0].Err)
+	}
+	if res[1].Err != nil || len(res[1].Families) != 1 {
+		t.Fatalf("fast: %+v", res[1])
+	}
+	if res[2].Err != nil || res[2].Duration < 50*time.Millisecond {
+		t.Fatalf("slowish should use its own deadline: %+v", res[2])
+	}
+	if res[3].Err == nil || res[3].TimedOut() {
+		t.Fatalf("missing: err = %v", res[3].Err)
+	}
+}
+
+func TestCollectMany_Parallelism(t *testing.T) {
+	var running, peak int32
+	r := NewRegistry()
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	for _, n := range names {
+		r.RegisterImplemented(sleepCollector{name: n, d: 10 * time.Millisecond, running: &running, peak: &peak})
+	}
+	for _, res := range r.CollectMany(context.Background(), names, CollectOptions{Parallelism: 2}) {
+		if res.Err != nil {
+			t.Fatalf("%s: %v", res.Name, res.Err)
+		}
+	}
+	if peak != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", peak)
+	}
+}
+
+func TestCollectMany_Cancel(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterImplemented(sleepCollector{name: "hung", d: time.Hour})
+	r.RegisterImplemented(sleepCollector{name: "next"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	res := r.CollectMany(ctx, []string{"hung", "next"}, CollectOptions{Parallelism: 1})
+	for _, r := range res {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Fatalf("%s: err = %v, want canceled", r.Name, r.Err)
+		}
+	}
+}
+
+func TestCollectMany_Upstream(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("loadavg is read from procfs")
+	}
+	r := NewRegistry()
+	r.RegisterImplemented(sleepCollector{name: "fast"})
+	r.RegisterBackend(BackendUpstream, NewUpstreamCollector("loadavg", ""), "")
+
+	// loadavg goes through the bridge scrape, fast through the worker pool; the order is kept.
+	res := r.CollectMany(context.Background(), []string{"loadavg", "fast"}, CollectOptions{Parallelism: 1})
+	if res[0].Name != "loadavg" || res[0].Err != nil || len(res[0].Families) == 0 {
+		t.Fatalf("loadavg: %+v", res[0])
+	}
+	for _, mf := range res[0].Families {
+		if !strings.HasPrefix(mf.Name, "node_load") {
+			t.Errorf("loadavg returned %s", mf.Name)
+		}
+	}
+	if res[1].Name != "fast" || res[1].Err != nil {
+		t.Fatalf("fast: %+v", res[1])
+	}
+}
+
+func TestParseTimeoutSpec(t *testing.T) {
+	def, overrides, err := ParseTimeoutSpec("5s, filesystem=30s,nfs=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def != 5*time.Second || overrides["filesystem"] != 30*time.Second || overrides["nfs"] != 0 {
+		t.Fatalf("got %s %v", def, overrides)
+	}
+	if def, _, _ := ParseTimeoutSpec(""); def != DefaultCollectTimeout {
+		t.Fatalf("empty spec: got %s", def)
+	}
+	for _, bad := range []string{"soon", "cpu=1s,5s", "-1s"} {
+		if _, _, err := ParseTimeoutSpec(bad); err == nil {
+			t.Errorf("ParseTimeoutSpec(%q): expected error", bad)
+		}
+	}
+}
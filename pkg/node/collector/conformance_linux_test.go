@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// familyShape is what both backends must agree on: the metric type and the set of label-name sets.
+type familyShape struct {
+	typ       MetricType
+	labelSets map[string]bool
+}
+
+func shapes(families []MetricFamily) map[string]familyShape {
+	out := map[string]familyShape{}
+	add := func(f MetricFamily, labels []Label) {
+		names := make([]string, 0, len(labels))
+		for _, l := range labels {
+			names = append(names, l.Name)
+		}
+		sort.Strings(names)
+		s, ok := out[f.Name]
+		if !ok {
+			s = familyShape{typ: f.Type, labelSets: map[string]bool{}}
+			out[f.Name] = s
+		}
+		s.labelSets[strings.Join(names, ",")] = true
+	}
+	for _, f := range families {
+		// Scrape meta-metrics are produced by the upstream wrapper only.
+		if strings.HasPrefix(f.Name, "node_scrape_collector_") {
+			continue
+		}
+		for _, s := range f.Samples {
+			add(f, s.Labels)
+		}
+		for _, h := range f.Histograms {
+			add(f, h.Labels)
+		}
+		for _, s := range f.Summaries {
+			add(f, s.Labels)
+		}
+	}
+	return out
+}
+
+func setKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, "{"+k+"}")
+	}
+	sort.Strings(out)
+	return out
+}
+
+// TestNativeUpstreamConformance checks on the current host that every native collector exposes the
+// same metric names, types and label sets as the node_exporter implementation it replaces.
+func TestNativeUpstreamConformance(t *testing.T) {
+	for _, native := range NativeCollectors() {
+		native := native
+		t.Run(native.Name(), func(t *testing.T) {
+			up, err := NewUpstreamCollector(native.Name(), "").Collect(context.Background())
+			if err != nil {
+				t.Skipf("upstream %s unavailable on this host: %v", native.Name(), err)
+			}
+			got, err := native.Collect(context.Background())
+			if err != nil {
+				t.Fatalf("native %s: %v", native.Name(), err)
+			}
+
+			want, have := shapes(up), shapes(got)
+			for name, w := range want {
+				h, ok := have[name]
+				if !ok {
+					t.Errorf("%s: missing in native backend", name)
+					continue
+				}
+				if h.typ != w.typ {
+					t.Errorf("%s: type %s, upstream %s", name, h.typ, w.typ)
+				}
+				if a, b := setKeys(h.labelSets), setKeys(w.labelSets); strings.Join(a, " ") != strings.Join(b, " ") {
+					t.Errorf("%s: labels %v, upstream %v", name, a, b)
+				}
+			}
+			for name := range have {
+				if _, ok := want[name]; !ok {
+					t.Errorf("%s: only in native backend", name)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,26 @@
 package collector
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/shirou/gopsutil/v4/common"
 	"github.com/shirou/gopsutil/v4/cpu"
 )
 
-type CPUCollector struct{}
+// userHZ is the kernel USER_HZ used by /proc/stat; it is 100 on all supported architectures.
+const userHZ = 100
+
+type CPUCollector struct {
+	// Info adds node_cpu_info from /proc/cpuinfo (upstream --collector.cpu.info, off by default).
+	Info bool
+}
 
 func NewCPUCollector() *CPUCollector { return &CPUCollector{} }
 
@@ -16,8 +28,7 @@ func (c *CPUCollector) Name() string     { return "cpu" }
 func (c *CPUCollector) Describe() string { return "Exposes CPU statistics" }
 
 func (c *CPUCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	info, _ := cpu.InfoWithContext(ctx)
-	times, err := cpu.TimesWithContext(ctx, true)
+	stats, err := readProcStatCPUs()
 	if err != nil {
 		return nil, err
 	}
@@ -27,61 +38,236 @@ func (c *CPUCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
 		Help: "Seconds the CPUs spent in each mode.",
 		Type: MetricTypeCounter,
 	}
-	for _, t := range times {
-		// node_exporter uses cpu label like "0", "1" and includes mode label.
-		cpuLabel := strings.TrimPrefix(t.CPU, "cpu")
-		add := func(mode string, v float64) {
-			cpuSeconds.Samples = append(cpuSeconds.Samples, Sample{
-				Labels: []Label{
-					{Name: "cpu", Value: cpuLabel},
-					{Name: "mode", Value: mode},
-				},
-				Value: v,
-			})
+	cpuGuest := MetricFamily{
+		Name: "node_cpu_guest_seconds_total",
+		Help: "Seconds the CPUs spent in guests (VMs) for each mode.",
+		Type: MetricTypeCounter,
+	}
+	modes := []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+	for _, s := range stats {
+		for i, mode := range modes {
+			cpuSeconds.Samples = append(cpuSeconds.Samples, Sample{Labels: sortedLabels("cpu", s.cpu, "mode", mode), Value: s.ticks[i] / userHZ})
+		}
+		// Guest time is also accounted for in user and nice.
+		cpuGuest.Samples = append(cpuGuest.Samples,
+			Sample{Labels: sortedLabels("cpu", s.cpu, "mode", "user"), Value: s.ticks[8] / userHZ},
+			Sample{Labels: sortedLabels("cpu", s.cpu, "mode", "nice"), Value: s.ticks[9] / userHZ},
+		)
+	}
+	out := []MetricFamily{cpuSeconds, cpuGuest}
+
+	out = append(out, cpuThrottles()...)
+	if isolated := cpuIsolated(); len(isolated.Samples) > 0 {
+		out = append(out, isolated)
+	}
+	if online := cpuOnline(); len(online.Samples) > 0 {
+		out = append(out, online)
+	}
+
+	if c.Info {
+		info, err := cpuInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+type procStatCPU struct {
+	cpu string
+	// user nice system idle iowait irq softirq steal guest guest_nice, in USER_HZ ticks.
+	ticks [10]float64
+}
+
+func readProcStatCPUs() ([]procStatCPU, error) {
+	f, err := os.Open(procFilePath("stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []procStatCPU
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+		s := procStatCPU{cpu: strings.TrimPrefix(fields[0], "cpu")}
+		for i := 0; i < len(s.ticks) && i+1 < len(fields); i++ {
+			v, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s line in %s: %q", fields[0], procFilePath("stat"), sc.Text())
+			}
+			s.ticks[i] = v
+		}
+		out = append(out, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no cpu lines in %s", procFilePath("stat"))
+	}
+	return out, nil
+}
+
+func sysCPUDirs() []string {
+	dirs, _ := filepath.Glob(sysFilePath("devices/system/cpu/cpu[0-9]*"))
+	return dirs
+}
+
+// cpuThrottles reads thermal_throttle counters, once per package and once per core.
+func cpuThrottles() []MetricFamily {
+	pkgThrottles := MetricFamily{Name: "node_cpu_package_throttles_total", Help: "Number of times this CPU package has been throttled.", Type: MetricTypeCounter}
+	coreThrottles := MetricFamily{Name: "node_cpu_core_throttles_total", Help: "Number of times this CPU core has been throttled.", Type: MetricTypeCounter}
+
+	seenPkg := map[string]bool{}
+	seenCore := map[string]bool{}
+	for _, dir := range sysCPUDirs() {
+		pkg, err := readSysString(filepath.Join(dir, "topology", "physical_package_id"))
+		if err != nil {
+			continue
+		}
+		core, err := readSysString(filepath.Join(dir, "topology", "core_id"))
+		if err != nil {
+			continue
 		}
-		add("user", t.User)
-		add("nice", t.Nice)
-		add("system", t.System)
-		add("idle", t.Idle)
-		add("iowait", t.Iowait)
-		add("irq", t.Irq)
-		add("softirq", t.Softirq)
-		add("steal", t.Steal)
-		add("guest", t.Guest)
-		add("guest_nice", t.GuestNice)
-	}
-
-	cpuInfo := MetricFamily{
+		if !seenCore[pkg+"/"+core] {
+			seenCore[pkg+"/"+core] = true
+			if v, err := readSysUint(filepath.Join(dir, "thermal_throttle", "core_throttle_count")); err == nil {
+				coreThrottles.Samples = append(coreThrottles.Samples, Sample{Labels: sortedLabels("package", pkg, "core", core), Value: v})
+			}
+		}
+		if !seenPkg[pkg] {
+			seenPkg[pkg] = true
+			if v, err := readSysUint(filepath.Join(dir, "thermal_throttle", "package_throttle_count")); err == nil {
+				pkgThrottles.Samples = append(pkgThrottles.Samples, Sample{Labels: sortedLabels("package", pkg), Value: v})
+			}
+		}
+	}
+
+	var out []MetricFamily
+	for _, f := range []MetricFamily{pkgThrottles, coreThrottles} {
+		if len(f.Samples) > 0 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func cpuIsolated() MetricFamily {
+	mf := MetricFamily{Name: "node_cpu_isolated", Help: "Whether each core is isolated, information from /sys/devices/system/cpu/isolated.", Type: MetricTypeGauge}
+	s, err := readSysString(sysFilePath("devices/system/cpu/isolated"))
+	if err != nil {
+		return mf
+	}
+	cpus, err := parseCPUList(s)
+	if err != nil {
+		return mf
+	}
+	for _, n := range cpus {
+		mf.Samples = append(mf.Samples, Sample{Labels: sortedLabels("cpu", strconv.Itoa(n)), Value: 1})
+	}
+	return mf
+}
+
+// cpuOnline reports cpuN/online. Like upstream it is skipped entirely when cpu0 has no online
+// file, which is the case on kernels without CPU hotplug.
+func cpuOnline() MetricFamily {
+	mf := MetricFamily{Name: "node_cpu_online", Help: "CPUs that are online and being scheduled.", Type: MetricTypeGauge}
+	dirs := sysCPUDirs()
+	if len(dirs) == 0 {
+		return mf
+	}
+	if _, err := os.Stat(filepath.Join(dirs[0], "online")); err != nil {
+		return mf
+	}
+	for _, dir := range dirs {
+		v := 0.0
+		if s, _ := readSysString(filepath.Join(dir, "online")); s == "1" {
+			v = 1
+		}
+		mf.Samples = append(mf.Samples, Sample{Labels: sortedLabels("cpu", strings.TrimPrefix(filepath.Base(dir), "cpu")), Value: v})
+	}
+	return mf
+}
+
+// parseCPUList parses kernel cpu lists such as "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q", s)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list %q", s)
+			}
+		}
+		for n := start; n <= end; n++ {
+			out = append(out, n)
+		}
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+func cpuInfo(ctx context.Context) (MetricFamily, error) {
+	mf := MetricFamily{
 		Name: "node_cpu_info",
-		Help: "CPU information.",
+		Help: "CPU information from /proc/cpuinfo.",
 		Type: MetricTypeGauge,
 	}
+	// gopsutil reads /proc/cpuinfo and sysfs from HOST_PROC/HOST_SYS unless told otherwise.
+	ctx = context.WithValue(ctx, common.EnvKey, common.EnvMap{common.HostProcEnvKey: procPath, common.HostSysEnvKey: sysPath})
+	info, err := cpu.InfoWithContext(ctx)
+	if err != nil {
+		return mf, err
+	}
 	for _, ci := range info {
-		model := ci.ModelName
-		if len(model) > 64 {
-			model = model[:61] + "..."
-		}
-		flags := strings.Join(ci.Flags, ",")
-		cpuInfo.Samples = append(cpuInfo.Samples, Sample{
-			Labels: []Label{
-				{Name: "cpu", Value: fmt.Sprintf("%d", ci.CPU)},
-				{Name: "vendor_id", Value: ci.VendorID},
-				{Name: "family", Value: ci.Family},
-				{Name: "model", Value: ci.Model},
-				{Name: "model_name", Value: model},
-				{Name: "stepping", Value: fmt.Sprintf("%d", ci.Stepping)},
-				{Name: "microcode", Value: ci.Microcode},
-				{Name: "mhz", Value: fmt.Sprintf("%.0f", ci.Mhz)},
-				{Name: "cache_size", Value: fmt.Sprintf("%d", ci.CacheSize)},
-				{Name: "flags", Value: flags},
-			},
+		mf.Samples = append(mf.Samples, Sample{
+			Labels: sortedLabels(
+				"package", ci.PhysicalID,
+				"core", ci.CoreID,
+				"cpu", strconv.Itoa(int(ci.CPU)),
+				"vendor", ci.VendorID,
+				"family", ci.Family,
+				"model", ci.Model,
+				"model_name", ci.ModelName,
+				"microcode", ci.Microcode,
+				"stepping", strconv.Itoa(int(ci.Stepping)),
+				"cachesize", fmt.Sprintf("%d KB", ci.CacheSize),
+			),
 			Value: 1,
 		})
 	}
+	return mf, nil
+}
 
-	return []MetricFamily{
-		cpuSeconds,
-		cpuInfo,
-	}, nil
+func readSysString(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
 }
 
+func readSysUint(path string) (float64, error) {
+	s, err := readSysString(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return float64(v), nil
+}
@@ -5,7 +5,8 @@ import (
 )
 
 // NewDefaultRegistry registers collectors and placeholders based on the node_exporter README list.
-// In upstream mode, all collectors are marked implemented and collected via upstream node_exporter code.
+// Every collector has an upstream implementation (node_exporter code); the ones in this package are
+// also registered as native implementations and are preferred in the default auto backend mode.
 func NewDefaultRegistry(cctx *ctx.Ctx) *Registry {
 	_ = cctx
 
@@ -83,6 +84,7 @@ func NewDefaultRegistry(cctx *ctx.Ctx) *Registry {
 		// disabled-by-default
 		"buddyinfo": "Exposes statistics of memory fragments from /proc/buddyinfo.",
 		"cgroups": "Exposes cgroups summary.",
+		"cgroup_stats": "Exposes per-cgroup cpu, memory, io, pids and PSI statistics with pod/container labels.",
 		"cpu_vulnerabilities": "Exposes CPU vulnerability information from sysfs.",
 		"drm": "Expose GPU metrics using sysfs / DRM.",
 		"drbd": "Exposes DRBD statistics.",
@@ -97,6 +99,7 @@ func NewDefaultRegistry(cctx *ctx.Ctx) *Registry {
 		"pcidevice": "Exposes PCI device information.",
 		"perf": "Exposes perf based metrics.",
 		"processes": "Exposes aggregate process statistics from /proc.",
+		"processes_grouped": "Exposes CPU, memory, IO, fd and thread statistics per process group (process-exporter style).",
 		"qdisc": "Exposes queuing discipline statistics.",
 		"slabinfo": "Exposes slab statistics from /proc/slabinfo.",
 		"softirqs": "Exposes detailed softirq statistics.",
@@ -104,6 +107,7 @@ func NewDefaultRegistry(cctx *ctx.Ctx) *Registry {
 		"swap": "Expose swap information from /proc/swaps.",
 		"systemd": "Exposes service and system status from systemd.",
 		"tcpstat": "Exposes TCP connection status information.",
+		"tcp_sockets": "Exposes TCP socket states, queues, retransmits and congestion per port from sock_diag.",
 		"wifi": "Exposes WiFi device and station statistics.",
 		"xfrm": "Exposes statistics from /proc/net/xfrm_stat.",
 		"zoneinfo": "Exposes NUMA memory zone metrics.",
@@ -115,9 +119,30 @@ func NewDefaultRegistry(cctx *ctx.Ctx) *Registry {
 
 	all := append(append(append([]string(nil), enabledByDefault...), disabledByDefault...), deprecated...)
 	for _, name := range all {
-		r.RegisterImplemented(NewUpstreamCollector(name, desc[name]))
+		r.RegisterBackend(BackendUpstream, NewUpstreamCollector(name, desc[name]), desc[name])
+	}
+	for _, c := range NativeCollectors() {
+		r.RegisterBackend(BackendNative, c, desc[c.Name()])
 	}
 
 	return r
 }
 
+// NativeCollectors returns the hand-written collectors of this package.
+func NativeCollectors() []Collector {
+	return []Collector{
+		NewCgroupStatsCollector(),
+		NewCPUCollector(),
+		NewDiskstatsCollector(),
+		NewFilefdCollector(),
+		NewFilesystemCollector(),
+		NewLoadavgCollector(),
+		NewMeminfoCollector(),
+		NewNetdevCollector(),
+		NewOSCollector(),
+		NewProcessesGroupedCollector(),
+		NewTCPSocketsCollector(),
+		NewTimeCollector(),
+		NewUnameCollector(),
+	}
+}
@@ -0,0 +1,235 @@
+package collector
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// DiffOptions controls which gauge changes are reported.
+type DiffOptions struct {
+	// Threshold is the minimum change for a gauge to be reported. With Relative it is a
+	// fraction of the old value (0.05 = 5%), otherwise an absolute delta. 0 reports any change.
+	Threshold float64
+	Relative  bool
+}
+
+// SeriesChange is one series present in both snapshots.
+type SeriesChange struct {
+	Metric string
+	Type   MetricType
+	Labels []Label
+	Old    float64
+	New    float64
+	// Rate is the per-second increase for counters (NaN when the snapshots are not ordered in time).
+	Rate float64
+}
+
+func (c SeriesChange) Delta() float64 { return c.New - c.Old }
+
+// SeriesRef is a series present in only one of the snapshots.
+type SeriesRef struct {
+	Metric string
+	Type   MetricType
+	Labels []Label
+	Value  float64
+}
+
+type SnapshotDiff struct {
+	Before, After *Snapshot
+	Elapsed       time.Duration
+
+	Added   []SeriesRef
+	Removed []SeriesRef
+	// Changed holds gauges (and untyped series) whose change exceeds the threshold.
+	Changed []SeriesChange
+	// Counters holds counters (including histogram/summary _count and _sum) that increased or reset.
+	Counters []SeriesChange
+}
+
+// flatSeries is a scalar series; histograms and summaries contribute their _count and _sum as counters.
+type flatSeries struct {
+	metric string
+	typ    MetricType
+	labels []Label
+	value  float64
+}
+
+func flatten(families []MetricFamily) (map[string]flatSeries, []string) {
+	out := map[string]flatSeries{}
+	var order []string
+	add := func(s flatSeries) {
+		k := s.metric + "\x00" + SeriesKey(s.labels)
+		if _, ok := out[k]; !ok {
+			order = append(order, k)
+		}
+		out[k] = s
+	}
+	for _, f := range families {
+		for _, s := range f.Samples {
+			add(flatSeries{f.Name, f.Type, s.Labels, s.Value})
+		}
+		for _, h := range f.Histograms {
+			add(flatSeries{f.Name + "_count", MetricTypeCounter, h.Labels, float64(h.Count)})
+			add(flatSeries{f.Name + "_sum", MetricTypeCounter, h.Labels, h.Sum})
+		}
+		for _, s := range f.Summaries {
+			add(flatSeries{f.Name + "_count", MetricTypeCounter, s.Labels, float64(s.Count)})
+			add(flatSeries{f.Name + "_sum", MetricTypeCounter, s.Labels, s.Sum})
+		}
+	}
+	return out, order
+}
+
+// DiffSnapshots compares two snapshots series by series. Counter rates use the snapshot
+// timestamps, so they are only meaningful when after was taken later on the same host.
+func DiffSnapshots(before, after *Snapshot, opt DiffOptions) *SnapshotDiff {
+	d := &SnapshotDiff{Before: before, After: after, Elapsed: after.Time.Sub(before.Time)}
+
+	old, oldOrder := flatten(before.Families)
+	cur, curOrder := flatten(after.Families)
+
+	for _, k := range oldOrder {
+		if _, ok := cur[k]; !ok {
+			s := old[k]
+			d.Removed = append(d.Removed, SeriesRef{Metric: s.metric, Type: s.typ, Labels: s.labels, Value: s.value})
+		}
+	}
+	for _, k := range curOrder {
+		s := cur[k]
+		o, ok := old[k]
+		if !ok {
+			d.Added = append(d.Added, SeriesRef{Metric: s.metric, Type: s.typ, Labels: s.labels, Value: s.value})
+			continue
+		}
+		c := SeriesChange{Metric: s.metric, Type: s.typ, Labels: s.labels, Old: o.value, New: s.value, Rate: math.NaN()}
+		if s.typ == MetricTypeCounter {
+			if c.New == c.Old {
+				continue
+			}
+			if d.Elapsed > 0 {
+				c.Rate = CounterRate(c.Old, c.New, d.Elapsed)
+			}
+			d.Counters = append(d.Counters, c)
+			continue
+		}
+		if gaugeChanged(c.Old, c.New, opt) {
+			d.Changed = append(d.Changed, c)
+		}
+	}
+
+	sortRefs(d.Added)
+	sortRefs(d.Removed)
+	sortChanges(d.Changed)
+	sortChanges(d.Counters)
+	return d
+}
+
+func gaugeChanged(old, cur float64, opt DiffOptions) bool {
+	if math.IsNaN(old) || math.IsNaN(cur) {
+		return math.IsNaN(old) != math.IsNaN(cur)
+	}
+	if old == cur {
+		return false
+	}
+	delta := math.Abs(cur - old)
+	if opt.Relative {
+		if old == 0 {
+			return true
+		}
+		return delta/math.Abs(old) > opt.Threshold
+	}
+	return delta > opt.Threshold
+}
+
+func sortRefs(refs []SeriesRef) {
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].Metric != refs[j].Metric {
+			return refs[i].Metric < refs[j].Metric
+		}
+		return SeriesKey(refs[i].Labels) < SeriesKey(refs[j].Labels)
+	})
+}
+
+func sortChanges(cs []SeriesChange) {
+	sort.SliceStable(cs, func(i, j int) bool {
+		if cs[i].Metric != cs[j].Metric {
+			return cs[i].Metric < cs[j].Metric
+		}
+		return SeriesKey(cs[i].Labels) < SeriesKey(cs[j].Labels)
+	})
+}
+
+type jsonSeriesChange struct {
+	Metric string     `json:"metric"`
+	Type   MetricType `json:"type"`
+	Labels jsonLabels `json:"labels,omitempty"`
+	Old    jsonFloat  `json:"old"`
+	New    jsonFloat  `json:"new"`
+	Delta  jsonFloat  `json:"delta"`
+	Rate   *jsonFloat `json:"rate,omitempty"`
+}
+
+func (c SeriesChange) MarshalJSON() ([]byte, error) {
+	jc := jsonSeriesChange{
+		Metric: c.Metric, Type: c.Type, Labels: c.Labels,
+		Old: jsonFloat(c.Old), New: jsonFloat(c.New), Delta: jsonFloat(c.Delta()),
+	}
+	if c.Type == MetricTypeCounter && !math.IsNaN(c.Rate) {
+		r := jsonFloat(c.Rate)
+		jc.Rate = &r
+	}
+	return json.Marshal(jc)
+}
+
+type jsonSeriesRef struct {
+	Metric string     `json:"metric"`
+	Type   MetricType `json:"type"`
+	Labels jsonLabels `json:"labels,omitempty"`
+	Value  jsonFloat  `json:"value"`
+}
+
+func (r SeriesRef) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSeriesRef{Metric: r.Metric, Type: r.Type, Labels: r.Labels, Value: jsonFloat(r.Value)})
+}
+
+type snapshotMeta struct {
+	Host string    `json:"host,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+type jsonSnapshotDiff struct {
+	Before         snapshotMeta   `json:"before"`
+	After          snapshotMeta   `json:"after"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+	Added          []SeriesRef    `json:"added"`
+	Removed        []SeriesRef    `json:"removed"`
+	Changed        []SeriesChange `json:"changed"`
+	Counters       []SeriesChange `json:"counters"`
+}
+
+// MarshalJSON emits the diff without the full snapshot contents.
+func (d *SnapshotDiff) MarshalJSON() ([]byte, error) {
+	nonNil := func(v []SeriesRef) []SeriesRef {
+		if v == nil {
+			return []SeriesRef{}
+		}
+		return v
+	}
+	nonNilC := func(v []SeriesChange) []SeriesChange {
+		if v == nil {
+			return []SeriesChange{}
+		}
+		return v
+	}
+	return json.Marshal(jsonSnapshotDiff{
+		Before:         snapshotMeta{Host: d.Before.Host, Time: d.Before.Time},
+		After:          snapshotMeta{Host: d.After.Host, Time: d.After.Time},
+		ElapsedSeconds: d.Elapsed.Seconds(),
+		Added:          nonNil(d.Added),
+		Removed:        nonNil(d.Removed),
+		Changed:        nonNilC(d.Changed),
+		Counters:       nonNilC(d.Counters),
+	})
+}
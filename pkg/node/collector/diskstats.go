@@ -1,44 +1,191 @@
 package collector
 
 import (
+	"bufio"
 	"context"
-	"sort"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diskstatsDefaultExclude matches node_exporter's default --collector.diskstats.device-exclude.
+const diskstatsDefaultExclude = `^(z?ram|loop|fd|(h|s|v|xv)d[a-z]|nvme\d+n\d+p)\d+$`
 
-	"github.com/shirou/gopsutil/v4/disk"
+const (
+	secondsPerTick = 1.0 / 1000.0
+	// Sectors in /proc/diskstats are always 512 bytes, whatever the device block size.
+	unixSectorSize = 512.0
 )
 
-type DiskstatsCollector struct{}
+type DiskstatsCollector struct {
+	// Exclude drops devices whose name matches; nil uses diskstatsDefaultExclude.
+	Exclude *regexp.Regexp
+}
 
 func NewDiskstatsCollector() *DiskstatsCollector { return &DiskstatsCollector{} }
 
 func (c *DiskstatsCollector) Name() string     { return "diskstats" }
 func (c *DiskstatsCollector) Describe() string { return "Exposes disk I/O statistics" }
 
+type diskstatsField struct {
+	name  string
+	help  string
+	typ   MetricType
+	scale float64
+}
+
+// diskstatsFields follow the /proc/diskstats columns after major, minor and device name.
+// Older kernels report fewer columns; missing trailing fields are simply not exposed.
+var diskstatsFields = []diskstatsField{
+	{"node_disk_reads_completed_total", "The total number of reads completed successfully.", MetricTypeCounter, 1},
+	{"node_disk_reads_merged_total", "The total number of reads merged.", MetricTypeCounter, 1},
+	{"node_disk_read_bytes_total", "The total number of bytes read successfully.", MetricTypeCounter, unixSectorSize},
+	{"node_disk_read_time_seconds_total", "The total number of seconds spent by all reads.", MetricTypeCounter, secondsPerTick},
+	{"node_disk_writes_completed_total", "The total number of writes completed successfully.", MetricTypeCounter, 1},
+	{"node_disk_writes_merged_total", "The number of writes merged.", MetricTypeCounter, 1},
+	{"node_disk_written_bytes_total", "The total number of bytes written successfully.", MetricTypeCounter, unixSectorSize},
+	{"node_disk_write_time_seconds_total", "This is the total number of seconds spent by all writes.", MetricTypeCounter, secondsPerTick},
+	{"node_disk_io_now", "The number of I/Os currently in progress.", MetricTypeGauge, 1},
+	{"node_disk_io_time_seconds_total", "Total seconds spent doing I/Os.", MetricTypeCounter, secondsPerTick},
+	{"node_disk_io_time_weighted_seconds_total", "The weighted # of seconds spent doing I/Os.", MetricTypeCounter, secondsPerTick},
+	{"node_disk_discards_completed_total", "The total number of discards completed successfully.", MetricTypeCounter, 1},
+	{"node_disk_discards_merged_total", "The total number of discards merged.", MetricTypeCounter, 1},
+	{"node_disk_discarded_sectors_total", "The total number of sectors discarded successfully.", MetricTypeCounter, 1},
+	{"node_disk_discard_time_seconds_total", "This is the total number of seconds spent by all discards.", MetricTypeCounter, secondsPerTick},
+	{"node_disk_flush_requests_total", "The total number of flush requests completed successfully", MetricTypeCounter, 1},
+	{"node_disk_flush_requests_time_seconds_total", "This is the total number of seconds spent by all flush requests.", MetricTypeCounter, secondsPerTick},
+}
+
+var diskstatsDefaultExcludeRe = regexp.MustCompile(diskstatsDefaultExclude)
+
 func (c *DiskstatsCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	m, err := disk.IOCountersWithContext(ctx)
+	_ = ctx
+	exclude := c.Exclude
+	if exclude == nil {
+		exclude = diskstatsDefaultExcludeRe
+	}
+
+	f, err := os.Open(procFilePath("diskstats"))
 	if err != nil {
 		return nil, err
 	}
-	devs := make([]string, 0, len(m))
-	for k := range m {
-		devs = append(devs, k)
+	defer f.Close()
+
+	info := MetricFamily{Name: "node_disk_info", Help: "Info of /sys/block/<block_device>.", Type: MetricTypeGauge}
+	fsInfo := MetricFamily{Name: "node_disk_filesystem_info", Help: "Info about disk filesystem.", Type: MetricTypeGauge}
+	dmInfo := MetricFamily{Name: "node_disk_device_mapper_info", Help: "Info about disk device mapper.", Type: MetricTypeGauge}
+	ata := map[string]*MetricFamily{
+		"ID_ATA_WRITE_CACHE":         {Name: "node_disk_ata_write_cache", Help: "ATA disk has a write cache.", Type: MetricTypeGauge},
+		"ID_ATA_WRITE_CACHE_ENABLED": {Name: "node_disk_ata_write_cache_enabled", Help: "ATA disk has its write cache enabled.", Type: MetricTypeGauge},
+		"ID_ATA_ROTATION_RATE_RPM":   {Name: "node_disk_ata_rotation_rate_rpm", Help: "ATA disk rotation rate in RPMs (0 for SSDs).", Type: MetricTypeGauge},
+	}
+	stats := make([]MetricFamily, len(diskstatsFields))
+	for i, fd := range diskstatsFields {
+		stats[i] = MetricFamily{Name: fd.name, Help: fd.help, Type: fd.typ}
 	}
-	sort.Strings(devs)
 
-	readBytes := MetricFamily{Name: "node_disk_read_bytes_total", Help: "The total number of bytes read successfully.", Type: MetricTypeCounter}
-	writtenBytes := MetricFamily{Name: "node_disk_written_bytes_total", Help: "The total number of bytes written successfully.", Type: MetricTypeCounter}
-	readsCompleted := MetricFamily{Name: "node_disk_reads_completed_total", Help: "The total number of reads completed successfully.", Type: MetricTypeCounter}
-	writesCompleted := MetricFamily{Name: "node_disk_writes_completed_total", Help: "The total number of writes completed successfully.", Type: MetricTypeCounter}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		major, minor, dev := fields[0], fields[1], fields[2]
+		if exclude.MatchString(dev) {
+			continue
+		}
+		labels := sortedLabels("device", dev)
+
+		udev, _ := readUdevProperties(major, minor)
+		serial := udev["SCSI_IDENT_SERIAL"]
+		if serial == "" {
+			serial = udev["ID_SERIAL_SHORT"]
+		}
+		if serial == "" {
+			serial = udev["ID_SERIAL"]
+		}
+		rotational, _ := readSysString(filepath.Join(sysFilePath("block"), dev, "queue", "rotational"))
+		if rotational == "" {
+			rotational = "0"
+		}
+		info.Samples = append(info.Samples, Sample{
+			Labels: sortedLabels("device", dev, "major", major, "minor", minor,
+				"path", udev["ID_PATH"], "wwn", udev["ID_WWN"], "model", udev["ID_MODEL"],
+				"serial", serial, "revision", udev["ID_REVISION"], "rotational", rotational),
+			Value: 1,
+		})
 
-	for _, dev := range devs {
-		s := m[dev]
-		labels := []Label{{Name: "device", Value: s.Name}}
-		readBytes.Samples = append(readBytes.Samples, Sample{Labels: labels, Value: float64(s.ReadBytes)})
-		writtenBytes.Samples = append(writtenBytes.Samples, Sample{Labels: labels, Value: float64(s.WriteBytes)})
-		readsCompleted.Samples = append(readsCompleted.Samples, Sample{Labels: labels, Value: float64(s.ReadCount)})
-		writesCompleted.Samples = append(writesCompleted.Samples, Sample{Labels: labels, Value: float64(s.WriteCount)})
+		for i, s := range fields[3:] {
+			if i >= len(diskstatsFields) {
+				break
+			}
+			v, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid line in %s: %q", procFilePath("diskstats"), sc.Text())
+			}
+			stats[i].Samples = append(stats[i].Samples, Sample{Labels: labels, Value: float64(v) * diskstatsFields[i].scale})
+		}
+
+		if fsType := udev["ID_FS_TYPE"]; fsType != "" {
+			fsInfo.Samples = append(fsInfo.Samples, Sample{
+				Labels: sortedLabels("device", dev, "type", fsType, "usage", udev["ID_FS_USAGE"], "uuid", udev["ID_FS_UUID"], "version", udev["ID_FS_VERSION"]),
+				Value:  1,
+			})
+		}
+		if name := udev["DM_NAME"]; name != "" {
+			dmInfo.Samples = append(dmInfo.Samples, Sample{
+				Labels: sortedLabels("device", dev, "name", name, "uuid", udev["DM_UUID"], "vg_name", udev["DM_VG_NAME"], "lv_name", udev["DM_LV_NAME"], "lv_layer", udev["DM_LV_LAYER"]),
+				Value:  1,
+			})
+		}
+		if udev["ID_ATA"] != "" {
+			for attr, mf := range ata {
+				if v, err := strconv.ParseFloat(udev[attr], 64); err == nil {
+					mf.Samples = append(mf.Samples, Sample{Labels: labels, Value: v})
+				}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	out := append([]MetricFamily{info}, stats...)
+	out = append(out, fsInfo, dmInfo, *ata["ID_ATA_WRITE_CACHE"], *ata["ID_ATA_WRITE_CACHE_ENABLED"], *ata["ID_ATA_ROTATION_RATE_RPM"])
+	return nonEmptyFamilies(out), nil
+}
+
+// readUdevProperties returns the E: properties of a block device from the udev database.
+func readUdevProperties(major, minor string) (map[string]string, error) {
+	f, err := os.Open(filepath.Join(udevDataPath, "b"+major+":"+minor))
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	return []MetricFamily{readBytes, writtenBytes, readsCompleted, writesCompleted}, nil
+	props := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, ok := strings.CutPrefix(sc.Text(), "E:")
+		if !ok {
+			continue
+		}
+		if name, value, found := strings.Cut(line, "="); found {
+			props[name] = value
+		}
+	}
+	return props, sc.Err()
 }
 
+func nonEmptyFamilies(families []MetricFamily) []MetricFamily {
+	out := families[:0]
+	for _, f := range families {
+		if len(f.Samples)+len(f.Histograms)+len(f.Summaries) > 0 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
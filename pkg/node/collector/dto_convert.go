@@ -2,8 +2,10 @@ package collector
 
 import (
 	"sort"
+	"time"
 
 	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
 )
 
 func DTOToNexa(mfs []*dto.MetricFamily) ([]MetricFamily, error) {
@@ -57,10 +59,11 @@ func convertOneDTO(mf *dto.MetricFamily) (MetricFamily, error) {
 			}
 			sort.Slice(buckets, func(i, j int) bool { return buckets[i].UpperBound < buckets[j].UpperBound })
 			f.Histograms = append(f.Histograms, Histogram{
-				Labels:  convertDTOLabels(m.GetLabel()),
-				Buckets: buckets,
-				Count:   h.GetSampleCount(),
-				Sum:     h.GetSampleSum(),
+				Labels:    convertDTOLabels(m.GetLabel()),
+				Buckets:   buckets,
+				Count:     h.GetSampleCount(),
+				Sum:       h.GetSampleSum(),
+				Timestamp: dtoTimestamp(m),
 			})
 		}
 	case MetricTypeSummary:
@@ -79,6 +82,7 @@ func convertOneDTO(mf *dto.MetricFamily) (MetricFamily, error) {
 				Quantiles: qs,
 				Count:     s.GetSampleCount(),
 				Sum:       s.GetSampleSum(),
+				Timestamp: dtoTimestamp(m),
 			})
 		}
 	default:
@@ -88,8 +92,9 @@ func convertOneDTO(mf *dto.MetricFamily) (MetricFamily, error) {
 				continue
 			}
 			f.Samples = append(f.Samples, Sample{
-				Labels: convertDTOLabels(m.GetLabel()),
-				Value:  v,
+				Labels:    convertDTOLabels(m.GetLabel()),
+				Value:     v,
+				Timestamp: dtoTimestamp(m),
 			})
 		}
 	}
@@ -134,3 +139,110 @@ func dtoMetricValue(t dto.MetricType, m *dto.Metric) (float64, bool) {
 	}
 }
 
+// NexaToDTO converts MetricFamilies back into Prometheus dto metric families, e.g. to reuse
+// client_golang/expfmt encoders which take care of escaping and special float values.
+func NexaToDTO(families []MetricFamily) []*dto.MetricFamily {
+	out := make([]*dto.MetricFamily, 0, len(families))
+	for _, f := range families {
+		mf := &dto.MetricFamily{
+			Name: proto.String(f.Name),
+			Help: proto.String(f.Help),
+		}
+		switch f.Type {
+		case MetricTypeCounter:
+			mf.Type = dto.MetricType_COUNTER.Enum()
+		case MetricTypeGauge:
+			mf.Type = dto.MetricType_GAUGE.Enum()
+		case MetricTypeHistogram:
+			mf.Type = dto.MetricType_HISTOGRAM.Enum()
+		case MetricTypeSummary:
+			mf.Type = dto.MetricType_SUMMARY.Enum()
+		default:
+			mf.Type = dto.MetricType_UNTYPED.Enum()
+		}
+
+		switch f.Type {
+		case MetricTypeHistogram:
+			for _, h := range f.Histograms {
+				dh := &dto.Histogram{
+					SampleCount: proto.Uint64(h.Count),
+					SampleSum:   proto.Float64(h.Sum),
+				}
+				for _, b := range h.Buckets {
+					dh.Bucket = append(dh.Bucket, &dto.Bucket{
+						UpperBound:      proto.Float64(b.UpperBound),
+						CumulativeCount: proto.Uint64(b.Count),
+					})
+				}
+				mf.Metric = append(mf.Metric, &dto.Metric{
+					Label:       convertNexaLabels(h.Labels),
+					Histogram:   dh,
+					TimestampMs: timestampMs(h.Timestamp),
+				})
+			}
+		case MetricTypeSummary:
+			for _, s := range f.Summaries {
+				ds := &dto.Summary{
+					SampleCount: proto.Uint64(s.Count),
+					SampleSum:   proto.Float64(s.Sum),
+				}
+				for _, q := range s.Quantiles {
+					ds.Quantile = append(ds.Quantile, &dto.Quantile{
+						Quantile: proto.Float64(q.Quantile),
+						Value:    proto.Float64(q.Value),
+					})
+				}
+				mf.Metric = append(mf.Metric, &dto.Metric{
+					Label:       convertNexaLabels(s.Labels),
+					Summary:     ds,
+					TimestampMs: timestampMs(s.Timestamp),
+				})
+			}
+		default:
+			for _, s := range f.Samples {
+				m := &dto.Metric{
+					Label:       convertNexaLabels(s.Labels),
+					TimestampMs: timestampMs(s.Timestamp),
+				}
+				switch f.Type {
+				case MetricTypeCounter:
+					m.Counter = &dto.Counter{Value: proto.Float64(s.Value)}
+				case MetricTypeGauge:
+					m.Gauge = &dto.Gauge{Value: proto.Float64(s.Value)}
+				default:
+					m.Untyped = &dto.Untyped{Value: proto.Float64(s.Value)}
+				}
+				mf.Metric = append(mf.Metric, m)
+			}
+		}
+		out = append(out, mf)
+	}
+	return out
+}
+
+func convertNexaLabels(labels []Label) []*dto.LabelPair {
+	if len(labels) == 0 {
+		return nil
+	}
+	out := make([]*dto.LabelPair, 0, len(labels))
+	for _, l := range labels {
+		out = append(out, &dto.LabelPair{Name: proto.String(l.Name), Value: proto.String(l.Value)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetName() < out[j].GetName() })
+	return out
+}
+
+func dtoTimestamp(m *dto.Metric) *time.Time {
+	if m.TimestampMs == nil {
+		return nil
+	}
+	ts := time.UnixMilli(m.GetTimestampMs())
+	return &ts
+}
+
+func timestampMs(ts *time.Time) *int64 {
+	if ts == nil {
+		return nil
+	}
+	return proto.Int64(ts.UnixMilli())
+}
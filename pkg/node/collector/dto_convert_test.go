@@ -37,6 +37,42 @@ func TestDTOToNexa_Gauge(t *testing.T) {
 	}
 }
 
-func protoString(s string) *string { return &s }
+func protoString(s string) *string    { return &s }
 func protoFloat64(v float64) *float64 { return &v }
 
+func TestNexaToDTO_RoundTrip(t *testing.T) {
+	in := []MetricFamily{
+		{
+			Name: "node_test_seconds",
+			Help: "Test histogram.",
+			Type: MetricTypeHistogram,
+			Histograms: []Histogram{{
+				Labels:  []Label{{Name: "op", Value: "read"}},
+				Buckets: []Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 3}},
+				Count:   3,
+				Sum:     1.5,
+			}},
+		},
+		{
+			Name:    "node_test_total",
+			Help:    "Test counter.",
+			Type:    MetricTypeCounter,
+			Samples: []Sample{{Value: 42}},
+		},
+	}
+
+	out, err := DTOToNexa(NexaToDTO(in))
+	if err != nil {
+		t.Fatalf("DTOToNexa error: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 families, got %d", len(out))
+	}
+	h := out[0].Histograms[0]
+	if out[0].Type != MetricTypeHistogram || h.Count != 3 || h.Sum != 1.5 || len(h.Buckets) != 2 || h.Labels[0].Value != "read" {
+		t.Fatalf("unexpected histogram: %+v", out[0])
+	}
+	if out[1].Type != MetricTypeCounter || out[1].Samples[0].Value != 42 {
+		t.Fatalf("unexpected counter: %+v", out[1])
+	}
+}
@@ -18,7 +18,7 @@ func (c *FilefdCollector) Describe() string { return "Exposes file descriptor st
 
 func (c *FilefdCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
 	_ = ctx
-	f, err := os.Open("/proc/sys/fs/file-nr")
+	f, err := os.Open(procFilePath("sys/fs/file-nr"))
 	if err != nil {
 		return nil, err
 	}
@@ -26,21 +26,19 @@ func (c *FilefdCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
 
 	sc := bufio.NewScanner(f)
 	if !sc.Scan() {
-		return nil, fmt.Errorf("empty /proc/sys/fs/file-nr")
+		return nil, fmt.Errorf("empty %s", procFilePath("sys/fs/file-nr"))
 	}
 	parts := strings.Fields(sc.Text())
 	if len(parts) < 3 {
-		return nil, fmt.Errorf("unexpected /proc/sys/fs/file-nr format: %q", sc.Text())
+		return nil, fmt.Errorf("unexpected %s format: %q", procFilePath("sys/fs/file-nr"), sc.Text())
 	}
 	allocated, _ := strconv.ParseFloat(parts[0], 64)
-	unused, _ := strconv.ParseFloat(parts[1], 64)
+	// The second value (unused) is always zero since Linux 2.6.
 	max, _ := strconv.ParseFloat(parts[2], 64)
-	used := allocated - unused
 
 	return []MetricFamily{
 		{Name: "node_filefd_allocated", Help: "File descriptor statistics: allocated.", Type: MetricTypeGauge, Samples: []Sample{{Value: allocated}}},
 		{Name: "node_filefd_maximum", Help: "File descriptor statistics: maximum.", Type: MetricTypeGauge, Samples: []Sample{{Value: max}}},
-		{Name: "node_filefd_used", Help: "File descriptor statistics: used.", Type: MetricTypeGauge, Samples: []Sample{{Value: used}}},
 	}, nil
 }
 
@@ -1,47 +1,156 @@
 package collector
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Defaults of node_exporter's --collector.filesystem.mount-points-exclude and fs-types-exclude.
+const (
+	filesystemDefaultMountPointsExclude = `^/(dev|proc|run/credentials/.+|sys|var/lib/docker/.+|var/lib/containers/storage/.+)($|/)`
+	filesystemDefaultFSTypesExclude     = `^(autofs|binfmt_misc|bpf|cgroup2?|configfs|debugfs|devpts|devtmpfs|fusectl|hugetlbfs|iso9660|mqueue|nsfs|overlay|proc|procfs|pstore|rpc_pipefs|securityfs|selinuxfs|squashfs|erofs|sysfs|tracefs)$`
+)
 
-	"github.com/shirou/gopsutil/v4/disk"
+var (
+	filesystemDefaultMountPointsExcludeRe = regexp.MustCompile(filesystemDefaultMountPointsExclude)
+	filesystemDefaultFSTypesExcludeRe     = regexp.MustCompile(filesystemDefaultFSTypesExclude)
 )
 
-type FilesystemCollector struct{}
+type FilesystemCollector struct {
+	// MountPointsExclude and FSTypesExclude drop matching mounts; nil uses the node_exporter defaults.
+	MountPointsExclude *regexp.Regexp
+	FSTypesExclude     *regexp.Regexp
+}
 
 func NewFilesystemCollector() *FilesystemCollector { return &FilesystemCollector{} }
 
 func (c *FilesystemCollector) Name() string     { return "filesystem" }
 func (c *FilesystemCollector) Describe() string { return "Exposes filesystem statistics" }
 
+type mountEntry struct {
+	device, mountPoint, fsType string
+	major, minor               string
+	readOnly                   bool
+}
+
+type statfsResult struct {
+	size, free, avail float64
+	files, filesFree  float64
+}
+
+// statfs reads the usage of the filesystem mounted at path; tests replace it to get stable numbers.
+var statfs = sysStatfs
+
 func (c *FilesystemCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	parts, err := disk.PartitionsWithContext(ctx, true)
+	_ = ctx
+	mountExclude, typeExclude := c.MountPointsExclude, c.FSTypesExclude
+	if mountExclude == nil {
+		mountExclude = filesystemDefaultMountPointsExcludeRe
+	}
+	if typeExclude == nil {
+		typeExclude = filesystemDefaultFSTypesExcludeRe
+	}
+
+	mounts, err := readMountInfo()
 	if err != nil {
 		return nil, err
 	}
 
+	deviceError := MetricFamily{Name: "node_filesystem_device_error", Help: "Whether an error occurred while getting statistics for the given device.", Type: MetricTypeGauge}
+	readonly := MetricFamily{Name: "node_filesystem_readonly", Help: "Filesystem read-only status.", Type: MetricTypeGauge}
 	size := MetricFamily{Name: "node_filesystem_size_bytes", Help: "Filesystem size in bytes.", Type: MetricTypeGauge}
-	avail := MetricFamily{Name: "node_filesystem_avail_bytes", Help: "Filesystem space available to non-root users in bytes.", Type: MetricTypeGauge}
 	free := MetricFamily{Name: "node_filesystem_free_bytes", Help: "Filesystem free space in bytes.", Type: MetricTypeGauge}
-	files := MetricFamily{Name: "node_filesystem_files", Help: "Filesystem total number of inodes.", Type: MetricTypeGauge}
-	filesFree := MetricFamily{Name: "node_filesystem_files_free", Help: "Filesystem total number of free inodes.", Type: MetricTypeGauge}
+	avail := MetricFamily{Name: "node_filesystem_avail_bytes", Help: "Filesystem space available to non-root users in bytes.", Type: MetricTypeGauge}
+	files := MetricFamily{Name: "node_filesystem_files", Help: "Filesystem total file nodes.", Type: MetricTypeGauge}
+	filesFree := MetricFamily{Name: "node_filesystem_files_free", Help: "Filesystem total free file nodes.", Type: MetricTypeGauge}
+	mountInfo := MetricFamily{Name: "node_filesystem_mount_info", Help: "Filesystem mount information.", Type: MetricTypeGauge}
+	purgeable := MetricFamily{Name: "node_filesystem_purgeable_bytes", Help: "Filesystem space available including purgeable space (MacOS specific).", Type: MetricTypeGauge}
 
-	for _, p := range parts {
-		u, uerr := disk.UsageWithContext(ctx, p.Mountpoint)
-		if uerr != nil {
+	seen := map[string]bool{}
+	for _, m := range mounts {
+		if mountExclude.MatchString(m.mountPoint) || typeExclude.MatchString(m.fsType) {
+			continue
+		}
+		st, serr := statfs(rootfsFilePath(m.mountPoint))
+		errLabel := ""
+		if serr != nil {
+			errLabel = serr.Error()
+		}
+		labels := sortedLabels("device", m.device, "mountpoint", m.mountPoint, "fstype", m.fsType, "device_error", errLabel)
+		// The same device can be mounted on the same path more than once; expose it once.
+		key := SeriesKey(labels)
+		if seen[key] {
 			continue
 		}
-		labels := []Label{
-			{Name: "device", Value: p.Device},
-			{Name: "fstype", Value: p.Fstype},
-			{Name: "mountpoint", Value: p.Mountpoint},
+		seen[key] = true
+
+		ro := 0.0
+		if m.readOnly {
+			ro = 1
+		}
+		readonly.Samples = append(readonly.Samples, Sample{Labels: labels, Value: ro})
+		if serr != nil {
+			deviceError.Samples = append(deviceError.Samples, Sample{Labels: labels, Value: 1})
+			continue
 		}
-		size.Samples = append(size.Samples, Sample{Labels: labels, Value: float64(u.Total)})
-		avail.Samples = append(avail.Samples, Sample{Labels: labels, Value: float64(u.Free)}) // best-effort
-		free.Samples = append(free.Samples, Sample{Labels: labels, Value: float64(u.Free)})
-		files.Samples = append(files.Samples, Sample{Labels: labels, Value: float64(u.InodesTotal)})
-		filesFree.Samples = append(filesFree.Samples, Sample{Labels: labels, Value: float64(u.InodesFree)})
+		deviceError.Samples = append(deviceError.Samples, Sample{Labels: labels, Value: 0})
+		size.Samples = append(size.Samples, Sample{Labels: labels, Value: st.size})
+		free.Samples = append(free.Samples, Sample{Labels: labels, Value: st.free})
+		avail.Samples = append(avail.Samples, Sample{Labels: labels, Value: st.avail})
+		files.Samples = append(files.Samples, Sample{Labels: labels, Value: st.files})
+		filesFree.Samples = append(filesFree.Samples, Sample{Labels: labels, Value: st.filesFree})
+		purgeable.Samples = append(purgeable.Samples, Sample{Labels: labels, Value: 0})
+		mountInfo.Samples = append(mountInfo.Samples, Sample{
+			Labels: sortedLabels("device", m.device, "major", m.major, "minor", m.minor, "mountpoint", m.mountPoint),
+			Value:  1,
+		})
 	}
 
-	return []MetricFamily{size, avail, free, files, filesFree}, nil
+	return nonEmptyFamilies([]MetricFamily{deviceError, readonly, size, free, avail, files, filesFree, mountInfo, purgeable}), nil
 }
 
+// readMountInfo parses /proc/1/mountinfo, falling back to /proc/self/mountinfo when pid 1 is
+// hidden (hidepid).
+func readMountInfo() ([]mountEntry, error) {
+	f, err := os.Open(procFilePath("1/mountinfo"))
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		f, err = os.Open(procFilePath("self/mountinfo"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []mountEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		pre, post, ok := strings.Cut(sc.Text(), " - ")
+		if !ok {
+			return nil, fmt.Errorf("invalid mountinfo line: %q", sc.Text())
+		}
+		a, b := strings.Fields(pre), strings.Fields(post)
+		if len(a) < 6 || len(b) < 3 {
+			return nil, fmt.Errorf("invalid mountinfo line: %q", sc.Text())
+		}
+		major, minor, ok := strings.Cut(a[2], ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed mount point major:minor %q", a[2])
+		}
+		mountPoint := strings.NewReplacer(`\040`, " ", `\011`, "\t").Replace(a[4])
+		out = append(out, mountEntry{
+			device:     b[1],
+			mountPoint: rootfsStripPrefix(mountPoint),
+			fsType:     b[0],
+			major:      major,
+			minor:      minor,
+			readOnly:   slices.Contains(strings.Split(a[5], ","), "ro") || slices.Contains(strings.Split(b[2], ","), "ro"),
+		})
+	}
+	return out, sc.Err()
+}
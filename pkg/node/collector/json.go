@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+)
+
+// JSON encoding of the metric types. Labels are encoded as an object (stable key order), and
+// non-finite floats (NaN, +Inf, -Inf), which JSON numbers cannot represent, as strings,
+// the same way the Prometheus HTTP API does.
+
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+}
+
+func (f *jsonFloat) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*f = jsonFloat(v)
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = jsonFloat(v)
+	return nil
+}
+
+type jsonLabels []Label
+
+func (l jsonLabels) MarshalJSON() ([]byte, error) {
+	m := make(map[string]string, len(l))
+	for _, lbl := range l {
+		m[lbl.Name] = lbl.Value
+	}
+	return json.Marshal(m)
+}
+
+func (l *jsonLabels) UnmarshalJSON(b []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*l = LabelsFromMap(m)
+	return nil
+}
+
+type jsonSample struct {
+	Labels    jsonLabels `json:"labels,omitempty"`
+	Value     jsonFloat  `json:"value"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+func (s Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSample{Labels: s.Labels, Value: jsonFloat(s.Value), Timestamp: s.Timestamp})
+}
+
+func (s *Sample) UnmarshalJSON(b []byte) error {
+	var js jsonSample
+	if err := json.Unmarshal(b, &js); err != nil {
+		return err
+	}
+	*s = Sample{Labels: js.Labels, Value: float64(js.Value), Timestamp: js.Timestamp}
+	return nil
+}
+
+type jsonBucket struct {
+	UpperBound jsonFloat `json:"le"`
+	Count      uint64    `json:"count"`
+}
+
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonBucket{UpperBound: jsonFloat(b.UpperBound), Count: b.Count})
+}
+
+func (b *Bucket) UnmarshalJSON(data []byte) error {
+	var jb jsonBucket
+	if err := json.Unmarshal(data, &jb); err != nil {
+		return err
+	}
+	*b = Bucket{UpperBound: float64(jb.UpperBound), Count: jb.Count}
+	return nil
+}
+
+type jsonHistogram struct {
+	Labels    jsonLabels `json:"labels,omitempty"`
+	Buckets   []Bucket   `json:"buckets"`
+	Count     uint64     `json:"count"`
+	Sum       jsonFloat  `json:"sum"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+func (h Histogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonHistogram{Labels: h.Labels, Buckets: h.Buckets, Count: h.Count, Sum: jsonFloat(h.Sum), Timestamp: h.Timestamp})
+}
+
+func (h *Histogram) UnmarshalJSON(b []byte) error {
+	var jh jsonHistogram
+	if err := json.Unmarshal(b, &jh); err != nil {
+		return err
+	}
+	*h = Histogram{Labels: jh.Labels, Buckets: jh.Buckets, Count: jh.Count, Sum: float64(jh.Sum), Timestamp: jh.Timestamp}
+	return nil
+}
+
+type jsonQuantile struct {
+	Quantile jsonFloat `json:"quantile"`
+	Value    jsonFloat `json:"value"`
+}
+
+func (q Quantile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonQuantile{Quantile: jsonFloat(q.Quantile), Value: jsonFloat(q.Value)})
+}
+
+func (q *Quantile) UnmarshalJSON(b []byte) error {
+	var jq jsonQuantile
+	if err := json.Unmarshal(b, &jq); err != nil {
+		return err
+	}
+	*q = Quantile{Quantile: float64(jq.Quantile), Value: float64(jq.Value)}
+	return nil
+}
+
+type jsonSummary struct {
+	Labels    jsonLabels `json:"labels,omitempty"`
+	Quantiles []Quantile `json:"quantiles"`
+	Count     uint64     `json:"count"`
+	Sum       jsonFloat  `json:"sum"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+func (s Summary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSummary{Labels: s.Labels, Quantiles: s.Quantiles, Count: s.Count, Sum: jsonFloat(s.Sum), Timestamp: s.Timestamp})
+}
+
+func (s *Summary) UnmarshalJSON(b []byte) error {
+	var js jsonSummary
+	if err := json.Unmarshal(b, &js); err != nil {
+		return err
+	}
+	*s = Summary{Labels: js.Labels, Quantiles: js.Quantiles, Count: js.Count, Sum: float64(js.Sum), Timestamp: js.Timestamp}
+	return nil
+}
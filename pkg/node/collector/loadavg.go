@@ -2,8 +2,10 @@ package collector
 
 import (
 	"context"
-
-	"github.com/shirou/gopsutil/v4/load"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 )
 
 type LoadavgCollector struct{}
@@ -14,10 +16,21 @@ func (c *LoadavgCollector) Name() string     { return "loadavg" }
 func (c *LoadavgCollector) Describe() string { return "Exposes load average" }
 
 func (c *LoadavgCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	avg, err := load.AvgWithContext(ctx)
+	_ = ctx
+	b, err := os.ReadFile(procFilePath("loadavg"))
 	if err != nil {
 		return nil, err
 	}
+	parts := strings.Fields(string(b))
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("unexpected %s format: %q", procFilePath("loadavg"), string(b))
+	}
+	var loads [3]float64
+	for i := range loads {
+		if loads[i], err = strconv.ParseFloat(parts[i], 64); err != nil {
+			return nil, fmt.Errorf("unexpected %s format: %q", procFilePath("loadavg"), string(b))
+		}
+	}
 
 	return []MetricFamily{
 		{
@@ -25,7 +38,7 @@ func (c *LoadavgCollector) Collect(ctx context.Context) ([]MetricFamily, error)
 			Help: "1m load average.",
 			Type: MetricTypeGauge,
 			Samples: []Sample{
-				{Value: avg.Load1},
+				{Value: loads[0]},
 			},
 		},
 		{
@@ -33,7 +46,7 @@ func (c *LoadavgCollector) Collect(ctx context.Context) ([]MetricFamily, error)
 			Help: "5m load average.",
 			Type: MetricTypeGauge,
 			Samples: []Sample{
-				{Value: avg.Load5},
+				{Value: loads[1]},
 			},
 		},
 		{
@@ -41,9 +54,8 @@ func (c *LoadavgCollector) Collect(ctx context.Context) ([]MetricFamily, error)
 			Help: "15m load average.",
 			Type: MetricTypeGauge,
 			Samples: []Sample{
-				{Value: avg.Load15},
+				{Value: loads[2]},
 			},
 		},
 	}, nil
 }
-
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// LabelMatcher is a PromQL-style label selector such as device="sda", mountpoint!="/boot" or device=~"nvme.*".
+// As in PromQL, a missing label matches as the empty string and regular expressions are fully anchored.
+type LabelMatcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+
+	re *regexp.Regexp
+}
+
+func NewLabelMatcher(t MatchType, name, value string) (*LabelMatcher, error) {
+	m := &LabelMatcher{Name: name, Type: t, Value: value}
+	switch t {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp in matcher %s%s%q: %w", name, t, value, err)
+		}
+		m.re = re
+	default:
+		return nil, fmt.Errorf("unknown match type %q", t)
+	}
+	return m, nil
+}
+
+// ParseLabelMatcher parses "name=value", "name!=value", "name=~regex" or "name!~regex".
+// The value may optionally be double-quoted.
+func ParseLabelMatcher(s string) (*LabelMatcher, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, "=!")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid label matcher %q (want name=value, name!=value, name=~re or name!~re)", s)
+	}
+	name, rest := strings.TrimSpace(s[:i]), s[i:]
+
+	var t MatchType
+	switch {
+	case strings.HasPrefix(rest, "=~"):
+		t = MatchRegexp
+	case strings.HasPrefix(rest, "!~"):
+		t = MatchNotRegexp
+	case strings.HasPrefix(rest, "!="):
+		t = MatchNotEqual
+	case strings.HasPrefix(rest, "="):
+		t = MatchEqual
+	default:
+		return nil, fmt.Errorf("invalid label matcher %q (want name=value, name!=value, name=~re or name!~re)", s)
+	}
+	value := strings.TrimSpace(rest[len(t):])
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+	return NewLabelMatcher(t, name, value)
+}
+
+func (m *LabelMatcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+// MatchesValue reports whether a single label value satisfies the matcher.
+func (m *LabelMatcher) MatchesValue(v string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// Matches evaluates the matcher against a label set.
+func (m *LabelMatcher) Matches(labels []Label) bool {
+	v := ""
+	for _, l := range labels {
+		if l.Name == m.Name {
+			v = l.Value
+			break
+		}
+	}
+	return m.MatchesValue(v)
+}
+
+// Filter selects families by name and series by label matchers.
+type Filter struct {
+	Metric   *regexp.Regexp
+	Matchers []*LabelMatcher
+}
+
+// ParseFilter builds a Filter from an (anchored) metric name regexp and label selectors.
+// It returns nil when nothing is to be filtered.
+func ParseFilter(metricRegex string, selectors []string) (*Filter, error) {
+	f := &Filter{}
+	if metricRegex != "" {
+		re, err := regexp.Compile("^(?:" + metricRegex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric regexp %q: %w", metricRegex, err)
+		}
+		f.Metric = re
+	}
+	for _, s := range selectors {
+		m, err := ParseLabelMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		f.Matchers = append(f.Matchers, m)
+	}
+	if f.Metric == nil && len(f.Matchers) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// MatchesLabels reports whether all label matchers accept the label set.
+func (f *Filter) MatchesLabels(labels []Label) bool {
+	if f == nil {
+		return true
+	}
+	for _, m := range f.Matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply filters Samples, Histograms and Summaries alike. Families whose name does not match,
+// or that lose all of their series to the label matchers, are dropped.
+func (f *Filter) Apply(families []MetricFamily) []MetricFamily {
+	if f == nil {
+		return families
+	}
+	out := make([]MetricFamily, 0, len(families))
+	for _, mf := range families {
+		if f.Metric != nil && !f.Metric.MatchString(mf.Name) {
+			continue
+		}
+		if len(f.Matchers) == 0 {
+			out = append(out, mf)
+			continue
+		}
+		nf := mf
+		nf.Samples, nf.Histograms, nf.Summaries = nil, nil, nil
+		for _, s := range mf.Samples {
+			if f.MatchesLabels(s.Labels) {
+				nf.Samples = append(nf.Samples, s)
+			}
+		}
+		for _, h := range mf.Histograms {
+			if f.MatchesLabels(h.Labels) {
+				nf.Histograms = append(nf.Histograms, h)
+			}
+		}
+		for _, s := range mf.Summaries {
+			if f.MatchesLabels(s.Labels) {
+				nf.Summaries = append(nf.Summaries, s)
+			}
+		}
+		if len(nf.Samples)+len(nf.Histograms)+len(nf.Summaries) == 0 {
+			continue
+		}
+		out = append(out, nf)
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package collector
+
+import "testing"
+
+func TestParseLabelMatcher(t *testing.T) {
+	cases := []struct {
+		in    string
+		typ   MatchType
+		name  string
+		value string
+	}{
+		{"device=sda", MatchEqual, "device", "sda"},
+		{"mountpoint!=/boot", MatchNotEqual, "mountpoint", "/boot"},
+		{`device=~"nvme.*"`, MatchRegexp, "device", "nvme.*"},
+		{"fstype!~tmpfs|overlay", MatchNotRegexp, "fstype", "tmpfs|overlay"},
+	}
+	for _, c := range cases {
+		m, err := ParseLabelMatcher(c.in)
+		if err != nil {
+			t.Fatalf("ParseLabelMatcher(%q) error: %v", c.in, err)
+		}
+		if m.Type != c.typ || m.Name != c.name || m.Value != c.value {
+			t.Fatalf("ParseLabelMatcher(%q) = %+v", c.in, m)
+		}
+	}
+	for _, bad := range []string{"", "device", "=sda", "device=~("} {
+		if _, err := ParseLabelMatcher(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	dev := func(d string) []Label { return []Label{{Name: "device", Value: d}} }
+	families := []MetricFamily{
+		{Name: "node_disk_reads_completed_total", Type: MetricTypeCounter, Samples: []Sample{{Labels: dev("sda")}, {Labels: dev("nvme0n1")}}},
+		{Name: "node_disk_io_time_seconds", Type: MetricTypeHistogram, Histograms: []Histogram{{Labels: dev("sda")}, {Labels: dev("nvme0n1")}}},
+		{Name: "node_disk_latency_seconds", Type: MetricTypeSummary, Summaries: []Summary{{Labels: dev("sda")}}},
+		{Name: "node_load1", Type: MetricTypeGauge, Samples: []Sample{{Value: 1}}},
+	}
+
+	f, err := ParseFilter("node_disk_.*", []string{"device=~nvme.*"})
+	if err != nil {
+		t.Fatalf("ParseFilter error: %v", err)
+	}
+	out := f.Apply(families)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 families, got %d: %+v", len(out), out)
+	}
+	if len(out[0].Samples) != 1 || out[0].Samples[0].Labels[0].Value != "nvme0n1" {
+		t.Fatalf("unexpected samples: %+v", out[0].Samples)
+	}
+	if len(out[1].Histograms) != 1 || out[1].Histograms[0].Labels[0].Value != "nvme0n1" {
+		t.Fatalf("unexpected histograms: %+v", out[1].Histograms)
+	}
+
+	// A missing label matches as the empty string.
+	f, _ = ParseFilter("", []string{"device!=sda"})
+	out = f.Apply(families)
+	if len(out) != 3 || out[2].Name != "node_load1" {
+		t.Fatalf("unexpected families: %+v", out)
+	}
+}
@@ -1,9 +1,12 @@
 package collector
 
 import (
+	"bufio"
 	"context"
-
-	"github.com/shirou/gopsutil/v4/mem"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 )
 
 type MeminfoCollector struct{}
@@ -13,72 +16,60 @@ func NewMeminfoCollector() *MeminfoCollector { return &MeminfoCollector{} }
 func (c *MeminfoCollector) Name() string     { return "meminfo" }
 func (c *MeminfoCollector) Describe() string { return "Exposes memory statistics" }
 
+// meminfoFields are the /proc/meminfo fields node_exporter exposes; others are ignored so that
+// both backends agree on the metric names.
+var meminfoFields = map[string]bool{
+	"Active": true, "Active(anon)": true, "Active(file)": true, "AnonHugePages": true, "AnonPages": true,
+	"Bounce": true, "Buffers": true, "Cached": true, "CmaFree": true, "CmaTotal": true, "CommitLimit": true,
+	"Committed_AS": true, "DirectMap1G": true, "DirectMap2M": true, "DirectMap4k": true, "Dirty": true,
+	"HardwareCorrupted": true, "Hugepagesize": true, "Inactive": true, "Inactive(anon)": true,
+	"Inactive(file)": true, "KernelStack": true, "Mapped": true, "MemAvailable": true, "MemFree": true,
+	"MemTotal": true, "Mlocked": true, "NFS_Unstable": true, "PageTables": true, "Percpu": true,
+	"SReclaimable": true, "SUnreclaim": true, "Shmem": true, "ShmemHugePages": true, "ShmemPmdMapped": true,
+	"Slab": true, "SwapCached": true, "SwapFree": true, "SwapTotal": true, "Unevictable": true,
+	"VmallocChunk": true, "VmallocTotal": true, "VmallocUsed": true, "Writeback": true, "WritebackTmp": true,
+	"Zswap": true, "Zswapped": true,
+	"HugePages_Free": true, "HugePages_Rsvd": true, "HugePages_Surp": true, "HugePages_Total": true,
+}
+
 func (c *MeminfoCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	v, err := mem.VirtualMemoryWithContext(ctx)
+	_ = ctx
+	f, err := os.Open(procFilePath("meminfo"))
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	// Align with node_exporter meminfo naming where possible.
-	// For full parity we will expand this with /proc/meminfo parsing later.
-	mf := []MetricFamily{
-		{
-			Name: "node_memory_MemTotal_bytes",
-			Help: "Memory information field MemTotal_bytes.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{Value: float64(v.Total)},
-			},
-		},
-		{
-			Name: "node_memory_MemFree_bytes",
-			Help: "Memory information field MemFree_bytes.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{Value: float64(v.Free)},
-			},
-		},
-		{
-			Name: "node_memory_Active_bytes",
-			Help: "Memory information field Active_bytes.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{Value: float64(v.Active)},
-			},
-		},
-		{
-			Name: "node_memory_Inactive_bytes",
-			Help: "Memory information field Inactive_bytes.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{Value: float64(v.Inactive)},
-			},
-		},
-		{
-			Name: "node_memory_MemAvailable_bytes",
-			Help: "Memory information field MemAvailable_bytes.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{Value: float64(v.Available)},
-			},
-		},
-		{
-			Name: "node_memory_Buffers_bytes",
-			Help: "Memory information field Buffers_bytes.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{Value: float64(v.Buffers)},
-			},
-		},
-		{
-			Name: "node_memory_Cached_bytes",
-			Help: "Memory information field Cached_bytes.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{Value: float64(v.Cached)},
-			},
-		},
+	var out []MetricFamily
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// e.g. "Active(anon):     217796 kB" or "HugePages_Total:       0"
+		key, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok || !meminfoFields[key] {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s line in %s: %q", key, procFilePath("meminfo"), sc.Text())
+		}
+		name := strings.NewReplacer("(", "_", ")", "").Replace(key)
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+			name += "_bytes"
+		}
+		out = append(out, MetricFamily{
+			Name:    "node_memory_" + name,
+			Help:    fmt.Sprintf("Memory information field %s.", name),
+			Type:    MetricTypeGauge,
+			Samples: []Sample{{Value: v}},
+		})
 	}
-	return mf, nil
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-
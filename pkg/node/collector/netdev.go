@@ -1,9 +1,13 @@
 package collector
 
 import (
+	"bufio"
 	"context"
-
-	net "github.com/shirou/gopsutil/v4/net"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 type NetdevCollector struct{}
@@ -13,24 +17,59 @@ func NewNetdevCollector() *NetdevCollector { return &NetdevCollector{} }
 func (c *NetdevCollector) Name() string     { return "netdev" }
 func (c *NetdevCollector) Describe() string { return "Exposes network interface statistics" }
 
+// netdevColumns are the /proc/net/dev columns, named like node_exporter's legacy metric names.
+var netdevColumns = []string{
+	"receive_bytes", "receive_packets", "receive_errs", "receive_drop", "receive_fifo", "receive_frame", "receive_compressed", "receive_multicast",
+	"transmit_bytes", "transmit_packets", "transmit_errs", "transmit_drop", "transmit_fifo", "transmit_colls", "transmit_carrier", "transmit_compressed",
+}
+
 func (c *NetdevCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	stats, err := net.IOCountersWithContext(ctx, true)
+	_ = ctx
+	f, err := os.Open(procFilePath("net/dev"))
 	if err != nil {
 		return nil, err
 	}
-	recvBytes := MetricFamily{Name: "node_network_receive_bytes_total", Help: "Network device statistic receive_bytes.", Type: MetricTypeCounter}
-	xmitBytes := MetricFamily{Name: "node_network_transmit_bytes_total", Help: "Network device statistic transmit_bytes.", Type: MetricTypeCounter}
-	recvPkts := MetricFamily{Name: "node_network_receive_packets_total", Help: "Network device statistic receive_packets.", Type: MetricTypeCounter}
-	xmitPkts := MetricFamily{Name: "node_network_transmit_packets_total", Help: "Network device statistic transmit_packets.", Type: MetricTypeCounter}
-
-	for _, s := range stats {
-		labels := []Label{{Name: "device", Value: s.Name}}
-		recvBytes.Samples = append(recvBytes.Samples, Sample{Labels: labels, Value: float64(s.BytesRecv)})
-		xmitBytes.Samples = append(xmitBytes.Samples, Sample{Labels: labels, Value: float64(s.BytesSent)})
-		recvPkts.Samples = append(recvPkts.Samples, Sample{Labels: labels, Value: float64(s.PacketsRecv)})
-		xmitPkts.Samples = append(xmitPkts.Samples, Sample{Labels: labels, Value: float64(s.PacketsSent)})
+	defer f.Close()
+
+	families := make([]MetricFamily, len(netdevColumns)+1)
+	for i, col := range netdevColumns {
+		families[i] = MetricFamily{Name: "node_network_" + col + "_total", Help: fmt.Sprintf("Network device statistic %s.", col), Type: MetricTypeCounter}
 	}
+	// rx_nohandler is not in /proc/net/dev; upstream reads it over netlink, we read it from sysfs.
+	nohandler := &families[len(netdevColumns)]
+	*nohandler = MetricFamily{Name: "node_network_receive_nohandler_total", Help: "Network device statistic receive_nohandler.", Type: MetricTypeCounter}
 
-	return []MetricFamily{recvBytes, xmitBytes, recvPkts, xmitPkts}, nil
+	sc := bufio.NewScanner(f)
+	for line := 0; sc.Scan(); line++ {
+		if line < 2 {
+			continue // two header lines
+		}
+		dev, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		dev = strings.TrimSpace(dev)
+		fields := strings.Fields(rest)
+		if len(fields) != len(netdevColumns) {
+			return nil, fmt.Errorf("invalid line in %s: %q", procFilePath("net/dev"), sc.Text())
+		}
+		labels := sortedLabels("device", dev)
+		for i, s := range fields {
+			v, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid line in %s: %q", procFilePath("net/dev"), sc.Text())
+			}
+			families[i].Samples = append(families[i].Samples, Sample{Labels: labels, Value: float64(v)})
+		}
+		if v, err := readSysUint(filepath.Join(sysFilePath("class/net"), dev, "statistics", "rx_nohandler")); err == nil {
+			nohandler.Samples = append(nohandler.Samples, Sample{Labels: labels, Value: v})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(nohandler.Samples) == 0 {
+		families = families[:len(netdevColumns)]
+	}
+	return families, nil
 }
-
@@ -1,13 +1,20 @@
 package collector
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
-
-	"github.com/shirou/gopsutil/v4/host"
+	"time"
 )
 
+var osVersionRegex = regexp.MustCompile(`^[0-9]+\.?[0-9]*`)
+
 type OSCollector struct{}
 
 func NewOSCollector() *OSCollector { return &OSCollector{} }
@@ -16,39 +23,104 @@ func (c *OSCollector) Name() string     { return "os" }
 func (c *OSCollector) Describe() string { return "Expose OS release info" }
 
 func (c *OSCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	hi, err := host.InfoWithContext(ctx)
+	_ = ctx
+	var (
+		env map[string]string
+		err error
+	)
+	for _, path := range []string{"etc/os-release", "usr/lib/os-release"} {
+		env, err = readOSRelease(rootfsFilePath(path))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
-	platform := hi.Platform
-	if platform == "" {
-		platform = "unknown"
-	}
-	version := hi.PlatformVersion
-	if version == "" {
-		version = "unknown"
+
+	out := []MetricFamily{{
+		Name: "node_os_info",
+		Help: "A metric with a constant '1' value labeled by build_id, id, id_like, image_id, image_version, " +
+			"name, pretty_name, variant, variant_id, version, version_codename, version_id.",
+		Type: MetricTypeGauge,
+		Samples: []Sample{{
+			Labels: sortedLabels(
+				"build_id", env["BUILD_ID"],
+				"id", env["ID"],
+				"id_like", env["ID_LIKE"],
+				"image_id", env["IMAGE_ID"],
+				"image_version", env["IMAGE_VERSION"],
+				"name", env["NAME"],
+				"pretty_name", env["PRETTY_NAME"],
+				"variant", env["VARIANT"],
+				"variant_id", env["VARIANT_ID"],
+				"version", env["VERSION"],
+				"version_codename", env["VERSION_CODENAME"],
+				"version_id", env["VERSION_ID"],
+			),
+			Value: 1,
+		}},
+	}}
+
+	if mm := osVersionRegex.FindString(env["VERSION_ID"]); mm != "" {
+		if v, err := strconv.ParseFloat(mm, 64); err == nil && v > 0 {
+			out = append(out, MetricFamily{
+				Name:    "node_os_version",
+				Help:    "Metric containing the major.minor part of the OS version.",
+				Type:    MetricTypeGauge,
+				Samples: []Sample{{Labels: sortedLabels("id", env["ID"], "id_like", env["ID_LIKE"], "name", env["NAME"]), Value: v}},
+			})
+		}
 	}
-	pretty := strings.TrimSpace(fmt.Sprintf("%s %s", platform, version))
-	if len(pretty) > 48 {
-		pretty = pretty[:45] + "..."
+	if end := env["SUPPORT_END"]; end != "" {
+		t, err := time.Parse(time.DateOnly, end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SUPPORT_END %q: %w", end, err)
+		}
+		out = append(out, MetricFamily{
+			Name:    "node_os_support_end_timestamp_seconds",
+			Help:    "Metric containing the end-of-life date timestamp of the OS.",
+			Type:    MetricTypeGauge,
+			Samples: []Sample{{Value: float64(t.Unix())}},
+		})
 	}
+	return out, nil
+}
 
-	return []MetricFamily{
-		{
-			Name: "node_os_info",
-			Help: "Operating system information.",
-			Type: MetricTypeGauge,
-			Samples: []Sample{
-				{
-					Labels: []Label{
-						{Name: "name", Value: platform},
-						{Name: "version", Value: version},
-						{Name: "pretty_name", Value: pretty},
-					},
-					Value: 1,
-				},
-			},
-		},
-	}, nil
+func readOSRelease(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseOSRelease(f)
 }
 
+// parseOSRelease reads os-release(5) KEY=value lines. Values may be single- or double-quoted;
+// backslash escapes are honoured inside double quotes.
+func parseOSRelease(r io.Reader) (map[string]string, error) {
+	env := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch {
+		case len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"':
+			if uq, err := strconv.Unquote(val); err == nil {
+				val = uq
+			} else {
+				val = val[1 : len(val)-1]
+			}
+		case len(val) >= 2 && val[0] == '\'' && val[len(val)-1] == '\'':
+			val = val[1 : len(val)-1]
+		}
+		env[key] = val
+	}
+	return env, sc.Err()
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Filesystem roots the native collectors read from; the defaults match node_exporter's
+// --path.procfs, --path.sysfs, --path.rootfs and --path.udev.data. Change them with SetPaths.
+var (
+	procPath     = "/proc"
+	sysPath      = "/sys"
+	rootfsPath   = "/"
+	udevDataPath = "/run/udev/data"
+)
+
+// Paths are the filesystem roots collectors read from, e.g. the host's /proc and /sys mounted
+// at /host/proc and /host/sys inside a debug container, or a fixture tree in tests.
+type Paths struct {
+	Procfs   string
+	Sysfs    string
+	Rootfs   string
+	UdevData string
+}
+
+func DefaultPaths() Paths {
+	return Paths{Procfs: "/proc", Sysfs: "/sys", Rootfs: "/", UdevData: "/run/udev/data"}
+}
+
+// CurrentPaths returns the roots in use.
+func CurrentPaths() Paths {
+	return Paths{Procfs: procPath, Sysfs: sysPath, Rootfs: rootfsPath, UdevData: udevDataPath}
+}
+
+// SetPaths changes the roots of the native collectors; empty fields keep their current value.
+// The upstream backend picks them up through ConfigureUpstream. It is not safe to call while
+// collectors run.
+func SetPaths(p Paths) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = filepath.Clean(v)
+		}
+	}
+	set(&procPath, p.Procfs)
+	set(&sysPath, p.Sysfs)
+	set(&rootfsPath, p.Rootfs)
+	set(&udevDataPath, p.UdevData)
+}
+
+func procFilePath(name string) string { return filepath.Join(procPath, name) }
+
+func sysFilePath(name string) string { return filepath.Join(sysPath, name) }
+
+func rootfsFilePath(name string) string { return filepath.Join(rootfsPath, name) }
+
+// rootfsStripPrefix turns a mount point seen from the host root back into the path as seen
+// from rootfsPath (used when running in a container with the host mounted at e.g. /host).
+func rootfsStripPrefix(path string) string {
+	if rootfsPath == "/" {
+		return path
+	}
+	if path == rootfsPath {
+		return "/"
+	}
+	if stripped, ok := strings.CutPrefix(path, rootfsPath+"/"); ok {
+		return "/" + stripped
+	}
+	return path
+}
+
+// sortedLabels builds a label set from name/value pairs, sorted by name like DTOToNexa output
+// so that SeriesKey and the conformance checks see native and upstream series alike.
+func sortedLabels(kv ...string) []Label {
+	out := make([]Label, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		out = append(out, Label{Name: kv[i], Value: kv[i+1]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,22 @@
+package collector
+
+import "testing"
+
+func TestRootfsStripPrefix(t *testing.T) {
+	old := CurrentPaths()
+	SetPaths(Paths{Rootfs: "/host"})
+	t.Cleanup(func() { SetPaths(old) })
+
+	for in, want := range map[string]string{
+		"/host":          "/",
+		"/host/":         "/",
+		"/host/var/lib":  "/var/lib",
+		"/hostdata":      "/hostdata",
+		"/hostdata/logs": "/hostdata/logs",
+		"/proc":          "/proc",
+	} {
+		if got := rootfsStripPrefix(in); got != want {
+			t.Errorf("rootfsStripPrefix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultPluginDir is where external collectors are loaded from unless another directory is given.
+const DefaultPluginDir = "/etc/nexa/collectors.d"
+
+const (
+	// DefaultPluginTimeout bounds a plugin run when its config sets no timeout.
+	DefaultPluginTimeout = 5 * time.Second
+	maxPluginOutput      = 16 << 20
+	pluginStderrTail     = 512
+	pluginDefaultPath    = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// PluginFormat is the output format of a plugin.
+type PluginFormat string
+
+const (
+	// PluginFormatAuto treats output starting with '[' or '{' as JSON and anything else as
+	// Prometheus text.
+	PluginFormatAuto       PluginFormat = "auto"
+	PluginFormatPrometheus PluginFormat = "prometheus"
+	// PluginFormatJSON is a list of metric families as printed by `nexa node -o json`, or an
+	// object with a "families" list like a snapshot.
+	PluginFormatJSON PluginFormat = "json"
+)
+
+// PluginConfig is the optional <executable>.yaml next to a plugin:
+//
+//	name: raid
+//	description: MegaRAID controller and disk state.
+//	args: [--all]
+//	timeout: 20s
+//	workdir: /var/lib/raid
+//	env: {STORCLI: /opt/MegaRAID/storcli/storcli64}
+//	inherit_env: false
+//	format: prometheus
+//	enabled_by_default: true
+type PluginConfig struct {
+	// Name is the collector name; default the executable name without its extension.
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Args        []string `json:"args,omitempty"`
+	// Timeout is a Go duration; default DefaultPluginTimeout. The --collector.timeout deadline
+	// still applies on top of it.
+	Timeout string `json:"timeout,omitempty"`
+	// Workdir is the working directory; default the plugin directory.
+	Workdir string `json:"workdir,omitempty"`
+	// Env is added to the environment. Plugins get a minimal environment (PATH and the NEXA_*
+	// variables) unless InheritEnv passes on nexa's own.
+	Env        map[string]string `json:"env,omitempty"`
+	InheritEnv bool              `json:"inherit_env,omitempty"`
+	Format     PluginFormat      `json:"format,omitempty"`
+	// EnabledByDefault defaults to true: dropping a plugin into the directory enables it.
+	EnabledByDefault *bool `json:"enabled_by_default,omitempty"`
+}
+
+// Plugin is an external collector: an executable whose output is collected on every run.
+type Plugin struct {
+	Path   string
+	Config PluginConfig
+
+	timeout time.Duration
+}
+
+var pluginNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// LoadPlugins finds the executables in dir and their optional YAML configs. Files that are not
+// executable, hidden files and the configs themselves are skipped. dir, the plugins and their
+// configs must pass checkPluginPerms.
+func LoadPlugins(dir string) ([]*Plugin, error) {
+	dfi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPluginPerms(dir, dfi); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Plugin
+	seen := map[string]string{}
+	for _, e := range entries {
+		name := e.Name()
+		ext := filepath.Ext(name)
+		if strings.HasPrefix(name, ".") || ext == ".yaml" || ext == ".yml" || e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() || fi.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		if err := checkPluginPerms(path, fi); err != nil {
+			return nil, err
+		}
+		p, err := loadPlugin(path)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := seen[p.Name()]; ok {
+			return nil, fmt.Errorf("plugins %s and %s are both named %s", prev, path, p.Name())
+		}
+		seen[p.Name()] = path
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func loadPlugin(path string) (*Plugin, error) {
+	p := &Plugin{Path: path}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, cfgPath := range []string{path + ".yaml", path + ".yml", base + ".yaml", base + ".yml"} {
+		fi, err := os.Stat(cfgPath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The config picks arguments and environment, so it is as sensitive as the executable.
+		if err := checkPluginPerms(cfgPath, fi); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(cfgPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(data, &p.Config); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfgPath, err)
+		}
+		break
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// checkPluginPerms refuses a plugin file or directory that anyone but root or the current user
+// could have written: nexa often runs as root and executes whatever the directory holds.
+func checkPluginPerms(path string, fi os.FileInfo) error {
+	if perm := fi.Mode().Perm(); perm&0o022 != 0 {
+		return fmt.Errorf("plugin %s is group- or world-writable (%s); refusing to run it", path, perm)
+	}
+	if uid, ok := fileOwner(fi); ok && uid != 0 && uid != os.Geteuid() {
+		return fmt.Errorf("plugin %s is owned by uid %d, not root or the current user; refusing to run it", path, uid)
+	}
+	return nil
+}
+
+func (p *Plugin) validate() error {
+	c := &p.Config
+	if c.Name == "" {
+		c.Name = strings.TrimSuffix(filepath.Base(p.Path), filepath.Ext(p.Path))
+	}
+	if !pluginNameRE.MatchString(c.Name) {
+		return fmt.Errorf("invalid collector name %q (set name: in its config)", c.Name)
+	}
+	if c.Description == "" {
+		c.Description = "External collector " + p.Path + "."
+	}
+	p.timeout = DefaultPluginTimeout
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", c.Timeout)
+		}
+		p.timeout = d
+	}
+	if c.Workdir == "" {
+		c.Workdir = filepath.Dir(p.Path)
+	}
+	switch c.Format {
+	case "":
+		c.Format = PluginFormatAuto
+	case PluginFormatAuto, PluginFormatPrometheus, PluginFormatJSON:
+	default:
+		return fmt.Errorf("unknown format %q (want auto, prometheus or json)", c.Format)
+	}
+	return nil
+}
+
+func (p *Plugin) Name() string     { return p.Config.Name }
+func (p *Plugin) Describe() string { return p.Config.Description }
+
+// EnabledByDefault reports whether the plugin runs without --collector.<name>.
+func (p *Plugin) EnabledByDefault() bool {
+	return p.Config.EnabledByDefault == nil || *p.Config.EnabledByDefault
+}
+
+func (p *Plugin) env() []string {
+	var env []string
+	if p.Config.InheritEnv {
+		env = os.Environ()
+	} else {
+		env = []string{"PATH=" + pluginDefaultPath}
+	}
+	paths := CurrentPaths()
+	env = append(env,
+		"NEXA_COLLECTOR="+p.Name(),
+		"NEXA_PATH_PROCFS="+paths.Procfs,
+		"NEXA_PATH_SYSFS="+paths.Sysfs,
+		"NEXA_PATH_ROOTFS="+paths.Rootfs,
+	)
+	keys := make([]string, 0, len(p.Config.Env))
+	for k := range p.Config.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+p.Config.Env[k])
+	}
+	return env
+}
+
+func (p *Plugin) Collect(ctx context.Context) ([]MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.Path, p.Config.Args...)
+	cmd.Dir = p.Config.Workdir
+	cmd.Env = p.env()
+	setPluginProcessGroup(cmd)
+	// Children that escape the process group and keep the pipes open must not hang the collection.
+	cmd.WaitDelay = time.Second
+	stdout := &limitedBuffer{limit: maxPluginOutput}
+	stderr := &limitedBuffer{limit: 64 << 10}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin %s timed out after %s: %w", p.Path, p.timeout, context.DeadlineExceeded)
+	}
+	if err != nil {
+		if msg := stderrTail(stderr.buf.Bytes()); msg != "" {
+			return nil, fmt.Errorf("plugin %s: %w: %s", p.Path, err, msg)
+		}
+		return nil, fmt.Errorf("plugin %s: %w", p.Path, err)
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("plugin %s: output exceeds %d bytes", p.Path, maxPluginOutput)
+	}
+	families, err := ParsePluginOutput(stdout.buf.Bytes(), p.Config.Format)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Path, err)
+	}
+	return families, nil
+}
+
+// ParsePluginOutput parses the output of a plugin in the given format.
+func ParsePluginOutput(out []byte, format PluginFormat) ([]MetricFamily, error) {
+	trimmed := bytes.TrimSpace(out)
+	if format == PluginFormatAuto || format == "" {
+		format = PluginFormatPrometheus
+		if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+			format = PluginFormatJSON
+		}
+	}
+	var families []MetricFamily
+	switch format {
+	case PluginFormatJSON:
+		if len(trimmed) > 0 && trimmed[0] == '{' {
+			var doc struct {
+				Families []MetricFamily `json:"families"`
+			}
+			if err := json.Unmarshal(trimmed, &doc); err != nil {
+				return nil, fmt.Errorf("invalid JSON output: %w", err)
+			}
+			families = doc.Families
+		} else if err := json.Unmarshal(trimmed, &families); err != nil {
+			return nil, fmt.Errorf("invalid JSON output: %w", err)
+		}
+		if err := validatePluginFamilies(families); err != nil {
+			return nil, err
+		}
+	default:
+		parser := expfmt.NewTextParser(model.LegacyValidation)
+		mfs, err := parser.TextToMetricFamilies(bytes.NewReader(out))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Prometheus text output: %w", err)
+		}
+		names := make([]string, 0, len(mfs))
+		for name := range mfs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f, err := convertOneDTO(mfs[name])
+			if err != nil {
+				return nil, err
+			}
+			families = append(families, f)
+		}
+	}
+	return families, nil
+}
+
+// validatePluginFamilies checks JSON output the way the text parser checks Prometheus text, and
+// sorts labels as the rest of the package expects.
+func validatePluginFamilies(families []MetricFamily) error {
+	for i := range families {
+		f := &families[i]
+		if !model.LegacyValidation.IsValidMetricName(f.Name) {
+			return fmt.Errorf("invalid metric name %q", f.Name)
+		}
+		switch f.Type {
+		case "":
+			f.Type = MetricTypeUntyped
+		case MetricTypeGauge, MetricTypeCounter, MetricTypeUntyped:
+			if len(f.Histograms) > 0 || len(f.Summaries) > 0 {
+				return fmt.Errorf("%s: %s family with histograms or summaries", f.Name, f.Type)
+			}
+		case MetricTypeHistogram, MetricTypeSummary:
+			if len(f.Samples) > 0 {
+				return fmt.Errorf("%s: %s family with plain samples", f.Name, f.Type)
+			}
+		default:
+			return fmt.Errorf("%s: unknown type %q", f.Name, f.Type)
+		}
+		sortLabels := func(labels []Label) error {
+			sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+			for _, l := range labels {
+				if !model.LegacyValidation.IsValidLabelName(l.Name) {
+					return fmt.Errorf("%s: invalid label name %q", f.Name, l.Name)
+				}
+			}
+			return nil
+		}
+		for _, s := range f.Samples {
+			if err := sortLabels(s.Labels); err != nil {
+				return err
+			}
+		}
+		for _, h := range f.Histograms {
+			if err := sortLabels(h.Labels); err != nil {
+				return err
+			}
+			sort.Slice(h.Buckets, func(i, j int) bool { return h.Buckets[i].UpperBound < h.Buckets[j].UpperBound })
+		}
+		for _, s := range f.Summaries {
+			if err := sortLabels(s.Labels); err != nil {
+				return err
+			}
+			for _, q := range s.Quantiles {
+				if q.Quantile < 0 || q.Quantile > 1 || math.IsNaN(q.Quantile) {
+					return fmt.Errorf("%s: invalid quantile %v", f.Name, q.Quantile)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest, so a runaway
+// plugin cannot exhaust memory.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// stderrTail returns the last line of a plugin's stderr, shortened for error messages.
+func stderrTail(b []byte) string {
+	s := strings.TrimSpace(string(b))
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[i+1:])
+	}
+	if len(s) > pluginStderrTail {
+		s = "..." + s[len(s)-pluginStderrTail:]
+	}
+	return s
+}
@@ -0,0 +1,25 @@
+package collector
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// fileOwner returns the uid owning the file described by fi.
+func fileOwner(fi os.FileInfo) (int, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(st.Uid), true
+}
+
+// setPluginProcessGroup runs a plugin in its own process group and kills the whole group on
+// timeout, so that children of shell-script plugins do not outlive the collection.
+func setPluginProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
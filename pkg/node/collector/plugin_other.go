@@ -0,0 +1,12 @@
+//go:build !linux
+
+package collector
+
+import (
+	"os"
+	"os/exec"
+)
+
+func fileOwner(fi os.FileInfo) (int, bool) { return 0, false }
+
+func setPluginProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParsePluginOutput(t *testing.T) {
+	want := []MetricFamily{{Name: "raid_disk_ok", Help: "Disk state.", Type: MetricTypeGauge, Samples: []Sample{
+		{Labels: []Label{{Name: "ctl", Value: "0"}, {Name: "disk", Value: "1"}}, Value: 1},
+	}}}
+	for name, out := range map[string]string{
+		"text":        "# HELP raid_disk_ok Disk state.\n# TYPE raid_disk_ok gauge\nraid_disk_ok{disk=\"1\",ctl=\"0\"} 1\n",
+		"json list":   `[{"name":"raid_disk_ok","help":"Disk state.","type":"gauge","samples":[{"labels":{"disk":"1","ctl":"0"},"value":1}]}]`,
+		"json object": ` {"families":[{"name":"raid_disk_ok","help":"Disk state.","type":"gauge","samples":[{"labels":{"disk":"1","ctl":"0"},"value":1}]}]}`,
+	} {
+		got, err := ParsePluginOutput([]byte(out), PluginFormatAuto)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+
+	for name, out := range map[string]string{
+		"bad text":       "raid_disk_ok{disk=1} 1\n",
+		"bad json":       `[{"name":"x"`,
+		"bad name":       `[{"name":"1x","type":"gauge"}]`,
+		"bad type":       `[{"name":"x","type":"meter"}]`,
+		"mixed":          `[{"name":"x","type":"gauge","histograms":[{"buckets":[],"count":0,"sum":0}]}]`,
+		"bad label name": `[{"name":"x","type":"gauge","samples":[{"labels":{"a-b":"1"},"value":1}]}]`,
+	} {
+		if _, err := ParsePluginOutput([]byte(out), PluginFormatAuto); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := ParsePluginOutput([]byte("[]"), PluginFormatPrometheus); err == nil {
+		t.Error("JSON parsed as Prometheus text")
+	}
+}
+
+func TestPluginCollect(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "app.sh", `echo "app_up{env=\"$FOO\",name=\"$NEXA_COLLECTOR\",cwd=\"$(pwd)\"} 1"`+"\n")
+	if err := os.WriteFile(filepath.Join(dir, "app.yaml"), []byte("name: app_health\nenv: {FOO: bar}\nworkdir: /\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writePlugin(t, dir, "broken", "echo starting >&2\necho 'disk 3 missing' >&2\nexit 2\n")
+	writePlugin(t, dir, "slow", "sleep 10\n")
+	if err := os.WriteFile(filepath.Join(dir, "slow.yaml"), []byte("timeout: 100ms\nenabled_by_default: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not executable"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := LoadPlugins(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	r.RegisterImplemented(&fakeCollector{name: "cpu"})
+	var names []string
+	for _, p := range plugins {
+		if err := r.RegisterPlugin(p); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, p.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"app_health", "broken", "slow"}) {
+		t.Fatalf("plugins = %v", names)
+	}
+	if st := r.Status("app_health"); !st.Implemented || st.Plugin != filepath.Join(dir, "app.sh") {
+		t.Errorf("status = %+v", st)
+	}
+	if got := r.DefaultCollectorsLinuxEnabledByDefault(); !reflect.DeepEqual(got, []string{"app_health", "broken"}) {
+		t.Errorf("enabled by default = %v", got)
+	}
+
+	results := r.CollectMany(context.Background(), names, CollectOptions{Timeout: 5 * time.Second})
+	if err := results[0].Err; err != nil {
+		t.Fatal(err)
+	}
+	want := []Label{{Name: "cwd", Value: "/"}, {Name: "env", Value: "bar"}, {Name: "name", Value: "app_health"}}
+	if got := results[0].Families[0].Samples[0].Labels; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels = %v, want %v", got, want)
+	}
+	if err := results[1].Err; err == nil || !strings.HasSuffix(err.Error(), "exit status 2: disk 3 missing") {
+		t.Errorf("broken plugin error = %v", err)
+	}
+	if !results[2].TimedOut() || results[2].Duration > 2*time.Second {
+		t.Errorf("slow plugin = %v after %s", results[2].Err, results[2].Duration)
+	}
+
+	clash := &Plugin{Path: "/x/cpu", Config: PluginConfig{Name: "cpu"}}
+	if err := r.RegisterPlugin(clash); err == nil {
+		t.Error("plugin replaced a built-in collector")
+	}
+}
+
+func TestLoadPluginsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "bad-name", "true\n")
+	if _, err := LoadPlugins(dir); err == nil || !strings.Contains(err.Error(), "invalid collector name") {
+		t.Errorf("bad name: %v", err)
+	}
+
+	dir = t.TempDir()
+	writePlugin(t, dir, "a", "true\n")
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("timeout: soon\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlugins(dir); err == nil {
+		t.Error("invalid timeout accepted")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("retries: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlugins(dir); err == nil {
+		t.Error("unknown config field accepted")
+	}
+}
+
+func TestLoadPluginsPermissions(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "a", "true\n")
+	if _, err := LoadPlugins(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path string
+		mode os.FileMode
+	}{
+		{filepath.Join(dir, "a"), 0o775},
+		{filepath.Join(dir, "a"), 0o757},
+		{dir, 0o777},
+	} {
+		fi, err := os.Stat(c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(c.path, c.mode); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPlugins(dir); err == nil || !strings.Contains(err.Error(), "writable") {
+			t.Errorf("%s with mode %s: err = %v", c.path, c.mode, err)
+		}
+		if err := os.Chmod(c.path, fi.Mode().Perm()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if os.Geteuid() == 0 {
+		if err := os.Chown(filepath.Join(dir, "a"), 65534, -1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPlugins(dir); err == nil || !strings.Contains(err.Error(), "owned by uid 65534") {
+			t.Errorf("foreign owner: err = %v", err)
+		}
+		if err := os.Chown(filepath.Join(dir, "a"), 0, -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("args: [x]\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "a.yaml"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlugins(dir); err == nil || !strings.Contains(err.Error(), "a.yaml") {
+		t.Errorf("world-writable config: err = %v", err)
+	}
+}
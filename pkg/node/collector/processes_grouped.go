@@ -0,0 +1,419 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/shirou/gopsutil/v4/common"
+	"github.com/shirou/gopsutil/v4/process"
+	"sigs.k8s.io/yaml"
+)
+
+// ProcessGroupsConfig configures the processes_grouped collector, in the spirit of
+// process-exporter's process_names:
+//
+//	groups:
+//	  - name: nginx
+//	    comm: [nginx]
+//	  - name: "java:{{.Matches.app}}"
+//	    cmdline: ['-jar\s+(?P<app>\S+)\.jar']
+//	  - name: "{{.Matches.unit}}"
+//	    cgroup: ['system\.slice/(?P<unit>[^/]+\.service)']
+//
+// Each process belongs to the first group that matches it; processes matching no group are
+// not reported.
+type ProcessGroupsConfig struct {
+	Groups []ProcessGroup `json:"groups"`
+}
+
+// ProcessGroup selects processes. All given criteria must match; a group without criteria
+// matches every process.
+type ProcessGroup struct {
+	// Name is a text/template for the groupname label with .Comm, .ExeBase, .PID and .Matches
+	// (the named captures of the cmdline and cgroup regexps). Default "{{.Comm}}".
+	Name string `json:"name,omitempty"`
+	// Comm lists accepted process names (/proc/<pid>/comm).
+	Comm []string `json:"comm,omitempty"`
+	// Cmdline regexps must all match the command line, arguments joined by spaces.
+	Cmdline []string `json:"cmdline,omitempty"`
+	// Cgroup regexps must all match one of the cgroup paths of /proc/<pid>/cgroup.
+	Cgroup []string `json:"cgroup,omitempty"`
+
+	name    *template.Template
+	comm    map[string]bool
+	cmdline []*regexp.Regexp
+	cgroup  []*regexp.Regexp
+}
+
+// DefaultProcessGroupsConfig groups every process by its name.
+func DefaultProcessGroupsConfig() *ProcessGroupsConfig {
+	cfg := &ProcessGroupsConfig{Groups: []ProcessGroup{{Name: "{{.Comm}}"}}}
+	if err := cfg.compile(); err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// ParseProcessGroupsConfig parses and validates a YAML config.
+func ParseProcessGroupsConfig(data []byte) (*ProcessGroupsConfig, error) {
+	var cfg ProcessGroupsConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Groups) == 0 {
+		return nil, fmt.Errorf("no groups configured")
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func LoadProcessGroupsConfig(path string) (*ProcessGroupsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseProcessGroupsConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *ProcessGroupsConfig) compile() error {
+	for i := range cfg.Groups {
+		g := &cfg.Groups[i]
+		if g.Name == "" {
+			g.Name = "{{.Comm}}"
+		}
+		t, err := template.New("name").Option("missingkey=zero").Parse(g.Name)
+		if err != nil {
+			return fmt.Errorf("group %d: name: %w", i+1, err)
+		}
+		g.name = t
+		g.comm = map[string]bool{}
+		for _, c := range g.Comm {
+			g.comm[c] = true
+		}
+		compile := func(field string, exprs []string) ([]*regexp.Regexp, error) {
+			out := make([]*regexp.Regexp, 0, len(exprs))
+			for _, e := range exprs {
+				re, err := regexp.Compile(e)
+				if err != nil {
+					return nil, fmt.Errorf("group %d: %s: %w", i+1, field, err)
+				}
+				out = append(out, re)
+			}
+			return out, nil
+		}
+		if g.cmdline, err = compile("cmdline", g.Cmdline); err != nil {
+			return err
+		}
+		if g.cgroup, err = compile("cgroup", g.Cgroup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// needsCgroup reports whether any group matches on cgroups, which costs an extra read per process.
+func (cfg *ProcessGroupsConfig) needsCgroup() bool {
+	for _, g := range cfg.Groups {
+		if len(g.cgroup) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type processNameData struct {
+	Comm    string
+	ExeBase string
+	PID     int32
+	Matches map[string]string
+}
+
+// groupName returns the name of the first group p belongs to.
+func (cfg *ProcessGroupsConfig) groupName(p *procInfo) (string, bool) {
+	for i := range cfg.Groups {
+		g := &cfg.Groups[i]
+		if len(g.comm) > 0 && !g.comm[p.comm] {
+			continue
+		}
+		matches := map[string]string{}
+		if !matchAll(g.cmdline, []string{p.cmdline}, matches) || !matchAll(g.cgroup, p.cgroups, matches) {
+			continue
+		}
+		var b strings.Builder
+		if err := g.name.Execute(&b, processNameData{Comm: p.comm, ExeBase: filepath.Base(p.exe), PID: p.pid, Matches: matches}); err != nil {
+			continue
+		}
+		if b.Len() == 0 {
+			continue
+		}
+		return b.String(), true
+	}
+	return "", false
+}
+
+// matchAll reports whether every regexp matches one of the candidates, recording named captures.
+func matchAll(res []*regexp.Regexp, candidates []string, matches map[string]string) bool {
+	for _, re := range res {
+		found := false
+		for _, c := range candidates {
+			m := re.FindStringSubmatch(c)
+			if m == nil {
+				continue
+			}
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					matches[name] = m[i]
+				}
+			}
+			found = true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	processGroupsMu  sync.RWMutex
+	processGroupsCfg = DefaultProcessGroupsConfig()
+)
+
+// SetProcessGroupsConfig replaces the configuration of the processes_grouped collector; nil
+// restores the default of one group per process name.
+func SetProcessGroupsConfig(cfg *ProcessGroupsConfig) {
+	if cfg == nil {
+		cfg = DefaultProcessGroupsConfig()
+	}
+	processGroupsMu.Lock()
+	processGroupsCfg = cfg
+	processGroupsMu.Unlock()
+}
+
+func currentProcessGroupsConfig() *ProcessGroupsConfig {
+	processGroupsMu.RLock()
+	defer processGroupsMu.RUnlock()
+	return processGroupsCfg
+}
+
+// procInfo is what the collector reads about one process.
+type procInfo struct {
+	pid     int32
+	comm    string
+	exe     string
+	cmdline string
+	cgroups []string
+
+	userSeconds, systemSeconds float64
+	rss, vms                   uint64
+	readBytes, writeBytes      uint64
+	fds                        int32
+	threads                    int32
+	voluntary, nonvoluntary    int64
+	startTimeMs                int64
+
+	// partial is set when fields that need privileges (io, fd) could not be read.
+	partial bool
+}
+
+type ProcessesGroupedCollector struct{}
+
+func NewProcessesGroupedCollector() *ProcessesGroupedCollector {
+	return &ProcessesGroupedCollector{}
+}
+
+func (c *ProcessesGroupedCollector) Name() string { return "processes_grouped" }
+func (c *ProcessesGroupedCollector) Describe() string {
+	return "Exposes per-group process statistics (process-exporter style namedprocess_namegroup_* metrics)"
+}
+
+func (c *ProcessesGroupedCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
+	cfg := currentProcessGroupsConfig()
+	ctx = context.WithValue(ctx, common.EnvKey, common.EnvMap{common.HostProcEnvKey: procPath})
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*procInfo, 0, len(procs))
+	for _, p := range procs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if info, ok := readProcInfo(ctx, p, cfg.needsCgroup()); ok {
+			infos = append(infos, info)
+		}
+	}
+	return groupProcesses(cfg, infos), nil
+}
+
+// readProcInfo reads p through gopsutil. Processes that exit while being read are skipped.
+func readProcInfo(ctx context.Context, p *process.Process, withCgroup bool) (*procInfo, bool) {
+	info := &procInfo{pid: p.Pid}
+	var err error
+	if info.comm, err = p.NameWithContext(ctx); err != nil {
+		return nil, false
+	}
+	times, err := p.TimesWithContext(ctx)
+	if err != nil {
+		return nil, false
+	}
+	info.userSeconds, info.systemSeconds = times.User, times.System
+	mem, err := p.MemoryInfoWithContext(ctx)
+	if err != nil {
+		return nil, false
+	}
+	info.rss, info.vms = mem.RSS, mem.VMS
+	if info.threads, err = p.NumThreadsWithContext(ctx); err != nil {
+		return nil, false
+	}
+	if info.startTimeMs, err = p.CreateTimeWithContext(ctx); err != nil {
+		return nil, false
+	}
+	if sw, err := p.NumCtxSwitchesWithContext(ctx); err == nil {
+		info.voluntary, info.nonvoluntary = sw.Voluntary, sw.Involuntary
+	}
+	if args, err := p.CmdlineSliceWithContext(ctx); err == nil {
+		info.cmdline = strings.Join(args, " ")
+	}
+	info.exe, _ = p.ExeWithContext(ctx)
+
+	// Reading another user's io and fd table needs CAP_SYS_PTRACE/root.
+	if io, err := p.IOCountersWithContext(ctx); err == nil {
+		info.readBytes, info.writeBytes = io.ReadBytes, io.WriteBytes
+	} else {
+		info.partial = true
+	}
+	if info.fds, err = p.NumFDsWithContext(ctx); err != nil {
+		info.partial = true
+	}
+	if withCgroup {
+		info.cgroups = readCgroupPaths(procFilePath(filepath.Join(strconv.Itoa(int(p.Pid)), "cgroup")))
+	}
+	return info, true
+}
+
+// readCgroupPaths returns the paths of a /proc/<pid>/cgroup file ("0::/system.slice/x.service").
+func readCgroupPaths(path string) []string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), ":", 3)
+		if len(parts) == 3 {
+			out = append(out, parts[2])
+		}
+	}
+	return out
+}
+
+type processGroupStats struct {
+	procs                      int
+	userSeconds, systemSeconds float64
+	rss, vms                   uint64
+	readBytes, writeBytes      uint64
+	fds, threads               int64
+	voluntary, nonvoluntary    int64
+	oldestStartMs              int64
+}
+
+// groupProcesses aggregates processes into the namedprocess_namegroup_* families.
+func groupProcesses(cfg *ProcessGroupsConfig, procs []*procInfo) []MetricFamily {
+	groups := map[string]*processGroupStats{}
+	partial := 0
+	for _, p := range procs {
+		name, ok := cfg.groupName(p)
+		if !ok {
+			continue
+		}
+		g := groups[name]
+		if g == nil {
+			g = &processGroupStats{oldestStartMs: p.startTimeMs}
+			groups[name] = g
+		}
+		g.procs++
+		g.userSeconds += p.userSeconds
+		g.systemSeconds += p.systemSeconds
+		g.rss += p.rss
+		g.vms += p.vms
+		g.readBytes += p.readBytes
+		g.writeBytes += p.writeBytes
+		g.fds += int64(p.fds)
+		g.threads += int64(p.threads)
+		g.voluntary += p.voluntary
+		g.nonvoluntary += p.nonvoluntary
+		if p.startTimeMs < g.oldestStartMs {
+			g.oldestStartMs = p.startTimeMs
+		}
+		if p.partial {
+			partial++
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	family := func(name, help string, typ MetricType) MetricFamily {
+		return MetricFamily{Name: "namedprocess_namegroup_" + name, Help: help, Type: typ}
+	}
+	var (
+		numProcs   = family("num_procs", "Number of processes in this group.", MetricTypeGauge)
+		cpu        = family("cpu_seconds_total", "CPU seconds used by processes in this group, by mode.", MetricTypeCounter)
+		memory     = family("memory_bytes", "Memory of processes in this group: resident (RSS) and virtual (VMS).", MetricTypeGauge)
+		readBytes  = family("read_bytes_total", "Bytes read from storage by processes in this group.", MetricTypeCounter)
+		writeBytes = family("write_bytes_total", "Bytes written to storage by processes in this group.", MetricTypeCounter)
+		fds        = family("open_filedesc", "Open file descriptors of processes in this group.", MetricTypeGauge)
+		threads    = family("num_threads", "Threads of processes in this group.", MetricTypeGauge)
+		ctxsw      = family("context_switches_total", "Context switches of processes in this group, by type.", MetricTypeCounter)
+		oldest     = family("oldest_start_time_seconds", "Start time of the oldest process in this group, in seconds since the epoch.", MetricTypeGauge)
+	)
+	for _, name := range names {
+		g := groups[name]
+		lbl := func(kv ...string) []Label { return sortedLabels(append([]string{"groupname", name}, kv...)...) }
+		numProcs.Samples = append(numProcs.Samples, Sample{Labels: lbl(), Value: float64(g.procs)})
+		cpu.Samples = append(cpu.Samples,
+			Sample{Labels: lbl("mode", "user"), Value: g.userSeconds},
+			Sample{Labels: lbl("mode", "system"), Value: g.systemSeconds})
+		memory.Samples = append(memory.Samples,
+			Sample{Labels: lbl("memtype", "resident"), Value: float64(g.rss)},
+			Sample{Labels: lbl("memtype", "virtual"), Value: float64(g.vms)})
+		readBytes.Samples = append(readBytes.Samples, Sample{Labels: lbl(), Value: float64(g.readBytes)})
+		writeBytes.Samples = append(writeBytes.Samples, Sample{Labels: lbl(), Value: float64(g.writeBytes)})
+		fds.Samples = append(fds.Samples, Sample{Labels: lbl(), Value: float64(g.fds)})
+		threads.Samples = append(threads.Samples, Sample{Labels: lbl(), Value: float64(g.threads)})
+		ctxsw.Samples = append(ctxsw.Samples,
+			Sample{Labels: lbl("ctxswitchtype", "voluntary"), Value: float64(g.voluntary)},
+			Sample{Labels: lbl("ctxswitchtype", "nonvoluntary"), Value: float64(g.nonvoluntary)})
+		oldest.Samples = append(oldest.Samples, Sample{Labels: lbl(), Value: float64(g.oldestStartMs) / 1000})
+	}
+	return []MetricFamily{
+		numProcs, cpu, memory, readBytes, writeBytes, fds, threads, ctxsw, oldest,
+		{
+			Name:    "namedprocess_scrape_partial_errors",
+			Help:    "Grouped processes whose io counters or file descriptors could not be read (usually missing privileges).",
+			Type:    MetricTypeGauge,
+			Samples: []Sample{{Value: float64(partial)}},
+		},
+	}
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"runtime"
+	"testing"
+)
+
+func TestParseProcessGroupsConfig(t *testing.T) {
+	for name, doc := range map[string]string{
+		"empty":         "groups: []\n",
+		"bad regexp":    "groups:\n- cmdline: ['(']\n",
+		"bad template":  "groups:\n- name: '{{.Comm'\n",
+		"unknown field": "groups:\n- exe: [x]\n",
+	} {
+		if _, err := ParseProcessGroupsConfig([]byte(doc)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestGroupProcesses(t *testing.T) {
+	cfg, err := ParseProcessGroupsConfig([]byte(`
+groups:
+- name: web
+  comm: [nginx, apache2]
+- name: "java:{{.Matches.app}}"
+  comm: [java]
+  cmdline: ['-jar\s+(?P<app>\w+)\.jar']
+- name: "{{.Matches.unit}}"
+  cgroup: ['system\.slice/(?P<unit>[^/]+)\.service']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	procs := []*procInfo{
+		{pid: 1, comm: "nginx", userSeconds: 1, systemSeconds: 2, rss: 100, vms: 1000, fds: 10, threads: 1, startTimeMs: 5000, voluntary: 3},
+		{pid: 2, comm: "nginx", userSeconds: 0.5, rss: 50, vms: 500, fds: 5, threads: 2, startTimeMs: 2000, partial: true},
+		{pid: 3, comm: "java", cmdline: "java -Xmx1g -jar billing.jar", readBytes: 7, writeBytes: 9, threads: 40, startTimeMs: 1000},
+		{pid: 4, comm: "java", cmdline: "java -cp x Main", cgroups: []string{"/system.slice/legacy.service"}, startTimeMs: 1000},
+		{pid: 5, comm: "bash", cgroups: []string{"/user.slice"}},
+	}
+	got := map[string]float64{}
+	for _, f := range groupProcesses(cfg, procs) {
+		for _, s := range f.Samples {
+			got[key(f.Name, s.Labels)] = s.Value
+		}
+	}
+	want := map[string]float64{
+		key("namedprocess_namegroup_num_procs", sortedLabels("groupname", "web")):                                            2,
+		key("namedprocess_namegroup_num_procs", sortedLabels("groupname", "java:billing")):                                   1,
+		key("namedprocess_namegroup_num_procs", sortedLabels("groupname", "legacy")):                                         1,
+		key("namedprocess_namegroup_cpu_seconds_total", sortedLabels("groupname", "web", "mode", "user")):                    1.5,
+		key("namedprocess_namegroup_cpu_seconds_total", sortedLabels("groupname", "web", "mode", "system")):                  2,
+		key("namedprocess_namegroup_memory_bytes", sortedLabels("groupname", "web", "memtype", "resident")):                  150,
+		key("namedprocess_namegroup_memory_bytes", sortedLabels("groupname", "web", "memtype", "virtual")):                   1500,
+		key("namedprocess_namegroup_open_filedesc", sortedLabels("groupname", "web")):                                        15,
+		key("namedprocess_namegroup_num_threads", sortedLabels("groupname", "java:billing")):                                 40,
+		key("namedprocess_namegroup_read_bytes_total", sortedLabels("groupname", "java:billing")):                            7,
+		key("namedprocess_namegroup_write_bytes_total", sortedLabels("groupname", "java:billing")):                           9,
+		key("namedprocess_namegroup_context_switches_total", sortedLabels("groupname", "web", "ctxswitchtype", "voluntary")): 3,
+		key("namedprocess_namegroup_oldest_start_time_seconds", sortedLabels("groupname", "web")):                            2,
+		key("namedprocess_scrape_partial_errors", nil):                                                                       1,
+	}
+	for k, v := range want {
+		if g, ok := got[k]; !ok || g != v {
+			t.Errorf("%s = %v (present %v), want %v", k, g, ok, v)
+		}
+	}
+	if _, ok := got[key("namedprocess_namegroup_num_procs", sortedLabels("groupname", "bash"))]; ok {
+		t.Error("bash matches no group and must not be reported")
+	}
+}
+
+func key(name string, labels []Label) string { return name + SeriesKey(labels) }
+
+// TestProcessesGroupedSelf collects the live process table and finds the test binary.
+func TestProcessesGroupedSelf(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ProcessGroupsConfig{Groups: []ProcessGroup{{Name: "self", Cmdline: []string{"^" + regexp.QuoteMeta(exe)}}}}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	SetProcessGroupsConfig(cfg)
+	t.Cleanup(func() { SetProcessGroupsConfig(nil) })
+
+	families, err := NewProcessesGroupedCollector().Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.Name != "namedprocess_namegroup_num_threads" {
+			continue
+		}
+		if len(f.Samples) != 1 || f.Samples[0].Value < 1 {
+			t.Fatalf("num_threads = %+v", f.Samples)
+		}
+		return
+	}
+	t.Fatal("no namedprocess_namegroup_num_threads family")
+}
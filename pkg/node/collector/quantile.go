@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"math"
+	"sort"
+)
+
+// Quantile estimates the q-quantile (0 <= q <= 1) of the observations in h the way PromQL's
+// histogram_quantile does: the rank q*count is located in the cumulative buckets and the value is
+// interpolated linearly within that bucket. The lowest bucket starts at 0 unless its upper bound
+// is negative, and a rank in the +Inf bucket yields the highest finite upper bound. NaN is returned
+// for an empty histogram, a histogram without buckets and for q outside [0, 1].
+func (h Histogram) Quantile(q float64) float64 {
+	if math.IsNaN(q) || q < 0 || q > 1 || len(h.Buckets) == 0 {
+		return math.NaN()
+	}
+	buckets := append([]Bucket(nil), h.Buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].UpperBound < buckets[j].UpperBound })
+	if !math.IsInf(buckets[len(buckets)-1].UpperBound, 1) {
+		// The exposition format requires a +Inf bucket; synthesize it from the count.
+		buckets = append(buckets, Bucket{UpperBound: math.Inf(1), Count: h.Count})
+	}
+	// Tolerate non-monotonic counts (e.g. from a racy textfile) as Prometheus does.
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i].Count < buckets[i-1].Count {
+			buckets[i].Count = buckets[i-1].Count
+		}
+	}
+	total := float64(buckets[len(buckets)-1].Count)
+	if len(buckets) < 2 || total == 0 {
+		return math.NaN()
+	}
+
+	rank := q * total
+	i := sort.Search(len(buckets)-1, func(i int) bool { return float64(buckets[i].Count) >= rank })
+	if i == len(buckets)-1 {
+		return buckets[len(buckets)-2].UpperBound
+	}
+	if i == 0 && buckets[0].UpperBound <= 0 {
+		return buckets[0].UpperBound
+	}
+	var start, prev float64
+	if i > 0 {
+		start = buckets[i-1].UpperBound
+		prev = float64(buckets[i-1].Count)
+	}
+	end := buckets[i].UpperBound
+	inBucket := float64(buckets[i].Count) - prev
+	if inBucket == 0 {
+		return end
+	}
+	return start + (end-start)*((rank-prev)/inBucket)
+}
+
+// Mean is Sum/Count, or NaN without observations.
+func (h Histogram) Mean() float64 { return mean(h.Sum, h.Count) }
+
+// Mean is Sum/Count, or NaN without observations.
+func (s Summary) Mean() float64 { return mean(s.Sum, s.Count) }
+
+func mean(sum float64, count uint64) float64 {
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	// 10 observations <= 0.1, 40 in (0.1, 0.5], 40 in (0.5, 1], 10 above 1.
+	h := Histogram{
+		Buckets: []Bucket{{0.1, 10}, {0.5, 50}, {1, 90}, {math.Inf(1), 100}},
+		Count:   100,
+		Sum:     55,
+	}
+	tests := []struct {
+		q, want float64
+	}{
+		{0, 0},
+		{0.05, 0.05},
+		{0.5, 0.5},
+		{0.7, 0.75},
+		{0.9, 1},
+		{0.99, 1}, // in the +Inf bucket: highest finite bound
+		{1, 1},
+	}
+	for _, tt := range tests {
+		if got := h.Quantile(tt.q); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if got := h.Mean(); got != 0.55 {
+		t.Errorf("Mean = %v", got)
+	}
+
+	// Without the +Inf bucket the count stands in for it.
+	noInf := Histogram{Buckets: []Bucket{{1, 2}, {2, 4}}, Count: 4}
+	if got := noInf.Quantile(0.5); got != 1 {
+		t.Errorf("no +Inf: Quantile(0.5) = %v", got)
+	}
+
+	for name, h := range map[string]Histogram{
+		"empty":      {Buckets: []Bucket{{1, 0}, {math.Inf(1), 0}}},
+		"no buckets": {Count: 3},
+		"only +Inf":  {Buckets: []Bucket{{math.Inf(1), 3}}, Count: 3},
+	} {
+		if got := h.Quantile(0.5); !math.IsNaN(got) {
+			t.Errorf("%s: Quantile = %v, want NaN", name, got)
+		}
+	}
+	if got := h.Quantile(1.5); !math.IsNaN(got) {
+		t.Errorf("Quantile(1.5) = %v, want NaN", got)
+	}
+	if got := (Summary{}).Mean(); !math.IsNaN(got) {
+		t.Errorf("empty summary Mean = %v, want NaN", got)
+	}
+}
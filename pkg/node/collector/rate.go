@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"strings"
+	"time"
+)
+
+// SeriesKey identifies a series within a family by its label set.
+// Labels are expected to be sorted by name, as produced by the collectors and DTOToNexa.
+func SeriesKey(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(0xff)
+		}
+		b.WriteString(l.Name)
+		b.WriteByte(0xfe)
+		b.WriteString(l.Value)
+	}
+	return b.String()
+}
+
+// CounterRate returns the per-second increase of a counter between two observations.
+// Like Prometheus rate(), a decreasing value is treated as a counter reset and the whole
+// current value counts as the increase.
+func CounterRate(prev, cur float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	inc := cur - prev
+	if cur < prev {
+		inc = cur
+	}
+	return inc / elapsed.Seconds()
+}
+
+// SampleIndex maps family name -> series key -> value for quick lookups between two scrapes.
+// Histograms and summaries are indexed through their _count and _sum series.
+type SampleIndex map[string]map[string]float64
+
+func NewSampleIndex(families []MetricFamily) SampleIndex {
+	idx := SampleIndex{}
+	put := func(name, key string, v float64) {
+		m := idx[name]
+		if m == nil {
+			m = map[string]float64{}
+			idx[name] = m
+		}
+		m[key] = v
+	}
+	for _, f := range families {
+		for _, s := range f.Samples {
+			put(f.Name, SeriesKey(s.Labels), s.Value)
+		}
+		for _, h := range f.Histograms {
+			k := SeriesKey(h.Labels)
+			put(f.Name+"_count", k, float64(h.Count))
+			put(f.Name+"_sum", k, h.Sum)
+		}
+		for _, s := range f.Summaries {
+			k := SeriesKey(s.Labels)
+			put(f.Name+"_count", k, float64(s.Count))
+			put(f.Name+"_sum", k, s.Sum)
+		}
+	}
+	return idx
+}
+
+// Lookup returns the value of a series and whether it was present.
+func (idx SampleIndex) Lookup(name string, labels []Label) (float64, bool) {
+	m, ok := idx[name]
+	if !ok {
+		return 0, false
+	}
+	v, ok := m[SeriesKey(labels)]
+	return v, ok
+}
@@ -4,12 +4,68 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 )
 
+// Backend selects which implementation of a collector runs.
+type Backend string
+
+const (
+	// BackendAuto prefers the native implementation and falls back to upstream.
+	BackendAuto Backend = "auto"
+	// BackendNative uses the hand-written collectors in this package.
+	BackendNative Backend = "native"
+	// BackendUpstream wraps the node_exporter collectors.
+	BackendUpstream Backend = "upstream"
+)
+
+func ParseBackend(s string) (Backend, error) {
+	switch b := Backend(strings.ToLower(strings.TrimSpace(s))); b {
+	case BackendAuto, BackendNative, BackendUpstream:
+		return b, nil
+	}
+	return "", fmt.Errorf("unknown collector backend %q (want native, upstream or auto)", s)
+}
+
+// ParseBackendSpec parses a --collector.backend value: a default backend optionally followed by
+// per-collector overrides, e.g. "auto" or "native,diskstats=upstream,cpu=upstream".
+func ParseBackendSpec(spec string) (Backend, map[string]Backend, error) {
+	def := BackendAuto
+	overrides := map[string]Backend{}
+	for i, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, isOverride := strings.Cut(part, "=")
+		if !isOverride {
+			if i != 0 {
+				return "", nil, fmt.Errorf("invalid collector backend %q: the default backend must come first", spec)
+			}
+			value = name
+		}
+		b, err := ParseBackend(value)
+		if err != nil {
+			return "", nil, err
+		}
+		if isOverride {
+			overrides[strings.TrimSpace(name)] = b
+		} else {
+			def = b
+		}
+	}
+	return def, overrides, nil
+}
+
 type Registry struct {
 	collectors map[string]Collector
 	status     map[string]CollectorStatus
 
+	// impls holds the implementations registered per backend; collectors holds the active one.
+	impls     map[string]map[Backend]Collector
+	backend   Backend
+	overrides map[string]Backend
+
 	// linuxEnabledByDefault mirrors the node_exporter README "Enabled by default" names for Linux,
 	// but in this project we may implement them gradually.
 	linuxEnabledByDefault []string
@@ -19,6 +75,8 @@ func NewRegistry() *Registry {
 	return &Registry{
 		collectors: make(map[string]Collector),
 		status:     make(map[string]CollectorStatus),
+		impls:      make(map[string]map[Backend]Collector),
+		backend:    BackendAuto,
 	}
 }
 
@@ -31,6 +89,99 @@ func (r *Registry) RegisterImplemented(c Collector) {
 	}
 }
 
+// RegisterBackend adds the implementation of a collector for one backend. Which implementation
+// runs is decided by SetBackend (auto by default). desc is used unless a description is already set.
+func (r *Registry) RegisterBackend(b Backend, c Collector, desc string) {
+	name := c.Name()
+	if r.impls[name] == nil {
+		r.impls[name] = map[Backend]Collector{}
+	}
+	r.impls[name][b] = c
+	st := r.status[name]
+	st.Name = name
+	if st.Description == "" {
+		st.Description = desc
+	}
+	r.status[name] = st
+	r.resolve(name)
+}
+
+// SetBackend selects the backend for all collectors with a choice of implementation; overrides
+// pick a backend for individual collectors. With BackendNative, collectors that have no native
+// implementation become unavailable.
+func (r *Registry) SetBackend(def Backend, overrides map[string]Backend) error {
+	for name, b := range overrides {
+		impls, ok := r.impls[name]
+		if !ok {
+			return fmt.Errorf("collector.backend: unknown collector %q or it has a single implementation", name)
+		}
+		if b != BackendAuto && impls[b] == nil {
+			return fmt.Errorf("collector.backend: collector %s has no %s implementation", name, b)
+		}
+	}
+	r.backend = def
+	r.overrides = overrides
+	for name := range r.impls {
+		r.resolve(name)
+	}
+	return nil
+}
+
+func (r *Registry) resolve(name string) {
+	impls := r.impls[name]
+	want := r.backend
+	if b, ok := r.overrides[name]; ok {
+		want = b
+	}
+	var (
+		active Collector
+		used   Backend
+	)
+	switch want {
+	case BackendAuto:
+		if c := impls[BackendNative]; c != nil {
+			active, used = c, BackendNative
+		} else if c := impls[BackendUpstream]; c != nil {
+			active, used = c, BackendUpstream
+		}
+	default:
+		if c := impls[want]; c != nil {
+			active, used = c, want
+		}
+	}
+
+	st := r.status[name]
+	st.Backends = st.Backends[:0]
+	for _, b := range []Backend{BackendNative, BackendUpstream} {
+		if impls[b] != nil {
+			st.Backends = append(st.Backends, b)
+		}
+	}
+	st.Implemented = active != nil
+	st.Backend = used
+	r.status[name] = st
+	if active != nil {
+		r.collectors[name] = active
+	} else {
+		delete(r.collectors, name)
+	}
+}
+
+// RegisterPlugin adds an external collector. Plugins cannot replace built-in collectors.
+func (r *Registry) RegisterPlugin(p *Plugin) error {
+	if r.Has(p.Name()) {
+		return fmt.Errorf("plugin %s: collector %s already exists (set name: in its config)", p.Path, p.Name())
+	}
+	r.RegisterImplemented(p)
+	st := r.status[p.Name()]
+	st.Plugin = p.Path
+	r.status[p.Name()] = st
+	if p.EnabledByDefault() {
+		r.linuxEnabledByDefault = append(r.linuxEnabledByDefault, p.Name())
+	}
+	return nil
+}
+
 func (r *Registry) RegisterPlaceholder(name, desc string) {
 	r.status[name] = CollectorStatus{
 		Name:        name,
@@ -68,18 +219,29 @@ func (r *Registry) Status(name string) CollectorStatus {
 	return CollectorStatus{Name: name}
 }
 
-func (r *Registry) Collect(name string) ([]MetricFamily, error) {
+// Collect runs a single collector. Use CollectMany to run several with deadlines.
+func (r *Registry) Collect(ctx context.Context, name string) ([]MetricFamily, error) {
 	st, ok := r.status[name]
 	if !ok {
 		return nil, fmt.Errorf("unknown collector: %s", name)
 	}
 	if !st.Implemented {
+		if len(st.Backends) > 0 {
+			return nil, fmt.Errorf("collector %s has no %s implementation (available: %s)", name, r.backend, joinBackends(st.Backends))
+		}
 		return nil, fmt.Errorf("collector %s not implemented", name)
 	}
 	c, ok := r.collectors[name]
 	if !ok {
 		return nil, fmt.Errorf("collector %s is marked implemented but missing", name)
 	}
-	return c.Collect(context.Background())
+	return c.Collect(ctx)
 }
 
+func joinBackends(bs []Backend) string {
+	out := make([]string, len(bs))
+	for i, b := range bs {
+		out[i] = string(b)
+	}
+	return strings.Join(out, ", ")
+}
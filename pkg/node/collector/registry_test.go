@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"testing"
+)
+
+type fakeCollector struct{ name, tag string }
+
+func (f fakeCollector) Name() string     { return f.name }
+func (f fakeCollector) Describe() string { return "fake " + f.tag }
+func (f fakeCollector) Collect(context.Context) ([]MetricFamily, error) {
+	return []MetricFamily{{Name: f.tag, Type: MetricTypeGauge, Samples: []Sample{{Value: 1}}}}, nil
+}
+
+func TestParseBackendSpec(t *testing.T) {
+	def, overrides, err := ParseBackendSpec("native, diskstats=upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def != BackendNative || overrides["diskstats"] != BackendUpstream || len(overrides) != 1 {
+		t.Fatalf("got %q %v", def, overrides)
+	}
+	if def, _, _ := ParseBackendSpec(""); def != BackendAuto {
+		t.Fatalf("empty spec: got %q, want auto", def)
+	}
+	for _, bad := range []string{"fast", "cpu=native,auto", "cpu=bogus"} {
+		if _, _, err := ParseBackendSpec(bad); err == nil {
+			t.Errorf("ParseBackendSpec(%q): expected error", bad)
+		}
+	}
+}
+
+func TestRegistry_SetBackend(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterBackend(BackendUpstream, fakeCollector{"cpu", "upstream"}, "")
+	r.RegisterBackend(BackendNative, fakeCollector{"cpu", "native"}, "")
+	r.RegisterBackend(BackendUpstream, fakeCollector{"arp", "upstream"}, "")
+
+	collected := func(name string) string {
+		t.Helper()
+		mf, err := r.Collect(context.Background(), name)
+		if err != nil {
+			t.Fatalf("Collect(%s): %v", name, err)
+		}
+		return mf[0].Name
+	}
+
+	// auto (default) prefers native.
+	if got := collected("cpu"); got != "native" {
+		t.Fatalf("auto cpu = %s", got)
+	}
+	if got := collected("arp"); got != "upstream" {
+		t.Fatalf("auto arp = %s", got)
+	}
+	if st := r.Status("cpu"); st.Backend != BackendNative || len(st.Backends) != 2 {
+		t.Fatalf("status = %+v", st)
+	}
+
+	if err := r.SetBackend(BackendNative, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Collect(context.Background(), "arp"); err == nil || r.Status("arp").Implemented {
+		t.Fatal("arp has no native implementation and should be unavailable")
+	}
+
+	if err := r.SetBackend(BackendAuto, map[string]Backend{"cpu": BackendUpstream}); err != nil {
+		t.Fatal(err)
+	}
+	if got := collected("cpu"); got != "upstream" {
+		t.Fatalf("override cpu = %s", got)
+	}
+	if err := r.SetBackend(BackendAuto, map[string]Backend{"arp": BackendNative}); err == nil {
+		t.Fatal("expected error for missing native arp")
+	}
+}
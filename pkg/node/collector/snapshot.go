@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// SnapshotVersion is the current on-disk snapshot format. Bump it on incompatible changes;
+// ReadSnapshot refuses files written by a newer version.
+const SnapshotVersion = 1
+
+// Snapshot is a point-in-time capture of collector output that can be saved and diffed later,
+// possibly against a capture from another host.
+type Snapshot struct {
+	Version    int            `json:"version"`
+	Host       string         `json:"host,omitempty"`
+	Time       time.Time      `json:"time"`
+	Collectors []string       `json:"collectors,omitempty"`
+	Errors     []string       `json:"errors,omitempty"`
+	Families   []MetricFamily `json:"families"`
+}
+
+func NewSnapshot(host string, at time.Time, collectors []string, families []MetricFamily) *Snapshot {
+	return &Snapshot{
+		Version:    SnapshotVersion,
+		Host:       host,
+		Time:       at,
+		Collectors: collectors,
+		Families:   families,
+	}
+}
+
+// Write encodes the snapshot as indented JSON.
+func (s *Snapshot) Write(w io.Writer) error {
+	if s.Families == nil {
+		s.Families = []MetricFamily{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	switch {
+	case s.Version == 0:
+		return nil, fmt.Errorf("not a nexa node snapshot (missing version)")
+	case s.Version > SnapshotVersion:
+		return nil, fmt.Errorf("snapshot version %d is newer than supported version %d", s.Version, SnapshotVersion)
+	}
+	return &s, nil
+}
+
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := ReadSnapshot(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := NewSnapshot("host-a", at, []string{"meminfo"}, []MetricFamily{{
+		Name:    "node_memory_MemFree_bytes",
+		Type:    MetricTypeGauge,
+		Samples: []Sample{{Value: math.NaN()}},
+	}})
+
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if got.Version != SnapshotVersion || got.Host != "host-a" || !got.Time.Equal(at) {
+		t.Fatalf("unexpected header: %+v", got)
+	}
+	if len(got.Families) != 1 || !math.IsNaN(got.Families[0].Samples[0].Value) {
+		t.Fatalf("unexpected families: %+v", got.Families)
+	}
+}
+
+func TestReadSnapshot_Version(t *testing.T) {
+	if _, err := ReadSnapshot(strings.NewReader(`{"families":[]}`)); err == nil {
+		t.Fatal("expected error for missing version")
+	}
+	if _, err := ReadSnapshot(strings.NewReader(`{"version":99,"families":[]}`)); err == nil {
+		t.Fatal("expected error for future version")
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dev := func(d string) []Label { return []Label{{Name: "device", Value: d}} }
+
+	before := NewSnapshot("h", t0, nil, []MetricFamily{
+		{Name: "node_load1", Type: MetricTypeGauge, Samples: []Sample{{Value: 1}}},
+		{Name: "node_load5", Type: MetricTypeGauge, Samples: []Sample{{Value: 1}}},
+		{Name: "node_disk_reads_completed_total", Type: MetricTypeCounter, Samples: []Sample{
+			{Labels: dev("sda"), Value: 100},
+			{Labels: dev("sdb"), Value: 5},
+		}},
+		{Name: "rpc_seconds", Type: MetricTypeHistogram, Histograms: []Histogram{{Count: 10, Sum: 1}}},
+	})
+	after := NewSnapshot("h", t0.Add(10*time.Second), nil, []MetricFamily{
+		{Name: "node_load1", Type: MetricTypeGauge, Samples: []Sample{{Value: 2}}},
+		{Name: "node_load5", Type: MetricTypeGauge, Samples: []Sample{{Value: 1.01}}},
+		{Name: "node_disk_reads_completed_total", Type: MetricTypeCounter, Samples: []Sample{
+			{Labels: dev("sda"), Value: 150},
+			{Labels: dev("sdc"), Value: 1},
+		}},
+		{Name: "rpc_seconds", Type: MetricTypeHistogram, Histograms: []Histogram{{Count: 20, Sum: 1}}},
+	})
+
+	d := DiffSnapshots(before, after, DiffOptions{Threshold: 0.05, Relative: true})
+	if d.Elapsed != 10*time.Second {
+		t.Fatalf("elapsed = %s", d.Elapsed)
+	}
+	if len(d.Added) != 1 || d.Added[0].Labels[0].Value != "sdc" {
+		t.Fatalf("added = %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Labels[0].Value != "sdb" {
+		t.Fatalf("removed = %+v", d.Removed)
+	}
+	// node_load5 moved by 1% and stays below the 5% threshold.
+	if len(d.Changed) != 1 || d.Changed[0].Metric != "node_load1" || d.Changed[0].Delta() != 1 {
+		t.Fatalf("changed = %+v", d.Changed)
+	}
+	// rpc_seconds_sum did not move and is omitted.
+	if len(d.Counters) != 2 {
+		t.Fatalf("counters = %+v", d.Counters)
+	}
+	if c := d.Counters[0]; c.Metric != "node_disk_reads_completed_total" || c.Rate != 5 {
+		t.Fatalf("disk counter = %+v", c)
+	}
+	if c := d.Counters[1]; c.Metric != "rpc_seconds_count" || c.Rate != 1 {
+		t.Fatalf("histogram count = %+v", c)
+	}
+
+	if d := DiffSnapshots(before, after, DiffOptions{Threshold: 0.5}); len(d.Changed) != 1 {
+		t.Fatalf("absolute threshold: changed = %+v", d.Changed)
+	}
+	if d := DiffSnapshots(after, before, DiffOptions{}); !math.IsNaN(d.Counters[0].Rate) {
+		t.Fatalf("expected no rate for reversed snapshots, got %v", d.Counters[0].Rate)
+	}
+}
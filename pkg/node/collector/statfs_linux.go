@@ -0,0 +1,18 @@
+package collector
+
+import "golang.org/x/sys/unix"
+
+func sysStatfs(path string) (statfsResult, error) {
+	var buf unix.Statfs_t
+	if err := unix.Statfs(path, &buf); err != nil {
+		return statfsResult{}, err
+	}
+	bsize := float64(buf.Bsize)
+	return statfsResult{
+		size:      float64(buf.Blocks) * bsize,
+		free:      float64(buf.Bfree) * bsize,
+		avail:     float64(buf.Bavail) * bsize,
+		files:     float64(buf.Files),
+		filesFree: float64(buf.Ffree),
+	}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package collector
+
+import "errors"
+
+func sysStatfs(path string) (statfsResult, error) {
+	return statfsResult{}, errors.New("statfs is only implemented on linux")
+}
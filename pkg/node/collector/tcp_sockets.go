@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/nexa/pkg/node/sockets"
+)
+
+// TCPSocketsCollector aggregates sock_diag socket details per port: a listener's port for the
+// connections it accepted, the remote port for connections this host opened.
+type TCPSocketsCollector struct{}
+
+func NewTCPSocketsCollector() *TCPSocketsCollector { return &TCPSocketsCollector{} }
+
+func (c *TCPSocketsCollector) Name() string { return "tcp_sockets" }
+func (c *TCPSocketsCollector) Describe() string {
+	return "Exposes TCP socket counts per state, queues, retransmits and congestion per port from sock_diag"
+}
+
+func (c *TCPSocketsCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
+	var opt sockets.Options
+	// With the host's /proc mounted into a container, report the host's sockets.
+	if ns := procFilePath("1/ns/net"); procPath != "/proc" {
+		if _, err := os.Stat(ns); err == nil {
+			opt.NetNS = ns
+		}
+	}
+	socks, err := sockets.Dump(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return tcpSocketFamilies(sockets.Aggregate(socks, false)), nil
+}
+
+func tcpSocketFamilies(stats []sockets.Stats) []MetricFamily {
+	type familyDef struct {
+		name, help string
+	}
+	defs := []familyDef{
+		{"node_tcp_sockets", "TCP sockets per port, direction and state."},
+		{"node_tcp_sockets_recv_queue_bytes", "Bytes not yet read by the application, summed over the connections of the port."},
+		{"node_tcp_sockets_send_queue_bytes", "Bytes not yet acknowledged by the peer, summed over the connections of the port."},
+		{"node_tcp_listen_accept_queue", "Connections waiting to be accepted on the listening port."},
+		{"node_tcp_listen_accept_queue_max", "Accept queue limit (backlog) of the listening port."},
+		{"node_tcp_sockets_retransmitted_segments", "Segments retransmitted over the lifetime of the current connections of the port."},
+		{"node_tcp_sockets_low_cwnd", "Established connections of the port with a congestion window below the initial window."},
+		{"node_tcp_sockets_rtt_max_seconds", "Largest smoothed round-trip time among the connections of the port."},
+	}
+	families := make(map[string]*MetricFamily, len(defs))
+	for _, d := range defs {
+		families[d.name] = &MetricFamily{Name: d.name, Help: d.help, Type: MetricTypeGauge}
+	}
+	add := func(name string, v float64, kv ...string) {
+		f := families[name]
+		f.Samples = append(f.Samples, Sample{Labels: sortedLabels(kv...), Value: v})
+	}
+	for i := range stats {
+		st := &stats[i]
+		port, dir := strconv.Itoa(int(st.Port)), string(st.Direction)
+		for _, s := range sockets.States() {
+			if n := st.Count(s); n > 0 {
+				add("node_tcp_sockets", float64(n), "port", port, "direction", dir, "state", s.String())
+			}
+		}
+		if st.Count(sockets.StateListen) > 0 {
+			add("node_tcp_listen_accept_queue", float64(st.AcceptQ), "port", port)
+			add("node_tcp_listen_accept_queue_max", float64(st.AcceptQMax), "port", port)
+		}
+		if st.Total == st.Count(sockets.StateListen) {
+			continue
+		}
+		add("node_tcp_sockets_recv_queue_bytes", float64(st.RecvQ), "port", port, "direction", dir)
+		add("node_tcp_sockets_send_queue_bytes", float64(st.SendQ), "port", port, "direction", dir)
+		add("node_tcp_sockets_retransmitted_segments", float64(st.Retrans), "port", port, "direction", dir)
+		add("node_tcp_sockets_low_cwnd", float64(st.LowCwnd), "port", port, "direction", dir)
+		add("node_tcp_sockets_rtt_max_seconds", st.MaxRTT.Seconds(), "port", port, "direction", dir)
+	}
+
+	out := make([]MetricFamily, 0, len(defs))
+	for _, d := range defs {
+		if f := families[d.name]; len(f.Samples) > 0 {
+			out = append(out, *f)
+		}
+	}
+	return out
+}
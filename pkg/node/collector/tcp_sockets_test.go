@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nexa/pkg/node/sockets"
+)
+
+func TestTCPSocketFamilies(t *testing.T) {
+	addr := netip.MustParseAddrPort
+	socks := []sockets.Socket{
+		{State: sockets.StateListen, Local: addr("0.0.0.0:8080"), RecvQ: 2, SendQ: 511},
+		{State: sockets.StateEstablished, Local: addr("10.0.0.1:8080"), Remote: addr("10.0.0.2:40000"), RecvQ: 100,
+			Info: &sockets.TCPInfo{RTT: 3 * time.Millisecond, SndCwnd: 2, TotalRetrans: 5}},
+		{State: sockets.StateTimeWait, Local: addr("10.0.0.1:41000"), Remote: addr("10.0.0.3:6379")},
+	}
+	var got []string
+	for _, f := range tcpSocketFamilies(sockets.Aggregate(socks, false)) {
+		for _, s := range f.Samples {
+			got = append(got, f.Name+"{"+FormatLabels(s.Labels)+"} "+strconv.FormatFloat(s.Value, 'g', -1, 64))
+		}
+	}
+	want := []string{
+		`node_tcp_sockets{direction="in",port="8080",state="established"} 1`,
+		`node_tcp_sockets{direction="in",port="8080",state="listen"} 1`,
+		`node_tcp_sockets{direction="out",port="6379",state="time_wait"} 1`,
+		`node_tcp_sockets_recv_queue_bytes{direction="in",port="8080"} 100`,
+		`node_tcp_sockets_recv_queue_bytes{direction="out",port="6379"} 0`,
+		`node_tcp_sockets_send_queue_bytes{direction="in",port="8080"} 0`,
+		`node_tcp_sockets_send_queue_bytes{direction="out",port="6379"} 0`,
+		`node_tcp_listen_accept_queue{port="8080"} 2`,
+		`node_tcp_listen_accept_queue_max{port="8080"} 511`,
+		`node_tcp_sockets_retransmitted_segments{direction="in",port="8080"} 5`,
+		`node_tcp_sockets_retransmitted_segments{direction="out",port="6379"} 0`,
+		`node_tcp_sockets_low_cwnd{direction="in",port="8080"} 1`,
+		`node_tcp_sockets_low_cwnd{direction="out",port="6379"} 0`,
+		`node_tcp_sockets_rtt_max_seconds{direction="in",port="8080"} 0.003`,
+		`node_tcp_sockets_rtt_max_seconds{direction="out",port="6379"} 0`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
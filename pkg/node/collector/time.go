@@ -2,6 +2,9 @@ package collector
 
 import (
 	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,25 +18,40 @@ func (c *TimeCollector) Describe() string { return "Exposes the current system t
 func (c *TimeCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
 	_ = ctx
 	now := time.Now()
-	_, offset := now.Zone()
+	zone, offset := now.Zone()
 
-	return []MetricFamily{
+	out := []MetricFamily{
 		{
 			Name: "node_time_seconds",
 			Help: "System time in seconds since epoch (1970).",
 			Type: MetricTypeGauge,
 			Samples: []Sample{
-				{Value: float64(now.Unix())},
+				{Value: float64(now.UnixNano()) / 1e9},
 			},
 		},
 		{
 			Name: "node_time_zone_offset_seconds",
-			Help: "System timezone offset in seconds.",
+			Help: "System time zone offset in seconds.",
 			Type: MetricTypeGauge,
 			Samples: []Sample{
-				{Value: float64(offset)},
+				{Labels: sortedLabels("time_zone", zone), Value: float64(offset)},
 			},
 		},
-	}, nil
-}
+	}
 
+	available := MetricFamily{Name: "node_time_clocksource_available_info", Help: "Available clocksources read from '/sys/devices/system/clocksource'.", Type: MetricTypeGauge}
+	current := MetricFamily{Name: "node_time_clocksource_current_info", Help: "Current clocksource read from '/sys/devices/system/clocksource'.", Type: MetricTypeGauge}
+	dirs, _ := filepath.Glob(sysFilePath("devices/system/clocksource/clocksource[0-9]*"))
+	for i, dir := range dirs {
+		device := strconv.Itoa(i)
+		if s, err := readSysString(filepath.Join(dir, "available_clocksource")); err == nil {
+			for _, cs := range strings.Fields(s) {
+				available.Samples = append(available.Samples, Sample{Labels: sortedLabels("device", device, "clocksource", cs), Value: 1})
+			}
+		}
+		if s, err := readSysString(filepath.Join(dir, "current_clocksource")); err == nil {
+			current.Samples = append(current.Samples, Sample{Labels: sortedLabels("device", device, "clocksource", s), Value: 1})
+		}
+	}
+	return append(out, nonEmptyFamilies([]MetricFamily{available, current})...), nil
+}
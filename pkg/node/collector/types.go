@@ -19,8 +19,8 @@ const (
 )
 
 type Label struct {
-	Name  string
-	Value string
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 func LabelsFromMap(m map[string]string) []Label {
@@ -73,11 +73,11 @@ type Bucket struct {
 }
 
 type Summary struct {
-	Labels     []Label
-	Quantiles  []Quantile // ordered by Quantile
-	Count      uint64
-	Sum        float64
-	Timestamp  *time.Time
+	Labels    []Label
+	Quantiles []Quantile // ordered by Quantile
+	Count     uint64
+	Sum       float64
+	Timestamp *time.Time
 }
 
 type Quantile struct {
@@ -86,13 +86,13 @@ type Quantile struct {
 }
 
 type MetricFamily struct {
-	Name string
-	Help string
-	Type MetricType
+	Name string     `json:"name"`
+	Help string     `json:"help,omitempty"`
+	Type MetricType `json:"type"`
 
-	Samples    []Sample
-	Histograms []Histogram
-	Summaries  []Summary
+	Samples    []Sample    `json:"samples,omitempty"`
+	Histograms []Histogram `json:"histograms,omitempty"`
+	Summaries  []Summary   `json:"summaries,omitempty"`
 }
 
 type Collector interface {
@@ -105,6 +105,12 @@ type CollectorStatus struct {
 	Name        string
 	Description string
 	Implemented bool
+	// Backend is the implementation in use; empty for collectors with a single implementation.
+	Backend Backend
+	// Backends lists the available implementations.
+	Backends []Backend
+	// Plugin is the executable of an external collector; empty for built-in ones.
+	Plugin string
 }
 
 func (mf MetricFamily) SamplesCount() int {
@@ -142,4 +148,3 @@ func (mf MetricFamily) Example() (labels string, value string) {
 		return FormatLabels(s.Labels), strconv.FormatFloat(s.Value, 'f', -1, 64)
 	}
 }
-
@@ -2,8 +2,6 @@ package collector
 
 import (
 	"context"
-
-	"github.com/shirou/gopsutil/v4/host"
 )
 
 type UnameCollector struct{}
@@ -13,8 +11,13 @@ func NewUnameCollector() *UnameCollector { return &UnameCollector{} }
 func (c *UnameCollector) Name() string     { return "uname" }
 func (c *UnameCollector) Describe() string { return "Exposes system information as provided by uname" }
 
+type utsname struct {
+	sysname, release, version, machine, nodename, domainname string
+}
+
 func (c *UnameCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	hi, err := host.InfoWithContext(ctx)
+	_ = ctx
+	u, err := uname()
 	if err != nil {
 		return nil, err
 	}
@@ -26,18 +29,17 @@ func (c *UnameCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
 			Type: MetricTypeGauge,
 			Samples: []Sample{
 				{
-					Labels: []Label{
-						// gopsutil doesn't expose full uname(2) fields; approximate with HostInfoStat.
-						{Name: "sysname", Value: hi.OS},
-						{Name: "release", Value: hi.KernelVersion},
-						{Name: "version", Value: hi.KernelVersion},
-						{Name: "machine", Value: hi.KernelArch},
-						{Name: "nodename", Value: hi.Hostname},
-					},
+					Labels: sortedLabels(
+						"sysname", u.sysname,
+						"release", u.release,
+						"version", u.version,
+						"machine", u.machine,
+						"nodename", u.nodename,
+						"domainname", u.domainname,
+					),
 					Value: 1,
 				},
 			},
 		},
 	}, nil
 }
-
@@ -0,0 +1,18 @@
+package collector
+
+import "golang.org/x/sys/unix"
+
+func uname() (utsname, error) {
+	var u unix.Utsname
+	if err := unix.Uname(&u); err != nil {
+		return utsname{}, err
+	}
+	return utsname{
+		sysname:    unix.ByteSliceToString(u.Sysname[:]),
+		release:    unix.ByteSliceToString(u.Release[:]),
+		version:    unix.ByteSliceToString(u.Version[:]),
+		machine:    unix.ByteSliceToString(u.Machine[:]),
+		nodename:   unix.ByteSliceToString(u.Nodename[:]),
+		domainname: unix.ByteSliceToString(u.Domainname[:]),
+	}, nil
+}
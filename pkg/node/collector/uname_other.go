@@ -0,0 +1,9 @@
+//go:build !linux
+
+package collector
+
+import "errors"
+
+func uname() (utsname, error) {
+	return utsname{}, errors.New("uname is only implemented on linux")
+}
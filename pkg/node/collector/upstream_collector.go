@@ -6,6 +6,8 @@ import (
 	up "github.com/nexa/pkg/node/upstream"
 )
 
+// UpstreamCollector runs one node_exporter collector through the shared upstream bridge. Collect
+// scrapes it alone; Registry.CollectMany scrapes all upstream collectors of a run together.
 type UpstreamCollector struct {
 	name string
 	desc string
@@ -19,10 +21,53 @@ func (c *UpstreamCollector) Name() string     { return c.name }
 func (c *UpstreamCollector) Describe() string { return c.desc }
 
 func (c *UpstreamCollector) Collect(ctx context.Context) ([]MetricFamily, error) {
-	dto, err := up.CollectDTO(ctx, []string{c.name})
+	b, err := up.Default()
 	if err != nil {
 		return nil, err
 	}
-	return DTOToNexa(dto)
+	r := b.GatherCollector(ctx, c.name)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return DTOToNexa(r.Families)
+}
+
+// collectUpstream scrapes the upstream collectors names[i] for i in idx in one pass over the
+// shared bridge and stores their results in results.
+func collectUpstream(ctx context.Context, names []string, idx []int, opt CollectOptions, results []CollectResult) {
+	b, err := up.Default()
+	if err != nil {
+		for _, i := range idx {
+			results[i] = CollectResult{Name: names[i], Err: err}
+		}
+		return
+	}
+	want := make([]string, len(idx))
+	for n, i := range idx {
+		want[n] = names[i]
+	}
+	res := b.GatherByCollector(ctx, opt.timeout, want...)
+	for _, i := range idx {
+		name := names[i]
+		r := res[name]
+		out := CollectResult{Name: name, Duration: r.Duration, Err: timeoutError(r.Err, opt.timeout(name))}
+		if r.Err == nil {
+			out.Families, out.Err = DTOToNexa(r.Families)
+		}
+		results[i] = out
+	}
 }
 
+// ConfigureUpstream prepares the upstream bridge for the given collectors, forwarding the
+// filesystem roots used by the native collectors and the textfile directories. Call it once,
+// before the first upstream collector runs.
+func ConfigureUpstream(collectors, textfileDirs []string) error {
+	return up.Configure(up.Options{
+		ProcPath:            procPath,
+		SysPath:             sysPath,
+		RootfsPath:          rootfsPath,
+		UdevDataPath:        udevDataPath,
+		TextfileDirectories: textfileDirs,
+		Collectors:          collectors,
+	})
+}
@@ -0,0 +1,117 @@
+// Package exporter serves collector.Registry output as a Prometheus /metrics endpoint.
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/nexa/pkg/node/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	scrapeDurationName = "node_scrape_collector_duration_seconds"
+	scrapeSuccessName  = "node_scrape_collector_success"
+)
+
+// Options configures the /metrics handler.
+type Options struct {
+	// Names are the collectors to run on every scrape.
+	Names []string
+	// MaxRequestsInFlight caps concurrent scrapes; further requests get 503. 0 means no limit.
+	MaxRequestsInFlight int
+	// Collect sets the worker pool size and per-collector deadlines of each scrape.
+	Collect collector.CollectOptions
+	// Transform is applied to each collector's output before encoding (e.g. post-filters).
+	Transform func([]collector.MetricFamily) ([]collector.MetricFamily, error)
+}
+
+// Handler returns an http.Handler that collects opt.Names from reg on every request and
+// encodes the result using the negotiated exposition format (text or OpenMetrics).
+func Handler(reg *collector.Registry, opt Options) http.Handler {
+	return promhttp.HandlerFor(NewGatherer(reg, opt), promhttp.HandlerOpts{
+		EnableOpenMetrics:   true,
+		MaxRequestsInFlight: opt.MaxRequestsInFlight,
+		ErrorHandling:       promhttp.ContinueOnError,
+	})
+}
+
+// Gatherer adapts a collector.Registry to prometheus.Gatherer.
+type Gatherer struct {
+	reg *collector.Registry
+	opt Options
+}
+
+var _ prometheus.Gatherer = (*Gatherer)(nil)
+
+func NewGatherer(reg *collector.Registry, opt Options) *Gatherer {
+	return &Gatherer{reg: reg, opt: opt}
+}
+
+// Gather runs all configured collectors concurrently. A failing or timed-out collector does not fail
+// the scrape; it is reported through node_scrape_collector_success instead, like node_exporter does.
+func (g *Gatherer) Gather() ([]*dto.MetricFamily, error) {
+	results := g.reg.CollectMany(context.Background(), g.opt.Names, g.opt.Collect)
+	if g.opt.Transform != nil {
+		for i, r := range results {
+			if r.Err == nil {
+				results[i].Families, results[i].Err = g.opt.Transform(r.Families)
+			}
+		}
+	}
+
+	duration := collector.MetricFamily{
+		Name: scrapeDurationName,
+		Help: "nexa: Duration of a collector scrape.",
+		Type: collector.MetricTypeGauge,
+	}
+	success := collector.MetricFamily{
+		Name: scrapeSuccessName,
+		Help: "nexa: Whether a collector succeeded.",
+		Type: collector.MetricTypeGauge,
+	}
+
+	byName := map[string]*dto.MetricFamily{}
+	for _, r := range results {
+		lbl := []collector.Label{{Name: "collector", Value: r.Name}}
+		duration.Samples = append(duration.Samples, collector.Sample{Labels: lbl, Value: r.Duration.Seconds()})
+		ok := 1.0
+		if r.Err != nil {
+			ok = 0
+		}
+		success.Samples = append(success.Samples, collector.Sample{Labels: lbl, Value: ok})
+
+		for _, mf := range collector.NexaToDTO(r.Families) {
+			// Upstream collectors report their own scrape metrics; ours cover every backend.
+			if strings.HasPrefix(mf.GetName(), "node_scrape_collector_") {
+				continue
+			}
+			mergeFamily(byName, mf)
+		}
+	}
+	for _, mf := range collector.NexaToDTO([]collector.MetricFamily{duration, success}) {
+		mergeFamily(byName, mf)
+	}
+
+	out := make([]*dto.MetricFamily, 0, len(byName))
+	for _, mf := range byName {
+		out = append(out, mf)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetName() < out[j].GetName() })
+	return out, nil
+}
+
+// mergeFamily keeps a single family per name; the exposition formats reject repeated families.
+func mergeFamily(byName map[string]*dto.MetricFamily, mf *dto.MetricFamily) {
+	if cur, ok := byName[mf.GetName()]; ok {
+		if cur.GetType() == mf.GetType() {
+			cur.Metric = append(cur.Metric, mf.Metric...)
+		}
+		return
+	}
+	byName[mf.GetName()] = mf
+}
@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+type fakeCollector struct {
+	name     string
+	families []collector.MetricFamily
+	err      error
+}
+
+func (c *fakeCollector) Name() string     { return c.name }
+func (c *fakeCollector) Describe() string { return "fake" }
+func (c *fakeCollector) Collect(ctx context.Context) ([]collector.MetricFamily, error) {
+	return c.families, c.err
+}
+
+func newTestRegistry() *collector.Registry {
+	reg := collector.NewRegistry()
+	reg.RegisterImplemented(&fakeCollector{
+		name: "good",
+		families: []collector.MetricFamily{{
+			Name: "node_test_info",
+			Help: "Test info.",
+			Type: collector.MetricTypeGauge,
+			Samples: []collector.Sample{
+				{Labels: []collector.Label{{Name: "path", Value: `C:\tmp "x"` + "\n"}}, Value: 1},
+			},
+		}},
+	})
+	reg.RegisterImplemented(&fakeCollector{name: "bad", err: errors.New("boom")})
+	return reg
+}
+
+func scrape(t *testing.T, h http.Handler, accept string) (string, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Header().Get("Content-Type"), string(body)
+}
+
+func TestHandler_TextFormat(t *testing.T) {
+	h := Handler(newTestRegistry(), Options{Names: []string{"bad", "good"}})
+	ct, body := scrape(t, h, "")
+
+	if !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	for _, want := range []string{
+		`node_test_info{path="C:\\tmp \"x\"\n"} 1`,
+		`node_scrape_collector_success{collector="bad"} 0`,
+		`node_scrape_collector_success{collector="good"} 1`,
+		`# TYPE node_scrape_collector_duration_seconds gauge`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandler_OpenMetrics(t *testing.T) {
+	h := Handler(newTestRegistry(), Options{Names: []string{"good"}})
+	ct, body := scrape(t, h, "application/openmetrics-text;version=1.0.0")
+
+	if !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Fatalf("missing OpenMetrics EOF marker:\n%s", body)
+	}
+}
@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const landingPage = `<html>
+<head><title>nexa node exporter</title></head>
+<body>
+<h1>nexa node exporter</h1>
+<p><a href="%s">Metrics</a></p>
+</body>
+</html>
+`
+
+// Serve exposes h on metricsPath at addr until ctx is cancelled, then shuts the server down gracefully.
+func Serve(ctx context.Context, addr, metricsPath string, h http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, h)
+	if metricsPath != "/" {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = fmt.Fprintf(w, landingPage, metricsPath)
+		})
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,100 @@
+// Package push sends collected node metrics to a Prometheus Pushgateway or a remote_write endpoint,
+// for hosts that Prometheus cannot scrape.
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Retry configures how failed requests are retried: network errors, 429 and 5xx responses are
+// retried with exponential backoff, other responses fail immediately.
+type Retry struct {
+	// Attempts is the total number of tries; values below 1 mean a single try.
+	Attempts   int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetry matches the defaults of the nexa node push flags.
+var DefaultRetry = Retry{Attempts: 4, MinBackoff: 500 * time.Millisecond, MaxBackoff: 30 * time.Second}
+
+func (r Retry) backoff(attempt int) time.Duration {
+	d := r.MinBackoff
+	for i := 1; i < attempt && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	return d
+}
+
+// StatusError is a non-2xx response.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("server returned HTTP status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("server returned HTTP status %d: %s", e.StatusCode, e.Body)
+}
+
+func retryable(err error) bool {
+	se, ok := err.(*StatusError)
+	if !ok {
+		return true // transport error
+	}
+	return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+}
+
+// send sends body to url, retrying according to retry.
+func send(ctx context.Context, client *http.Client, retry Retry, method, url string, body []byte, header http.Header) error {
+	attempts := retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(retry.backoff(attempt - 1)):
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+			}
+		}
+		err = sendOnce(ctx, client, method, url, body, header)
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+func sendOnce(ctx context.Context, client *http.Client, method, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
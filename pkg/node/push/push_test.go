@@ -0,0 +1,267 @@
+package push
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/nexa/pkg/net/httpclient"
+	"github.com/nexa/pkg/node/collector"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var testFamilies = []collector.MetricFamily{
+	{Name: "node_load1", Help: "1m load average.", Type: collector.MetricTypeGauge, Samples: []collector.Sample{{Value: 0.5}}},
+	{Name: "node_cpu_seconds_total", Help: "Seconds the CPUs spent in each mode.", Type: collector.MetricTypeCounter, Samples: []collector.Sample{
+		{Labels: []collector.Label{{Name: "cpu", Value: "0"}, {Name: "mode", Value: "idle"}}, Value: 100},
+	}},
+	{Name: "rpc_seconds", Type: collector.MetricTypeHistogram, Histograms: []collector.Histogram{{
+		Buckets: []collector.Bucket{{UpperBound: 0.1, Count: 3}, {UpperBound: math.Inf(1), Count: 4}}, Count: 4, Sum: 1.5,
+	}}},
+}
+
+// writeRequest is the decoded form of a prompb.WriteRequest.
+type writeRequest struct {
+	series   map[string]float64 // "name{labels}" -> value
+	stamps   map[int64]bool
+	metadata map[string]uint64 // family -> type
+}
+
+func decodeWriteRequest(t *testing.T, b []byte) writeRequest {
+	t.Helper()
+	wr := writeRequest{series: map[string]float64{}, stamps: map[int64]bool{}, metadata: map[string]uint64{}}
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("bad tag: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			n = fn(num, typ, b)
+			if n < 0 {
+				t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+			}
+			b = b[n:]
+		}
+	}
+	fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		msg, n := protowire.ConsumeBytes(b)
+		switch num {
+		case writeRequestTimeseries:
+			var name string
+			var labels []string
+			var value float64
+			fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+				inner, n := protowire.ConsumeBytes(b)
+				switch num {
+				case timeSeriesLabels:
+					var k, v string
+					fields(inner, func(num protowire.Number, typ protowire.Type, b []byte) int {
+						s, n := protowire.ConsumeString(b)
+						if num == labelName {
+							k = s
+						} else {
+							v = s
+						}
+						return n
+					})
+					if k == "__name__" {
+						name = v
+					} else {
+						labels = append(labels, k+"="+v)
+					}
+				case timeSeriesSamples:
+					fields(inner, func(num protowire.Number, typ protowire.Type, b []byte) int {
+						if num == sampleValue {
+							bits, n := protowire.ConsumeFixed64(b)
+							value = math.Float64frombits(bits)
+							return n
+						}
+						ts, n := protowire.ConsumeVarint(b)
+						wr.stamps[int64(ts)] = true
+						return n
+					})
+				}
+				return n
+			})
+			wr.series[name+"{"+strings.Join(labels, ",")+"}"] = value
+		case writeRequestMetadata:
+			var name string
+			var typ uint64
+			fields(msg, func(num protowire.Number, wt protowire.Type, b []byte) int {
+				if wt == protowire.VarintType {
+					v, n := protowire.ConsumeVarint(b)
+					typ = v
+					return n
+				}
+				s, n := protowire.ConsumeString(b)
+				if num == metadataFamilyName {
+					name = s
+				}
+				return n
+			})
+			wr.metadata[name] = typ
+		}
+		return n
+	})
+	return wr
+}
+
+func TestRemoteWrite(t *testing.T) {
+	var got writeRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		b, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("snappy: %v", err)
+			return
+		}
+		got = decodeWriteRequest(t, b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	rw := &RemoteWrite{
+		URL:            srv.URL + "/api/v1/write",
+		ExternalLabels: []collector.Label{{Name: "instance", Value: "host-a"}},
+		Client:         newClient(t, httpclient.Config{BearerToken: "s3cret"}),
+	}
+	at := time.UnixMilli(1700000000123)
+	if err := rw.Write(context.Background(), testFamilies, at); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{
+		"node_load1{instance=host-a}":                             0.5,
+		"node_cpu_seconds_total{cpu=0,instance=host-a,mode=idle}": 100,
+		"rpc_seconds_bucket{instance=host-a,le=0.1}":              3,
+		"rpc_seconds_bucket{instance=host-a,le=+Inf}":             4,
+		"rpc_seconds_sum{instance=host-a}":                        1.5,
+		"rpc_seconds_count{instance=host-a}":                      4,
+	}
+	if len(got.series) != len(want) {
+		t.Fatalf("series = %v, want %v", got.series, want)
+	}
+	for k, v := range want {
+		if got.series[k] != v {
+			t.Errorf("%s = %v, want %v (all: %v)", k, got.series[k], v, got.series)
+		}
+	}
+	if len(got.stamps) != 1 || !got.stamps[at.UnixMilli()] {
+		t.Errorf("timestamps = %v", got.stamps)
+	}
+	if got.metadata["node_cpu_seconds_total"] != 1 || got.metadata["node_load1"] != 2 || got.metadata["rpc_seconds"] != 3 {
+		t.Errorf("metadata = %v", got.metadata)
+	}
+}
+
+func TestSeriesLabels(t *testing.T) {
+	got := seriesLabels("up", []collector.Label{{Name: "instance", Value: "own"}}, []collector.Label{{Name: "instance", Value: "ext"}, {Name: "dc", Value: "eu"}})
+	if s := collector.FormatLabels(got); s != `__name__="up",dc="eu",instance="own"` {
+		t.Fatalf("seriesLabels = %s", s)
+	}
+}
+
+func TestPushgateway(t *testing.T) {
+	var path string
+	var families map[string]float64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s", r.Method)
+		}
+		if u, p, ok := r.BasicAuth(); !ok || u != "push" || p != "pw" {
+			t.Errorf("basic auth = %q %q %v", u, p, ok)
+		}
+		path = r.URL.EscapedPath()
+		parser := expfmt.NewTextParser(model.UTF8Validation)
+		mfs, err := parser.TextToMetricFamilies(r.Body)
+		if err != nil {
+			t.Errorf("parse: %v", err)
+		}
+		families = map[string]float64{}
+		for name, mf := range mfs {
+			for _, m := range mf.Metric {
+				if m.TimestampMs != nil {
+					t.Errorf("%s has a timestamp", name)
+				}
+			}
+			families[name] = float64(len(mf.Metric))
+		}
+	}))
+	defer srv.Close()
+
+	ts := time.Now()
+	in := append([]collector.MetricFamily(nil), testFamilies...)
+	in = append(in, collector.MetricFamily{Name: "textfile_metric", Type: collector.MetricTypeGauge, Samples: []collector.Sample{{Value: 1, Timestamp: &ts}}})
+	p := &Pushgateway{
+		URL:      srv.URL + "/",
+		Job:      "nexa",
+		Grouping: []collector.Label{{Name: "path", Value: "/var/lib"}, {Name: "instance", Value: "host a"}},
+		Client:   newClient(t, httpclient.Config{BasicAuth: "push:pw"}),
+	}
+	if err := p.Push(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/metrics/job/nexa/instance/host%20a/path@base64/L3Zhci9saWI"; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if len(families) != 4 || families["rpc_seconds"] != 1 {
+		t.Errorf("families = %v", families)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	rw := &RemoteWrite{URL: srv.URL, Client: srv.Client(), Retry: Retry{Attempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}}
+	if err := rw.Write(context.Background(), testFamilies, time.Now()); err != nil {
+		t.Fatalf("expected success on the third attempt: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("calls = %d", calls.Load())
+	}
+
+	// Client errors are not retried.
+	calls.Store(0)
+	status = http.StatusBadRequest
+	err := rw.Write(context.Background(), testFamilies, time.Now())
+	if se, ok := err.(interface{ Unwrap() error }); !ok || se.Unwrap().(*StatusError).StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func newClient(t *testing.T, cfg httpclient.Config) *http.Client {
+	t.Helper()
+	client, err := cfg.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
@@ -0,0 +1,90 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/nexa/pkg/node/collector"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Pushgateway pushes to the grouping key of a Prometheus Pushgateway.
+type Pushgateway struct {
+	// URL is the Pushgateway base URL, e.g. http://pushgateway:9091.
+	URL string
+	Job string
+	// Grouping are further grouping key labels (e.g. instance). The Pushgateway attaches them
+	// to every pushed series.
+	Grouping []collector.Label
+
+	// Client sends the requests; build it with httpclient.Config.NewClient for auth and TLS.
+	Client *http.Client
+	Retry  Retry
+}
+
+// GroupURL is the URL of the grouping key. Values that are empty or contain a slash use the
+// base64 form of the Pushgateway API.
+func (p *Pushgateway) GroupURL() (string, error) {
+	if p.Job == "" {
+		return "", fmt.Errorf("pushgateway: job must not be empty")
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return "", fmt.Errorf("pushgateway: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("pushgateway: URL %q must be http or https", p.URL)
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(u.String(), "/"))
+	b.WriteString("/metrics")
+	segment := func(name, value string) {
+		if value == "" || strings.Contains(value, "/") {
+			b.WriteString("/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value)))
+			if value == "" {
+				b.WriteString("=")
+			}
+			return
+		}
+		b.WriteString("/" + name + "/" + url.PathEscape(value))
+	}
+	segment("job", p.Job)
+	grouping := append([]collector.Label(nil), p.Grouping...)
+	sort.Slice(grouping, func(i, j int) bool { return grouping[i].Name < grouping[j].Name })
+	for _, l := range grouping {
+		if l.Name == "job" {
+			return "", fmt.Errorf("pushgateway: job is set with --job, not as a grouping label")
+		}
+		segment(l.Name, l.Value)
+	}
+	return b.String(), nil
+}
+
+// Push replaces the metrics of the grouping key with families (HTTP PUT). Timestamps are
+// dropped because the Pushgateway rejects them.
+func (p *Pushgateway) Push(ctx context.Context, families []collector.MetricFamily) error {
+	target, err := p.GroupURL()
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	for _, mf := range collector.NexaToDTO(families) {
+		for _, m := range mf.Metric {
+			m.TimestampMs = nil
+		}
+		if _, err := expfmt.MetricFamilyToText(&body, mf); err != nil {
+			return err
+		}
+	}
+	header := http.Header{"Content-Type": {string(expfmt.NewFormat(expfmt.TypeTextPlain))}}
+	if err := send(ctx, p.Client, p.Retry, http.MethodPut, target, body.Bytes(), header); err != nil {
+		return fmt.Errorf("pushgateway: %w", err)
+	}
+	return nil
+}
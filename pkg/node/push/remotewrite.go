@@ -0,0 +1,161 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/nexa/pkg/node/collector"
+	"github.com/nexa/pkg/node/render"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWrite sends samples to a Prometheus remote_write (1.0) endpoint, e.g.
+// http://prometheus:9090/api/v1/write.
+type RemoteWrite struct {
+	URL string
+	// ExternalLabels are added to every series that does not already have the label.
+	ExternalLabels []collector.Label
+
+	// Client sends the requests; build it with httpclient.Config.NewClient for auth and TLS.
+	Client *http.Client
+	Retry  Retry
+}
+
+// Write encodes families as a snappy-compressed WriteRequest and posts it. Samples without
+// their own timestamp are stamped with at.
+func (rw *RemoteWrite) Write(ctx context.Context, families []collector.MetricFamily, at time.Time) error {
+	body := snappy.Encode(nil, EncodeWriteRequest(families, rw.ExternalLabels, at))
+	header := http.Header{
+		"Content-Encoding":                  {"snappy"},
+		"Content-Type":                      {"application/x-protobuf"},
+		"X-Prometheus-Remote-Write-Version": {"0.1.0"},
+	}
+	if err := send(ctx, rw.Client, rw.Retry, http.MethodPost, rw.URL, body, header); err != nil {
+		return fmt.Errorf("remote_write: %w", err)
+	}
+	return nil
+}
+
+// Field numbers of prometheus/prompb/remote.proto and types.proto.
+const (
+	writeRequestTimeseries = 1
+	writeRequestMetadata   = 3
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+
+	metadataType       = 1
+	metadataFamilyName = 2
+	metadataHelp       = 4
+)
+
+// Values of prompb.MetricMetadata_MetricType.
+var metadataTypes = map[collector.MetricType]uint64{
+	collector.MetricTypeCounter:   1,
+	collector.MetricTypeGauge:     2,
+	collector.MetricTypeHistogram: 3,
+	collector.MetricTypeSummary:   5,
+}
+
+// EncodeWriteRequest serializes families as an uncompressed prompb.WriteRequest with one series
+// per sample (histograms and summaries become their classic _bucket/_sum/_count and quantile
+// series) and the family type and help as metadata.
+func EncodeWriteRequest(families []collector.MetricFamily, external []collector.Label, at time.Time) []byte {
+	var buf []byte
+	series := func(name string, labels []collector.Label, v float64, ts *time.Time) {
+		ms := at.UnixMilli()
+		if ts != nil {
+			ms = ts.UnixMilli()
+		}
+		var msg []byte
+		for _, l := range seriesLabels(name, labels, external) {
+			var lb []byte
+			lb = protowire.AppendTag(lb, labelName, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Name)
+			lb = protowire.AppendTag(lb, labelValue, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Value)
+			msg = protowire.AppendTag(msg, timeSeriesLabels, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, lb)
+		}
+		var sb []byte
+		sb = protowire.AppendTag(sb, sampleValue, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(v))
+		sb = protowire.AppendTag(sb, sampleTimestamp, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(ms))
+		msg = protowire.AppendTag(msg, timeSeriesSamples, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, sb)
+
+		buf = protowire.AppendTag(buf, writeRequestTimeseries, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, msg)
+	}
+
+	for _, f := range families {
+		for _, s := range f.Samples {
+			series(f.Name, s.Labels, s.Value, s.Timestamp)
+		}
+		for _, h := range f.Histograms {
+			for _, b := range h.Buckets {
+				series(f.Name+"_bucket", render.WithLabel(h.Labels, "le", render.FormatFloat(b.UpperBound)), float64(b.Count), h.Timestamp)
+			}
+			series(f.Name+"_sum", h.Labels, h.Sum, h.Timestamp)
+			series(f.Name+"_count", h.Labels, float64(h.Count), h.Timestamp)
+		}
+		for _, s := range f.Summaries {
+			for _, q := range s.Quantiles {
+				series(f.Name, render.WithLabel(s.Labels, "quantile", render.FormatFloat(q.Quantile)), q.Value, s.Timestamp)
+			}
+			series(f.Name+"_sum", s.Labels, s.Sum, s.Timestamp)
+			series(f.Name+"_count", s.Labels, float64(s.Count), s.Timestamp)
+		}
+	}
+
+	for _, f := range families {
+		var msg []byte
+		if t, ok := metadataTypes[f.Type]; ok {
+			msg = protowire.AppendTag(msg, metadataType, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, t)
+		}
+		msg = protowire.AppendTag(msg, metadataFamilyName, protowire.BytesType)
+		msg = protowire.AppendString(msg, f.Name)
+		if f.Help != "" {
+			msg = protowire.AppendTag(msg, metadataHelp, protowire.BytesType)
+			msg = protowire.AppendString(msg, f.Help)
+		}
+		buf = protowire.AppendTag(buf, writeRequestMetadata, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, msg)
+	}
+	return buf
+}
+
+// seriesLabels returns __name__, the series labels and the external labels the series does not
+// override, sorted by name as remote_write requires.
+func seriesLabels(name string, labels, external []collector.Label) []collector.Label {
+	out := make([]collector.Label, 0, len(labels)+len(external)+1)
+	out = append(out, collector.Label{Name: "__name__", Value: name})
+	out = append(out, labels...)
+	for _, e := range external {
+		found := false
+		for _, l := range labels {
+			if l.Name == e.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,39 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nexa/pkg/node/check"
+	"github.com/olekukonko/tablewriter"
+)
+
+// PrintCheck renders a check report: the Nagios-style headline, then the findings and any rule or
+// collector errors.
+func PrintCheck(w io.Writer, rep *check.Report) error {
+	fmt.Fprintln(w, rep.Headline())
+	if len(rep.Findings) > 0 {
+		fmt.Fprintln(w)
+		t := tablewriter.NewWriter(w)
+		t.Header([]string{"Severity", "Rule", "Series", "Value", "Summary"})
+		for _, f := range rep.Findings {
+			_ = t.Append([]string{strings.ToUpper(string(f.Severity)), f.Rule, f.Series, strconv.FormatFloat(f.Value, 'g', 6, 64), f.Summary})
+		}
+		if err := t.Render(); err != nil {
+			return err
+		}
+	}
+	if len(rep.Errors) > 0 || len(rep.CollectErrors) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Errors:")
+		for _, e := range rep.Errors {
+			fmt.Fprintf(w, "- rule %s: %s\n", e.Rule, e.Error)
+		}
+		for _, e := range rep.CollectErrors {
+			fmt.Fprintf(w, "- %s\n", e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+	"github.com/olekukonko/tablewriter"
+)
+
+// PrintDiff renders a snapshot diff as one table per section (added, removed, changed gauges,
+// counter rates). opt.Limit caps the rows of each section.
+func PrintDiff(w io.Writer, d *collector.SnapshotDiff, opt Options) error {
+	if opt.Limit <= 0 {
+		opt.Limit = 2000
+	}
+	value := func(metric string, v float64) string {
+		if opt.Humanize {
+			return formatValueHuman(metric, v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	fmt.Fprintf(w, "Before: %s\n", snapshotTitle(d.Before))
+	fmt.Fprintf(w, "After:  %s\n", snapshotTitle(d.After))
+	fmt.Fprintf(w, "Elapsed: %s\n", d.Elapsed.Round(time.Millisecond))
+	if d.Before.Host != d.After.Host {
+		fmt.Fprintln(w, "(snapshots come from different hosts; counter rates compare unrelated counters)")
+	}
+
+	section := func(title string, n int, header []string, row func(i int) []string) error {
+		fmt.Fprintf(w, "\n%s (%d)\n", title, n)
+		if n == 0 {
+			return nil
+		}
+		t := tablewriter.NewWriter(w)
+		t.Header(header)
+		for i := 0; i < n && i < opt.Limit; i++ {
+			_ = t.Append(row(i))
+		}
+		if err := t.Render(); err != nil {
+			return err
+		}
+		if n > opt.Limit {
+			fmt.Fprintf(w, "(truncated to %d rows; use --limit or filters)\n", opt.Limit)
+		}
+		return nil
+	}
+
+	if err := section("Added series", len(d.Added), []string{"Metric", "Labels", "Value"}, func(i int) []string {
+		s := d.Added[i]
+		return []string{s.Metric, collector.FormatLabels(s.Labels), value(s.Metric, s.Value)}
+	}); err != nil {
+		return err
+	}
+	if err := section("Removed series", len(d.Removed), []string{"Metric", "Labels", "Value"}, func(i int) []string {
+		s := d.Removed[i]
+		return []string{s.Metric, collector.FormatLabels(s.Labels), value(s.Metric, s.Value)}
+	}); err != nil {
+		return err
+	}
+	if err := section("Changed gauges", len(d.Changed), []string{"Metric", "Labels", "Before", "After", "Delta"}, func(i int) []string {
+		c := d.Changed[i]
+		return []string{c.Metric, collector.FormatLabels(c.Labels), value(c.Metric, c.Old), value(c.Metric, c.New), formatDelta(c, value)}
+	}); err != nil {
+		return err
+	}
+	return section("Counters", len(d.Counters), []string{"Metric", "Labels", "Before", "After", "Rate/s"}, func(i int) []string {
+		c := d.Counters[i]
+		rate := "-"
+		if !math.IsNaN(c.Rate) {
+			rate = formatRate(c.Metric, c.Rate, opt.Humanize)
+		}
+		return []string{c.Metric, collector.FormatLabels(c.Labels), value(c.Metric, c.Old), value(c.Metric, c.New), rate}
+	})
+}
+
+func snapshotTitle(s *collector.Snapshot) string {
+	host := s.Host
+	if host == "" {
+		host = "(unknown host)"
+	}
+	return fmt.Sprintf("%s at %s", host, s.Time.Format(time.RFC3339))
+}
+
+func formatDelta(c collector.SeriesChange, value func(metric string, v float64) string) string {
+	delta := c.Delta()
+	s := value(c.Metric, delta)
+	if delta > 0 {
+		s = "+" + s
+	}
+	if c.Old != 0 && !math.IsNaN(delta) && !math.IsInf(delta, 0) {
+		s += fmt.Sprintf(" (%+.1f%%)", delta/math.Abs(c.Old)*100)
+	}
+	return s
+}
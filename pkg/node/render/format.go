@@ -0,0 +1,187 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+	"sigs.k8s.io/yaml"
+)
+
+type Format string
+
+const (
+	FormatTable       Format = "table"
+	FormatJSON        Format = "json"
+	FormatYAML        Format = "yaml"
+	FormatProm        Format = "prom"
+	FormatOpenMetrics Format = "openmetrics"
+	FormatCSV         Format = "csv"
+)
+
+var formats = []Format{FormatTable, FormatJSON, FormatYAML, FormatProm, FormatOpenMetrics, FormatCSV}
+
+// FormatNames lists the supported -o values, e.g. for flag help.
+func FormatNames() []string {
+	out := make([]string, 0, len(formats))
+	for _, f := range formats {
+		out = append(out, string(f))
+	}
+	return out
+}
+
+func ParseFormat(s string) (Format, error) {
+	for _, f := range formats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q (want one of %s)", s, strings.Join(FormatNames(), "|"))
+}
+
+// Write renders families in the given format after applying the MetricRegex/LabelEquals filters.
+// Machine-readable formats ignore ShowSamples, Limit and Humanize: they always emit every series verbatim.
+func Write(w io.Writer, families []collector.MetricFamily, format Format, opt Options) error {
+	if format == "" || format == FormatTable {
+		return PrintMetricFamilies(w, families, opt)
+	}
+	filtered, err := FilterFamilies(families, opt)
+	if err != nil {
+		return err
+	}
+	filtered = sortedFamilies(filtered)
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, filtered)
+	case FormatYAML:
+		return WriteYAML(w, filtered)
+	case FormatProm:
+		return WritePrometheusText(w, filtered)
+	case FormatOpenMetrics:
+		return WriteOpenMetrics(w, filtered)
+	case FormatCSV:
+		return WriteCSV(w, filtered)
+	}
+	return fmt.Errorf("unsupported output format %q", format)
+}
+
+func WriteJSON(w io.Writer, families []collector.MetricFamily) error {
+	if families == nil {
+		families = []collector.MetricFamily{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(families)
+}
+
+func WriteYAML(w io.Writer, families []collector.MetricFamily) error {
+	if families == nil {
+		families = []collector.MetricFamily{}
+	}
+	b, err := yaml.Marshal(families)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// WriteCSV writes one row per exposed series (histograms and summaries are expanded into their
+// _bucket/_sum/_count and quantile series, like the text format).
+func WriteCSV(w io.Writer, families []collector.MetricFamily) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"metric", "type", "labels", "value", "timestamp"}); err != nil {
+		return err
+	}
+	row := func(metric string, t collector.MetricType, labels []collector.Label, v float64, ts *time.Time) error {
+		tsStr := ""
+		if ts != nil {
+			tsStr = ts.UTC().Format(time.RFC3339Nano)
+		}
+		return cw.Write([]string{metric, string(t), formatLabelsEscaped(labels), FormatFloat(v), tsStr})
+	}
+
+	for _, f := range families {
+		switch f.Type {
+		case collector.MetricTypeHistogram:
+			for _, h := range f.Histograms {
+				for _, b := range h.Buckets {
+					if err := row(f.Name+"_bucket", f.Type, WithLabel(h.Labels, "le", FormatFloat(b.UpperBound)), float64(b.Count), h.Timestamp); err != nil {
+						return err
+					}
+				}
+				if err := row(f.Name+"_sum", f.Type, h.Labels, h.Sum, h.Timestamp); err != nil {
+					return err
+				}
+				if err := row(f.Name+"_count", f.Type, h.Labels, float64(h.Count), h.Timestamp); err != nil {
+					return err
+				}
+			}
+		case collector.MetricTypeSummary:
+			for _, s := range f.Summaries {
+				for _, q := range s.Quantiles {
+					if err := row(f.Name, f.Type, WithLabel(s.Labels, "quantile", FormatFloat(q.Quantile)), q.Value, s.Timestamp); err != nil {
+						return err
+					}
+				}
+				if err := row(f.Name+"_sum", f.Type, s.Labels, s.Sum, s.Timestamp); err != nil {
+					return err
+				}
+				if err := row(f.Name+"_count", f.Type, s.Labels, float64(s.Count), s.Timestamp); err != nil {
+					return err
+				}
+			}
+		default:
+			for _, s := range f.Samples {
+				if err := row(f.Name, f.Type, s.Labels, s.Value, s.Timestamp); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// FormatFloat formats a sample value the way the Prometheus text format does.
+func FormatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatLabelsEscaped formats labels as in the text exposition format, escaping \, " and newlines.
+func formatLabelsEscaped(labels []collector.Label) string {
+	var b strings.Builder
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(l.Value))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WithLabel returns a copy of labels with name=value appended.
+func WithLabel(labels []collector.Label, name, value string) []collector.Label {
+	out := make([]collector.Label, 0, len(labels)+1)
+	out = append(out, labels...)
+	return append(out, collector.Label{Name: name, Value: value})
+}
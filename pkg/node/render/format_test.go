@@ -0,0 +1,128 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func formatFixture() []collector.MetricFamily {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []collector.MetricFamily{
+		{
+			Name: "node_disk_read_bytes_total",
+			Help: "The total number of bytes read successfully.",
+			Type: collector.MetricTypeCounter,
+			Samples: []collector.Sample{
+				{Labels: []collector.Label{{Name: "device", Value: "sda"}}, Value: 1024},
+				{Labels: []collector.Label{{Name: "device", Value: "nvme0n1"}}, Value: 2.5e9, Timestamp: &ts},
+			},
+		},
+		{
+			Name: "node_hwmon_temp_celsius",
+			Help: "Hardware monitor for temperature (input).",
+			Type: collector.MetricTypeGauge,
+			Samples: []collector.Sample{
+				{Labels: []collector.Label{{Name: "chip", Value: `pci "0"`}}, Value: math.NaN()},
+			},
+		},
+		{
+			Name: "http_request_duration_seconds",
+			Help: "Request latency.",
+			Type: collector.MetricTypeHistogram,
+			Histograms: []collector.Histogram{{
+				Labels: []collector.Label{{Name: "code", Value: "200"}},
+				Buckets: []collector.Bucket{
+					{UpperBound: 0.1, Count: 3},
+					{UpperBound: 1, Count: 5},
+					{UpperBound: math.Inf(1), Count: 6},
+				},
+				Count: 6,
+				Sum:   2.75,
+			}},
+		},
+		{
+			Name: "rpc_duration_seconds",
+			Help: "RPC latency.",
+			Type: collector.MetricTypeSummary,
+			Summaries: []collector.Summary{{
+				Quantiles: []collector.Quantile{{Quantile: 0.5, Value: 0.01}, {Quantile: 0.99, Value: 0.2}},
+				Count:     10,
+				Sum:       0.5,
+			}},
+		},
+	}
+}
+
+func TestWrite_Golden(t *testing.T) {
+	for _, f := range []Format{FormatJSON, FormatYAML, FormatProm, FormatOpenMetrics, FormatCSV} {
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, formatFixture(), f, Options{}); err != nil {
+				t.Fatalf("Write(%s): %v", f, err)
+			}
+			golden := filepath.Join("testdata", "format."+string(f)+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create): %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Fatalf("output mismatch for %s\n--- got ---\n%s\n--- want ---\n%s", f, buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestWrite_AppliesFilters(t *testing.T) {
+	var buf bytes.Buffer
+	opt := Options{MetricRegex: "node_disk_.*", LabelEquals: []string{`device="sda"`}}
+	if err := Write(&buf, formatFixture(), FormatProm, opt); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := "# HELP node_disk_read_bytes_total The total number of bytes read successfully.\n" +
+		"# TYPE node_disk_read_bytes_total counter\n" +
+		"node_disk_read_bytes_total{device=\"sda\"} 1024\n"
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("JSON"); err != nil || f != FormatJSON {
+		t.Fatalf("ParseFormat(JSON) = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestWriteJSON_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, formatFixture()); err != nil {
+		t.Fatal(err)
+	}
+	var back []collector.MetricFamily
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(back) != 4 || !math.IsNaN(back[1].Samples[0].Value) || !math.IsInf(back[2].Histograms[0].Buckets[2].UpperBound, 1) {
+		t.Fatalf("unexpected round trip: %+v", back)
+	}
+	if ts := back[0].Samples[1].Timestamp; ts == nil || ts.Year() != 2024 {
+		t.Fatalf("timestamp lost: %+v", back[0].Samples[1])
+	}
+}
@@ -2,6 +2,8 @@ package render
 
 import (
 	"bytes"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/nexa/pkg/node/collector"
@@ -32,8 +34,35 @@ func TestWritePrometheusText_SimpleGauge(t *testing.T) {
 	if !bytes.Contains(buf.Bytes(), []byte("# TYPE node_test_metric gauge")) {
 		t.Fatalf("missing TYPE line: %q", out)
 	}
-	if !bytes.Contains(buf.Bytes(), []byte(`node_test_metric{a="b"} 1.000000`)) {
+	if !bytes.Contains(buf.Bytes(), []byte(`node_test_metric{a="b"} 1
+`)) {
 		t.Fatalf("missing sample line: %q", out)
 	}
 }
 
+func TestWritePrometheusText_EscapingAndSpecialValues(t *testing.T) {
+	f := collector.MetricFamily{
+		Name: "node_test_metric",
+		Help: "Back\\slash and\nnewline.",
+		Type: collector.MetricTypeGauge,
+		Samples: []collector.Sample{
+			{Labels: []collector.Label{{Name: "path", Value: `C:\dir "x"` + "\n"}}, Value: math.NaN()},
+			{Labels: []collector.Label{{Name: "path", Value: "inf"}}, Value: math.Inf(1)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePrometheusText(&buf, []collector.MetricFamily{f}); err != nil {
+		t.Fatalf("WritePrometheusText error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`# HELP node_test_metric Back\\slash and\nnewline.`,
+		`node_test_metric{path="C:\\dir \"x\"\n"} NaN`,
+		`node_test_metric{path="inf"} +Inf`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
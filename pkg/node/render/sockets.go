@@ -0,0 +1,64 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/nexa/pkg/node/sockets"
+	"github.com/olekukonko/tablewriter"
+)
+
+// PrintSocketStats renders `nexa node sockets`: one row per port (and remote address with
+// byRemote). Accept Q shows the queued connections of the listeners over their backlog.
+func PrintSocketStats(w io.Writer, stats []sockets.Stats, byRemote, human bool) error {
+	header := []string{"Port", "Dir"}
+	if byRemote {
+		header = append(header, "Remote")
+	}
+	header = append(header, "Total", "Estab", "Time Wait", "Close Wait", "Syn Recv", "Other",
+		"Recv Q", "Send Q", "Accept Q", "Retrans", "Low Cwnd", "Avg RTT", "Max RTT")
+	t := tablewriter.NewWriter(w)
+	t.Header(header)
+
+	bytes := func(v uint64) string {
+		if human {
+			return humanizeBytesIEC(float64(v))
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	rtt := func(d time.Duration) string {
+		if d == 0 {
+			return "-"
+		}
+		return d.Round(10 * time.Microsecond).String()
+	}
+	for i := range stats {
+		st := &stats[i]
+		row := []string{strconv.Itoa(int(st.Port)), string(st.Direction)}
+		if byRemote {
+			remote := "*"
+			if st.Remote.IsValid() {
+				remote = st.Remote.String()
+			}
+			row = append(row, remote)
+		}
+		estab := st.Count(sockets.StateEstablished)
+		tw := st.Count(sockets.StateTimeWait)
+		cw := st.Count(sockets.StateCloseWait)
+		sr := st.Count(sockets.StateSynRecv) + st.Count(sockets.StateNewSynRecv)
+		acceptQ := "-"
+		if st.Count(sockets.StateListen) > 0 {
+			acceptQ = fmt.Sprintf("%d/%d", st.AcceptQ, st.AcceptQMax)
+		}
+		row = append(row,
+			strconv.Itoa(st.Total), strconv.Itoa(estab), strconv.Itoa(tw), strconv.Itoa(cw), strconv.Itoa(sr),
+			strconv.Itoa(st.Total-estab-tw-cw-sr-st.Count(sockets.StateListen)),
+			bytes(st.RecvQ), bytes(st.SendQ), acceptQ,
+			strconv.FormatUint(st.Retrans, 10), strconv.Itoa(st.LowCwnd), rtt(st.AvgRTT()), rtt(st.MaxRTT),
+		)
+		_ = t.Append(row)
+	}
+	return t.Render()
+}
@@ -3,7 +3,11 @@ package render
 import (
 	"fmt"
 	"io"
+	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nexa/pkg/node/collector"
 	"github.com/olekukonko/tablewriter"
@@ -13,18 +17,26 @@ type Options struct {
 	ShowSamples bool
 	Limit       int
 	Humanize    bool
-	MetricRegex string
-	LabelEquals []string // key=value, repeatable
+	MetricRegex string   // anchored regexp on the metric family name
+	LabelEquals []string // PromQL-style selectors: k=v, k!=v, k=~re, k!~re (repeatable, ANDed)
+}
+
+// FilterFamilies applies MetricRegex and LabelEquals to families.
+func FilterFamilies(families []collector.MetricFamily, opt Options) ([]collector.MetricFamily, error) {
+	f, err := collector.ParseFilter(opt.MetricRegex, opt.LabelEquals)
+	if err != nil {
+		return nil, err
+	}
+	return f.Apply(families), nil
 }
 
 func PrintMetricFamilies(w io.Writer, families []collector.MetricFamily, opt Options) error {
 	if opt.Limit <= 0 {
 		opt.Limit = 2000
 	}
-	filtered := make([]collector.MetricFamily, 0, len(families))
-	for _, f := range families {
-		// TODO: apply regex and label filters (in next todo).
-		filtered = append(filtered, f)
+	filtered, err := FilterFamilies(families, opt)
+	if err != nil {
+		return err
 	}
 	if opt.ShowSamples {
 		return printSamples(w, filtered, opt.Limit, opt.Humanize)
@@ -40,7 +52,12 @@ func printFamiliesSummary(w io.Writer, families []collector.MetricFamily, humani
 		lbl, val := f.Example()
 
 		// Override numeric examples with human-readable formatting where possible.
-		if humanize && (f.Type == collector.MetricTypeGauge || f.Type == collector.MetricTypeCounter || f.Type == collector.MetricTypeUntyped) && len(f.Samples) > 0 {
+		switch {
+		case f.Type == collector.MetricTypeHistogram && len(f.Histograms) > 0:
+			val = histogramStats(f.Name, f.Histograms[0], humanize)
+		case f.Type == collector.MetricTypeSummary && len(f.Summaries) > 0:
+			val = summaryStats(f.Name, f.Summaries[0], humanize)
+		case humanize && len(f.Samples) > 0:
 			val = formatValueHuman(f.Name, f.Samples[0].Value)
 		}
 
@@ -89,6 +106,10 @@ func printSamples(w io.Writer, families []collector.MetricFamily, limit int, hum
 					emit(sumMetric, lbl, fmt.Sprintf("%f", h.Sum))
 					emit(countMetric, lbl, fmt.Sprintf("%d", h.Count))
 				}
+				for _, q := range sampleQuantiles {
+					emit(f.Name+" ("+quantileName(q)+")", lbl, formatStat(f.Name, h.Quantile(q), humanize))
+				}
+				emit(f.Name+" (avg)", lbl, formatStat(f.Name, h.Mean(), humanize))
 				for _, b := range h.Buckets {
 					l2 := append([]collector.Label(nil), h.Labels...)
 					l2 = append(l2, collector.Label{Name: "le", Value: fmt.Sprintf("%g", b.UpperBound)})
@@ -112,6 +133,7 @@ func printSamples(w io.Writer, families []collector.MetricFamily, limit int, hum
 					emit(sumMetric, lbl, fmt.Sprintf("%f", s.Sum))
 					emit(countMetric, lbl, fmt.Sprintf("%d", s.Count))
 				}
+				emit(f.Name+" (avg)", lbl, formatStat(f.Name, s.Mean(), humanize))
 				for _, q := range s.Quantiles {
 					l2 := append([]collector.Label(nil), s.Labels...)
 					l2 = append(l2, collector.Label{Name: "quantile", Value: fmt.Sprintf("%g", q.Quantile)})
@@ -146,19 +168,89 @@ func printSamples(w io.Writer, families []collector.MetricFamily, limit int, hum
 	return nil
 }
 
+// sampleQuantiles are the quantiles interpolated from histogram buckets for display.
+var sampleQuantiles = []float64{0.5, 0.9, 0.99}
+
+// quantileName turns 0.99 into "p99".
+func quantileName(q float64) string {
+	return "p" + strconv.FormatFloat(q*100, 'g', -1, 64)
+}
+
+func formatStat(metric string, v float64, humanize bool) string {
+	switch {
+	case math.IsNaN(v):
+		return "-"
+	case humanize:
+		return formatValueHuman(metric, v)
+	default:
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	}
+}
+
+// histogramStats is the one-line description of a histogram in the summary table, e.g.
+// "p50=1.2ms p90=4ms p99=9.8ms avg=1.9ms".
+func histogramStats(metric string, h collector.Histogram, humanize bool) string {
+	parts := make([]string, 0, len(sampleQuantiles)+1)
+	for _, q := range sampleQuantiles {
+		parts = append(parts, quantileName(q)+"="+formatStat(metric, h.Quantile(q), humanize))
+	}
+	parts = append(parts, "avg="+formatStat(metric, h.Mean(), humanize))
+	return strings.Join(parts, " ")
+}
+
+// summaryStats is histogramStats for a summary, using its precomputed quantiles.
+func summaryStats(metric string, s collector.Summary, humanize bool) string {
+	parts := make([]string, 0, len(s.Quantiles)+1)
+	for _, q := range s.Quantiles {
+		parts = append(parts, quantileName(q.Quantile)+"="+formatStat(metric, q.Value, humanize))
+	}
+	parts = append(parts, "avg="+formatStat(metric, s.Mean(), humanize))
+	return strings.Join(parts, " ")
+}
+
 func PrintCollectorList(w io.Writer, rows []collector.CollectorStatus) error {
 	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
 
 	t := tablewriter.NewWriter(w)
-	t.Header([]string{"Collector", "Implemented", "Description"})
+	t.Header([]string{"Collector", "Implemented", "Backend", "Available", "Description"})
 
 	for _, r := range rows {
 		impl := "no"
 		if r.Implemented {
 			impl = "yes"
 		}
-		_ = t.Append([]string{r.Name, impl, r.Description})
+		backend := string(r.Backend)
+		switch {
+		case r.Plugin != "":
+			backend = "plugin"
+		case backend == "":
+			backend = "-"
+		}
+		avail := make([]string, 0, len(r.Backends))
+		for _, b := range r.Backends {
+			avail = append(avail, string(b))
+		}
+		_ = t.Append([]string{r.Name, impl, backend, strings.Join(avail, ","), r.Description})
 	}
 	return t.Render()
 }
 
+// PrintCollectTimings prints how long each collector took, slowest first.
+func PrintCollectTimings(w io.Writer, results []collector.CollectResult) error {
+	rows := append([]collector.CollectResult(nil), results...)
+	collector.SortResultsByDuration(rows)
+
+	t := tablewriter.NewWriter(w)
+	t.Header([]string{"Collector", "Duration", "Families", "Status"})
+	for _, r := range rows {
+		status := "ok"
+		switch {
+		case r.TimedOut():
+			status = "timeout"
+		case r.Err != nil:
+			status = "error"
+		}
+		_ = t.Append([]string{r.Name, r.Duration.Round(time.Microsecond).String(), fmt.Sprintf("%d", len(r.Families)), status})
+	}
+	return t.Render()
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+func TestPrintMetricFamilies_Distributions(t *testing.T) {
+	families := []collector.MetricFamily{
+		{
+			Name: "rpc_duration_seconds",
+			Type: collector.MetricTypeHistogram,
+			Histograms: []collector.Histogram{{
+				Labels:  []collector.Label{{Name: "op", Value: "read"}},
+				Buckets: []collector.Bucket{{UpperBound: 0.1, Count: 50}, {UpperBound: 1, Count: 100}, {UpperBound: math.Inf(1), Count: 100}},
+				Count:   100,
+				Sum:     20,
+			}},
+		},
+		{
+			Name: "gc_duration_seconds",
+			Type: collector.MetricTypeSummary,
+			Summaries: []collector.Summary{{
+				Quantiles: []collector.Quantile{{Quantile: 0.5, Value: 0.001}, {Quantile: 0.99, Value: 0.004}},
+				Count:     4,
+				Sum:       0.008,
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintMetricFamilies(&buf, families, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"p50=0.1 p90=0.82 p99=0.982 avg=0.2", "p50=0.001 p99=0.004 avg=0.002"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("summary table lacks %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := PrintMetricFamilies(&buf, families, Options{ShowSamples: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"rpc_duration_seconds (p90)", "0.82", "rpc_duration_seconds (avg)", "gc_duration_seconds (avg)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("samples lack %q:\n%s", want, buf.String())
+		}
+	}
+}
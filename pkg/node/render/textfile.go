@@ -0,0 +1,39 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nexa/pkg/node/textfile"
+	"github.com/olekukonko/tablewriter"
+)
+
+// PrintTextfileLint renders the problems found by `nexa node textfile lint`, followed by a
+// one-line summary.
+func PrintTextfileLint(w io.Writer, files int, problems []textfile.Problem) error {
+	var errs, warns int
+	if len(problems) > 0 {
+		t := tablewriter.NewWriter(w)
+		t.Header([]string{"File", "Line", "Severity", "Problem"})
+		for _, p := range problems {
+			line := ""
+			if p.Line > 0 {
+				line = strconv.Itoa(p.Line)
+			}
+			_ = t.Append([]string{p.File, line, strings.ToUpper(string(p.Severity)), p.Message})
+			if p.Severity == textfile.SeverityError {
+				errs++
+			} else {
+				warns++
+			}
+		}
+		if err := t.Render(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d files checked: %d errors, %d warnings\n", files, errs, warns)
+	return nil
+}
@@ -0,0 +1,283 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nexa/pkg/node/top"
+	"github.com/olekukonko/tablewriter"
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as one block character each, scaled from 0 to scale (or to the largest
+// value when scale <= 0). Unknown (NaN) values are drawn as spaces.
+func Sparkline(values []float64, scale float64) string {
+	if scale <= 0 {
+		for _, v := range values {
+			if !math.IsNaN(v) && v > scale {
+				scale = v
+			}
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if math.IsNaN(v) {
+			b.WriteByte(' ')
+			continue
+		}
+		i := 0
+		if scale > 0 {
+			i = int(math.Round(v / scale * float64(len(sparkBlocks)-1)))
+		}
+		i = min(max(i, 0), len(sparkBlocks)-1)
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// formatTopValue formats v compactly for a dashboard cell.
+func formatTopValue(u top.Unit, v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "-"
+	}
+	switch u {
+	case top.UnitPercent:
+		return strconv.FormatFloat(v, 'f', 1, 64) + "%"
+	case top.UnitBytes:
+		return humanizeBytesIEC(v)
+	case top.UnitBytesPerSecond:
+		return humanizeBytesIEC(v) + "/s"
+	case top.UnitPerSecond:
+		if v >= 100 {
+			return humanizeNumber(math.Round(v))
+		}
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	case top.UnitSeconds:
+		if v < 1 {
+			return strconv.FormatFloat(v*1000, 'f', 2, 64) + "ms"
+		}
+		return strconv.FormatFloat(v, 'f', 2, 64) + "s"
+	default:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+}
+
+// PrintTopPlain renders every pane as a table, for `nexa node top` when stdout is not a terminal.
+func PrintTopPlain(w io.Writer, panes []top.Pane) error {
+	for i, p := range panes {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n", p.Title)
+		if len(p.Rows) == 0 {
+			fmt.Fprintln(w, "(no data)")
+			continue
+		}
+		header := []string{p.KeyColumn}
+		for _, c := range p.Columns {
+			header = append(header, c.Name)
+		}
+		header = append(header, p.Columns[p.Spark].Name+" history")
+		t := tablewriter.NewWriter(w)
+		t.Header(header)
+		for _, r := range p.Rows {
+			row := []string{r.Key}
+			for ci, c := range p.Columns {
+				row = append(row, formatTopValue(c.Unit, r.Values[ci]))
+			}
+			row = append(row, Sparkline(r.History, p.SparkMax))
+			_ = t.Append(row)
+		}
+		if err := t.Render(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TopFrame describes the terminal a full-screen frame is drawn for.
+type TopFrame struct {
+	Title  string // first line, e.g. host name and time
+	Status string // last line; replaces the key hint when set (e.g. a collection error)
+	Width  int
+	Height int
+}
+
+// topChrome is the number of lines DrawTop uses besides the rows: title, tabs, pane title,
+// column header and the hint line.
+const topChrome = 5
+
+// TopPageSize is the number of rows DrawTop shows on a terminal of the given height.
+func TopPageSize(height int) int { return max(1, height-topChrome) }
+
+var topHelp = []string{
+	"tab, l, →       next pane",
+	"shift-tab, h, ← previous pane",
+	"1-9             jump to pane",
+	"j/k, ↓/↑        scroll",
+	"space, PgDn/PgUp page",
+	"s / S           sort by next / previous column",
+	"r               reverse sort order",
+	"R, ctrl-l       refresh now",
+	"?               toggle this help",
+	"q, ctrl-c       quit",
+}
+
+// DrawTop renders one full-screen frame of the focused pane. Lines are cut to the frame width
+// and end with \r\n, as the terminal is in raw mode.
+func DrawTop(w io.Writer, panes []top.Pane, v *top.View, f TopFrame) error {
+	var lines []string
+	lines = append(lines, "\033[1m"+f.Title+"\033[0m")
+
+	var tabs strings.Builder
+	for i, p := range panes {
+		label := fmt.Sprintf(" %d %s ", i+1, p.Name)
+		if i == v.Pane {
+			label = "\033[7m" + label + "\033[0m"
+		}
+		tabs.WriteString(label)
+	}
+	lines = append(lines, tabs.String())
+
+	page := TopPageSize(f.Height)
+	if v.ShowHelp || len(panes) == 0 {
+		lines = append(lines, "Keys", "")
+		lines = append(lines, topHelp...)
+	} else {
+		p := panes[v.Pane]
+		p.Rows = append([]top.Row(nil), p.Rows...)
+		col, desc := v.Sort(p.Name)
+		p.Sort(col, desc)
+		order := p.KeyColumn
+		if col >= 0 {
+			order = p.Columns[col].Name
+		}
+		dir := "↑"
+		if desc {
+			dir = "↓"
+		}
+		lines = append(lines, fmt.Sprintf("%s  (sorted by %s %s, %d rows)", p.Title, order, dir, len(p.Rows)))
+		lines = append(lines, drawPaneRows(p, v.Scroll, page, f.Width)...)
+	}
+	for len(lines) < f.Height-1 {
+		lines = append(lines, "")
+	}
+	hint := "tab/←→ pane  j/k scroll  s/S sort  r reverse  R refresh  ? help  q quit"
+	if f.Status != "" {
+		hint = f.Status
+	}
+	lines = append(lines[:min(len(lines), max(0, f.Height-1))], "\033[2m"+hint+"\033[0m")
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, l := range lines {
+		b.WriteString(truncateVisible(l, f.Width))
+		b.WriteString("\033[K")
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\033[J")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// drawPaneRows lays out the column header and the visible rows of p with a sparkline column
+// taking the remaining width.
+func drawPaneRows(p top.Pane, scroll, page, width int) []string {
+	keyW := utf8.RuneCountInString(p.KeyColumn)
+	for _, r := range p.Rows {
+		keyW = max(keyW, utf8.RuneCountInString(r.Key))
+	}
+	keyW = min(keyW, max(8, width/4))
+
+	cells := make([][]string, len(p.Rows))
+	colW := make([]int, len(p.Columns))
+	for ci, c := range p.Columns {
+		colW[ci] = len(c.Name)
+	}
+	for ri, r := range p.Rows {
+		cells[ri] = make([]string, len(p.Columns))
+		for ci, c := range p.Columns {
+			cells[ri][ci] = formatTopValue(c.Unit, r.Values[ci])
+			colW[ci] = max(colW[ci], len(cells[ri][ci]))
+		}
+	}
+	used := keyW
+	for _, cw := range colW {
+		used += 2 + cw
+	}
+	sparkW := width - used - 2
+
+	line := func(key string, vals []string, spark string) string {
+		var b strings.Builder
+		b.WriteString(padRight(truncateVisible(key, keyW), keyW))
+		for ci, s := range vals {
+			b.WriteString("  ")
+			b.WriteString(strings.Repeat(" ", colW[ci]-len(s)))
+			b.WriteString(s)
+		}
+		if sparkW > 0 {
+			b.WriteString("  ")
+			b.WriteString(spark)
+		}
+		return b.String()
+	}
+
+	header := make([]string, len(p.Columns))
+	for ci, c := range p.Columns {
+		header[ci] = c.Name
+	}
+	out := []string{"\033[1m" + line(p.KeyColumn, header, p.Columns[p.Spark].Name+" history") + "\033[0m"}
+	if len(p.Rows) == 0 {
+		return append(out, "(no data)")
+	}
+	end := min(len(p.Rows), scroll+page)
+	for ri := max(0, scroll); ri < end; ri++ {
+		h := p.Rows[ri].History
+		if sparkW > 0 && len(h) > sparkW {
+			h = h[len(h)-sparkW:]
+		}
+		out = append(out, line(p.Rows[ri].Key, cells[ri], Sparkline(h, p.SparkMax)))
+	}
+	return out
+}
+
+func padRight(s string, n int) string {
+	if c := utf8.RuneCountInString(s); c < n {
+		return s + strings.Repeat(" ", n-c)
+	}
+	return s
+}
+
+// truncateVisible cuts s to n visible runes, not counting ANSI escape sequences, and keeps the
+// sequences so styles are still reset.
+func truncateVisible(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	visible := 0
+	inEsc := false
+	for _, r := range s {
+		switch {
+		case inEsc:
+			b.WriteRune(r)
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				inEsc = false
+			}
+		case r == '\033':
+			inEsc = true
+			b.WriteRune(r)
+		case visible < n:
+			b.WriteRune(r)
+			visible++
+		}
+	}
+	return b.String()
+}
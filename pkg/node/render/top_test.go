@@ -0,0 +1,53 @@
+package render
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nexa/pkg/node/top"
+)
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]float64{0, 50, 100, math.NaN(), 200}, 100); got != "▁▅█ █" {
+		t.Errorf("fixed scale = %q", got)
+	}
+	if got := Sparkline([]float64{1, 2, 4}, 0); got != "▃▅█" {
+		t.Errorf("auto scale = %q", got)
+	}
+}
+
+func TestDrawTop(t *testing.T) {
+	panes := []top.Pane{{
+		Name:      "disk",
+		Title:     "Disk I/O per device",
+		KeyColumn: "device",
+		Columns:   []top.Column{{Name: "util", Unit: top.UnitPercent}, {Name: "r_await", Unit: top.UnitSeconds}},
+		SparkMax:  100,
+		Rows: []top.Row{
+			{Key: "sda", Values: []float64{10, 0.0042}, History: []float64{0, 10}},
+			{Key: "nvme0n1", Values: []float64{90, math.NaN()}, History: []float64{90}},
+		},
+	}}
+	v := top.NewView()
+	v.SortCol["disk"], v.SortDesc["disk"] = 0, true
+
+	var buf bytes.Buffer
+	if err := DrawTop(&buf, panes, v, TopFrame{Title: "host", Width: 60, Height: 8}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "\r\n"); n != 7 {
+		t.Errorf("%d lines, want 8:\n%s", n+1, out)
+	}
+	nvme, sda := strings.Index(out, "nvme0n1"), strings.Index(out, "sda")
+	if nvme < 0 || sda < 0 || nvme > sda {
+		t.Errorf("rows not sorted by util descending:\n%s", out)
+	}
+	for _, want := range []string{"sorted by util ↓", "4.20ms", "util history", "▁▂"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("frame lacks %q:\n%s", want, out)
+		}
+	}
+}
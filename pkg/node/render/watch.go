@@ -0,0 +1,129 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ClearScreen moves the cursor home and clears the terminal, used to refresh watch frames in place.
+const ClearScreen = "\033[H\033[2J"
+
+// PrintRateFrame renders one watch frame: counters (and histogram/summary _count/_sum) are shown as
+// per-second rates since prev, everything else as the current value. Series without a previous
+// observation (first frame, or newly appeared) show "-" until the next sample.
+func PrintRateFrame(w io.Writer, prev, cur []collector.MetricFamily, elapsed time.Duration, opt Options) error {
+	if opt.Limit <= 0 {
+		opt.Limit = 2000
+	}
+	cur, err := FilterFamilies(cur, opt)
+	if err != nil {
+		return err
+	}
+
+	var prevIdx collector.SampleIndex
+	if prev != nil {
+		prevIdx = collector.NewSampleIndex(prev)
+	}
+
+	t := tablewriter.NewWriter(w)
+	t.Header([]string{"Metric", "Labels", "Value", "Rate/s"})
+
+	printed := 0
+	truncated := false
+	emit := func(metric string, labels []collector.Label, value string, rate string) {
+		if printed >= opt.Limit {
+			truncated = true
+			return
+		}
+		_ = t.Append([]string{metric, collector.FormatLabels(labels), value, rate})
+		printed++
+	}
+	rateOf := func(metric string, labels []collector.Label, v float64) string {
+		if prevIdx == nil {
+			return "-"
+		}
+		pv, ok := prevIdx.Lookup(metric, labels)
+		if !ok {
+			return "-"
+		}
+		return formatRate(metric, collector.CounterRate(pv, v, elapsed), opt.Humanize)
+	}
+	value := func(metric string, v float64) string {
+		if opt.Humanize {
+			return formatValueHuman(metric, v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	for _, f := range cur {
+		switch f.Type {
+		case collector.MetricTypeCounter:
+			for _, s := range f.Samples {
+				emit(f.Name, s.Labels, value(f.Name, s.Value), rateOf(f.Name, s.Labels, s.Value))
+			}
+		case collector.MetricTypeHistogram, collector.MetricTypeSummary:
+			type series struct {
+				labels []collector.Label
+				count  uint64
+				sum    float64
+			}
+			var all []series
+			for _, h := range f.Histograms {
+				all = append(all, series{h.Labels, h.Count, h.Sum})
+			}
+			for _, s := range f.Summaries {
+				all = append(all, series{s.Labels, s.Count, s.Sum})
+			}
+			for _, s := range all {
+				countMetric, sumMetric := f.Name+"_count", f.Name+"_sum"
+				emit(countMetric, s.labels, value(countMetric, float64(s.count)), rateOf(countMetric, s.labels, float64(s.count)))
+				emit(sumMetric, s.labels, value(sumMetric, s.sum), rateOf(sumMetric, s.labels, s.sum))
+			}
+		default:
+			for _, s := range f.Samples {
+				emit(f.Name, s.Labels, value(f.Name, s.Value), "")
+			}
+		}
+		if truncated {
+			break
+		}
+	}
+
+	if err := t.Render(); err != nil {
+		return err
+	}
+	if truncated {
+		fmt.Fprintf(w, "\n(truncated to %d rows; use --limit or filters)\n", opt.Limit)
+	}
+	return nil
+}
+
+// formatRate formats a per-second rate. Byte counters become B/s, second counters (e.g. CPU time)
+// become a utilisation percentage, everything else a plain number per second.
+func formatRate(metric string, v float64, humanize bool) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Sprintf("%f", v)
+	}
+	if !humanize {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+	base := strings.TrimSuffix(metric, "_total")
+	switch {
+	case strings.HasSuffix(base, "_bytes"):
+		return humanizeBytesIEC(v) + "/s"
+	case strings.HasSuffix(base, "_seconds"):
+		return fmt.Sprintf("%.1f%%", v*100)
+	default:
+		if v == math.Trunc(v) {
+			return humanizeNumber(v) + "/s"
+		}
+		return strconv.FormatFloat(v, 'f', 2, 64) + "/s"
+	}
+}
@@ -0,0 +1,49 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+func counterFamily(values map[string]float64) collector.MetricFamily {
+	f := collector.MetricFamily{Name: "node_disk_reads_completed_total", Help: "Reads.", Type: collector.MetricTypeCounter}
+	for dev, v := range values {
+		f.Samples = append(f.Samples, collector.Sample{Labels: []collector.Label{{Name: "device", Value: dev}}, Value: v})
+	}
+	return f
+}
+
+func TestPrintRateFrame(t *testing.T) {
+	prev := []collector.MetricFamily{counterFamily(map[string]float64{"sda": 100, "sdb": 500, "gone": 1})}
+	// sdb was reset, sdc is new and gone disappeared.
+	cur := []collector.MetricFamily{counterFamily(map[string]float64{"sda": 300, "sdb": 40, "sdc": 7})}
+
+	var buf bytes.Buffer
+	if err := PrintRateFrame(&buf, prev, cur, 2*time.Second, Options{Humanize: true}); err != nil {
+		t.Fatalf("PrintRateFrame error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"100/s", "20/s"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "gone") {
+		t.Fatalf("disappeared series rendered:\n%s", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, `"sdc"`) && !strings.Contains(line, " - ") {
+			t.Fatalf("new series should have no rate yet: %q", line)
+		}
+	}
+}
+
+func TestCounterRate_Reset(t *testing.T) {
+	if got := collector.CounterRate(10, 4, 2*time.Second); got != 2 {
+		t.Fatalf("CounterRate after reset = %v, want 2", got)
+	}
+}
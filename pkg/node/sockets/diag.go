@@ -0,0 +1,105 @@
+package sockets
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"time"
+
+	"github.com/mdlayher/netlink"
+)
+
+// sock_diag constants from linux/sock_diag.h and linux/inet_diag.h.
+const (
+	sockDiagByFamily = 20
+	inetDiagInfo     = 2 // INET_DIAG_INFO attribute: struct tcp_info
+	ipprotoTCP       = 6
+	afInet           = 2
+	afInet6          = 10
+
+	inetDiagReqLen = 56 // struct inet_diag_req_v2
+	inetDiagMsgLen = 72 // struct inet_diag_msg
+)
+
+// diagRequest is an inet_diag_req_v2 dumping every TCP socket of family with its tcp_info.
+func diagRequest(family uint8) []byte {
+	b := make([]byte, inetDiagReqLen)
+	b[0] = family
+	b[1] = ipprotoTCP
+	b[2] = 1 << (inetDiagInfo - 1)
+	binary.NativeEndian.PutUint32(b[4:], 0xffffffff) // all states
+	return b
+}
+
+var errShortMessage = errors.New("short inet_diag_msg")
+
+// parseDiagMsg decodes an inet_diag_msg and its attributes.
+func parseDiagMsg(b []byte) (Socket, error) {
+	if len(b) < inetDiagMsgLen {
+		return Socket{}, errShortMessage
+	}
+	family := b[0]
+	addr := func(raw []byte, port []byte) netip.AddrPort {
+		p := binary.BigEndian.Uint16(port)
+		if family == afInet {
+			return netip.AddrPortFrom(netip.AddrFrom4([4]byte(raw[:4])), p)
+		}
+		return netip.AddrPortFrom(netip.AddrFrom16([16]byte(raw[:16])), p)
+	}
+	s := Socket{
+		State:  State(b[1]),
+		Local:  addr(b[8:24], b[4:6]),
+		Remote: addr(b[24:40], b[6:8]),
+		RecvQ:  binary.NativeEndian.Uint32(b[56:]),
+		SendQ:  binary.NativeEndian.Uint32(b[60:]),
+		UID:    binary.NativeEndian.Uint32(b[64:]),
+		Inode:  binary.NativeEndian.Uint32(b[68:]),
+	}
+	if len(b) == inetDiagMsgLen {
+		return s, nil
+	}
+	ad, err := netlink.NewAttributeDecoder(b[inetDiagMsgLen:])
+	if err != nil {
+		return s, err
+	}
+	for ad.Next() {
+		if ad.Type() == inetDiagInfo {
+			s.Info = parseTCPInfo(ad.Bytes())
+		}
+	}
+	return s, ad.Err()
+}
+
+// parseTCPInfo decodes the fields of struct tcp_info that TCPInfo holds. Older kernels send a
+// shorter struct; missing fields stay zero.
+func parseTCPInfo(b []byte) *TCPInfo {
+	if len(b) < 8 {
+		return nil
+	}
+	u32 := func(off int) uint32 {
+		if off+4 > len(b) {
+			return 0
+		}
+		return binary.NativeEndian.Uint32(b[off:])
+	}
+	u64 := func(off int) uint64 {
+		if off+8 > len(b) {
+			return 0
+		}
+		return binary.NativeEndian.Uint64(b[off:])
+	}
+	us := func(off int) time.Duration { return time.Duration(u32(off)) * time.Microsecond }
+	return &TCPInfo{
+		Retransmits:  b[2],
+		RTO:          us(8),
+		SndMSS:       u32(16),
+		Unacked:      u32(24),
+		Lost:         u32(32),
+		RTT:          us(68),
+		RTTVar:       us(72),
+		SndCwnd:      u32(80),
+		TotalRetrans: u32(100),
+		BytesAcked:   u64(120),
+		BytesRecv:    u64(128),
+	}
+}
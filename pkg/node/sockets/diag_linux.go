@@ -0,0 +1,60 @@
+package sockets
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Dump returns the TCP sockets of the selected network namespace. Reading other namespaces
+// needs CAP_SYS_ADMIN; tcp_info of sockets owned by other users is returned to any user.
+func Dump(ctx context.Context, opt Options) ([]Socket, error) {
+	cfg := &netlink.Config{}
+	if opt.NetNS != "" {
+		ns, err := os.Open(opt.NetNS)
+		if err != nil {
+			return nil, err
+		}
+		defer ns.Close()
+		cfg.NetNS = int(ns.Fd())
+	}
+	conn, err := netlink.Dial(unix.NETLINK_SOCK_DIAG, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("sock_diag: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	var families []uint8
+	if opt.IPv4 || !opt.IPv6 {
+		families = append(families, afInet)
+	}
+	if opt.IPv6 || !opt.IPv4 {
+		families = append(families, afInet6)
+	}
+	var out []Socket
+	for _, family := range families {
+		msgs, err := conn.Execute(netlink.Message{
+			Header: netlink.Header{Type: sockDiagByFamily, Flags: netlink.Request | netlink.Dump},
+			Data:   diagRequest(family),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("sock_diag: %w", err)
+		}
+		for _, m := range msgs {
+			s, err := parseDiagMsg(m.Data)
+			if err != nil {
+				return nil, fmt.Errorf("sock_diag: %w", err)
+			}
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sockets
+
+import (
+	"context"
+	"errors"
+)
+
+func Dump(ctx context.Context, opt Options) ([]Socket, error) {
+	return nil, errors.New("sock_diag is only available on linux")
+}
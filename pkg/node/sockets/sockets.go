@@ -0,0 +1,272 @@
+// Package sockets lists TCP sockets with their tcp_info through sock_diag (like ss -tni) and
+// aggregates them per port for `nexa node sockets` and the tcp_sockets collector.
+package sockets
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// State is a TCP state as numbered by the kernel (include/net/tcp_states.h).
+type State uint8
+
+const (
+	StateEstablished State = 1 + iota
+	StateSynSent
+	StateSynRecv
+	StateFinWait1
+	StateFinWait2
+	StateTimeWait
+	StateClose
+	StateCloseWait
+	StateLastAck
+	StateListen
+	StateClosing
+	StateNewSynRecv
+	numStates
+)
+
+// States lists the states in kernel order.
+func States() []State {
+	out := make([]State, 0, numStates-1)
+	for s := StateEstablished; s < numStates; s++ {
+		out = append(out, s)
+	}
+	return out
+}
+
+var stateNames = [...]string{
+	StateEstablished: "established",
+	StateSynSent:     "syn_sent",
+	StateSynRecv:     "syn_recv",
+	StateFinWait1:    "fin_wait1",
+	StateFinWait2:    "fin_wait2",
+	StateTimeWait:    "time_wait",
+	StateClose:       "close",
+	StateCloseWait:   "close_wait",
+	StateLastAck:     "last_ack",
+	StateListen:      "listen",
+	StateClosing:     "closing",
+	StateNewSynRecv:  "new_syn_recv",
+}
+
+// String returns the name used by the tcpstat collector, e.g. time_wait.
+func (s State) String() string {
+	if s > 0 && s < numStates {
+		return stateNames[s]
+	}
+	return "unknown"
+}
+
+// TCPInfo is the part of struct tcp_info nexa uses. TIME-WAIT and NEW-SYN-RECV sockets have none.
+type TCPInfo struct {
+	// Retransmits counts unrecovered RTO timeouts of the current episode.
+	Retransmits  uint8
+	RTO          time.Duration
+	RTT          time.Duration
+	RTTVar       time.Duration
+	SndMSS       uint32
+	SndCwnd      uint32 // segments
+	Unacked      uint32
+	Lost         uint32
+	TotalRetrans uint32 // segments retransmitted over the socket's lifetime
+	BytesAcked   uint64
+	BytesRecv    uint64
+}
+
+// Socket is one TCP socket.
+type Socket struct {
+	State  State
+	Local  netip.AddrPort
+	Remote netip.AddrPort
+	// RecvQ and SendQ are the queued bytes; for listening sockets RecvQ is the accept queue
+	// length and SendQ the backlog, as ss shows them.
+	RecvQ, SendQ uint32
+	UID          uint32
+	Inode        uint32
+	Info         *TCPInfo
+}
+
+// Options select the sockets Dump returns.
+type Options struct {
+	// NetNS is a network namespace file such as /proc/<pid>/ns/net; empty means the namespace of
+	// the calling process.
+	NetNS string
+	// IPv4 and IPv6 select the address families; both false means both.
+	IPv4, IPv6 bool
+}
+
+// Direction says which end of a connection the aggregated port is.
+type Direction string
+
+const (
+	// DirectionIn groups sockets by a local port that has a listener: listeners and the
+	// connections they accepted.
+	DirectionIn Direction = "in"
+	// DirectionOut groups the remaining sockets by remote port: connections this host opened.
+	DirectionOut Direction = "out"
+)
+
+// LowCwnd is the congestion window, in segments, below which an established socket is counted
+// as congested: the initial window of current kernels.
+const LowCwnd = 10
+
+// Key identifies a group of sockets.
+type Key struct {
+	Port      uint16
+	Direction Direction
+	// Remote is the peer address; only set when aggregating per remote address.
+	Remote netip.Addr
+}
+
+// Stats aggregates the sockets of a Key.
+type Stats struct {
+	Key
+	Total  int
+	States [numStates]int
+	// RecvQ and SendQ sum the queues of connected sockets.
+	RecvQ, SendQ uint64
+	// AcceptQ and AcceptQMax sum the accept queues and backlogs of the listeners.
+	AcceptQ, AcceptQMax uint64
+	// Retrans sums the retransmitted segments of the current sockets.
+	Retrans uint64
+	// LowCwnd counts established sockets with a congestion window below LowCwnd.
+	LowCwnd int
+	MaxRTT  time.Duration
+
+	rttSum time.Duration
+	rttN   int
+}
+
+// Count returns the number of sockets in state s.
+func (st *Stats) Count(s State) int {
+	if s >= numStates {
+		return 0
+	}
+	return st.States[s]
+}
+
+// AvgRTT is the mean smoothed RTT of the sockets with tcp_info, or 0.
+func (st *Stats) AvgRTT() time.Duration {
+	if st.rttN == 0 {
+		return 0
+	}
+	return st.rttSum / time.Duration(st.rttN)
+}
+
+// Aggregate groups socks by port and direction, and by remote address with byRemote. A
+// connection belongs to its local port when that port has a listener and to its remote port
+// otherwise, which keeps ephemeral ports out of the result. The result is sorted by Key.
+func Aggregate(socks []Socket, byRemote bool) []Stats {
+	listening := map[uint16]bool{}
+	for _, s := range socks {
+		if s.State == StateListen {
+			listening[s.Local.Port()] = true
+		}
+	}
+	groups := map[Key]*Stats{}
+	for _, s := range socks {
+		k := Key{Port: s.Remote.Port(), Direction: DirectionOut}
+		if s.State == StateListen || listening[s.Local.Port()] {
+			k = Key{Port: s.Local.Port(), Direction: DirectionIn}
+		}
+		if byRemote && s.State != StateListen {
+			k.Remote = s.Remote.Addr().Unmap()
+		}
+		st := groups[k]
+		if st == nil {
+			st = &Stats{Key: k}
+			groups[k] = st
+		}
+		st.add(s)
+	}
+
+	out := make([]Stats, 0, len(groups))
+	for _, st := range groups {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key.less(out[j].Key) })
+	return out
+}
+
+func (st *Stats) add(s Socket) {
+	st.Total++
+	if s.State < numStates {
+		st.States[s.State]++
+	}
+	if s.State == StateListen {
+		st.AcceptQ += uint64(s.RecvQ)
+		st.AcceptQMax += uint64(s.SendQ)
+	} else {
+		st.RecvQ += uint64(s.RecvQ)
+		st.SendQ += uint64(s.SendQ)
+	}
+	if i := s.Info; i != nil {
+		st.Retrans += uint64(i.TotalRetrans)
+		if s.State == StateEstablished && i.SndCwnd < LowCwnd {
+			st.LowCwnd++
+		}
+		if s.State != StateListen {
+			st.rttSum += i.RTT
+			st.rttN++
+			st.MaxRTT = max(st.MaxRTT, i.RTT)
+		}
+	}
+}
+
+func (k Key) less(o Key) bool {
+	if k.Direction != o.Direction {
+		return k.Direction == DirectionIn
+	}
+	if k.Port != o.Port {
+		return k.Port < o.Port
+	}
+	return k.Remote.Less(o.Remote)
+}
+
+// SortKeys are the orders accepted by Sort.
+var SortKeys = []string{"total", "established", "time_wait", "close_wait", "syn_recv", "recvq", "sendq", "acceptq", "retrans", "lowcwnd", "rtt", "port"}
+
+// Sort orders stats by key, largest first (port sorts ascending). It reports false for an unknown
+// key.
+func Sort(stats []Stats, key string) bool {
+	var v func(*Stats) float64
+	switch key {
+	case "total":
+		v = func(s *Stats) float64 { return float64(s.Total) }
+	case "established", "time_wait", "close_wait", "syn_recv":
+		var state State
+		for _, s := range States() {
+			if s.String() == key {
+				state = s
+			}
+		}
+		v = func(s *Stats) float64 { return float64(s.Count(state)) }
+	case "recvq":
+		v = func(s *Stats) float64 { return float64(s.RecvQ) }
+	case "sendq":
+		v = func(s *Stats) float64 { return float64(s.SendQ) }
+	case "acceptq":
+		v = func(s *Stats) float64 { return float64(s.AcceptQ) }
+	case "retrans":
+		v = func(s *Stats) float64 { return float64(s.Retrans) }
+	case "lowcwnd":
+		v = func(s *Stats) float64 { return float64(s.LowCwnd) }
+	case "rtt":
+		v = func(s *Stats) float64 { return float64(s.MaxRTT) }
+	case "port":
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].Key.less(stats[j].Key) })
+		return true
+	default:
+		return false
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := v(&stats[i]), v(&stats[j])
+		if a != b {
+			return a > b
+		}
+		return stats[i].Key.less(stats[j].Key)
+	})
+	return true
+}
@@ -0,0 +1,149 @@
+package sockets
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/netlink"
+)
+
+// diagMsg builds an inet_diag_msg as the kernel sends it, optionally with a tcp_info attribute.
+func diagMsg(t *testing.T, family uint8, state State, local, remote netip.AddrPort, rq, wq uint32, info []byte) []byte {
+	t.Helper()
+	b := make([]byte, inetDiagMsgLen)
+	b[0], b[1] = family, byte(state)
+	binary.BigEndian.PutUint16(b[4:], local.Port())
+	binary.BigEndian.PutUint16(b[6:], remote.Port())
+	l, r := local.Addr().AsSlice(), remote.Addr().AsSlice()
+	copy(b[8:], l)
+	copy(b[24:], r)
+	binary.NativeEndian.PutUint32(b[56:], rq)
+	binary.NativeEndian.PutUint32(b[60:], wq)
+	binary.NativeEndian.PutUint32(b[64:], 1000)
+	if info == nil {
+		return b
+	}
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(inetDiagInfo, info)
+	attrs, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(b, attrs...)
+}
+
+func tcpInfoBytes(rtt time.Duration, cwnd, retrans uint32) []byte {
+	b := make([]byte, 232)
+	b[0] = byte(StateEstablished)
+	binary.NativeEndian.PutUint32(b[68:], uint32(rtt/time.Microsecond))
+	binary.NativeEndian.PutUint32(b[80:], cwnd)
+	binary.NativeEndian.PutUint32(b[100:], retrans)
+	binary.NativeEndian.PutUint64(b[120:], 4096)
+	return b
+}
+
+func TestParseDiagMsg(t *testing.T) {
+	local := netip.MustParseAddrPort("10.0.0.1:443")
+	remote := netip.MustParseAddrPort("192.0.2.7:51234")
+	s, err := parseDiagMsg(diagMsg(t, afInet, StateEstablished, local, remote, 10, 20, tcpInfoBytes(1500*time.Microsecond, 4, 7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Socket{State: StateEstablished, Local: local, Remote: remote, RecvQ: 10, SendQ: 20, UID: 1000,
+		Info: &TCPInfo{RTT: 1500 * time.Microsecond, SndCwnd: 4, TotalRetrans: 7, BytesAcked: 4096}}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("got %+v %+v\nwant %+v %+v", s, s.Info, want, want.Info)
+	}
+
+	local6 := netip.MustParseAddrPort("[2001:db8::1]:8080")
+	s, err = parseDiagMsg(diagMsg(t, afInet6, StateTimeWait, local6, netip.MustParseAddrPort("[2001:db8::2]:40000"), 0, 0, nil))
+	if err != nil || s.Local != local6 || s.State != StateTimeWait || s.Info != nil {
+		t.Fatalf("ipv6 time-wait: %+v, %v", s, err)
+	}
+
+	// Older kernels send a shorter tcp_info.
+	if info := parseTCPInfo(tcpInfoBytes(time.Millisecond, 10, 1)[:104]); info.TotalRetrans != 1 || info.BytesAcked != 0 {
+		t.Errorf("short tcp_info = %+v", info)
+	}
+	if _, err := parseDiagMsg(make([]byte, 40)); err == nil {
+		t.Error("short message accepted")
+	}
+}
+
+func sock(state State, local, remote string, info *TCPInfo) Socket {
+	return Socket{State: state, Local: netip.MustParseAddrPort(local), Remote: netip.MustParseAddrPort(remote), RecvQ: 1, SendQ: 2, Info: info}
+}
+
+func TestAggregate(t *testing.T) {
+	socks := []Socket{
+		{State: StateListen, Local: netip.MustParseAddrPort("0.0.0.0:443"), RecvQ: 3, SendQ: 128},
+		{State: StateListen, Local: netip.MustParseAddrPort("[::]:443"), RecvQ: 1, SendQ: 128},
+		sock(StateEstablished, "10.0.0.1:443", "192.0.2.7:50000", &TCPInfo{RTT: 2 * time.Millisecond, SndCwnd: 4, TotalRetrans: 3}),
+		sock(StateEstablished, "[::ffff:10.0.0.1]:443", "[::ffff:192.0.2.7]:50001", &TCPInfo{RTT: 4 * time.Millisecond, SndCwnd: 20}),
+		sock(StateTimeWait, "10.0.0.1:443", "192.0.2.8:50002", nil),
+		// Outgoing connections to a database: grouped by the remote port.
+		sock(StateEstablished, "10.0.0.1:41000", "10.0.0.9:5432", &TCPInfo{RTT: time.Millisecond, SndCwnd: 10}),
+		sock(StateCloseWait, "10.0.0.1:41001", "10.0.0.9:5432", nil),
+	}
+	stats := Aggregate(socks, false)
+	if len(stats) != 2 {
+		t.Fatalf("groups = %+v", stats)
+	}
+	in, out := stats[0], stats[1]
+	if in.Key != (Key{Port: 443, Direction: DirectionIn}) || out.Key != (Key{Port: 5432, Direction: DirectionOut}) {
+		t.Fatalf("keys = %+v, %+v", in.Key, out.Key)
+	}
+	if in.Total != 5 || in.Count(StateListen) != 2 || in.Count(StateEstablished) != 2 || in.Count(StateTimeWait) != 1 {
+		t.Errorf("in counts = %+v", in)
+	}
+	if in.AcceptQ != 4 || in.AcceptQMax != 256 || in.RecvQ != 3 || in.SendQ != 6 {
+		t.Errorf("in queues: accept %d/%d recv %d send %d", in.AcceptQ, in.AcceptQMax, in.RecvQ, in.SendQ)
+	}
+	if in.Retrans != 3 || in.LowCwnd != 1 || in.MaxRTT != 4*time.Millisecond || in.AvgRTT() != 3*time.Millisecond {
+		t.Errorf("in tcp_info: retrans %d lowcwnd %d rtt %s/%s", in.Retrans, in.LowCwnd, in.AvgRTT(), in.MaxRTT)
+	}
+	if out.Total != 2 || out.Count(StateCloseWait) != 1 || out.LowCwnd != 0 {
+		t.Errorf("out = %+v", out)
+	}
+
+	byRemote := Aggregate(socks, true)
+	var remotes []string
+	for _, st := range byRemote {
+		remotes = append(remotes, st.Remote.String())
+	}
+	// IPv4-mapped addresses are unmapped, listeners have no remote.
+	want := []string{"invalid IP", "192.0.2.7", "192.0.2.8", "10.0.0.9"}
+	if !reflect.DeepEqual(remotes, want) {
+		t.Errorf("remotes = %v, want %v", remotes, want)
+	}
+}
+
+func TestSort(t *testing.T) {
+	stats := []Stats{
+		{Key: Key{Port: 80, Direction: DirectionIn}, Total: 5, States: [numStates]int{StateTimeWait: 1}},
+		{Key: Key{Port: 22, Direction: DirectionIn}, Total: 5, States: [numStates]int{StateTimeWait: 4}},
+		{Key: Key{Port: 53, Direction: DirectionOut}, Total: 9},
+	}
+	ports := func() []uint16 {
+		var out []uint16
+		for _, s := range stats {
+			out = append(out, s.Port)
+		}
+		return out
+	}
+	for key, want := range map[string][]uint16{
+		"total":     {53, 22, 80},
+		"time_wait": {22, 80, 53},
+		"port":      {22, 80, 53},
+	} {
+		if !Sort(stats, key) || !reflect.DeepEqual(ports(), want) {
+			t.Errorf("Sort(%s) = %v, want %v", key, ports(), want)
+		}
+	}
+	if Sort(stats, "bogus") {
+		t.Error("unknown sort key accepted")
+	}
+}
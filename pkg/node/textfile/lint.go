@@ -0,0 +1,124 @@
+package textfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+// Files expands paths to the files the textfile collector would read. Paths may be glob patterns
+// (as in --collector.textfile.directory); directories contribute their *.prom files (not
+// recursively) and plain files are taken as given.
+func Files(paths []string) ([]string, error) {
+	var out []string
+	for _, pattern := range paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			// Let Stat produce the usual "no such file or directory" error.
+			matches = []string{pattern}
+		}
+		for _, p := range matches {
+			fi, err := os.Stat(p)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				out = append(out, p)
+				continue
+			}
+			proms, err := filepath.Glob(filepath.Join(p, "*.prom"))
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(proms)
+			out = append(out, proms...)
+		}
+	}
+	return out, nil
+}
+
+// ParseFile is Parse for a file on disk.
+func ParseFile(path string) ([]collector.MetricFamily, []Problem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return Parse(f, path)
+}
+
+// Lint checks each of files, as expanded by Files, on its own and then the files together, the
+// way the textfile collector merges them: a family must have the same type everywhere, should have
+// the same help, and a series may only appear in one file. File names are not globbed again.
+func Lint(files []string) ([]Problem, error) {
+	type origin struct {
+		file string
+		typ  collector.MetricType
+		help string
+	}
+	var problems []Problem
+	seenFamily := map[string]origin{}
+	seenSeries := map[string]string{}
+	for _, file := range files {
+		families, ps, err := ParseFile(file)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, ps...)
+		for _, mf := range families {
+			if prev, ok := seenFamily[mf.Name]; ok {
+				if prev.typ != mf.Type {
+					problems = append(problems, Problem{File: file, Severity: SeverityError,
+						Message: fmt.Sprintf("%s is %s here but %s in %s", mf.Name, mf.Type, prev.typ, prev.file)})
+				} else if prev.help != mf.Help {
+					problems = append(problems, Problem{File: file, Severity: SeverityWarning,
+						Message: fmt.Sprintf("%s has a different HELP than in %s", mf.Name, prev.file)})
+				}
+			} else {
+				seenFamily[mf.Name] = origin{file: file, typ: mf.Type, help: mf.Help}
+			}
+			for _, key := range seriesKeys(mf) {
+				if prev, ok := seenSeries[key]; ok && prev != file {
+					problems = append(problems, Problem{File: file, Severity: SeverityError,
+						Message: fmt.Sprintf("series %s is also in %s", key, prev)})
+					continue
+				}
+				seenSeries[key] = file
+			}
+		}
+	}
+	return problems, nil
+}
+
+func seriesKeys(mf collector.MetricFamily) []string {
+	var out []string
+	add := func(labels []collector.Label) {
+		out = append(out, mf.Name+"{"+labelKey(labels)+"}")
+	}
+	for _, s := range mf.Samples {
+		add(s.Labels)
+	}
+	for _, h := range mf.Histograms {
+		add(h.Labels)
+	}
+	for _, s := range mf.Summaries {
+		add(s.Labels)
+	}
+	return out
+}
+
+// HasErrors reports whether any problem is an error.
+func HasErrors(ps []Problem) bool {
+	for _, p := range ps {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+// Package textfile reads, checks and writes the *.prom files of the node_exporter textfile
+// collector.
+package textfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nexa/pkg/node/collector"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+type Severity string
+
+const (
+	// SeverityError problems make node_exporter drop the file (or the whole scrape).
+	SeverityError Severity = "error"
+	// SeverityWarning problems are accepted but usually unintended.
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is one lint finding. Line is 0 for problems that concern the file as a whole.
+type Problem struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (p Problem) String() string {
+	loc := p.File
+	if p.Line > 0 {
+		loc += ":" + strconv.Itoa(p.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, p.Severity, p.Message)
+}
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ValidMetricName reports whether name is a legacy (non-UTF-8) Prometheus metric name.
+func ValidMetricName(name string) bool { return metricNameRe.MatchString(name) }
+
+// ValidLabelName reports whether name is a legacy Prometheus label name that is not reserved.
+func ValidLabelName(name string) bool {
+	return labelNameRe.MatchString(name) && !strings.HasPrefix(name, "__")
+}
+
+// Parse reads a file in the Prometheus text format and returns its metric families together with
+// the problems found in it. file is only used in the problems. The returned error is reserved for
+// read errors; a file that does not parse yields a problem and no families.
+func Parse(r io.Reader, file string) ([]collector.MetricFamily, []Problem, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	problems := lintLines(data, file)
+
+	parser := expfmt.NewTextParser(model.LegacyValidation)
+	dtos, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		problems = append(problems, Problem{File: file, Severity: SeverityError, Message: err.Error()})
+		sortProblems(problems)
+		return nil, problems, nil
+	}
+	mfs := make([]*dto.MetricFamily, 0, len(dtos))
+	for _, mf := range dtos {
+		mfs = append(mfs, mf)
+	}
+	families, err := collector.DTOToNexa(mfs)
+	if err != nil {
+		problems = append(problems, Problem{File: file, Severity: SeverityError, Message: err.Error()})
+		sortProblems(problems)
+		return nil, problems, nil
+	}
+	sortProblems(problems)
+	return families, problems, nil
+}
+
+// familyState tracks what lintLines has seen of one metric family.
+type familyState struct {
+	help, typed bool
+	kind        string
+	firstSample int
+	lastSample  int
+	closed      bool // another family's lines came after this one's samples
+}
+
+// lintLines performs the checks the text parser does not do, or does without line numbers:
+// names, HELP/TYPE presence and placement, duplicate series, split families and timestamps.
+func lintLines(data []byte, file string) []Problem {
+	var problems []Problem
+	add := func(line int, sev Severity, format string, args ...any) {
+		problems = append(problems, Problem{File: file, Line: line, Severity: sev, Message: fmt.Sprintf(format, args...)})
+	}
+
+	families := map[string]*familyState{}
+	var order []string
+	state := func(name string) *familyState {
+		st, ok := families[name]
+		if !ok {
+			st = &familyState{}
+			families[name] = st
+			order = append(order, name)
+		}
+		return st
+	}
+	current := ""
+	switchTo := func(name string) {
+		if current != "" && current != name {
+			if st := families[current]; st != nil && st.lastSample > 0 {
+				st.closed = true
+			}
+		}
+		current = name
+	}
+	series := map[string]int{}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				continue // plain comment
+			}
+			name := fields[2]
+			if !ValidMetricName(name) {
+				add(lineNo, SeverityError, "invalid metric name %q", name)
+				continue
+			}
+			st := state(name)
+			switchTo(name)
+			if st.closed {
+				add(lineNo, SeverityError, "%s %s after the samples of %s; all lines of a family must be grouped together", fields[1], name, name)
+			}
+			if fields[1] == "HELP" {
+				if st.help {
+					add(lineNo, SeverityError, "second HELP line for %s", name)
+				}
+				st.help = true
+				continue
+			}
+			if len(fields) != 4 {
+				add(lineNo, SeverityError, "TYPE line for %s needs exactly one type", name)
+				continue
+			}
+			if st.typed {
+				add(lineNo, SeverityError, "second TYPE line for %s", name)
+			}
+			switch fields[3] {
+			case "counter", "gauge", "histogram", "summary", "untyped":
+			default:
+				add(lineNo, SeverityError, "unknown type %q for %s", fields[3], name)
+			}
+			if st.firstSample > 0 {
+				add(lineNo, SeverityError, "TYPE for %s after its first sample (line %d)", name, st.firstSample)
+			}
+			st.typed, st.kind = true, fields[3]
+			continue
+		}
+
+		name, labels, rest, err := splitSample(line)
+		if err != nil {
+			add(lineNo, SeverityError, "%v", err)
+			continue
+		}
+		if !ValidMetricName(name) {
+			add(lineNo, SeverityError, "invalid metric name %q", name)
+			continue
+		}
+		family := familyOf(name, families)
+		st := state(family)
+		switchTo(family)
+		if st.closed {
+			add(lineNo, SeverityError, "sample of %s after other families; all lines of a family must be grouped together", family)
+			st.closed = false
+		}
+		if st.firstSample == 0 {
+			st.firstSample = lineNo
+		}
+		st.lastSample = lineNo
+
+		seen := map[string]bool{}
+		for _, l := range labels {
+			if !ValidLabelName(l.Name) {
+				add(lineNo, SeverityError, "invalid label name %q", l.Name)
+			}
+			if seen[l.Name] {
+				add(lineNo, SeverityError, "duplicate label %q", l.Name)
+			}
+			seen[l.Name] = true
+		}
+		fields := strings.Fields(rest)
+		switch len(fields) {
+		case 1:
+		case 2:
+			add(lineNo, SeverityError, "sample has a timestamp; the textfile collector rejects client-side timestamps")
+		default:
+			add(lineNo, SeverityError, "expected a value and an optional timestamp after %s", name)
+			continue
+		}
+		if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+			add(lineNo, SeverityError, "invalid value %q", fields[0])
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		key := name + "{" + collector.FormatLabels(labels) + "}"
+		if first, ok := series[key]; ok {
+			add(lineNo, SeverityError, "duplicate series %s (first on line %d)", key, first)
+		} else {
+			series[key] = lineNo
+		}
+	}
+
+	for _, name := range order {
+		st := families[name]
+		if st.firstSample == 0 {
+			continue
+		}
+		if !st.help {
+			add(st.firstSample, SeverityWarning, "%s has no HELP line", name)
+		}
+		if !st.typed {
+			add(st.firstSample, SeverityWarning, "%s has no TYPE line and is untyped", name)
+		}
+	}
+	return problems
+}
+
+// familyOf maps histogram and summary sample names (_bucket, _sum, _count) to the family
+// declared by a TYPE line.
+func familyOf(name string, families map[string]*familyState) string {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		st, ok := families[base]
+		if !ok {
+			continue
+		}
+		if st.kind == "histogram" || (st.kind == "summary" && suffix != "_bucket") {
+			return base
+		}
+	}
+	return name
+}
+
+// splitSample splits `name{k="v",...} rest` into its parts, unescaping label values.
+func splitSample(line string) (name string, labels []collector.Label, rest string, err error) {
+	i := strings.IndexAny(line, "{ \t")
+	if i < 0 {
+		return "", nil, "", fmt.Errorf("sample %q has no value", line)
+	}
+	name, line = line[:i], line[i:]
+	if !strings.HasPrefix(line, "{") {
+		return name, nil, line, nil
+	}
+	line = line[1:]
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(line, "}") {
+			return name, labels, line[1:], nil
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return "", nil, "", fmt.Errorf("label without value in %s", name)
+		}
+		lname := strings.TrimSpace(line[:eq])
+		line = strings.TrimLeft(line[eq+1:], " \t")
+		if !strings.HasPrefix(line, `"`) {
+			return "", nil, "", fmt.Errorf("label %s of %s: value must be quoted", lname, name)
+		}
+		var b strings.Builder
+		j := 1
+		for ; j < len(line) && line[j] != '"'; j++ {
+			if line[j] != '\\' {
+				b.WriteByte(line[j])
+				continue
+			}
+			j++
+			if j == len(line) {
+				break
+			}
+			switch line[j] {
+			case 'n':
+				b.WriteByte('\n')
+			case '\\', '"':
+				b.WriteByte(line[j])
+			default:
+				return "", nil, "", fmt.Errorf("label %s of %s: invalid escape \\%c", lname, name, line[j])
+			}
+		}
+		if j >= len(line) {
+			return "", nil, "", fmt.Errorf("label %s of %s: unterminated value", lname, name)
+		}
+		labels = append(labels, collector.Label{Name: lname, Value: b.String()})
+		line = strings.TrimLeft(line[j+1:], " \t")
+		if strings.HasPrefix(line, ",") {
+			line = line[1:]
+			continue
+		}
+		if !strings.HasPrefix(line, "}") {
+			return "", nil, "", fmt.Errorf("expected , or } after label %s of %s", lname, name)
+		}
+	}
+}
+
+func sortProblems(ps []Problem) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].File != ps[j].File {
+			return ps[i].File < ps[j].File
+		}
+		return ps[i].Line < ps[j].Line
+	})
+}
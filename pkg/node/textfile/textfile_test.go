@@ -0,0 +1,242 @@
+package textfile
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+func TestParse_Clean(t *testing.T) {
+	in := `# HELP backup_last_success_seconds Time of the last successful backup.
+# TYPE backup_last_success_seconds gauge
+backup_last_success_seconds{job="db"} 1.7e+09
+backup_last_success_seconds{job="home"} 1.6e+09
+# HELP rpc_seconds RPC latency.
+# TYPE rpc_seconds histogram
+rpc_seconds_bucket{le="0.1"} 1
+rpc_seconds_bucket{le="+Inf"} 2
+rpc_seconds_sum 0.3
+rpc_seconds_count 2
+`
+	families, problems, err := Parse(strings.NewReader(in), "ok.prom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("problems = %v", problems)
+	}
+	if len(families) != 2 || families[0].Name != "backup_last_success_seconds" || len(families[0].Samples) != 2 {
+		t.Fatalf("families = %+v", families)
+	}
+	if families[1].Type != collector.MetricTypeHistogram || len(families[1].Histograms) != 1 {
+		t.Fatalf("histogram = %+v", families[1])
+	}
+}
+
+func TestParse_Problems(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string // "line severity substring"
+	}{
+		{
+			name: "missing help and type",
+			in:   "foo 1\n",
+			want: []string{"1 warning foo has no HELP line", "1 warning foo has no TYPE line"},
+		},
+		{
+			name: "duplicate series",
+			in:   "# HELP foo x\n# TYPE foo gauge\nfoo{a=\"1\",b=\"2\"} 1\nfoo{b=\"2\",a=\"1\"} 2\n",
+			want: []string{`4 error duplicate series foo{a="1",b="2"} (first on line 3)`},
+		},
+		{
+			name: "bad label names",
+			in:   "# HELP foo x\n# TYPE foo gauge\nfoo{__x=\"1\",a=\"1\",a=\"2\"} 1\n",
+			want: []string{`3 error invalid label name "__x"`, `3 error duplicate label "a"`},
+		},
+		{
+			name: "bad metric name",
+			in:   "# HELP 1foo x\n",
+			want: []string{`1 error invalid metric name "1foo"`},
+		},
+		{
+			name: "timestamp",
+			in:   "# HELP foo x\n# TYPE foo gauge\nfoo 1 1700000000000\n",
+			want: []string{"3 error sample has a timestamp"},
+		},
+		{
+			name: "split family",
+			in:   "# HELP foo x\n# TYPE foo gauge\nfoo{a=\"1\"} 1\n# HELP bar x\n# TYPE bar gauge\nbar 1\nfoo{a=\"2\"} 1\n",
+			want: []string{"7 error sample of foo after other families"},
+		},
+		{
+			name: "type after sample and unknown type",
+			in:   "# HELP foo x\nfoo 1\n# TYPE foo gauges\n",
+			want: []string{`3 error unknown type "gauges" for foo`, "3 error TYPE for foo after its first sample (line 2)"},
+		},
+		{
+			name: "mixed type",
+			in:   "# HELP foo x\n# TYPE foo gauge\n# TYPE foo counter\nfoo 1\n",
+			want: []string{"3 error second TYPE line for foo"},
+		},
+		{
+			name: "bad value",
+			in:   "# HELP foo x\n# TYPE foo gauge\nfoo one\n",
+			want: []string{`3 error invalid value "one"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems, err := Parse(strings.NewReader(tt.in), "f.prom")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				found := false
+				for _, p := range problems {
+					if strings.HasPrefix(strings.Join([]string{strconv.Itoa(p.Line), string(p.Severity), p.Message}, " "), w) {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("missing %q in %v", w, problems)
+				}
+			}
+		})
+	}
+}
+
+func TestLint_AcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.prom", "# HELP foo x\n# TYPE foo gauge\nfoo{a=\"1\"} 1\n")
+	write("b.prom", "# HELP foo y\n# TYPE foo gauge\nfoo{a=\"1\"} 2\n")
+	write("c.prom", "# HELP foo x\n# TYPE foo counter\nfoo{a=\"3\"} 2\n")
+	write("ignored.txt", "garbage {\n")
+
+	files, err := Files([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err := Lint(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, p := range problems {
+		msgs = append(msgs, filepath.Base(p.File)+" "+string(p.Severity)+" "+p.Message)
+	}
+	got := strings.Join(msgs, "\n")
+	for _, w := range []string{
+		"b.prom warning foo has a different HELP than in " + filepath.Join(dir, "a.prom"),
+		`b.prom error series foo{a="1"} is also in ` + filepath.Join(dir, "a.prom"),
+		"c.prom error foo is counter here but gauge in " + filepath.Join(dir, "a.prom"),
+	} {
+		if !strings.Contains(got, w) {
+			t.Errorf("missing %q in\n%s", w, got)
+		}
+	}
+	if strings.Contains(got, "ignored.txt") {
+		t.Errorf("non-.prom file was linted:\n%s", got)
+	}
+	if !HasErrors(problems) {
+		t.Error("HasErrors = false")
+	}
+}
+
+func TestSetSampleAndWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.prom")
+
+	families, err := SetSample(nil, "backup_ok", "Whether the last backup worked.", collector.MetricTypeGauge,
+		[]collector.Label{{Name: "job", Value: "db"}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	families, err = SetSample(families, "backup_ok", "", collector.MetricTypeGauge,
+		[]collector.Label{{Name: "job", Value: "home"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	families, err = SetSample(families, "backup_ok", "", collector.MetricTypeGauge,
+		[]collector.Label{{Name: "job", Value: "db"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, families); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP backup_ok Whether the last backup worked.
+# TYPE backup_ok gauge
+backup_ok{job="db"} 0
+backup_ok{job="home"} 0
+`
+	if string(data) != want {
+		t.Fatalf("file =\n%s\nwant\n%s", data, want)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("temporary file left behind: %v", entries)
+	}
+
+	// The written file must lint clean and parse back.
+	problems, err := Lint([]string{path})
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("lint = %v, %v", problems, err)
+	}
+
+	if _, err := SetSample(families, "backup_ok", "", collector.MetricTypeCounter, nil, 1); err == nil {
+		t.Error("type change accepted")
+	}
+	if _, err := SetSample(nil, "backup-ok", "", collector.MetricTypeGauge, nil, 1); err == nil {
+		t.Error("invalid name accepted")
+	}
+	if _, err := SetSample(nil, "x", "", collector.MetricTypeGauge, []collector.Label{{Name: "a"}, {Name: "a"}}, 1); err == nil {
+		t.Error("duplicate label accepted")
+	}
+
+	// Without help text there is no HELP line rather than an empty one.
+	families, err = SetSample(nil, "foo_bytes", "", collector.MetricTypeGauge, nil, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, families); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "# TYPE foo_bytes gauge\nfoo_bytes 12\n" {
+		t.Errorf("file without help =\n%s", data)
+	}
+}
+
+func TestLint_GlobCharsInNames(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"m*.prom": "# HELP a a\n# TYPE a gauge\na 1\n",
+		"mm.prom": "# HELP b b\n# TYPE b gauge\nb 1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := Files([]string{dir})
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Files = %v, %v", files, err)
+	}
+	// Globbing "m*.prom" again would read mm.prom twice and report its series as duplicated.
+	if problems, err := Lint(files); err != nil || len(problems) != 0 {
+		t.Fatalf("lint = %v, %v", problems, err)
+	}
+}
@@ -0,0 +1,116 @@
+package textfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nexa/pkg/node/collector"
+	"github.com/prometheus/common/expfmt"
+)
+
+// WriteFile writes families to path in the text format. The data goes to a temporary file in the
+// same directory first, which is synced and then renamed over path, so the textfile collector never
+// reads a partially written file. Families without help get no HELP line.
+func WriteFile(path string, families []collector.MetricFamily) error {
+	sorted := append([]collector.MetricFamily(nil), families...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	var buf bytes.Buffer
+	for _, mf := range collector.NexaToDTO(sorted) {
+		if mf.GetHelp() == "" {
+			mf.Help = nil
+		}
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			return err
+		}
+	}
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	// The collector only reads *.prom, so the temporary name must not end in it.
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SetSample returns families with the series name{labels} set to value, replacing an existing
+// series with the same labels or adding it (and its family) otherwise. help replaces the family's
+// help when non-empty. Only gauge, counter and untyped families can be set this way.
+func SetSample(families []collector.MetricFamily, name, help string, typ collector.MetricType, labels []collector.Label, value float64) ([]collector.MetricFamily, error) {
+	if !ValidMetricName(name) {
+		return nil, fmt.Errorf("invalid metric name %q", name)
+	}
+	switch typ {
+	case collector.MetricTypeGauge, collector.MetricTypeCounter, collector.MetricTypeUntyped:
+	default:
+		return nil, fmt.Errorf("cannot write a single %s sample; use gauge, counter or untyped", typ)
+	}
+	labels = append([]collector.Label(nil), labels...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	for i, l := range labels {
+		if !ValidLabelName(l.Name) {
+			return nil, fmt.Errorf("invalid label name %q", l.Name)
+		}
+		if i > 0 && labels[i-1].Name == l.Name {
+			return nil, fmt.Errorf("duplicate label %q", l.Name)
+		}
+	}
+
+	out := append([]collector.MetricFamily(nil), families...)
+	idx := -1
+	for i := range out {
+		if out[i].Name == name {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		out = append(out, collector.MetricFamily{Name: name, Type: typ})
+		idx = len(out) - 1
+	}
+	mf := &out[idx]
+	if mf.Type != typ {
+		return nil, fmt.Errorf("%s is already a %s", name, mf.Type)
+	}
+	if help != "" {
+		mf.Help = help
+	}
+	samples := append([]collector.Sample(nil), mf.Samples...)
+	want := labelKey(labels)
+	for i := range samples {
+		if labelKey(samples[i].Labels) == want {
+			samples[i] = collector.Sample{Labels: labels, Value: value}
+			mf.Samples = samples
+			return out, nil
+		}
+	}
+	mf.Samples = append(samples, collector.Sample{Labels: labels, Value: value})
+	return out, nil
+}
+
+func labelKey(labels []collector.Label) string {
+	sorted := append([]collector.Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return collector.FormatLabels(sorted)
+}
@@ -0,0 +1,437 @@
+// Package top turns successive collections into the panes of `nexa node top`: per-core CPU
+// modes, memory and swap, PSI, disk and network rates and filesystem usage, each row with a short
+// history for sparklines.
+package top
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+// Collectors are the collectors the dashboard reads from.
+var Collectors = []string{"cpu", "meminfo", "pressure", "diskstats", "netdev", "filesystem"}
+
+// DefaultHistory is the number of refreshes kept per row for its sparkline.
+const DefaultHistory = 30
+
+// Unit tells the renderer how to format a value.
+type Unit int
+
+const (
+	UnitNumber Unit = iota
+	UnitPercent
+	UnitBytes
+	UnitBytesPerSecond
+	UnitPerSecond
+	UnitSeconds
+)
+
+type Column struct {
+	Name string
+	Unit Unit
+}
+
+// Row is one line of a pane. Values are aligned with the pane's columns; NaN means not yet known
+// (rates need two collections). History holds the last values of the pane's spark column, oldest
+// first.
+type Row struct {
+	Key     string
+	Values  []float64
+	History []float64
+}
+
+type Pane struct {
+	Name  string
+	Title string
+	// KeyColumn is the header of the row keys, e.g. "device".
+	KeyColumn string
+	Columns   []Column
+	// Spark is the index of the column whose history is drawn.
+	Spark int
+	// SparkMax fixes the top of the sparkline scale (100 for percentages); 0 scales to the
+	// largest value in the history.
+	SparkMax float64
+	Rows     []Row
+}
+
+// Sort orders the rows by column col (descending if desc); col < 0 sorts by key, with numbers
+// embedded in keys compared numerically so that cpu10 follows cpu9.
+func (p *Pane) Sort(col int, desc bool) {
+	sort.SliceStable(p.Rows, func(i, j int) bool {
+		a, b := p.Rows[i], p.Rows[j]
+		if col >= 0 && col < len(p.Columns) {
+			va, vb := a.Values[col], b.Values[col]
+			switch {
+			case math.IsNaN(va) && math.IsNaN(vb), va == vb:
+			case math.IsNaN(va): // unknown values sort last either way
+				return false
+			case math.IsNaN(vb):
+				return true
+			case desc:
+				return va > vb
+			default:
+				return va < vb
+			}
+		}
+		if desc && col < 0 {
+			return naturalLess(b.Key, a.Key)
+		}
+		return naturalLess(a.Key, b.Key)
+	})
+}
+
+// Dashboard keeps the previous collection and the row histories between refreshes.
+type Dashboard struct {
+	History int
+
+	prev    collector.SampleIndex
+	prevAt  time.Time
+	history map[string][]float64
+	panes   []Pane
+}
+
+func New(history int) *Dashboard {
+	if history <= 0 {
+		history = DefaultHistory
+	}
+	return &Dashboard{History: history, history: map[string][]float64{}}
+}
+
+// Panes returns the panes computed by the last Update.
+func (d *Dashboard) Panes() []Pane { return d.panes }
+
+// Update computes the panes from a new collection taken at at. Rates are computed against the
+// previous Update and are NaN on the first one.
+func (d *Dashboard) Update(families []collector.MetricFamily, at time.Time) {
+	s := &snapshot{
+		cur:     collector.NewSampleIndex(families),
+		prev:    d.prev,
+		elapsed: at.Sub(d.prevAt),
+		byName:  map[string]collector.MetricFamily{},
+	}
+	for _, f := range families {
+		s.byName[f.Name] = f
+	}
+	panes := []Pane{cpuPane(s), memoryPane(s), pressurePane(s), diskPane(s), networkPane(s), filesystemPane(s)}
+
+	seen := map[string]bool{}
+	for pi := range panes {
+		p := &panes[pi]
+		for ri := range p.Rows {
+			r := &p.Rows[ri]
+			key := p.Name + "/" + r.Key
+			seen[key] = true
+			h := append(d.history[key], r.Values[p.Spark])
+			if len(h) > d.History {
+				h = h[len(h)-d.History:]
+			}
+			d.history[key] = h
+			r.History = append([]float64(nil), h...)
+		}
+	}
+	for key := range d.history {
+		if !seen[key] {
+			delete(d.history, key)
+		}
+	}
+	d.prev, d.prevAt, d.panes = s.cur, at, panes
+}
+
+type snapshot struct {
+	cur, prev collector.SampleIndex
+	elapsed   time.Duration
+	byName    map[string]collector.MetricFamily
+}
+
+// value returns the current value of name{labels}.
+func (s *snapshot) value(name string, labels []collector.Label) (float64, bool) {
+	return s.cur.Lookup(name, labels)
+}
+
+// rate returns the per-second increase of the counter name{labels}, NaN without a previous value.
+func (s *snapshot) rate(name string, labels []collector.Label) float64 {
+	cur, ok := s.cur.Lookup(name, labels)
+	if !ok || s.prev == nil {
+		return math.NaN()
+	}
+	prev, ok := s.prev.Lookup(name, labels)
+	if !ok {
+		return math.NaN()
+	}
+	return collector.CounterRate(prev, cur, s.elapsed)
+}
+
+// groups returns the distinct values of label over the samples of name, in natural order.
+func (s *snapshot) groups(name, label string) []string {
+	set := map[string]bool{}
+	for _, smp := range s.byName[name].Samples {
+		for _, l := range smp.Labels {
+			if l.Name == label {
+				set[l.Value] = true
+			}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return naturalLess(out[i], out[j]) })
+	return out
+}
+
+func labels(kv ...string) []collector.Label {
+	out := make([]collector.Label, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		out = append(out, collector.Label{Name: kv[i], Value: kv[i+1]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func cpuPane(s *snapshot) Pane {
+	p := Pane{
+		Name:      "cpu",
+		KeyColumn: "core",
+		Title:     "CPU modes per core",
+		Columns: []Column{
+			{"busy", UnitPercent}, {"user", UnitPercent}, {"nice", UnitPercent}, {"system", UnitPercent},
+			{"iowait", UnitPercent}, {"irq", UnitPercent}, {"steal", UnitPercent}, {"idle", UnitPercent},
+		},
+		Spark:    0,
+		SparkMax: 100,
+	}
+	const name = "node_cpu_seconds_total"
+	cpus := s.groups(name, "cpu")
+	total := make([]float64, len(p.Columns))
+	for _, cpu := range cpus {
+		mode := func(m string) float64 { return 100 * s.rate(name, labels("cpu", cpu, "mode", m)) }
+		irq := mode("irq")
+		if soft := mode("softirq"); !math.IsNaN(soft) {
+			irq += soft
+		}
+		idle := mode("idle")
+		busy := math.NaN()
+		if !math.IsNaN(idle) {
+			busy = 100 - idle
+			if busy < 0 {
+				busy = 0
+			}
+		}
+		vals := []float64{busy, mode("user"), mode("nice"), mode("system"), mode("iowait"), irq, mode("steal"), idle}
+		for i, v := range vals {
+			total[i] += v
+		}
+		p.Rows = append(p.Rows, Row{Key: "cpu" + cpu, Values: vals})
+	}
+	if len(cpus) > 1 {
+		for i := range total {
+			total[i] /= float64(len(cpus))
+		}
+		p.Rows = append([]Row{{Key: "all", Values: total}}, p.Rows...)
+	}
+	return p
+}
+
+func memoryPane(s *snapshot) Pane {
+	p := Pane{
+		Name:      "memory",
+		KeyColumn: "kind",
+		Title:     "Memory and swap",
+		Columns:   []Column{{"used%", UnitPercent}, {"total", UnitBytes}, {"used", UnitBytes}, {"available", UnitBytes}, {"cached", UnitBytes}},
+		Spark:     0,
+		SparkMax:  100,
+	}
+	get := func(field string) float64 {
+		if v, ok := s.value("node_memory_"+field+"_bytes", nil); ok {
+			return v
+		}
+		return math.NaN()
+	}
+	row := func(key string, total, avail, cached float64) {
+		if math.IsNaN(total) || total == 0 {
+			return
+		}
+		used := total - avail
+		p.Rows = append(p.Rows, Row{Key: key, Values: []float64{100 * used / total, total, used, avail, cached}})
+	}
+	avail := get("MemAvailable")
+	if math.IsNaN(avail) {
+		avail = get("MemFree") + get("Buffers") + get("Cached")
+	}
+	row("memory", get("MemTotal"), avail, get("Cached"))
+	row("swap", get("SwapTotal"), get("SwapFree"), get("SwapCached"))
+	return p
+}
+
+func pressurePane(s *snapshot) Pane {
+	p := Pane{
+		Name:      "pressure",
+		KeyColumn: "resource",
+		Title:     "Pressure stall information (share of time stalled)",
+		Columns:   []Column{{"some", UnitPercent}, {"full", UnitPercent}},
+		Spark:     0,
+		SparkMax:  100,
+	}
+	for _, res := range []string{"cpu", "memory", "io"} {
+		some := 100 * s.rate("node_pressure_"+res+"_waiting_seconds_total", nil)
+		full := 100 * s.rate("node_pressure_"+res+"_stalled_seconds_total", nil)
+		if _, ok := s.value("node_pressure_"+res+"_waiting_seconds_total", nil); !ok {
+			continue
+		}
+		p.Rows = append(p.Rows, Row{Key: res, Values: []float64{some, full}})
+	}
+	return p
+}
+
+func diskPane(s *snapshot) Pane {
+	p := Pane{
+		Name:      "disk",
+		KeyColumn: "device",
+		Title:     "Disk I/O per device",
+		Columns: []Column{
+			{"util", UnitPercent}, {"r/s", UnitPerSecond}, {"w/s", UnitPerSecond},
+			{"read", UnitBytesPerSecond}, {"write", UnitBytesPerSecond},
+			{"r_await", UnitSeconds}, {"w_await", UnitSeconds}, {"queue", UnitNumber},
+		},
+		Spark:    0,
+		SparkMax: 100,
+	}
+	for _, dev := range s.groups("node_disk_reads_completed_total", "device") {
+		l := labels("device", dev)
+		r := s.rate("node_disk_reads_completed_total", l)
+		w := s.rate("node_disk_writes_completed_total", l)
+		p.Rows = append(p.Rows, Row{Key: dev, Values: []float64{
+			100 * s.rate("node_disk_io_time_seconds_total", l),
+			r, w,
+			s.rate("node_disk_read_bytes_total", l),
+			s.rate("node_disk_written_bytes_total", l),
+			await(s.rate("node_disk_read_time_seconds_total", l), r),
+			await(s.rate("node_disk_write_time_seconds_total", l), w),
+			s.rate("node_disk_io_time_weighted_seconds_total", l),
+		}})
+	}
+	return p
+}
+
+// await is the average time per completed request, as in iostat; 0 when nothing completed.
+func await(busy, ops float64) float64 {
+	if math.IsNaN(busy) || math.IsNaN(ops) {
+		return math.NaN()
+	}
+	if ops == 0 {
+		return 0
+	}
+	return busy / ops
+}
+
+func networkPane(s *snapshot) Pane {
+	p := Pane{
+		Name:      "network",
+		KeyColumn: "interface",
+		Title:     "Network per interface",
+		Columns: []Column{
+			{"rx", UnitBytesPerSecond}, {"tx", UnitBytesPerSecond},
+			{"rx pkt/s", UnitPerSecond}, {"tx pkt/s", UnitPerSecond},
+			{"rx drop/s", UnitPerSecond}, {"tx drop/s", UnitPerSecond},
+			{"errs/s", UnitPerSecond},
+		},
+		Spark: 0,
+	}
+	for _, dev := range s.groups("node_network_receive_bytes_total", "device") {
+		l := labels("device", dev)
+		errs := s.rate("node_network_receive_errs_total", l) + s.rate("node_network_transmit_errs_total", l)
+		p.Rows = append(p.Rows, Row{Key: dev, Values: []float64{
+			s.rate("node_network_receive_bytes_total", l),
+			s.rate("node_network_transmit_bytes_total", l),
+			s.rate("node_network_receive_packets_total", l),
+			s.rate("node_network_transmit_packets_total", l),
+			s.rate("node_network_receive_drop_total", l),
+			s.rate("node_network_transmit_drop_total", l),
+			errs,
+		}})
+	}
+	return p
+}
+
+func filesystemPane(s *snapshot) Pane {
+	p := Pane{
+		Name:      "filesystem",
+		KeyColumn: "mountpoint",
+		Title:     "Filesystem usage per mount point",
+		Columns: []Column{
+			{"used%", UnitPercent}, {"size", UnitBytes}, {"used", UnitBytes}, {"avail", UnitBytes},
+			{"inodes%", UnitPercent},
+		},
+		Spark:    0,
+		SparkMax: 100,
+	}
+	for _, smp := range s.byName["node_filesystem_size_bytes"].Samples {
+		size := smp.Value
+		if size == 0 {
+			continue
+		}
+		mp := labelValue(smp.Labels, "mountpoint")
+		free, _ := s.value("node_filesystem_free_bytes", smp.Labels)
+		avail, ok := s.value("node_filesystem_avail_bytes", smp.Labels)
+		if !ok {
+			avail = free
+		}
+		used := size - free
+		usedPct := math.NaN()
+		if used+avail > 0 {
+			// Like df: the reserved blocks count neither as used nor as available.
+			usedPct = 100 * used / (used + avail)
+		}
+		inodes := math.NaN()
+		if files, ok := s.value("node_filesystem_files", smp.Labels); ok && files > 0 {
+			ffree, _ := s.value("node_filesystem_files_free", smp.Labels)
+			inodes = 100 * (files - ffree) / files
+		}
+		p.Rows = append(p.Rows, Row{Key: mp, Values: []float64{usedPct, size, used, avail, inodes}})
+	}
+	p.Sort(-1, false)
+	return p
+}
+
+func labelValue(ls []collector.Label, name string) string {
+	for _, l := range ls {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// naturalLess compares strings piecewise, digit runs by numeric value.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, _ := strconv.ParseUint(da, 10, 64)
+			nb, _ := strconv.ParseUint(db, 10, 64)
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		return s
+	}
+	return s[:i]
+}
@@ -0,0 +1,217 @@
+package top
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+func counter(name string, samples ...collector.Sample) collector.MetricFamily {
+	return collector.MetricFamily{Name: name, Type: collector.MetricTypeCounter, Samples: samples}
+}
+
+func gauge(name string, samples ...collector.Sample) collector.MetricFamily {
+	return collector.MetricFamily{Name: name, Type: collector.MetricTypeGauge, Samples: samples}
+}
+
+func sample(v float64, kv ...string) collector.Sample {
+	return collector.Sample{Labels: labels(kv...), Value: v}
+}
+
+// collection returns a node whose counters grew by scale times a fixed per-second rate.
+func collection(scale float64) []collector.MetricFamily {
+	var cpu []collector.Sample
+	for _, c := range []string{"0", "1"} {
+		cpu = append(cpu,
+			sample(scale*0.25, "cpu", c, "mode", "user"),
+			sample(scale*0.05, "cpu", c, "mode", "system"),
+			sample(scale*0.70, "cpu", c, "mode", "idle"),
+		)
+	}
+	return []collector.MetricFamily{
+		counter("node_cpu_seconds_total", cpu...),
+		gauge("node_memory_MemTotal_bytes", sample(1000)),
+		gauge("node_memory_MemAvailable_bytes", sample(250)),
+		gauge("node_memory_Cached_bytes", sample(100)),
+		counter("node_pressure_io_waiting_seconds_total", sample(scale*0.1)),
+		counter("node_pressure_io_stalled_seconds_total", sample(scale*0.05)),
+		counter("node_disk_reads_completed_total", sample(scale*100, "device", "sda")),
+		counter("node_disk_writes_completed_total", sample(scale*50, "device", "sda")),
+		counter("node_disk_read_bytes_total", sample(scale*4096, "device", "sda")),
+		counter("node_disk_read_time_seconds_total", sample(scale*0.2, "device", "sda")),
+		counter("node_disk_write_time_seconds_total", sample(scale*0.5, "device", "sda")),
+		counter("node_disk_io_time_seconds_total", sample(scale*0.4, "device", "sda")),
+		counter("node_network_receive_bytes_total", sample(scale*1e6, "device", "eth0")),
+		counter("node_network_receive_drop_total", sample(scale*2, "device", "eth0")),
+		gauge("node_filesystem_size_bytes", sample(100, "device", "sda1", "fstype", "ext4", "mountpoint", "/")),
+		gauge("node_filesystem_free_bytes", sample(30, "device", "sda1", "fstype", "ext4", "mountpoint", "/")),
+		gauge("node_filesystem_avail_bytes", sample(20, "device", "sda1", "fstype", "ext4", "mountpoint", "/")),
+	}
+}
+
+func row(t *testing.T, panes []Pane, pane, key string) Row {
+	t.Helper()
+	for _, p := range panes {
+		if p.Name != pane {
+			continue
+		}
+		for _, r := range p.Rows {
+			if r.Key == key {
+				return r
+			}
+		}
+	}
+	t.Fatalf("no row %s/%s", pane, key)
+	return Row{}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestDashboardUpdate(t *testing.T) {
+	d := New(3)
+	t0 := time.Unix(1000, 0)
+	d.Update(collection(100), t0)
+
+	if r := row(t, d.Panes(), "cpu", "cpu0"); !math.IsNaN(r.Values[0]) {
+		t.Fatalf("first update: busy = %v, want NaN", r.Values[0])
+	}
+	if r := row(t, d.Panes(), "memory", "memory"); !near(r.Values[0], 75) || !near(r.Values[2], 750) {
+		t.Fatalf("memory = %v", r.Values)
+	}
+	if r := row(t, d.Panes(), "filesystem", "/"); !near(r.Values[0], 70/0.9) {
+		// used 70 of used+avail 90, as df reports it
+		t.Fatalf("filesystem = %v", r.Values)
+	}
+
+	for i := 1; i <= 3; i++ {
+		d.Update(collection(100+10*float64(i)), t0.Add(time.Duration(i)*10*time.Second))
+	}
+	panes := d.Panes()
+
+	cpu := row(t, panes, "cpu", "all")
+	if !near(cpu.Values[0], 30) || !near(cpu.Values[1], 25) || !near(cpu.Values[3], 5) || !near(cpu.Values[7], 70) {
+		t.Errorf("cpu all = %v", cpu.Values)
+	}
+	if len(cpu.History) != 3 || !near(cpu.History[0], 30) {
+		t.Errorf("cpu history = %v", cpu.History)
+	}
+	psi := row(t, panes, "pressure", "io")
+	if !near(psi.Values[0], 10) || !near(psi.Values[1], 5) {
+		t.Errorf("psi = %v", psi.Values)
+	}
+	disk := row(t, panes, "disk", "sda")
+	// util 40%, 100 r/s, 50 w/s, r_await 0.2/100 s, w_await 0.5/50 s
+	want := []float64{40, 100, 50, 4096, 0, 0.002, 0.01}
+	for i, w := range want {
+		if i == 4 {
+			if !math.IsNaN(disk.Values[i]) {
+				t.Errorf("write bytes = %v, want NaN (not collected)", disk.Values[i])
+			}
+			continue
+		}
+		if !near(disk.Values[i], w) {
+			t.Errorf("disk column %s = %v, want %v", panes[3].Columns[i].Name, disk.Values[i], w)
+		}
+	}
+	if net := row(t, panes, "network", "eth0"); !near(net.Values[0], 1e6) || !near(net.Values[4], 2) {
+		t.Errorf("network = %v", net.Values)
+	}
+}
+
+func TestDashboardHistory(t *testing.T) {
+	d := New(2)
+	t0 := time.Unix(0, 0)
+	for i := 0; i < 4; i++ {
+		d.Update(collection(float64(i)), t0.Add(time.Duration(i)*time.Second))
+	}
+	if h := row(t, d.Panes(), "cpu", "cpu1").History; len(h) != 2 || !near(h[0], 30) || !near(h[1], 30) {
+		t.Fatalf("history = %v", h)
+	}
+	// Rows that disappear lose their history.
+	d.Update(nil, t0.Add(5*time.Second))
+	if len(d.history) != 0 {
+		t.Fatalf("stale history kept: %v", d.history)
+	}
+}
+
+func TestPaneSort(t *testing.T) {
+	p := Pane{Columns: []Column{{"v", UnitNumber}}, Rows: []Row{
+		{Key: "cpu10", Values: []float64{1}},
+		{Key: "cpu9", Values: []float64{math.NaN()}},
+		{Key: "cpu2", Values: []float64{5}},
+	}}
+	keys := func() []string {
+		var out []string
+		for _, r := range p.Rows {
+			out = append(out, r.Key)
+		}
+		return out
+	}
+	p.Sort(-1, false)
+	if got := keys(); !reflect.DeepEqual(got, []string{"cpu2", "cpu9", "cpu10"}) {
+		t.Errorf("by key = %v", got)
+	}
+	p.Sort(0, true)
+	if got := keys(); !reflect.DeepEqual(got, []string{"cpu2", "cpu10", "cpu9"}) {
+		t.Errorf("by value desc = %v", got)
+	}
+	p.Sort(0, false)
+	if got := keys(); !reflect.DeepEqual(got, []string{"cpu10", "cpu2", "cpu9"}) {
+		t.Errorf("by value asc = %v", got)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := ParseKeys([]byte("\t\x1b[Z\x1b[A\x1b[6~s3q\x03x"))
+	want := []Key{KeyNextPane, KeyPrevPane, KeyUp, KeyPageDown, KeyNextSort, KeyPane1 + 2, KeyQuit, KeyQuit}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseKeys = %v, want %v", got, want)
+	}
+}
+
+func TestViewHandle(t *testing.T) {
+	panes := []Pane{
+		{Name: "a", Columns: []Column{{"x", UnitNumber}, {"y", UnitNumber}}, Rows: make([]Row, 10)},
+		{Name: "b"},
+	}
+	v := NewView()
+	v.Handle(KeyDown, panes, 4)
+	v.Handle(KeyPageDown, panes, 4)
+	v.Handle(KeyPageDown, panes, 4)
+	if v.Scroll != 6 {
+		t.Errorf("scroll = %d, want 6 (clamped to rows - page)", v.Scroll)
+	}
+
+	for _, want := range []struct {
+		col  int
+		desc bool
+	}{{0, true}, {1, true}, {-1, false}} {
+		v.Handle(KeyNextSort, panes, 4)
+		if col, desc := v.Sort("a"); col != want.col || desc != want.desc {
+			t.Errorf("sort = %d/%v, want %d/%v", col, desc, want.col, want.desc)
+		}
+	}
+	v.Handle(KeyPrevSort, panes, 4)
+	if col, _ := v.Sort("a"); col != 1 {
+		t.Errorf("previous sort = %d, want 1", col)
+	}
+	v.Handle(KeyReverse, panes, 4)
+	if _, desc := v.Sort("a"); desc {
+		t.Error("reverse did not flip the order")
+	}
+
+	v.Handle(KeyPrevPane, panes, 4)
+	if v.Pane != 1 || v.Scroll != 0 {
+		t.Errorf("pane = %d scroll = %d", v.Pane, v.Scroll)
+	}
+	v.Handle(KeyPane1+5, panes, 4)
+	if v.Pane != 1 {
+		t.Errorf("jump past the last pane moved to %d", v.Pane)
+	}
+	if quit, _ := v.Handle(KeyQuit, panes, 4); !quit {
+		t.Error("KeyQuit did not quit")
+	}
+}
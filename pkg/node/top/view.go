@@ -0,0 +1,176 @@
+package top
+
+// Key is a decoded key press.
+type Key int
+
+const (
+	KeyNone Key = iota
+	KeyQuit
+	KeyNextPane
+	KeyPrevPane
+	KeyUp
+	KeyDown
+	KeyPageUp
+	KeyPageDown
+	KeyNextSort
+	KeyPrevSort
+	KeyReverse
+	KeyRefresh
+	KeyHelp
+	// KeyPane1 .. KeyPane9 jump to a pane by position.
+	KeyPane1
+	KeyPane9 = KeyPane1 + 8
+)
+
+// ParseKeys decodes the bytes read from a terminal in raw mode. Arrow keys and shift-tab arrive
+// as escape sequences; unknown input is dropped.
+func ParseKeys(b []byte) []Key {
+	var out []Key
+	for len(b) > 0 {
+		if b[0] == 0x1b && len(b) >= 3 && (b[1] == '[' || b[1] == 'O') {
+			switch b[2] {
+			case 'A':
+				out = append(out, KeyUp)
+			case 'B':
+				out = append(out, KeyDown)
+			case 'C':
+				out = append(out, KeyNextPane)
+			case 'D':
+				out = append(out, KeyPrevPane)
+			case 'Z':
+				out = append(out, KeyPrevPane)
+			case '5', '6':
+				// PgUp / PgDn: ESC [ 5 ~ and ESC [ 6 ~
+				if len(b) >= 4 && b[3] == '~' {
+					if b[2] == '5' {
+						out = append(out, KeyPageUp)
+					} else {
+						out = append(out, KeyPageDown)
+					}
+					b = b[4:]
+					continue
+				}
+			}
+			b = b[3:]
+			continue
+		}
+		switch c := b[0]; {
+		case c == 'q' || c == 'Q' || c == 0x03: // ctrl-c
+			out = append(out, KeyQuit)
+		case c == '\t' || c == 'l':
+			out = append(out, KeyNextPane)
+		case c == 'h':
+			out = append(out, KeyPrevPane)
+		case c == 'k':
+			out = append(out, KeyUp)
+		case c == 'j':
+			out = append(out, KeyDown)
+		case c == ' ':
+			out = append(out, KeyPageDown)
+		case c == 's' || c == '>':
+			out = append(out, KeyNextSort)
+		case c == 'S' || c == '<':
+			out = append(out, KeyPrevSort)
+		case c == 'r':
+			out = append(out, KeyReverse)
+		case c == 'R' || c == 0x0c: // ctrl-l
+			out = append(out, KeyRefresh)
+		case c == '?':
+			out = append(out, KeyHelp)
+		case c >= '1' && c <= '9':
+			out = append(out, KeyPane1+Key(c-'1'))
+		}
+		b = b[1:]
+	}
+	return out
+}
+
+// View is the interactive state: the focused pane, its sort order and scroll position.
+type View struct {
+	Pane int
+	// SortCol is per pane; -1 sorts by row key.
+	SortCol  map[string]int
+	SortDesc map[string]bool
+	Scroll   int
+	ShowHelp bool
+}
+
+func NewView() *View {
+	return &View{SortCol: map[string]int{}, SortDesc: map[string]bool{}}
+}
+
+// Sort returns the sort column and direction of pane; by default a pane is sorted by key.
+func (v *View) Sort(pane string) (int, bool) {
+	col, ok := v.SortCol[pane]
+	if !ok {
+		return -1, false
+	}
+	return col, v.SortDesc[pane]
+}
+
+// Handle applies k to the view; pageSize is the number of visible rows. It reports whether the
+// dashboard should quit (quit) and whether it should be redrawn (redraw); KeyRefresh asks for
+// an immediate collection and reports neither.
+func (v *View) Handle(k Key, panes []Pane, pageSize int) (quit, redraw bool) {
+	if len(panes) == 0 {
+		return k == KeyQuit, false
+	}
+	if v.Pane >= len(panes) {
+		v.Pane = 0
+	}
+	p := panes[v.Pane]
+	switch {
+	case k == KeyQuit:
+		return true, false
+	case k == KeyNextPane:
+		v.Pane = (v.Pane + 1) % len(panes)
+		v.Scroll = 0
+	case k == KeyPrevPane:
+		v.Pane = (v.Pane + len(panes) - 1) % len(panes)
+		v.Scroll = 0
+	case k >= KeyPane1 && k <= KeyPane9:
+		if i := int(k - KeyPane1); i < len(panes) {
+			v.Pane = i
+			v.Scroll = 0
+		}
+	case k == KeyUp:
+		v.Scroll--
+	case k == KeyDown:
+		v.Scroll++
+	case k == KeyPageUp:
+		v.Scroll -= pageSize
+	case k == KeyPageDown:
+		v.Scroll += pageSize
+	case k == KeyNextSort, k == KeyPrevSort:
+		// Cycle key -> column 0 -> ... -> last column -> key. Numeric columns start descending,
+		// which is what one wants during an incident (busiest first).
+		col, _ := v.Sort(p.Name)
+		n := len(p.Columns) + 1
+		step := 1
+		if k == KeyPrevSort {
+			step = n - 1
+		}
+		col = (col+1+step)%n - 1
+		v.SortCol[p.Name] = col
+		v.SortDesc[p.Name] = col >= 0
+	case k == KeyReverse:
+		col, desc := v.Sort(p.Name)
+		v.SortCol[p.Name] = col
+		v.SortDesc[p.Name] = !desc
+	case k == KeyHelp:
+		v.ShowHelp = !v.ShowHelp
+	default:
+		return false, false
+	}
+	v.clampScroll(len(panes[v.Pane].Rows), pageSize)
+	return false, true
+}
+
+func (v *View) clampScroll(rows, pageSize int) {
+	if max := rows - pageSize; v.Scroll > max {
+		v.Scroll = max
+	}
+	if v.Scroll < 0 {
+		v.Scroll = 0
+	}
+}
@@ -0,0 +1,140 @@
+package tsdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+// A block file starts with magic and is followed by records:
+//
+//	uvarint(len(payload)) payload crc32c(payload)
+//
+// The first payload byte is the record type. A block is self-contained: every series is defined
+// by a series record before its first chunk record. A torn record at the end of a file (the
+// recorder was killed mid-write) ends the block; everything before it stays readable.
+const magic = "NXTSDB\x00\x01"
+
+const (
+	recordMeta   byte = 1 // host
+	recordSeries byte = 2 // id, family, type, help, name, labels
+	recordChunk  byte = 3 // series id, encoded chunk
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type recordWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func (rw *recordWriter) write(payload []byte) error {
+	rw.buf = binary.AppendUvarint(rw.buf[:0], uint64(len(payload)))
+	rw.buf = append(rw.buf, payload...)
+	rw.buf = binary.BigEndian.AppendUint32(rw.buf, crc32.Checksum(payload, castagnoli))
+	_, err := rw.w.Write(rw.buf)
+	return err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func encodeMeta(host string) []byte {
+	return appendString([]byte{recordMeta}, host)
+}
+
+func encodeSeries(id uint64, d SeriesDef) []byte {
+	b := binary.AppendUvarint([]byte{recordSeries}, id)
+	b = appendString(b, d.Family)
+	b = appendString(b, string(d.Type))
+	b = appendString(b, d.Help)
+	b = appendString(b, d.Name)
+	b = binary.AppendUvarint(b, uint64(len(d.Labels)))
+	for _, l := range d.Labels {
+		b = appendString(b, l.Name)
+		b = appendString(b, l.Value)
+	}
+	return b
+}
+
+func encodeChunk(id uint64, chunk []byte) []byte {
+	b := binary.AppendUvarint([]byte{recordChunk}, id)
+	return append(b, chunk...)
+}
+
+var errTorn = errors.New("torn record")
+
+type recordReader struct {
+	r *bufio.Reader
+}
+
+// next returns the next record payload, io.EOF at a clean end and errTorn for a truncated or
+// corrupt record.
+func (rr *recordReader) next() ([]byte, error) {
+	n, err := binary.ReadUvarint(rr.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil || n > 64<<20 {
+		return nil, errTorn
+	}
+	b := make([]byte, n+4)
+	if _, err := io.ReadFull(rr.r, b); err != nil {
+		return nil, errTorn
+	}
+	payload := b[:n]
+	if binary.BigEndian.Uint32(b[n:]) != crc32.Checksum(payload, castagnoli) || len(payload) == 0 {
+		return nil, errTorn
+	}
+	return payload, nil
+}
+
+type decoder struct {
+	b   []byte
+	err bool
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, k := binary.Uvarint(d.b)
+	if k <= 0 {
+		d.err = true
+		return 0
+	}
+	d.b = d.b[k:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err || n > uint64(len(d.b)) {
+		d.err = true
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+func decodeSeries(payload []byte) (uint64, SeriesDef, error) {
+	d := decoder{b: payload[1:]}
+	id := d.uvarint()
+	def := SeriesDef{Family: d.string(), Type: collector.MetricType(d.string()), Help: d.string(), Name: d.string()}
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		return 0, def, fmt.Errorf("corrupt series record")
+	}
+	for i := uint64(0); i < n; i++ {
+		def.Labels = append(def.Labels, collector.Label{Name: d.string(), Value: d.string()})
+	}
+	if d.err {
+		return 0, def, fmt.Errorf("corrupt series record")
+	}
+	return id, def, nil
+}
@@ -0,0 +1,196 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// MaxChunkSamples bounds the size of a chunk: a series' chunk is written out once it holds this
+// many samples (20 minutes at the default 10s interval).
+const MaxChunkSamples = 120
+
+// Point is one sample of a series; T is in milliseconds since the epoch.
+type Point struct {
+	T int64
+	V float64
+}
+
+// chunkEncoder compresses points the way Gorilla (and Prometheus' XOR chunks) do: timestamps as
+// delta-of-delta varints, values XORed with their predecessor so that unchanged values take a
+// single bit and slowly changing ones a handful.
+type chunkEncoder struct {
+	n      int
+	ts     []byte
+	values bitWriter
+
+	t, tDelta int64
+	v         uint64
+	leading   uint8 // of the last written XOR window; 0xff before the first one
+	trailing  uint8
+}
+
+func newChunkEncoder() *chunkEncoder { return &chunkEncoder{leading: 0xff} }
+
+func (e *chunkEncoder) append(t int64, v float64) {
+	vb := math.Float64bits(v)
+	switch e.n {
+	case 0:
+		e.ts = binary.AppendVarint(e.ts, t)
+		e.values.writeBits(vb, 64)
+	case 1:
+		e.tDelta = t - e.t
+		e.ts = binary.AppendVarint(e.ts, e.tDelta)
+		e.writeValue(vb)
+	default:
+		delta := t - e.t
+		e.ts = binary.AppendVarint(e.ts, delta-e.tDelta)
+		e.tDelta = delta
+		e.writeValue(vb)
+	}
+	e.t, e.v = t, vb
+	e.n++
+}
+
+func (e *chunkEncoder) writeValue(vb uint64) {
+	xor := vb ^ e.v
+	if xor == 0 {
+		e.values.writeBit(false)
+		return
+	}
+	e.values.writeBit(true)
+	leading := uint8(bits.LeadingZeros64(xor))
+	trailing := uint8(bits.TrailingZeros64(xor))
+	if leading > 31 {
+		leading = 31 // 5 bits
+	}
+	if e.leading != 0xff && leading >= e.leading && trailing >= e.trailing {
+		// Fits the previous window.
+		e.values.writeBit(false)
+		e.values.writeBits(xor>>e.trailing, 64-int(e.leading)-int(e.trailing))
+		return
+	}
+	e.leading, e.trailing = leading, trailing
+	sig := 64 - leading - trailing
+	e.values.writeBit(true)
+	e.values.writeBits(uint64(leading), 5)
+	e.values.writeBits(uint64(sig&63), 6) // 64 significant bits are written as 0
+	e.values.writeBits(xor>>trailing, int(sig))
+}
+
+// bytes returns the encoded chunk: sample count, timestamp stream length, timestamps, values.
+func (e *chunkEncoder) bytes() []byte {
+	out := binary.AppendUvarint(nil, uint64(e.n))
+	out = binary.AppendUvarint(out, uint64(len(e.ts)))
+	out = append(out, e.ts...)
+	return append(out, e.values.buf...)
+}
+
+var errCorruptChunk = errors.New("corrupt chunk")
+
+// decodeChunk returns the points of an encoded chunk.
+func decodeChunk(b []byte) ([]Point, error) {
+	n, k := binary.Uvarint(b)
+	if k <= 0 || n > 1<<20 {
+		return nil, errCorruptChunk
+	}
+	b = b[k:]
+	tsLen, k := binary.Uvarint(b)
+	if k <= 0 || tsLen > uint64(len(b)-k) {
+		return nil, errCorruptChunk
+	}
+	ts, vals := b[k:k+int(tsLen)], b[k+int(tsLen):]
+
+	out := make([]Point, 0, n)
+	r := bitReader{buf: vals}
+	var (
+		t, tDelta         int64
+		v                 uint64
+		leading, trailing uint8
+	)
+	for i := uint64(0); i < n; i++ {
+		d, k := binary.Varint(ts)
+		if k <= 0 {
+			return nil, errCorruptChunk
+		}
+		ts = ts[k:]
+		switch i {
+		case 0:
+			t = d
+			v = r.readBits(64)
+		case 1:
+			tDelta = d
+			t += tDelta
+		default:
+			tDelta += d
+			t += tDelta
+		}
+		if i > 0 && r.readBit() {
+			if r.readBit() {
+				leading = uint8(r.readBits(5))
+				sig := uint8(r.readBits(6))
+				if sig == 0 {
+					sig = 64
+				}
+				trailing = 64 - leading - sig
+			}
+			sig := 64 - int(leading) - int(trailing)
+			v ^= r.readBits(sig) << trailing
+		}
+		if r.err {
+			return nil, errCorruptChunk
+		}
+		out = append(out, Point{T: t, V: math.Float64frombits(v)})
+	}
+	return out, nil
+}
+
+type bitWriter struct {
+	buf   []byte
+	nbits int // bits used in the last byte; 0 means it is full (or buf is empty)
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.nbits == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if bit {
+		w.buf[len(w.buf)-1] |= 1 << (7 - w.nbits)
+	}
+	w.nbits = (w.nbits + 1) % 8
+}
+
+// writeBits writes the low n bits of v, most significant first.
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit(v>>uint(i)&1 == 1)
+	}
+}
+
+type bitReader struct {
+	buf []byte
+	pos int // in bits
+	err bool
+}
+
+func (r *bitReader) readBit() bool {
+	if r.pos >= len(r.buf)*8 {
+		r.err = true
+		return false
+	}
+	bit := r.buf[r.pos/8]>>(7-r.pos%8)&1 == 1
+	r.pos++
+	return bit
+}
+
+func (r *bitReader) readBits(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.readBit() {
+			v |= 1
+		}
+	}
+	return v
+}
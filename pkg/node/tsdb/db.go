@@ -0,0 +1,389 @@
+// Package tsdb is a small on-disk time series store for `nexa node record` and `nexa node replay`.
+//
+// Samples are appended to block files covering a fixed wall-clock window (one hour by default).
+// Within a block each series is written as chunks of at most MaxChunkSamples points, compressed
+// with delta-of-delta timestamps and XORed values. Blocks older than the retention are deleted
+// whole, so the directory behaves like a ring buffer.
+package tsdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+const (
+	DefaultBlockDuration = time.Hour
+	// DefaultFlushAge bounds how much data a crash can lose: open chunks older than this are
+	// written out even if they are not full.
+	DefaultFlushAge = 5 * time.Minute
+	blockExt        = ".nxb"
+)
+
+type Options struct {
+	Dir string
+	// Retention is how long blocks are kept; 0 keeps them forever.
+	Retention     time.Duration
+	BlockDuration time.Duration
+	FlushAge      time.Duration
+	// Host is recorded in every block for replay headers.
+	Host string
+}
+
+type openSeries struct {
+	id      uint64
+	def     SeriesDef
+	defined bool // series record written to the current block
+	enc     *chunkEncoder
+}
+
+// Writer appends collections to the block files in Options.Dir.
+type Writer struct {
+	opt Options
+
+	f          *os.File
+	rw         recordWriter
+	blockEnd   int64
+	series     map[string]*openSeries
+	nextID     uint64
+	chunkStart int64 // oldest unwritten sample
+}
+
+// Create prepares dir for recording and removes blocks past the retention.
+func Create(opt Options) (*Writer, error) {
+	if opt.BlockDuration <= 0 {
+		opt.BlockDuration = DefaultBlockDuration
+	}
+	if opt.FlushAge <= 0 {
+		opt.FlushAge = DefaultFlushAge
+	}
+	if err := os.MkdirAll(opt.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &Writer{opt: opt}
+	if err := w.applyRetention(time.Now()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Append records one collection taken at at. Collections must be appended in time order.
+func (w *Writer) Append(at time.Time, families []collector.MetricFamily) error {
+	t := at.UnixMilli()
+	if w.f == nil || t >= w.blockEnd {
+		if err := w.rotate(at); err != nil {
+			return err
+		}
+	}
+	if w.chunkStart == 0 {
+		w.chunkStart = t
+	}
+	for _, fs := range flatten(families) {
+		key := fs.def.key()
+		s, ok := w.series[key]
+		if !ok {
+			s = &openSeries{id: w.nextID, def: fs.def, enc: newChunkEncoder()}
+			w.nextID++
+			w.series[key] = s
+		}
+		if s.enc.n > 0 && t <= s.enc.t {
+			continue // out of order or duplicate timestamp
+		}
+		s.enc.append(t, fs.value)
+		if s.enc.n >= MaxChunkSamples {
+			if err := w.writeChunk(s); err != nil {
+				return err
+			}
+		}
+	}
+	if time.Duration(t-w.chunkStart)*time.Millisecond >= w.opt.FlushAge {
+		if err := w.flushChunks(); err != nil {
+			return err
+		}
+		w.chunkStart = 0
+	}
+	return w.rw.w.Flush()
+}
+
+func (w *Writer) writeChunk(s *openSeries) error {
+	if s.enc.n == 0 {
+		return nil
+	}
+	if !s.defined {
+		if err := w.rw.write(encodeSeries(s.id, s.def)); err != nil {
+			return err
+		}
+		s.defined = true
+	}
+	if err := w.rw.write(encodeChunk(s.id, s.enc.bytes())); err != nil {
+		return err
+	}
+	s.enc = newChunkEncoder()
+	return nil
+}
+
+func (w *Writer) flushChunks() error {
+	keys := make([]string, 0, len(w.series))
+	for k := range w.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := w.writeChunk(w.series[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotate closes the current block and starts one for the window containing at.
+func (w *Writer) rotate(at time.Time) error {
+	if err := w.closeBlock(); err != nil {
+		return err
+	}
+	start := at.UnixMilli()
+	end := at.Truncate(w.opt.BlockDuration).Add(w.opt.BlockDuration).UnixMilli()
+	path := filepath.Join(w.opt.Dir, fmt.Sprintf("%d-%d%s", start, end, blockExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w.f, w.blockEnd = f, end
+	w.rw = recordWriter{w: bufio.NewWriter(f)}
+	w.series, w.nextID, w.chunkStart = map[string]*openSeries{}, 0, 0
+	if _, err := w.rw.w.WriteString(magic); err != nil {
+		return err
+	}
+	if err := w.rw.write(encodeMeta(w.opt.Host)); err != nil {
+		return err
+	}
+	return w.applyRetention(at)
+}
+
+func (w *Writer) closeBlock() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.flushChunks()
+	if ferr := w.rw.w.Flush(); err == nil {
+		err = ferr
+	}
+	if serr := w.f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
+
+// Close writes out the open chunks and closes the current block.
+func (w *Writer) Close() error { return w.closeBlock() }
+
+func (w *Writer) applyRetention(now time.Time) error {
+	if w.opt.Retention <= 0 {
+		return nil
+	}
+	blocks, err := Blocks(w.opt.Dir)
+	if err != nil {
+		return err
+	}
+	cutoff := now.Add(-w.opt.Retention)
+	for _, b := range blocks {
+		if b.End.Before(cutoff) {
+			if err := os.Remove(b.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Block is a block file; Start is its first sample and End the end of its window.
+type Block struct {
+	Path       string
+	Start, End time.Time
+}
+
+// Blocks lists the block files in dir, oldest first.
+func Blocks(dir string) ([]Block, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Block
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), blockExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		a, b, ok := strings.Cut(name, "-")
+		start, err1 := strconv.ParseInt(a, 10, 64)
+		end, err2 := strconv.ParseInt(b, 10, 64)
+		if !ok || err1 != nil || err2 != nil {
+			continue
+		}
+		out = append(out, Block{Path: filepath.Join(dir, e.Name()), Start: time.UnixMilli(start), End: time.UnixMilli(end)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+// Series is a stored series with its points in time order.
+type Series struct {
+	SeriesDef
+	Points []Point
+}
+
+// Recording is what Read returns: the series of a time range and the hosts that recorded them.
+type Recording struct {
+	Series []*Series
+	Hosts  []string
+}
+
+// Read loads the points between from and to (inclusive) from the blocks in dir.
+func Read(dir string, from, to time.Time) (*Recording, error) {
+	blocks, err := Blocks(dir)
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := from.UnixMilli(), to.UnixMilli()
+	byKey := map[string]*Series{}
+	hosts := map[string]bool{}
+	for _, b := range blocks {
+		if b.Start.After(to) || b.End.Before(from) {
+			continue
+		}
+		host, err := readBlock(b.Path, lo, hi, byKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Path, err)
+		}
+		if host != "" {
+			hosts[host] = true
+		}
+	}
+
+	rec := &Recording{}
+	for _, s := range byKey {
+		if len(s.Points) == 0 {
+			continue
+		}
+		sort.Slice(s.Points, func(i, j int) bool { return s.Points[i].T < s.Points[j].T })
+		rec.Series = append(rec.Series, s)
+	}
+	sort.Slice(rec.Series, func(i, j int) bool { return rec.Series[i].key() < rec.Series[j].key() })
+	for h := range hosts {
+		rec.Hosts = append(rec.Hosts, h)
+	}
+	sort.Strings(rec.Hosts)
+	return rec, nil
+}
+
+func readBlock(path string, lo, hi int64, byKey map[string]*Series) (host string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, head); err != nil || string(head) != magic {
+		return "", fmt.Errorf("not a nexa tsdb block")
+	}
+	rr := recordReader{r: br}
+	ids := map[uint64]*Series{}
+	for {
+		payload, err := rr.next()
+		if err != nil {
+			// io.EOF, or a torn tail after a crash: keep what was read.
+			return host, nil
+		}
+		switch payload[0] {
+		case recordMeta:
+			d := decoder{b: payload[1:]}
+			host = d.string()
+		case recordSeries:
+			id, def, err := decodeSeries(payload)
+			if err != nil {
+				return host, err
+			}
+			s, ok := byKey[def.key()]
+			if !ok {
+				s = &Series{SeriesDef: def}
+				byKey[def.key()] = s
+			}
+			ids[id] = s
+		case recordChunk:
+			d := decoder{b: payload[1:]}
+			id := d.uvarint()
+			s, ok := ids[id]
+			if d.err || !ok {
+				return host, fmt.Errorf("chunk of unknown series %d", id)
+			}
+			points, err := decodeChunk(d.b)
+			if err != nil {
+				return host, err
+			}
+			for _, p := range points {
+				if p.T >= lo && p.T <= hi {
+					s.Points = append(s.Points, p)
+				}
+			}
+		}
+	}
+}
+
+// Times returns the distinct collection times in rec, oldest first.
+func (rec *Recording) Times() []time.Time {
+	set := map[int64]bool{}
+	for _, s := range rec.Series {
+		for _, p := range s.Points {
+			set[p.T] = true
+		}
+	}
+	ts := make([]int64, 0, len(set))
+	for t := range set {
+		ts = append(ts, t)
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+	out := make([]time.Time, len(ts))
+	for i, t := range ts {
+		out[i] = time.UnixMilli(t)
+	}
+	return out
+}
+
+// At returns the families of the last collection at or before t, and its time. ok is false when
+// there is no collection in rec at or before t.
+func (rec *Recording) At(t time.Time) (families []collector.MetricFamily, at time.Time, ok bool) {
+	limit := t.UnixMilli()
+	var best int64
+	found := false
+	for _, s := range rec.Series {
+		i := sort.Search(len(s.Points), func(i int) bool { return s.Points[i].T > limit })
+		if i > 0 && (!found || s.Points[i-1].T > best) {
+			best, found = s.Points[i-1].T, true
+		}
+	}
+	if !found {
+		return nil, time.Time{}, false
+	}
+	var flat []flatSample
+	for _, s := range rec.Series {
+		i := sort.Search(len(s.Points), func(i int) bool { return s.Points[i].T >= best })
+		if i < len(s.Points) && s.Points[i].T == best {
+			flat = append(flat, flatSample{s.SeriesDef, s.Points[i].V})
+		}
+	}
+	return unflatten(flat), time.UnixMilli(best), true
+}
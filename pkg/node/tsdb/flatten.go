@@ -0,0 +1,157 @@
+package tsdb
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+// SeriesDef describes a stored series. Histograms and summaries are stored as their exposition
+// series (_bucket with le, the quantiles, _sum and _count) and reassembled on read.
+type SeriesDef struct {
+	Family string
+	Type   collector.MetricType
+	Help   string
+	// Name is the exposed series name, e.g. rpc_seconds_bucket for a histogram bucket.
+	Name   string
+	Labels []collector.Label
+}
+
+func (d SeriesDef) key() string { return d.Name + "\xfd" + collector.SeriesKey(d.Labels) }
+
+type flatSample struct {
+	def   SeriesDef
+	value float64
+}
+
+// flatten expands families into one value per exposed series.
+func flatten(families []collector.MetricFamily) []flatSample {
+	var out []flatSample
+	for _, f := range families {
+		add := func(name string, labels []collector.Label, v float64) {
+			out = append(out, flatSample{SeriesDef{Family: f.Name, Type: f.Type, Help: f.Help, Name: name, Labels: labels}, v})
+		}
+		for _, s := range f.Samples {
+			add(f.Name, s.Labels, s.Value)
+		}
+		for _, h := range f.Histograms {
+			for _, b := range h.Buckets {
+				add(f.Name+"_bucket", withLabel(h.Labels, "le", formatBound(b.UpperBound)), float64(b.Count))
+			}
+			add(f.Name+"_sum", h.Labels, h.Sum)
+			add(f.Name+"_count", h.Labels, float64(h.Count))
+		}
+		for _, s := range f.Summaries {
+			for _, q := range s.Quantiles {
+				add(f.Name, withLabel(s.Labels, "quantile", formatBound(q.Quantile)), q.Value)
+			}
+			add(f.Name+"_sum", s.Labels, s.Sum)
+			add(f.Name+"_count", s.Labels, float64(s.Count))
+		}
+	}
+	return out
+}
+
+// unflatten is the inverse of flatten.
+func unflatten(samples []flatSample) []collector.MetricFamily {
+	type partial struct {
+		labels []collector.Label
+		h      collector.Histogram
+		s      collector.Summary
+	}
+	type family struct {
+		mf    collector.MetricFamily
+		parts map[string]*partial
+		order []string
+	}
+	families := map[string]*family{}
+	var names []string
+	for _, fs := range samples {
+		d := fs.def
+		f, ok := families[d.Family]
+		if !ok {
+			f = &family{mf: collector.MetricFamily{Name: d.Family, Type: d.Type, Help: d.Help}, parts: map[string]*partial{}}
+			families[d.Family] = f
+			names = append(names, d.Family)
+		}
+		if d.Type != collector.MetricTypeHistogram && d.Type != collector.MetricTypeSummary {
+			f.mf.Samples = append(f.mf.Samples, collector.Sample{Labels: d.Labels, Value: fs.value})
+			continue
+		}
+		base, special := withoutLabel(d.Labels, "le", "quantile")
+		k := collector.SeriesKey(base)
+		p, ok := f.parts[k]
+		if !ok {
+			p = &partial{labels: base}
+			f.parts[k] = p
+			f.order = append(f.order, k)
+		}
+		switch d.Name {
+		case d.Family + "_sum":
+			p.h.Sum, p.s.Sum = fs.value, fs.value
+		case d.Family + "_count":
+			p.h.Count, p.s.Count = uint64(fs.value), uint64(fs.value)
+		case d.Family + "_bucket":
+			ub, _ := strconv.ParseFloat(special, 64)
+			p.h.Buckets = append(p.h.Buckets, collector.Bucket{UpperBound: ub, Count: uint64(fs.value)})
+		case d.Family:
+			q, _ := strconv.ParseFloat(special, 64)
+			p.s.Quantiles = append(p.s.Quantiles, collector.Quantile{Quantile: q, Value: fs.value})
+		}
+	}
+
+	sort.Strings(names)
+	out := make([]collector.MetricFamily, 0, len(names))
+	for _, name := range names {
+		f := families[name]
+		for _, k := range f.order {
+			p := f.parts[k]
+			if f.mf.Type == collector.MetricTypeHistogram {
+				p.h.Labels = p.labels
+				sort.Slice(p.h.Buckets, func(i, j int) bool { return p.h.Buckets[i].UpperBound < p.h.Buckets[j].UpperBound })
+				f.mf.Histograms = append(f.mf.Histograms, p.h)
+			} else {
+				p.s.Labels = p.labels
+				sort.Slice(p.s.Quantiles, func(i, j int) bool { return p.s.Quantiles[i].Quantile < p.s.Quantiles[j].Quantile })
+				f.mf.Summaries = append(f.mf.Summaries, p.s)
+			}
+		}
+		out = append(out, f.mf)
+	}
+	return out
+}
+
+func formatBound(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// withLabel returns labels plus name=value, kept sorted by name.
+func withLabel(labels []collector.Label, name, value string) []collector.Label {
+	out := make([]collector.Label, 0, len(labels)+1)
+	out = append(out, labels...)
+	out = append(out, collector.Label{Name: name, Value: value})
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// withoutLabel drops the labels named in names and returns the value of the last one dropped.
+func withoutLabel(labels []collector.Label, names ...string) ([]collector.Label, string) {
+	var out []collector.Label
+	var dropped string
+outer:
+	for _, l := range labels {
+		for _, n := range names {
+			if l.Name == n {
+				dropped = l.Value
+				continue outer
+			}
+		}
+		out = append(out, l)
+	}
+	return out, dropped
+}
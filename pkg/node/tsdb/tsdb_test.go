@@ -0,0 +1,167 @@
+package tsdb
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nexa/pkg/node/collector"
+)
+
+func TestChunkRoundTrip(t *testing.T) {
+	var want []Point
+	e := newChunkEncoder()
+	values := []float64{0, 0, 1, 1.5, -3, math.Inf(1), math.Inf(-1), 1e300, 5e-324, 42, 42, 42}
+	ts := int64(1_700_000_000_000)
+	for i := 0; i < MaxChunkSamples; i++ {
+		ts += 10_000 + int64(i%3) - 1 // jittered interval
+		v := values[i%len(values)] + float64(i/len(values))
+		want = append(want, Point{ts, v})
+		e.append(ts, v)
+	}
+	e.append(ts+1, math.NaN())
+
+	got, err := decodeChunk(e.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want)+1 || !math.IsNaN(got[len(got)-1].V) {
+		t.Fatalf("decoded %d points, last %v", len(got), got[len(got)-1])
+	}
+	if !reflect.DeepEqual(got[:len(want)], want) {
+		t.Fatalf("round trip mismatch:\n got %v\nwant %v", got[:5], want[:5])
+	}
+	if size := len(e.bytes()); size > len(want)*8 {
+		t.Errorf("chunk of %d points is %d bytes; compression is not working", len(want), size)
+	}
+	if _, err := decodeChunk(e.bytes()[:10]); err == nil {
+		t.Error("truncated chunk decoded without error")
+	}
+}
+
+func TestFlattenRoundTrip(t *testing.T) {
+	in := []collector.MetricFamily{
+		{Name: "a_total", Type: collector.MetricTypeCounter, Help: "A.", Samples: []collector.Sample{
+			{Labels: []collector.Label{{Name: "x", Value: "1"}}, Value: 3},
+		}},
+		{Name: "rpc_seconds", Type: collector.MetricTypeHistogram, Help: "RPC latency.", Histograms: []collector.Histogram{{
+			Labels:  []collector.Label{{Name: "method", Value: "get"}},
+			Buckets: []collector.Bucket{{UpperBound: 0.1, Count: 2}, {UpperBound: 1, Count: 5}, {UpperBound: math.Inf(1), Count: 6}},
+			Count:   6, Sum: 2.5,
+		}}},
+		{Name: "gc_seconds", Type: collector.MetricTypeSummary, Summaries: []collector.Summary{{
+			Quantiles: []collector.Quantile{{Quantile: 0.5, Value: 0.01}, {Quantile: 0.99, Value: 0.2}},
+			Count:     10, Sum: 0.4,
+		}}},
+	}
+	got := unflatten(flatten(in))
+	want := []collector.MetricFamily{in[0], in[2], in[1]} // sorted by name
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unflatten(flatten()) =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func gauge(v float64) []collector.MetricFamily {
+	return []collector.MetricFamily{{Name: "load", Type: collector.MetricTypeGauge, Help: "Load.", Samples: []collector.Sample{
+		{Labels: []collector.Label{{Name: "cpu", Value: "0"}}, Value: v},
+	}}}
+}
+
+func TestWriterReader(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(Options{Dir: dir, BlockDuration: time.Hour, FlushAge: time.Minute, Host: "web-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 3, 1, 0, 30, 0, 0, time.UTC)
+	const n = 400 // crosses into a second block and several chunks
+	for i := 0; i < n; i++ {
+		if err := w.Append(t0.Add(time.Duration(i)*10*time.Second), gauge(float64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := Blocks(dir)
+	if err != nil || len(blocks) != 2 {
+		t.Fatalf("blocks = %v, %v; want 2", blocks, err)
+	}
+
+	rec, err := Read(dir, t0, t0.Add(time.Hour*2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rec.Hosts, []string{"web-1"}) || len(rec.Series) != 1 || len(rec.Series[0].Points) != n {
+		t.Fatalf("read hosts %v, %d series", rec.Hosts, len(rec.Series))
+	}
+	if times := rec.Times(); len(times) != n || !times[0].Equal(t0) {
+		t.Fatalf("times: %d, first %v", len(times), times[0])
+	}
+
+	fams, at, ok := rec.At(t0.Add(35*time.Minute + 5*time.Second))
+	if !ok || !at.Equal(t0.Add(35*time.Minute)) {
+		t.Fatalf("At = %v, %v", at, ok)
+	}
+	if !reflect.DeepEqual(fams, gauge(210)) {
+		t.Fatalf("At families = %+v", fams)
+	}
+	if _, _, ok := rec.At(t0.Add(-time.Second)); ok {
+		t.Error("At before the first collection returned data")
+	}
+
+	sub, err := Read(dir, t0.Add(time.Minute), t0.Add(2*time.Minute))
+	if err != nil || len(sub.Series[0].Points) != 7 {
+		t.Fatalf("range read: %v, %+v", err, sub)
+	}
+}
+
+func TestReadTornTail(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(Options{Dir: dir, FlushAge: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		if err := w.Append(t0.Add(time.Duration(i)*time.Second), gauge(float64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocks, _ := Blocks(dir)
+	path := blocks[0].Path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash part-way through the last record.
+	if err := os.WriteFile(path, data[:len(data)-3], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := Read(dir, t0, t0.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Series) != 1 || len(rec.Series[0].Points) == 0 || len(rec.Series[0].Points) >= 10 {
+		t.Fatalf("torn block: %+v", rec.Series)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "1000-2000"+blockExt)
+	if err := os.WriteFile(old, []byte(magic), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := Create(Options{Dir: dir, Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("block past retention not removed: %v", err)
+	}
+}
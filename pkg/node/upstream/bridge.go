@@ -1,44 +1,219 @@
+// Package upstream runs the node_exporter collectors in-process.
 package upstream
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"runtime"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
-	nexp "github.com/prometheus/node_exporter/collector"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+	nexp "github.com/prometheus/node_exporter/collector"
+)
+
+// Options are the nexa settings forwarded to node_exporter's kingpin flags. Empty fields keep the
+// node_exporter defaults.
+type Options struct {
+	ProcPath     string
+	SysPath      string
+	RootfsPath   string
+	UdevDataPath string
+	// TextfileDirectories maps to the repeatable --collector.textfile.directory.
+	TextfileDirectories []string
+	// Collectors are the node_exporter collectors to enable; every other collector is disabled.
+	// Empty keeps node_exporter's enabled-by-default set.
+	Collectors []string
+}
+
+// Args renders the options as node_exporter command line flags. Collectors unknown to
+// node_exporter on this platform are skipped.
+func (o Options) Args() []string {
+	var args []string
+	flag := func(name, value string) {
+		if value != "" {
+			args = append(args, fmt.Sprintf("--%s=%s", name, value))
+		}
+	}
+	flag("path.procfs", o.ProcPath)
+	flag("path.sysfs", o.SysPath)
+	flag("path.rootfs", o.RootfsPath)
+	flag("path.udev.data", o.UdevDataPath)
+	for _, dir := range o.TextfileDirectories {
+		flag("collector.textfile.directory", dir)
+	}
+	for _, name := range o.Collectors {
+		if Known(name) {
+			args = append(args, "--collector."+name)
+		}
+	}
+	return args
+}
+
+// Known reports whether node_exporter has a collector of that name on this platform.
+func Known(name string) bool {
+	return kingpin.CommandLine.GetFlag("collector."+name) != nil
+}
+
+var (
+	mu         sync.Mutex
+	configured *Options
+	shared     *Bridge
+	sharedErr  error
 )
 
-var initOnce sync.Once
+// Configure sets the options of the process-wide bridge. node_exporter keeps its flags in
+// package globals, so this can only happen once and must precede the first Default call.
+func Configure(opt Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if shared != nil || sharedErr != nil {
+		return fmt.Errorf("upstream bridge already initialized")
+	}
+	configured = &opt
+	return nil
+}
 
-func ensureUpstreamDefaultsParsed() {
-	initOnce.Do(func() {
-		// node_exporter collectors register kingpin flags with defaults (enabled/disabled, procfs paths, etc).
-		// Without parsing, bool flags default to false and NewNodeCollector may think collectors are disabled.
-		_, _ = kingpin.CommandLine.Parse([]string{})
-	})
+// Default returns the process-wide bridge, building it on first use from the options passed to
+// Configure (or the node_exporter defaults).
+func Default() (*Bridge, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if shared == nil && sharedErr == nil {
+		var opt Options
+		if configured != nil {
+			opt = *configured
+		}
+		shared, sharedErr = newBridge(opt)
+	}
+	return shared, sharedErr
 }
 
-// CollectDTO uses upstream node_exporter collectors to gather Prometheus dto metric families.
-// filters corresponds to node_exporter collector names; empty means "all enabled" upstream.
-func CollectDTO(ctx context.Context, filters []string) ([]*dto.MetricFamily, error) {
-	_ = ctx
+// Bridge holds node_exporter collectors that are instantiated once and scraped repeatedly.
+// Each collector has its own registry so results can be attributed to it.
+type Bridge struct {
+	collectors map[string]*bridgeCollector
+	names      []string
+}
 
-	ensureUpstreamDefaultsParsed()
+type bridgeCollector struct {
+	// mu serializes scrapes of one collector; err is the Update error of the scrape in progress.
+	mu  sync.Mutex
+	c   nexp.Collector
+	reg *prometheus.Registry
+	err error
+}
+
+// Describe sends nothing: the collector is unchecked, like most node_exporter collectors.
+func (bc *bridgeCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (bc *bridgeCollector) Collect(ch chan<- prometheus.Metric) {
+	bc.err = bc.c.Update(ch)
+}
+
+func newBridge(opt Options) (*Bridge, error) {
+	if _, err := kingpin.CommandLine.Parse(opt.Args()); err != nil {
+		return nil, fmt.Errorf("node_exporter flags: %w", err)
+	}
+	if len(opt.Collectors) > 0 {
+		nexp.DisableDefaultCollectors()
+	}
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelWarn}))
-	nc, err := nexp.NewNodeCollector(logger, filters...)
+	nc, err := nexp.NewNodeCollector(logger)
 	if err != nil {
 		return nil, err
 	}
+	b := &Bridge{collectors: make(map[string]*bridgeCollector, len(nc.Collectors))}
+	for name, c := range nc.Collectors {
+		bc := &bridgeCollector{c: c, reg: prometheus.NewRegistry()}
+		if err := bc.reg.Register(bc); err != nil {
+			return nil, fmt.Errorf("register %s: %w", name, err)
+		}
+		b.collectors[name] = bc
+		b.names = append(b.names, name)
+	}
+	sort.Strings(b.names)
+	return b, nil
+}
 
-	reg := prometheus.NewRegistry()
-	if err := reg.Register(nc); err != nil {
-		return nil, err
+// Names lists the enabled node_exporter collectors.
+func (b *Bridge) Names() []string { return append([]string(nil), b.names...) }
+
+// Result is the output of one collector in a scrape.
+type Result struct {
+	Families []*dto.MetricFamily
+	Duration time.Duration
+	Err      error
+}
+
+// GatherCollector scrapes a single collector. node_exporter collectors cannot be interrupted, so
+// when ctx ends first the scrape finishes in the background and ctx.Err() is returned.
+func (b *Bridge) GatherCollector(ctx context.Context, name string) Result {
+	bc, ok := b.collectors[name]
+	if !ok {
+		if !Known(name) {
+			return Result{Err: fmt.Errorf("collector %s is not available in node_exporter on %s", name, runtime.GOOS)}
+		}
+		return Result{Err: fmt.Errorf("collector %s is not enabled in node_exporter", name)}
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{Err: err}
 	}
+	done := make(chan Result, 1)
+	begin := time.Now()
+	go func() {
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+		families, err := bc.reg.Gather()
+		if bc.err != nil && !nexp.IsNoDataError(bc.err) {
+			err = bc.err
+		}
+		done <- Result{Families: families, Duration: time.Since(begin), Err: err}
+	}()
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		return Result{Duration: time.Since(begin), Err: ctx.Err()}
+	}
+}
 
-	return reg.Gather()
+// GatherByCollector scrapes the named collectors (all enabled ones when names is empty) in one
+// concurrent pass, like a node_exporter scrape, and splits the results back by collector. A
+// non-nil timeout gives each collector its own deadline within ctx.
+func (b *Bridge) GatherByCollector(ctx context.Context, timeout func(name string) time.Duration, names ...string) map[string]Result {
+	if len(names) == 0 {
+		names = b.names
+	}
+	out := make(map[string]Result, len(names))
+	var (
+		wg    sync.WaitGroup
+		outMu sync.Mutex
+	)
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			cctx := ctx
+			if timeout != nil {
+				if d := timeout(name); d > 0 {
+					var cancel context.CancelFunc
+					cctx, cancel = context.WithTimeout(ctx, d)
+					defer cancel()
+				}
+			}
+			r := b.GatherCollector(cctx, name)
+			outMu.Lock()
+			out[name] = r
+			outMu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return out
 }
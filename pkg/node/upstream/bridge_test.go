@@ -0,0 +1,95 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestOptionsArgs(t *testing.T) {
+	opt := Options{
+		ProcPath:            "/host/proc",
+		RootfsPath:          "/host",
+		TextfileDirectories: []string{"/var/lib/node", "/etc/node/*.d"},
+		Collectors:          []string{"loadavg", "no_such_collector"},
+	}
+	want := []string{
+		"--path.procfs=/host/proc",
+		"--path.rootfs=/host",
+		"--collector.textfile.directory=/var/lib/node",
+		"--collector.textfile.directory=/etc/node/*.d",
+		"--collector.loadavg",
+	}
+	if got := opt.Args(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Args() = %q, want %q", got, want)
+	}
+}
+
+func TestBridge_GatherByCollector(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("loadavg/uname are read from procfs")
+	}
+	b, err := newBridge(Options{Collectors: []string{"loadavg", "uname"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Names(); !reflect.DeepEqual(got, []string{"loadavg", "uname"}) {
+		t.Fatalf("Names() = %v: only the requested collectors should be enabled", got)
+	}
+
+	res := b.GatherByCollector(context.Background(), nil)
+	for name, prefix := range map[string]string{"loadavg": "node_load", "uname": "node_uname_"} {
+		r := res[name]
+		if r.Err != nil || len(r.Families) == 0 {
+			t.Fatalf("%s: %+v", name, r)
+		}
+		for _, mf := range r.Families {
+			if n := mf.GetName(); len(n) < len(prefix) || n[:len(prefix)] != prefix {
+				t.Errorf("%s returned %s", name, n)
+			}
+		}
+	}
+	if r := b.GatherCollector(context.Background(), "cpu"); r.Err == nil {
+		t.Fatal("expected error for a collector that is not enabled")
+	}
+
+	// A per-collector deadline only stops waiting for that collector.
+	res = b.GatherByCollector(context.Background(), func(name string) time.Duration {
+		if name == "uname" {
+			return time.Nanosecond
+		}
+		return 0
+	}, "loadavg", "uname")
+	if res["loadavg"].Err != nil || !errors.Is(res["uname"].Err, context.DeadlineExceeded) {
+		t.Fatalf("per-collector timeout: loadavg=%v uname=%v", res["loadavg"].Err, res["uname"].Err)
+	}
+}
+
+func TestBridge_Paths(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("loadavg is read from procfs")
+	}
+	b, err := newBridge(Options{ProcPath: "../collector/testdata/fixtures/proc", Collectors: []string{"loadavg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Restore the defaults for the other tests; node_exporter keeps them in package globals.
+	t.Cleanup(func() { _, _ = newBridge(Options{}) })
+
+	r := b.GatherCollector(context.Background(), "loadavg")
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	for _, mf := range r.Families {
+		if mf.GetName() == "node_load1" {
+			if v := mf.GetMetric()[0].GetGauge().GetValue(); v != 0.21 {
+				t.Fatalf("node_load1 = %v, want 0.21 from the fixture", v)
+			}
+			return
+		}
+	}
+	t.Fatal("node_load1 missing")
+}
@@ -0,0 +1,77 @@
+// Package timeutil parses the absolute and relative times given on the nexa command line.
+package timeutil
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTime parses a time given on the command line, relative to now:
+//
+//	now, -30m              now, or a duration before it
+//	15:04, 15:04:05        today at that time, or yesterday if that is still in the future
+//	2006-01-02 15:04[:05]  local time
+//	RFC 3339
+//	1700000000[.5]         unix seconds, as Prometheus accepts them
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "now" {
+		return now, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 {
+		return time.UnixMilli(int64(math.Round(f * 1000))), nil
+	}
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: %w", s, err)
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		clock, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		y, m, d := now.Date()
+		t := time.Date(y, m, d, clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location())
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want HH:MM[:SS], YYYY-MM-DD HH:MM[:SS], RFC 3339, unix seconds, -DURATION or now", s)
+}
+
+// ParseRange parses "FROM..TO", each side as in ParseTime. When both are clock times and TO
+// would come before FROM, FROM is taken from the previous day (e.g. 23:50..00:10).
+func ParseRange(s string, now time.Time) (from, to time.Time, err error) {
+	a, b, ok := strings.Cut(s, "..")
+	if !ok {
+		return from, to, fmt.Errorf("invalid range %q: want FROM..TO", s)
+	}
+	if from, err = ParseTime(a, now); err != nil {
+		return from, to, err
+	}
+	if to, err = ParseTime(b, now); err != nil {
+		return from, to, err
+	}
+	if to.Before(from) && from.Sub(to) < 24*time.Hour && !strings.Contains(a, "-") {
+		from = from.AddDate(0, 0, -1)
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("invalid range %q: end is before start", s)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,35 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Time{
+		"now":                  now,
+		"-30m":                 now.Add(-30 * time.Minute),
+		"01:15":                time.Date(2026, 3, 1, 1, 15, 0, 0, time.UTC),
+		"03:00":                time.Date(2026, 2, 28, 3, 0, 0, 0, time.UTC),
+		"2026-02-27 10:00:30":  time.Date(2026, 2, 27, 10, 0, 30, 0, time.UTC),
+		"2026-02-27T10:00:00Z": time.Date(2026, 2, 27, 10, 0, 0, 0, time.UTC),
+		"1772200800.5":         time.Date(2026, 2, 27, 14, 0, 0, 5e8, time.UTC),
+	} {
+		got, err := ParseTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseTime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Error("ParseTime accepted garbage")
+	}
+
+	from, to, err := ParseRange("23:50..00:10", time.Date(2026, 3, 1, 0, 30, 0, 0, time.UTC))
+	if err != nil || !from.Equal(time.Date(2026, 2, 28, 23, 50, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 3, 1, 0, 10, 0, 0, time.UTC)) {
+		t.Errorf("ParseRange across midnight = %v..%v, %v", from, to, err)
+	}
+	if _, _, err := ParseRange("-10m..-20m", now); err == nil {
+		t.Error("ParseRange accepted a reversed range")
+	}
+}